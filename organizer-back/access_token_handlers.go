@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"organizer-back/models"
+	"organizer-back/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerAccessTokenRoutes wires the caller's personal access tokens. Since these grant CalDAV
+// access, the session token must carry a valid signature.
+func registerAccessTokenRoutes(api *gin.RouterGroup, accessTokensService *services.AccessTokensService, authService *services.AuthService) {
+	api.GET("/me/tokens", func(c *gin.Context) {
+		userID := verifiedUserIDFromAuthHeader(authService, c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		tokens, err := accessTokensService.List(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, tokens)
+	})
+
+	// The token itself is only returned by this request
+	api.POST("/me/tokens", func(c *gin.Context) {
+		userID := verifiedUserIDFromAuthHeader(authService, c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		var req models.PersonalAccessTokenRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		token, err := accessTokensService.Create(userID, &req)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, token)
+	})
+
+	api.DELETE("/me/tokens/:id", func(c *gin.Context) {
+		userID := verifiedUserIDFromAuthHeader(authService, c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		if err := accessTokensService.Delete(userID, id); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+	})
+}
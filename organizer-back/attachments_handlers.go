@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"organizer-back/models"
+	"organizer-back/services"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerAttachmentRoutes wires note attachment upload, listing, download and deletion
+func registerAttachmentRoutes(api *gin.RouterGroup, attachmentsService *services.AttachmentsService) {
+	api.POST("/notes/:id/attachments", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		noteID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		// Leave some headroom for the multipart envelope around the file itself
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, attachmentsService.MaxBytes()+1<<20)
+		fh, err := c.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		f, err := fh.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer f.Close()
+
+		attachment, err := attachmentsService.Upload(userID, noteID, fh.Filename, fh.Size, f)
+		if err != nil {
+			status := http.StatusBadRequest
+			switch err.Error() {
+			case "note not found":
+				status = http.StatusNotFound
+			case "forbidden":
+				status = http.StatusForbidden
+			case "file too large", "attachment quota exceeded":
+				status = http.StatusRequestEntityTooLarge
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, attachment)
+	})
+
+	api.GET("/notes/:id/attachments", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		noteID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		attachments, err := attachmentsService.List(userID, noteID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, attachments)
+	})
+
+	api.GET("/notes/:id/attachments/:attachmentId", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		noteID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("attachmentId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid attachment id"})
+			return
+		}
+		attachment, blob, err := attachmentsService.Open(userID, noteID, id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		defer blob.Close()
+
+		// Only render types that are safe inline; everything else is forced to download
+		disposition := "attachment"
+		if strings.HasPrefix(attachment.ContentType, "image/") || attachment.ContentType == "application/pdf" {
+			disposition = "inline"
+		}
+		c.Header("Content-Type", attachment.ContentType)
+		c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+		c.Header("X-Content-Type-Options", "nosniff")
+		// ServeContent streams the blob and handles Range / If-Range requests
+		http.ServeContent(c.Writer, c.Request, attachment.FileName, attachment.CreatedAt, blob)
+	})
+
+	api.DELETE("/notes/:id/attachments/:attachmentId", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		noteID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("attachmentId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid attachment id"})
+			return
+		}
+		if err := attachmentsService.Delete(userID, noteID, id); err != nil {
+			status := http.StatusBadRequest
+			if err.Error() == "forbidden" {
+				status = http.StatusForbidden
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+	})
+
+	api.GET("/attachments/usage", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		usage, err := attachmentsService.Usage(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, usage)
+	})
+
+	api.PUT("/users/:id/attachment-quota", requireAdmin(), func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		var req models.AttachmentQuotaRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		if err := attachmentsService.SetQuota(id, &req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "updated"})
+	})
+}
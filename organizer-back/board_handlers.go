@@ -0,0 +1,184 @@
+package main
+
+import (
+	"net/http"
+	"organizer-back/models"
+	"organizer-back/services"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerBoardRoutes wires the kanban board of each project: the snapshot, its columns and
+// card moves
+func registerBoardRoutes(api *gin.RouterGroup, boardsService *services.BoardsService, usersService *services.UsersService) {
+	boardErrorStatus := func(err error) int {
+		if strings.HasSuffix(err.Error(), "not found") {
+			return http.StatusNotFound
+		}
+		if strings.Contains(err.Error(), "WIP limit") {
+			return http.StatusConflict
+		}
+		return http.StatusBadRequest
+	}
+
+	// Columns and cards in one response; cards are the project's top-level todos
+	api.GET("/projects/:id/board", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		projectID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		now, ok := requestNow(c, usersService, userID)
+		if !ok {
+			return
+		}
+		board, err := boardsService.Board(userID, projectID, now)
+		if err != nil {
+			c.JSON(boardErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, board)
+	})
+
+	api.POST("/projects/:id/board/columns", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		projectID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		var req models.BoardColumnRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		column, err := boardsService.CreateColumn(userID, projectID, &req)
+		if err != nil {
+			c.JSON(boardErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, column)
+	})
+
+	api.PUT("/projects/:id/board/columns/:column_id", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		projectID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		columnID, err := strconv.Atoi(c.Param("column_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid column id"})
+			return
+		}
+		var req models.BoardColumnRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		column, err := boardsService.UpdateColumn(userID, projectID, columnID, &req)
+		if err != nil {
+			c.JSON(boardErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, column)
+	})
+
+	api.DELETE("/projects/:id/board/columns/:column_id", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		projectID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		columnID, err := strconv.Atoi(c.Param("column_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid column id"})
+			return
+		}
+		if err := boardsService.DeleteColumn(userID, projectID, columnID); err != nil {
+			c.JSON(boardErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+	})
+
+	api.POST("/projects/:id/board/columns/:column_id/move", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		projectID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		columnID, err := strconv.Atoi(c.Param("column_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid column id"})
+			return
+		}
+		var req models.BoardColumnMoveRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		column, err := boardsService.MoveColumn(userID, projectID, columnID, &req)
+		if err != nil {
+			c.JSON(boardErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, column)
+	})
+
+	// Drag and drop: {"column_id": 2, "before": todoID} or "after", or last in the column
+	api.POST("/projects/:id/board/cards/:todo_id/move", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		projectID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		todoID, err := strconv.Atoi(c.Param("todo_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid todo id"})
+			return
+		}
+		var req models.BoardCardMoveRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		todo, err := boardsService.MoveCard(userID, projectID, todoID, &req)
+		if err != nil {
+			c.JSON(boardErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, todo)
+	})
+}
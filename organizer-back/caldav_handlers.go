@@ -0,0 +1,617 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"organizer-back/models"
+	"organizer-back/services"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CalDAV (RFC 4791) for calendar apps. Every user has a principal and a calendar home
+// holding two calendars, their events and their todos:
+//
+//	/dav/principals/<username>/
+//	/dav/calendars/<username>/events/<name>.ics
+//	/dav/calendars/<username>/todos/<name>.ics
+//
+// Clients sign in with basic auth, the username and a personal access token as password.
+
+const (
+	nsDAV       = "DAV:"
+	nsCalDAV    = "urn:ietf:params:xml:ns:caldav"
+	nsCalServer = "http://calendarserver.org/ns/"
+
+	davPrefix  = "/dav"
+	davMaxBody = 1 << 20
+	// Format of the bounds of a calendar-query time-range
+	davTimeRangeLayout = "20060102T150405Z"
+)
+
+var davNamespacePrefixes = map[string]string{nsDAV: "d", nsCalDAV: "c", nsCalServer: "cs"}
+
+var davCalendarNames = map[string]string{
+	services.CalDAVEvents: "Eventos",
+	services.CalDAVTodos:  "Tareas",
+}
+
+var davCalendarComponents = map[string]string{
+	services.CalDAVEvents: "VEVENT",
+	services.CalDAVTodos:  "VTODO",
+}
+
+var davCalendarData = xml.Name{Space: nsCalDAV, Local: "calendar-data"}
+
+type davElement struct {
+	XMLName xml.Name
+}
+
+type davPropList struct {
+	Names []davElement `xml:",any"`
+}
+
+type davPropfind struct {
+	XMLName  xml.Name     `xml:"DAV: propfind"`
+	AllProp  *struct{}    `xml:"DAV: allprop"`
+	PropName *struct{}    `xml:"DAV: propname"`
+	Prop     *davPropList `xml:"DAV: prop"`
+}
+
+type davCompFilter struct {
+	Name      string `xml:"name,attr"`
+	TimeRange *struct {
+		Start string `xml:"start,attr"`
+		End   string `xml:"end,attr"`
+	} `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	CompFilters []davCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// davReport holds the parts of calendar-query and calendar-multiget requests we use
+type davReport struct {
+	XMLName xml.Name
+	AllProp *struct{}    `xml:"DAV: allprop"`
+	Prop    *davPropList `xml:"DAV: prop"`
+	Hrefs   []string     `xml:"DAV: href"`
+	Filter  *struct {
+		CompFilter davCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+// davTarget is the resource a request path points at
+type davTarget struct {
+	kind       string // root, principal, home, calendar or object
+	username   string
+	collection string
+	name       string
+}
+
+// davResource is a resource with the inner XML of each property it has
+type davResource struct {
+	href   string
+	props  map[xml.Name]string
+	status int // set for resources that could not be read
+}
+
+// davAuthenticator checks the basic auth credentials of CalDAV clients;
+// *services.AccessTokensService implements it
+type davAuthenticator interface {
+	Authenticate(username, token string) (*models.User, error)
+}
+
+// registerCalDAVRoutes wires the CalDAV server under /dav/ and its well-known redirect
+func registerCalDAVRoutes(r *gin.Engine, caldavService *services.CalDAVService, authenticator davAuthenticator) {
+	wellKnown := func(c *gin.Context) {
+		c.Redirect(http.StatusMovedPermanently, davPrefix+"/")
+	}
+	r.GET("/.well-known/caldav", wellKnown)
+	r.Handle("PROPFIND", "/.well-known/caldav", wellKnown)
+
+	handler := func(c *gin.Context) {
+		c.Header("DAV", "1, 3, calendar-access")
+		c.Header("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT")
+		if c.Request.Method == http.MethodOptions {
+			c.Status(http.StatusOK)
+			return
+		}
+
+		username, token, ok := c.Request.BasicAuth()
+		var user *models.User
+		if ok {
+			var err error
+			if user, err = authenticator.Authenticate(username, token); err != nil && err.Error() != "invalid credentials" {
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		if user == nil {
+			c.Header("WWW-Authenticate", `Basic realm="Organizer", charset="UTF-8"`)
+			c.String(http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		target, ok := parseDavPath(c.Param("path"))
+		if !ok {
+			c.String(http.StatusNotFound, "not found")
+			return
+		}
+		if target.kind != "root" && !strings.EqualFold(target.username, user.Username) {
+			c.String(http.StatusForbidden, "forbidden")
+			return
+		}
+		target.username = user.Username
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, davMaxBody)
+		switch c.Request.Method {
+		case "PROPFIND":
+			davPropfindHandler(c, caldavService, user, target)
+		case "REPORT":
+			davReportHandler(c, caldavService, user, target)
+		case http.MethodGet, http.MethodHead:
+			davGetHandler(c, caldavService, user, target)
+		case http.MethodPut:
+			davPutHandler(c, caldavService, user, target)
+		case http.MethodDelete:
+			davDeleteHandler(c, caldavService, user, target)
+		default:
+			c.String(http.StatusMethodNotAllowed, "method not allowed")
+		}
+	}
+	for _, method := range []string{http.MethodOptions, "PROPFIND", "REPORT", http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete} {
+		r.Handle(method, davPrefix+"/*path", handler)
+	}
+}
+
+func parseDavPath(path string) (davTarget, bool) {
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return davTarget{kind: "root"}, true
+	}
+	parts := strings.Split(trimmed, "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "principals":
+		return davTarget{kind: "principal", username: parts[1]}, true
+	case len(parts) == 2 && parts[0] == "calendars":
+		return davTarget{kind: "home", username: parts[1]}, true
+	case len(parts) >= 3 && parts[0] == "calendars" && davCalendarNames[parts[2]] != "":
+		t := davTarget{kind: "calendar", username: parts[1], collection: parts[2]}
+		if len(parts) == 4 {
+			t.kind, t.name = "object", parts[3]
+		}
+		return t, len(parts) <= 4
+	}
+	return davTarget{}, false
+}
+
+func davPrincipalHref(username string) string {
+	return davPrefix + "/principals/" + url.PathEscape(username) + "/"
+}
+
+func davHomeHref(username string) string {
+	return davPrefix + "/calendars/" + url.PathEscape(username) + "/"
+}
+
+func davCalendarHref(username, collection string) string {
+	return davHomeHref(username) + collection + "/"
+}
+
+func davObjectHref(username, collection, name string) string {
+	return davCalendarHref(username, collection) + url.PathEscape(name)
+}
+
+func davEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func davHref(href string) string {
+	return "<d:href>" + davEscape(href) + "</d:href>"
+}
+
+func davName(space, local string) xml.Name {
+	return xml.Name{Space: space, Local: local}
+}
+
+// davCommonProps are the properties every resource of the user has
+func davCommonProps(user *models.User) map[xml.Name]string {
+	return map[xml.Name]string{
+		davName(nsDAV, "current-user-principal"): davHref(davPrincipalHref(user.Username)),
+		davName(nsDAV, "owner"):                  davHref(davPrincipalHref(user.Username)),
+	}
+}
+
+func davRootResource(user *models.User) davResource {
+	props := davCommonProps(user)
+	props[davName(nsDAV, "resourcetype")] = "<d:collection/>"
+	props[davName(nsDAV, "displayname")] = "Organizer"
+	return davResource{href: davPrefix + "/", props: props}
+}
+
+func davPrincipalResource(user *models.User) davResource {
+	props := davCommonProps(user)
+	props[davName(nsDAV, "resourcetype")] = "<d:principal/>"
+	props[davName(nsDAV, "displayname")] = davEscape(strings.TrimSpace(user.FirstName + " " + user.LastName))
+	props[davName(nsDAV, "principal-URL")] = davHref(davPrincipalHref(user.Username))
+	props[davName(nsCalDAV, "calendar-home-set")] = davHref(davHomeHref(user.Username))
+	props[davName(nsCalDAV, "calendar-user-address-set")] = davHref("mailto:" + user.Email)
+	return davResource{href: davPrincipalHref(user.Username), props: props}
+}
+
+func davHomeResource(user *models.User) davResource {
+	props := davCommonProps(user)
+	props[davName(nsDAV, "resourcetype")] = "<d:collection/>"
+	props[davName(nsDAV, "displayname")] = "Organizer"
+	return davResource{href: davHomeHref(user.Username), props: props}
+}
+
+func davCalendarResource(user *models.User, collection, ctag string) davResource {
+	props := davCommonProps(user)
+	props[davName(nsDAV, "resourcetype")] = "<d:collection/><c:calendar/>"
+	props[davName(nsDAV, "displayname")] = davCalendarNames[collection]
+	props[davName(nsCalDAV, "supported-calendar-component-set")] = `<c:comp name="` + davCalendarComponents[collection] + `"/>`
+	props[davName(nsCalDAV, "supported-calendar-data")] = `<c:calendar-data content-type="text/calendar" version="2.0"/>`
+	props[davName(nsDAV, "supported-report-set")] = `<d:supported-report><d:report><c:calendar-query/></d:report></d:supported-report>` +
+		`<d:supported-report><d:report><c:calendar-multiget/></d:report></d:supported-report>`
+	props[davName(nsDAV, "current-user-privilege-set")] = `<d:privilege><d:read/></d:privilege><d:privilege><d:write/></d:privilege>` +
+		`<d:privilege><d:write-content/></d:privilege><d:privilege><d:bind/></d:privilege><d:privilege><d:unbind/></d:privilege>`
+	props[davName(nsCalServer, "getctag")] = davEscape(ctag)
+	props[davName(nsDAV, "getetag")] = davEscape(`"` + ctag + `"`)
+	return davResource{href: davCalendarHref(user.Username, collection), props: props}
+}
+
+// davObjectResource describes an object; calendar-data is only sent when asked for
+func davObjectResource(user *models.User, collection string, obj *services.CalDAVObject, withData bool) davResource {
+	props := davCommonProps(user)
+	props[davName(nsDAV, "resourcetype")] = ""
+	props[davName(nsDAV, "getetag")] = davEscape(obj.ETag)
+	props[davName(nsDAV, "getcontenttype")] = "text/calendar; charset=utf-8; component=" + strings.ToLower(davCalendarComponents[collection])
+	props[davName(nsDAV, "getcontentlength")] = strconv.Itoa(len(obj.Data))
+	props[davName(nsDAV, "getlastmodified")] = obj.LastModified.UTC().Format(http.TimeFormat)
+	if withData {
+		props[davCalendarData] = davEscape(string(obj.Data))
+	}
+	return davResource{href: davObjectHref(user.Username, collection, obj.Name), props: props}
+}
+
+// davRequestedProps reads the prop element of a request; nil means all properties
+func davRequestedProps(list *davPropList) []xml.Name {
+	if list == nil {
+		return nil
+	}
+	names := make([]xml.Name, 0, len(list.Names))
+	for _, n := range list.Names {
+		names = append(names, n.XMLName)
+	}
+	return names
+}
+
+func davWantsData(props []xml.Name) bool {
+	for _, n := range props {
+		if n == davCalendarData {
+			return true
+		}
+	}
+	return false
+}
+
+func davPropfindHandler(c *gin.Context, svc *services.CalDAVService, user *models.User, target davTarget) {
+	var req davPropfind
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.String(http.StatusRequestEntityTooLarge, "request too large")
+		return
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := xml.Unmarshal(body, &req); err != nil {
+			c.String(http.StatusBadRequest, "invalid propfind")
+			return
+		}
+	}
+	props := davRequestedProps(req.Prop)
+	depth := c.GetHeader("Depth")
+	if depth == "" {
+		depth = "infinity"
+	}
+	withChildren := depth != "0"
+
+	var resources []davResource
+	switch target.kind {
+	case "root":
+		resources = append(resources, davRootResource(user))
+		if withChildren {
+			resources = append(resources, davPrincipalResource(user), davHomeResource(user))
+		}
+	case "principal":
+		resources = append(resources, davPrincipalResource(user))
+	case "home":
+		resources = append(resources, davHomeResource(user))
+		if withChildren {
+			for _, collection := range []string{services.CalDAVEvents, services.CalDAVTodos} {
+				ctag, err := svc.CTag(user.ID, collection)
+				if err != nil {
+					c.String(http.StatusInternalServerError, err.Error())
+					return
+				}
+				resources = append(resources, davCalendarResource(user, collection, ctag))
+			}
+		}
+	case "calendar":
+		ctag, err := svc.CTag(user.ID, target.collection)
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		resources = append(resources, davCalendarResource(user, target.collection, ctag))
+		if withChildren {
+			objects, err := svc.List(user.ID, target.collection, nil)
+			if err != nil {
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+			for i := range objects {
+				resources = append(resources, davObjectResource(user, target.collection, &objects[i], davWantsData(props)))
+			}
+		}
+	case "object":
+		obj, err := svc.Get(user.ID, target.collection, target.name)
+		if err != nil {
+			davError(c, err)
+			return
+		}
+		resources = append(resources, davObjectResource(user, target.collection, obj, davWantsData(props)))
+	}
+	writeMultistatus(c, resources, props, req.PropName != nil)
+}
+
+func davReportHandler(c *gin.Context, svc *services.CalDAVService, user *models.User, target davTarget) {
+	if target.kind != "calendar" && target.kind != "object" {
+		c.String(http.StatusForbidden, "reports are only supported on calendars")
+		return
+	}
+	var req davReport
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.String(http.StatusRequestEntityTooLarge, "request too large")
+		return
+	}
+	if err := xml.Unmarshal(body, &req); err != nil {
+		c.String(http.StatusBadRequest, "invalid report")
+		return
+	}
+	props := davRequestedProps(req.Prop)
+	withData := props == nil || davWantsData(props)
+
+	var resources []davResource
+	switch req.XMLName {
+	case davName(nsCalDAV, "calendar-multiget"):
+		for _, href := range req.Hrefs {
+			resources = append(resources, davMultigetResource(svc, user, strings.TrimSpace(href), withData))
+		}
+	case davName(nsCalDAV, "calendar-query"):
+		tr, component, err := davQueryFilter(&req)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		if component == "" || component == davCalendarComponents[target.collection] {
+			objects, err := svc.List(user.ID, target.collection, tr)
+			if err != nil {
+				c.String(http.StatusInternalServerError, err.Error())
+				return
+			}
+			for i := range objects {
+				if target.kind == "object" && objects[i].Name != target.name {
+					continue
+				}
+				resources = append(resources, davObjectResource(user, target.collection, &objects[i], withData))
+			}
+		}
+	default:
+		c.Header("Content-Type", "application/xml; charset=utf-8")
+		c.String(http.StatusForbidden, xml.Header+`<d:error xmlns:d="DAV:"><d:supported-report/></d:error>`)
+		return
+	}
+	writeMultistatus(c, resources, props, false)
+}
+
+// davMultigetResource resolves one href of a calendar-multiget
+func davMultigetResource(svc *services.CalDAVService, user *models.User, href string, withData bool) davResource {
+	notFound := davResource{href: href, status: http.StatusNotFound}
+	u, err := url.Parse(href)
+	if err != nil || !strings.HasPrefix(u.Path, davPrefix+"/") {
+		return notFound
+	}
+	target, ok := parseDavPath(strings.TrimPrefix(u.Path, davPrefix))
+	if !ok || target.kind != "object" || !strings.EqualFold(target.username, user.Username) {
+		return notFound
+	}
+	obj, err := svc.Get(user.ID, target.collection, target.name)
+	if err != nil {
+		return notFound
+	}
+	res := davObjectResource(user, target.collection, obj, withData)
+	// Answer with the href the client used
+	res.href = href
+	return res
+}
+
+// davQueryFilter finds the component and time range a calendar-query asks for. Only the
+// time-range of the VEVENT or VTODO filter is applied; clients filter the rest themselves.
+func davQueryFilter(req *davReport) (*services.CalDAVTimeRange, string, error) {
+	if req.Filter == nil || !strings.EqualFold(req.Filter.CompFilter.Name, "VCALENDAR") {
+		return nil, "", nil
+	}
+	for _, f := range req.Filter.CompFilter.CompFilters {
+		component := strings.ToUpper(f.Name)
+		if f.TimeRange == nil {
+			return nil, component, nil
+		}
+		tr := &services.CalDAVTimeRange{}
+		var err error
+		if f.TimeRange.Start != "" {
+			if tr.Start, err = time.Parse(davTimeRangeLayout, f.TimeRange.Start); err != nil {
+				return nil, "", errors.New("invalid time-range start")
+			}
+		}
+		if f.TimeRange.End != "" {
+			if tr.End, err = time.Parse(davTimeRangeLayout, f.TimeRange.End); err != nil {
+				return nil, "", errors.New("invalid time-range end")
+			}
+		}
+		return tr, component, nil
+	}
+	return nil, "", nil
+}
+
+func davGetHandler(c *gin.Context, svc *services.CalDAVService, user *models.User, target davTarget) {
+	if target.kind != "object" {
+		c.String(http.StatusOK, "Organizer CalDAV")
+		return
+	}
+	obj, err := svc.Get(user.ID, target.collection, target.name)
+	if err != nil {
+		davError(c, err)
+		return
+	}
+	c.Header("ETag", obj.ETag)
+	c.Header("Last-Modified", obj.LastModified.UTC().Format(http.TimeFormat))
+	if match := c.GetHeader("If-None-Match"); match != "" && match == obj.ETag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", obj.Data)
+}
+
+func davPutHandler(c *gin.Context, svc *services.CalDAVService, user *models.User, target davTarget) {
+	if target.kind != "object" {
+		c.String(http.StatusMethodNotAllowed, "only calendar objects can be written")
+		return
+	}
+	_, created, err := svc.Put(user.ID, target.collection, target.name, c.Request.Body, c.GetHeader("If-Match"), c.GetHeader("If-None-Match"))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.String(http.StatusRequestEntityTooLarge, "request too large")
+			return
+		}
+		davError(c, err)
+		return
+	}
+	// No ETag: the object is stored re-serialized, not as sent, so clients must fetch it to
+	// get the current data and its ETag (RFC 4791, section 5.3.4)
+	if created {
+		c.Status(http.StatusCreated)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func davDeleteHandler(c *gin.Context, svc *services.CalDAVService, user *models.User, target davTarget) {
+	if target.kind != "object" {
+		c.String(http.StatusForbidden, "collections cannot be deleted")
+		return
+	}
+	if err := svc.Delete(user.ID, target.collection, target.name, c.GetHeader("If-Match")); err != nil {
+		davError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// davError maps service errors to status codes
+func davError(c *gin.Context, err error) {
+	msg := err.Error()
+	status := http.StatusBadRequest
+	switch {
+	case msg == "precondition failed":
+		status = http.StatusPreconditionFailed
+	case strings.HasSuffix(msg, "not found"):
+		status = http.StatusNotFound
+	case strings.HasSuffix(msg, "already exists"):
+		status = http.StatusConflict
+	case strings.HasPrefix(msg, "error "):
+		status = http.StatusInternalServerError
+	}
+	c.String(status, msg)
+}
+
+// writeMultistatus answers with a 207 listing each resource. With requested properties each
+// is reported as found (200) or missing (404); otherwise all properties are listed, or only
+// their names when namesOnly is set.
+func writeMultistatus(c *gin.Context, resources []davResource, requested []xml.Name, namesOnly bool) {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:c="` + nsCalDAV + `" xmlns:cs="` + nsCalServer + `">`)
+	for _, res := range resources {
+		b.WriteString("<d:response>" + davHref(res.href))
+		if res.status != 0 {
+			fmt.Fprintf(&b, "<d:status>HTTP/1.1 %d %s</d:status></d:response>", res.status, http.StatusText(res.status))
+			continue
+		}
+		var found, missing []xml.Name
+		if requested == nil {
+			for name := range res.props {
+				found = append(found, name)
+			}
+			sort.Slice(found, func(i, j int) bool {
+				return found[i].Space+found[i].Local < found[j].Space+found[j].Local
+			})
+		} else {
+			for _, name := range requested {
+				if _, ok := res.props[name]; ok {
+					found = append(found, name)
+				} else {
+					missing = append(missing, name)
+				}
+			}
+		}
+		if len(found) > 0 {
+			b.WriteString("<d:propstat><d:prop>")
+			for _, name := range found {
+				if namesOnly {
+					writeDavProp(&b, name, "")
+				} else {
+					writeDavProp(&b, name, res.props[name])
+				}
+			}
+			b.WriteString("</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>")
+		}
+		if len(missing) > 0 {
+			b.WriteString("<d:propstat><d:prop>")
+			for _, name := range missing {
+				writeDavProp(&b, name, "")
+			}
+			b.WriteString("</d:prop><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat>")
+		}
+		b.WriteString("</d:response>")
+	}
+	b.WriteString("</d:multistatus>")
+	c.Data(http.StatusMultiStatus, "application/xml; charset=utf-8", []byte(b.String()))
+}
+
+func writeDavProp(b *strings.Builder, name xml.Name, inner string) {
+	tag := name.Local
+	open := tag
+	if prefix, ok := davNamespacePrefixes[name.Space]; ok {
+		tag = prefix + ":" + name.Local
+		open = tag
+	} else if name.Space != "" {
+		tag = "x:" + name.Local
+		open = tag + ` xmlns:x="` + davEscape(name.Space) + `"`
+	}
+	if inner == "" {
+		b.WriteString("<" + open + "/>")
+		return
+	}
+	b.WriteString("<" + open + ">" + inner + "</" + tag + ">")
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"organizer-back/models"
+	"organizer-back/services"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-webdav"
+	"github.com/emersion/go-webdav/caldav"
+	"github.com/gin-gonic/gin"
+)
+
+// memClock hands out strictly increasing modification times, like updated_at=NOW() in
+// separate transactions
+type memClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *memClock) tick() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(time.Second)
+	return c.now
+}
+
+// memEvents is an in-memory services.CalDAVEventStore
+type memEvents struct {
+	mu     sync.Mutex
+	clock  *memClock
+	nextID int
+	rows   map[int]models.Event
+}
+
+func (m *memEvents) Stamp(userID int) (int, *time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	var last *time.Time
+	for _, e := range m.rows {
+		if e.UserID != userID {
+			continue
+		}
+		count++
+		if last == nil || e.UpdatedAt.After(*last) {
+			t := e.UpdatedAt
+			last = &t
+		}
+	}
+	return count, last, nil
+}
+
+func (m *memEvents) ListOwned(userID int) ([]models.Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.Event{}
+	for _, e := range m.rows {
+		if e.UserID == userID {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartsAt.Before(out[j].StartsAt) })
+	return out, nil
+}
+
+func (m *memEvents) find(match func(e *models.Event) bool) (*models.Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.rows {
+		if match(&e) {
+			return &e, nil
+		}
+	}
+	return nil, errors.New("event not found")
+}
+
+func (m *memEvents) GetByUID(userID int, uid string) (*models.Event, error) {
+	return m.find(func(e *models.Event) bool { return e.UserID == userID && e.UID == uid })
+}
+
+func (m *memEvents) GetByDavName(userID int, name string) (*models.Event, error) {
+	return m.find(func(e *models.Event) bool {
+		return e.UserID == userID && (e.DavName != nil && *e.DavName == name || e.DavName == nil && e.UID+".ics" == name)
+	})
+}
+
+func (m *memEvents) Create(e *models.Event, attendeeIDs []int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, other := range m.rows {
+		if other.UserID == e.UserID && other.UID == e.UID {
+			return errors.New("an event with that uid already exists")
+		}
+	}
+	m.nextID++
+	e.ID = m.nextID
+	e.CreatedAt = m.clock.tick()
+	e.UpdatedAt = e.CreatedAt
+	m.rows[e.ID] = *e
+	return nil
+}
+
+func (m *memEvents) Update(e *models.Event, attendeeIDs []int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.rows[e.ID]
+	if !ok || stored.UserID != e.UserID {
+		return errors.New("event not found")
+	}
+	e.UID, e.DavName, e.CreatedAt = stored.UID, stored.DavName, stored.CreatedAt
+	e.UpdatedAt = m.clock.tick()
+	m.rows[e.ID] = *e
+	return nil
+}
+
+func (m *memEvents) Delete(userID, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.rows[id]; !ok || e.UserID != userID {
+		return errors.New("event not found")
+	}
+	delete(m.rows, id)
+	return nil
+}
+
+// memTodos is an in-memory services.CalDAVTodoStore
+type memTodos struct {
+	mu     sync.Mutex
+	clock  *memClock
+	nextID int
+	rows   map[int]models.Todo
+}
+
+func (m *memTodos) Stamp(userID int) (int, *time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	var last *time.Time
+	for _, t := range m.rows {
+		if t.UserID != userID {
+			continue
+		}
+		count++
+		if last == nil || t.UpdatedAt.After(*last) {
+			u := t.UpdatedAt
+			last = &u
+		}
+	}
+	return count, last, nil
+}
+
+func (m *memTodos) ListAll(userID int) ([]models.Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.Todo{}
+	for _, t := range m.rows {
+		if t.UserID == userID {
+			out = append(out, t)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (m *memTodos) find(match func(t *models.Todo) bool) (*models.Todo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.rows {
+		if match(&t) {
+			return &t, nil
+		}
+	}
+	return nil, errors.New("todo not found")
+}
+
+func (m *memTodos) GetByID(userID, id int) (*models.Todo, error) {
+	return m.find(func(t *models.Todo) bool { return t.UserID == userID && t.ID == id })
+}
+
+func (m *memTodos) GetByUID(userID int, uid string) (*models.Todo, error) {
+	return m.find(func(t *models.Todo) bool { return t.UserID == userID && t.UID == uid })
+}
+
+func (m *memTodos) GetByDavName(userID int, name string) (*models.Todo, error) {
+	return m.find(func(t *models.Todo) bool {
+		return t.UserID == userID && (t.DavName != nil && *t.DavName == name || t.DavName == nil && t.UID+".ics" == name)
+	})
+}
+
+func (m *memTodos) Create(t *models.Todo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, other := range m.rows {
+		if other.UserID == t.UserID && other.UID == t.UID {
+			return errors.New("a todo with that uid already exists")
+		}
+	}
+	m.nextID++
+	t.ID = m.nextID
+	t.CreatedAt = m.clock.tick()
+	t.UpdatedAt = t.CreatedAt
+	m.rows[t.ID] = *t
+	return nil
+}
+
+func (m *memTodos) Update(t *models.Todo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.rows[t.ID]
+	if !ok || stored.UserID != t.UserID {
+		return errors.New("todo not found")
+	}
+	t.UID, t.DavName, t.ParentID, t.Position, t.CreatedAt = stored.UID, stored.DavName, stored.ParentID, stored.Position, stored.CreatedAt
+	t.UpdatedAt = m.clock.tick()
+	m.rows[t.ID] = *t
+	return nil
+}
+
+func (m *memTodos) Delete(userID, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t, ok := m.rows[id]; !ok || t.UserID != userID {
+		return errors.New("todo not found")
+	}
+	delete(m.rows, id)
+	return nil
+}
+
+type memTimezones map[int]string
+
+func (m memTimezones) GetTimezone(userID int) (string, error) {
+	return m[userID], nil
+}
+
+// memAuth accepts one personal access token per user
+type memAuth map[string]*models.User
+
+func (a memAuth) Authenticate(username, token string) (*models.User, error) {
+	if u := a[token]; u != nil && strings.EqualFold(u.Username, username) {
+		return u, nil
+	}
+	return nil, errors.New("invalid credentials")
+}
+
+const (
+	davTestToken = "org_test-token"
+	davTestEvent = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" +
+		"BEGIN:VEVENT\r\nUID:standup-1\r\nDTSTAMP:20250301T080000Z\r\nDTSTART:20250310T090000Z\r\nDTEND:20250310T093000Z\r\nSUMMARY:%s\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	davTestTodo = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" +
+		"BEGIN:VTODO\r\nUID:todo-1\r\nDTSTAMP:20250301T080000Z\r\nSUMMARY:Write tests\r\nDUE;VALUE=DATE:20250312\r\nEND:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+)
+
+// davTestServer serves the /dav routes on in-memory stores for the user "ana"
+func davTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	clock := &memClock{now: time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)}
+	svc := services.NewCalDAVServiceWithStores(
+		&memEvents{clock: clock, rows: map[int]models.Event{}},
+		&memTodos{clock: clock, rows: map[int]models.Todo{}},
+		memTimezones{1: "Europe/Madrid"},
+	)
+	r := gin.New()
+	registerCalDAVRoutes(r, svc, memAuth{davTestToken: {ID: 1, Username: "ana", Email: "ana@example.com"}})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// davDo sends a raw request, for what the client library does not cover: preconditions and
+// the ctag
+func davDo(t *testing.T, c webdav.HTTPClient, method, url, body string, header map[string]string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func davPut(t *testing.T, c webdav.HTTPClient, url, body string, header map[string]string) *http.Response {
+	t.Helper()
+	if header == nil {
+		header = map[string]string{}
+	}
+	header["Content-Type"] = "text/calendar; charset=utf-8"
+	return davDo(t, c, http.MethodPut, url, body, header)
+}
+
+func davCTag(t *testing.T, c webdav.HTTPClient, url string) string {
+	t.Helper()
+	resp := davDo(t, c, "PROPFIND", url, `<?xml version="1.0"?><d:propfind xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/"><d:prop><cs:getctag/></d:prop></d:propfind>`,
+		map[string]string{"Depth": "0", "Content-Type": "application/xml"})
+	if resp.StatusCode != http.StatusMultiStatus {
+		t.Fatalf("PROPFIND getctag: %s", resp.Status)
+	}
+	dec := xml.NewDecoder(resp.Body)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			t.Fatalf("no getctag in the response: %v", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name == (xml.Name{Space: nsCalServer, Local: "getctag"}) {
+			var ctag string
+			if err := dec.DecodeElement(&ctag, &start); err != nil {
+				t.Fatal(err)
+			}
+			return ctag
+		}
+	}
+}
+
+func TestCalDAVClient(t *testing.T) {
+	srv := davTestServer(t)
+	ctx := context.Background()
+	httpClient := webdav.HTTPClientWithBasicAuth(srv.Client(), "ana", davTestToken)
+	client, err := caldav.NewClient(httpClient, srv.URL+"/dav/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Discovery: principal, calendar home and calendars
+	principal, err := client.FindCurrentUserPrincipal(ctx)
+	if err != nil {
+		t.Fatalf("FindCurrentUserPrincipal: %v", err)
+	}
+	if principal != "/dav/principals/ana/" {
+		t.Fatalf("principal = %q", principal)
+	}
+	home, err := client.FindCalendarHomeSet(ctx, principal)
+	if err != nil {
+		t.Fatalf("FindCalendarHomeSet: %v", err)
+	}
+	if home != "/dav/calendars/ana/" {
+		t.Fatalf("calendar home = %q", home)
+	}
+	calendars, err := client.FindCalendars(ctx, home)
+	if err != nil {
+		t.Fatalf("FindCalendars: %v", err)
+	}
+	found := map[string]string{}
+	for _, cal := range calendars {
+		found[cal.Path] = strings.Join(cal.SupportedComponentSet, ",")
+	}
+	if len(found) != 2 || found["/dav/calendars/ana/events/"] != "VEVENT" || found["/dav/calendars/ana/todos/"] != "VTODO" {
+		t.Fatalf("calendars = %v", found)
+	}
+
+	eventsURL := srv.URL + "/dav/calendars/ana/events/"
+	eventPath := "/dav/calendars/ana/events/client-name.ics"
+	ctag := davCTag(t, httpClient, eventsURL)
+
+	// Create only if absent; the response carries no ETag since the stored data differs
+	resp := davPut(t, httpClient, srv.URL+eventPath, strings.Replace(davTestEvent, "%s", "Standup", 1), map[string]string{"If-None-Match": "*"})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT create: %s", resp.Status)
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		t.Errorf("PUT returned ETag %s", etag)
+	}
+	if resp := davPut(t, httpClient, srv.URL+eventPath, strings.Replace(davTestEvent, "%s", "Again", 1), map[string]string{"If-None-Match": "*"}); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("PUT with If-None-Match on an existing object: %s", resp.Status)
+	}
+	if resp := davPut(t, httpClient, srv.URL+"/dav/calendars/ana/todos/todo-1.ics", davTestTodo, nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT todo: %s", resp.Status)
+	}
+	next := davCTag(t, httpClient, eventsURL)
+	if next == ctag {
+		t.Error("ctag did not change after a create")
+	}
+	ctag = next
+
+	// calendar-query with a time-range
+	query := func(start, end time.Time) []caldav.CalendarObject {
+		t.Helper()
+		objects, err := client.QueryCalendar(ctx, "/dav/calendars/ana/events/", &caldav.CalendarQuery{
+			CompRequest: caldav.CalendarCompRequest{Name: "VCALENDAR", AllProps: true, AllComps: true},
+			CompFilter: caldav.CompFilter{Name: "VCALENDAR", Comps: []caldav.CompFilter{
+				{Name: "VEVENT", Start: start, End: end},
+			}},
+		})
+		if err != nil {
+			t.Fatalf("QueryCalendar: %v", err)
+		}
+		return objects
+	}
+	objects := query(time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC))
+	if len(objects) != 1 || objects[0].Path != eventPath || objects[0].ETag == "" {
+		t.Fatalf("query in range = %+v", objects)
+	}
+	if summary := objects[0].Data.Children[0].Props.Get("SUMMARY"); summary == nil || summary.Value != "Standup" {
+		t.Errorf("query calendar-data summary = %v", summary)
+	}
+	if objects := query(time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC), time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC)); len(objects) != 0 {
+		t.Errorf("query out of range = %+v", objects)
+	}
+	etag := objects[0].ETag
+
+	// calendar-multiget
+	objects, err = client.MultiGetCalendar(ctx, "/dav/calendars/ana/todos/", &caldav.CalendarMultiGet{
+		Paths:       []string{"/dav/calendars/ana/todos/todo-1.ics"},
+		CompRequest: caldav.CalendarCompRequest{Name: "VCALENDAR", AllProps: true, AllComps: true},
+	})
+	if err != nil {
+		t.Fatalf("MultiGetCalendar: %v", err)
+	}
+	if len(objects) != 1 || objects[0].Path != "/dav/calendars/ana/todos/todo-1.ics" || len(objects[0].Data.Children) != 1 || objects[0].Data.Children[0].Name != "VTODO" {
+		t.Fatalf("multiget = %+v", objects)
+	}
+
+	// Update only if unchanged
+	quoted := strconv.Quote(etag)
+	if resp := davPut(t, httpClient, srv.URL+eventPath, strings.Replace(davTestEvent, "%s", "Stale", 1), map[string]string{"If-Match": `"0-0"`}); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("PUT with a stale If-Match: %s", resp.Status)
+	}
+	if resp := davPut(t, httpClient, srv.URL+"/dav/calendars/ana/events/missing.ics", strings.Replace(davTestEvent, "%s", "Missing", 1), map[string]string{"If-Match": "*"}); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("PUT with If-Match on a missing object: %s", resp.Status)
+	}
+	resp = davPut(t, httpClient, srv.URL+eventPath, strings.Replace(davTestEvent, "%s", "Daily standup", 1), map[string]string{"If-Match": quoted})
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("PUT update: %s", resp.Status)
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		t.Errorf("PUT update returned ETag %s", etag)
+	}
+	obj, err := client.GetCalendarObject(ctx, eventPath)
+	if err != nil {
+		t.Fatalf("GetCalendarObject: %v", err)
+	}
+	if obj.ETag == "" || obj.ETag == etag {
+		t.Errorf("ETag after update = %q, was %q", obj.ETag, etag)
+	}
+	if summary := obj.Data.Children[0].Props.Get("SUMMARY"); summary == nil || summary.Value != "Daily standup" {
+		t.Errorf("summary after update = %v", summary)
+	}
+	if next := davCTag(t, httpClient, eventsURL); next == ctag {
+		t.Error("ctag did not change after an update")
+	} else {
+		ctag = next
+	}
+
+	// Delete, honouring If-Match
+	if resp := davDo(t, httpClient, http.MethodDelete, srv.URL+eventPath, "", map[string]string{"If-Match": quoted}); resp.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("DELETE with a stale If-Match: %s", resp.Status)
+	}
+	if err := client.RemoveAll(ctx, eventPath); err != nil {
+		t.Fatalf("DELETE: %v", err)
+	}
+	if _, err := client.GetCalendarObject(ctx, eventPath); err == nil {
+		t.Error("object still readable after DELETE")
+	}
+	if objects := query(time.Time{}, time.Time{}); len(objects) != 0 {
+		t.Errorf("query after DELETE = %+v", objects)
+	}
+	if next := davCTag(t, httpClient, eventsURL); next == ctag {
+		t.Error("ctag did not change after a delete")
+	}
+}
+
+func TestCalDAVAuth(t *testing.T) {
+	srv := davTestServer(t)
+	ctx := context.Background()
+
+	client, err := caldav.NewClient(webdav.HTTPClientWithBasicAuth(srv.Client(), "ana", "org_wrong"), srv.URL+"/dav/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.FindCurrentUserPrincipal(ctx); err == nil {
+		t.Error("a wrong token was accepted")
+	}
+
+	httpClient := webdav.HTTPClientWithBasicAuth(srv.Client(), "ana", davTestToken)
+	resp := davDo(t, httpClient, "PROPFIND", srv.URL+"/dav/calendars/bob/", "", map[string]string{"Depth": "1"})
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("PROPFIND on another user's calendars: %s", resp.Status)
+	}
+	io.Copy(io.Discard, resp.Body)
+}
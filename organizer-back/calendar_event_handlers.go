@@ -0,0 +1,159 @@
+package main
+
+import (
+	"net/http"
+	"organizer-back/models"
+	"organizer-back/services"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerCalendarEventRoutes wires calendar events, their occurrences and invitations
+func registerCalendarEventRoutes(api *gin.RouterGroup, eventsService *services.EventsService, usersService *services.UsersService) {
+	eventErrorStatus := func(err error) int {
+		if strings.HasSuffix(err.Error(), "not found") {
+			return http.StatusNotFound
+		}
+		if strings.HasSuffix(err.Error(), "already exists") {
+			return http.StatusConflict
+		}
+		return http.StatusBadRequest
+	}
+
+	api.GET("/events", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		var q models.EventRangeQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from and to are required"})
+			return
+		}
+		now, ok := requestNow(c, usersService, userID)
+		if !ok {
+			return
+		}
+		occurrences, err := eventsService.Occurrences(userID, &q, now.Location())
+		if err != nil {
+			c.JSON(eventErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, occurrences)
+	})
+
+	api.POST("/events", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		var req models.EventRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		now, ok := requestNow(c, usersService, userID)
+		if !ok {
+			return
+		}
+		event, err := eventsService.Create(userID, &req, now.Location().String())
+		if err != nil {
+			c.JSON(eventErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, event)
+	})
+
+	api.GET("/events/:id", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		event, err := eventsService.Get(userID, id)
+		if err != nil {
+			c.JSON(eventErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, event)
+	})
+
+	api.PUT("/events/:id", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		var req models.EventRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		now, ok := requestNow(c, usersService, userID)
+		if !ok {
+			return
+		}
+		event, err := eventsService.Update(userID, id, &req, now.Location().String())
+		if err != nil {
+			c.JSON(eventErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, event)
+	})
+
+	api.DELETE("/events/:id", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		if err := eventsService.Delete(userID, id); err != nil {
+			c.JSON(eventErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+	})
+
+	api.PUT("/events/:id/attendance", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		var req models.EventAttendanceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		event, err := eventsService.SetAttendance(userID, id, req.Status)
+		if err != nil {
+			c.JSON(eventErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, event)
+	})
+}
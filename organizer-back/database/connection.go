@@ -20,6 +20,9 @@ type db struct {
 
 var DB *sql.DB
 
+// ConnInfo is the connection string of DB, for connections outside the pool (LISTEN)
+var ConnInfo string
+
 func NewDB() *db {
 	log.Println("Creating new database...")
 	return &db{
@@ -39,6 +42,7 @@ func (db *db) InitDB() error {
 		db.host, db.port, db.user, db.password, db.name, db.sslmode)
 
 	var err error
+	ConnInfo = psqlInfo
 	DB, err = sql.Open("postgres", psqlInfo)
 	if err != nil {
 		return fmt.Errorf("error opening database: %v", err)
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"net/http"
+	"organizer-back/models"
+	"organizer-back/services"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerEmotionRoutes wires the mood journal: the emotion catalog, entries and aggregates
+func registerEmotionRoutes(api *gin.RouterGroup, emotionsService *services.EmotionsService, usersService *services.UsersService) {
+	emotionErrorStatus := func(err error) int {
+		if strings.HasSuffix(err.Error(), "not found") {
+			return http.StatusNotFound
+		}
+		if strings.HasSuffix(err.Error(), "already exists") {
+			return http.StatusConflict
+		}
+		return http.StatusBadRequest
+	}
+
+	api.GET("/emotions/labels", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		labels, err := emotionsService.Labels(userID, c.Query("include_archived") == "true")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, labels)
+	})
+
+	api.POST("/emotions/labels", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		var req models.EmotionLabelRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		label, err := emotionsService.CreateLabel(userID, &req)
+		if err != nil {
+			c.JSON(emotionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, label)
+	})
+
+	api.PUT("/emotions/labels/:id", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		var req models.EmotionLabelRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		label, err := emotionsService.UpdateLabel(userID, id, &req)
+		if err != nil {
+			c.JSON(emotionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, label)
+	})
+
+	api.DELETE("/emotions/labels/:id", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		archived, err := emotionsService.DeleteLabel(userID, id)
+		if err != nil {
+			c.JSON(emotionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		if archived {
+			c.JSON(http.StatusOK, gin.H{"message": "archived"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+	})
+
+	api.GET("/emotions/trends", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		var q models.EmotionRangeQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query"})
+			return
+		}
+		now, ok := requestNow(c, usersService, userID)
+		if !ok {
+			return
+		}
+		trends, err := emotionsService.Trends(userID, &q, now)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, trends)
+	})
+
+	api.GET("/emotions/correlations", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		var q models.EmotionRangeQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query"})
+			return
+		}
+		now, ok := requestNow(c, usersService, userID)
+		if !ok {
+			return
+		}
+		correlations, err := emotionsService.Correlations(userID, &q, now)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, correlations)
+	})
+
+	api.GET("/emotions", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		var q models.EmotionRangeQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query"})
+			return
+		}
+		now, ok := requestNow(c, usersService, userID)
+		if !ok {
+			return
+		}
+		entries, err := emotionsService.List(userID, &q, now)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, entries)
+	})
+
+	api.POST("/emotions", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		var req models.EmotionEntryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		now, ok := requestNow(c, usersService, userID)
+		if !ok {
+			return
+		}
+		entry, err := emotionsService.Create(userID, &req, now)
+		if err != nil {
+			c.JSON(emotionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, entry)
+	})
+
+	api.GET("/emotions/:id", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		entry, err := emotionsService.Get(userID, id)
+		if err != nil {
+			c.JSON(emotionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, entry)
+	})
+
+	api.PUT("/emotions/:id", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		var req models.EmotionEntryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		now, ok := requestNow(c, usersService, userID)
+		if !ok {
+			return
+		}
+		entry, err := emotionsService.Update(userID, id, &req, now)
+		if err != nil {
+			c.JSON(emotionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, entry)
+	})
+
+	api.DELETE("/emotions/:id", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		if err := emotionsService.Delete(userID, id); err != nil {
+			c.JSON(emotionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+	})
+}
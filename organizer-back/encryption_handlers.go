@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/http"
+	"organizer-back/models"
+	"organizer-back/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// encryptionErrorStatus maps key errors of encrypted notes, falling back to def
+func encryptionErrorStatus(err error, def int) int {
+	switch err.Error() {
+	case "notes are locked":
+		return http.StatusLocked
+	case "encryption not set up":
+		return http.StatusConflict
+	}
+	return def
+}
+
+// registerEncryptionRoutes wires setup, unlock and lock of encrypted notes. Unlocking returns
+// a grant that clients send back in the X-Decryption-Grant header, so these routes need a
+// session token with a valid signature.
+func registerEncryptionRoutes(api *gin.RouterGroup, encryptionService *services.EncryptionService, authService *services.AuthService) {
+	api.GET("/notes/encryption", func(c *gin.Context) {
+		userID := verifiedUserIDFromAuthHeader(authService, c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		status, err := encryptionService.Status(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, status)
+	})
+
+	api.POST("/notes/encryption/setup", func(c *gin.Context) {
+		userID := verifiedUserIDFromAuthHeader(authService, c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		var req models.EncryptionSetupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		grant, err := encryptionService.Setup(userID, &req)
+		if err != nil {
+			status := http.StatusBadRequest
+			if err.Error() == "encryption already set up" {
+				status = http.StatusConflict
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if grant == nil {
+			c.JSON(http.StatusCreated, gin.H{"message": "encryption enabled"})
+			return
+		}
+		c.JSON(http.StatusCreated, grant)
+	})
+
+	api.POST("/notes/encryption/unlock", func(c *gin.Context) {
+		userID := verifiedUserIDFromAuthHeader(authService, c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		var req models.EncryptionUnlockRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		grant, err := encryptionService.Unlock(userID, req.Passphrase)
+		if err != nil {
+			status := encryptionErrorStatus(err, http.StatusBadRequest)
+			if err.Error() == "invalid passphrase" {
+				status = http.StatusUnauthorized
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, grant)
+	})
+
+	api.POST("/notes/encryption/lock", func(c *gin.Context) {
+		userID := verifiedUserIDFromAuthHeader(authService, c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		encryptionService.Lock(c.GetHeader("X-Decryption-Grant"))
+		c.JSON(http.StatusOK, gin.H{"message": "locked"})
+	})
+
+	api.PUT("/notes/encryption/passphrase", func(c *gin.Context) {
+		userID := verifiedUserIDFromAuthHeader(authService, c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		var req models.EncryptionPassphraseChangeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		if err := encryptionService.ChangePassphrase(userID, &req); err != nil {
+			status := encryptionErrorStatus(err, http.StatusBadRequest)
+			if err.Error() == "invalid passphrase" {
+				status = http.StatusUnauthorized
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "passphrase changed"})
+	})
+}
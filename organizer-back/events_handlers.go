@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"organizer-back/realtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sseKeepAlive keeps proxies from closing idle event streams
+const sseKeepAlive = 25 * time.Second
+
+// registerEventRoutes wires GET /events/stream, the server-sent event stream of the caller's
+// live events (reminders, pomodoro timer changes)
+func registerEventRoutes(api *gin.RouterGroup, broker *realtime.Broker) {
+	api.GET("/events/stream", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			// EventSource cannot send headers, so the token may come in the query string
+			userID = extractUserIDFromAuthHeader("Bearer " + c.Query("access_token"))
+		}
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		events, unsubscribe := broker.Subscribe(userID)
+		defer unsubscribe()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		keepAlive := time.NewTicker(sseKeepAlive)
+		defer keepAlive.Stop()
+		c.Stream(func(w io.Writer) bool {
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case ev := <-events:
+				c.SSEvent(ev.Type, ev.Data)
+			case <-keepAlive.C:
+				io.WriteString(w, ": keep-alive\n\n")
+			}
+			return true
+		})
+	})
+}
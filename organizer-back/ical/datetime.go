@@ -0,0 +1,122 @@
+package ical
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	dateLayout  = "20060102"
+	localLayout = "20060102T150405"
+	utcLayout   = "20060102T150405Z"
+)
+
+// DateTime is a DATE or DATE-TIME value. Dates are midnight in the location they were read
+// in; floating times (no zone and no TZID) are read in the caller's default location.
+type DateTime struct {
+	Time     time.Time
+	Date     bool
+	Floating bool
+	// Zone the value was written in: the TZID, "UTC" for a trailing Z, "" when floating
+	TZID string
+}
+
+// DateTime reads the value of a DTSTART-like property. An unknown TZID falls back to def.
+func (p *Property) DateTime(def *time.Location) (DateTime, error) {
+	values, err := p.DateTimes(def)
+	if err != nil {
+		return DateTime{}, err
+	}
+	if len(values) != 1 {
+		return DateTime{}, ErrInvalid
+	}
+	return values[0], nil
+}
+
+// DateTimes reads a comma separated list of DATE or DATE-TIME values, as in EXDATE
+func (p *Property) DateTimes(def *time.Location) ([]DateTime, error) {
+	loc, tzid := def, ""
+	if name := p.Param("TZID"); name != "" {
+		if l := LoadLocation(name); l != nil {
+			loc, tzid = l, l.String()
+		}
+	}
+	date := strings.EqualFold(p.Param("VALUE"), "DATE")
+	var out []DateTime
+	for _, raw := range strings.Split(p.Value, ",") {
+		raw = strings.TrimSpace(raw)
+		var dt DateTime
+		var err error
+		switch {
+		case date || len(raw) == len(dateLayout):
+			dt.Date = true
+			dt.TZID = tzid
+			dt.Time, err = time.ParseInLocation(dateLayout, raw, loc)
+		case strings.HasSuffix(raw, "Z"):
+			dt.TZID = "UTC"
+			dt.Time, err = time.Parse(utcLayout, raw)
+		default:
+			dt.TZID = tzid
+			dt.Floating = tzid == ""
+			dt.Time, err = time.ParseInLocation(localLayout, raw, loc)
+		}
+		if err != nil {
+			return nil, ErrInvalid
+		}
+		out = append(out, dt)
+	}
+	return out, nil
+}
+
+// LoadLocation resolves a TZID. Besides IANA names it accepts the "/vendor/.../Area/City"
+// ids some clients write; it returns nil for anything else.
+func LoadLocation(tzid string) *time.Location {
+	tzid = strings.TrimSpace(tzid)
+	if tzid == "" || tzid == "Local" {
+		return nil
+	}
+	if loc, err := time.LoadLocation(tzid); err == nil {
+		return loc
+	}
+	if strings.HasPrefix(tzid, "/") {
+		parts := strings.Split(strings.Trim(tzid, "/"), "/")
+		for i := len(parts) - 2; i >= 0; i-- {
+			if loc, err := time.LoadLocation(strings.Join(parts[i:], "/")); err == nil {
+				return loc
+			}
+		}
+	}
+	return nil
+}
+
+// AddDate appends a VALUE=DATE property with the dates of ts in their own location
+func (c *Component) AddDate(name string, ts ...time.Time) {
+	values := make([]string, len(ts))
+	for i, t := range ts {
+		values[i] = t.Format(dateLayout)
+	}
+	c.Add(name, strings.Join(values, ","), "VALUE", "DATE")
+}
+
+// AddDateTime appends a DATE-TIME property: in UTC when tz is "UTC" or empty, otherwise as
+// local times with a TZID
+func (c *Component) AddDateTime(name string, tz string, ts ...time.Time) {
+	values := make([]string, len(ts))
+	loc := LoadLocation(tz)
+	if tz == "UTC" || loc == nil {
+		for i, t := range ts {
+			values[i] = t.UTC().Format(utcLayout)
+		}
+		c.Add(name, strings.Join(values, ","))
+		return
+	}
+	for i, t := range ts {
+		values[i] = t.In(loc).Format(localLayout)
+	}
+	c.Add(name, strings.Join(values, ","), "TZID", loc.String())
+}
+
+// AddUTC appends a DATE-TIME property in UTC, as DTSTAMP and LAST-MODIFIED require
+func (c *Component) AddUTC(name string, t time.Time) {
+	c.Add(name, t.UTC().Format(utcLayout))
+}
@@ -0,0 +1,403 @@
+// Package ical reads and writes the iCalendar format (RFC 5545) used by calendar feeds,
+// imports and CalDAV. It models a calendar as a tree of components holding properties and
+// leaves their meaning to the caller, apart from helpers for the TEXT, DATE, DATE-TIME and
+// DURATION value types. Time zones are named by TZID and resolved with the IANA database;
+// VTIMEZONE definitions are ignored when reading and generated from that database by
+// AddTimezones when writing.
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var ErrInvalid = errors.New("invalid iCalendar data")
+
+const (
+	// Lines are folded after this many octets, as RFC 5545 recommends
+	foldLength = 75
+	// Guards against absurdly nested or long input
+	maxDepth      = 8
+	maxLineLength = 1 << 20
+)
+
+// Property is a content line: a name, its parameters and its raw value. TEXT values are
+// kept escaped; use Text and AddText to read and write them.
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Param returns a parameter of the property, "" when it is missing
+func (p *Property) Param(name string) string {
+	return p.Params[strings.ToUpper(name)]
+}
+
+// Text returns the value unescaped as TEXT
+func (p *Property) Text() string {
+	if !strings.Contains(p.Value, `\`) {
+		return p.Value
+	}
+	var b strings.Builder
+	for i := 0; i < len(p.Value); i++ {
+		c := p.Value[i]
+		if c == '\\' && i+1 < len(p.Value) {
+			i++
+			switch p.Value[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(p.Value[i])
+			}
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// Component is a BEGIN/END block such as VCALENDAR, VEVENT or VTODO
+type Component struct {
+	Name       string
+	Properties []Property
+	Components []*Component
+}
+
+func NewComponent(name string) *Component {
+	return &Component{Name: strings.ToUpper(name)}
+}
+
+// Get returns the first property with the given name, or nil
+func (c *Component) Get(name string) *Property {
+	name = strings.ToUpper(name)
+	for i := range c.Properties {
+		if c.Properties[i].Name == name {
+			return &c.Properties[i]
+		}
+	}
+	return nil
+}
+
+// All returns every property with the given name
+func (c *Component) All(name string) []Property {
+	name = strings.ToUpper(name)
+	var out []Property
+	for _, p := range c.Properties {
+		if p.Name == name {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Text returns the unescaped TEXT value of the first property with the given name
+func (c *Component) Text(name string) string {
+	if p := c.Get(name); p != nil {
+		return p.Text()
+	}
+	return ""
+}
+
+// Add appends a property with a raw value; params are name/value pairs
+func (c *Component) Add(name, value string, params ...string) {
+	p := Property{Name: strings.ToUpper(name), Value: value}
+	if len(params) > 0 {
+		p.Params = map[string]string{}
+		for i := 0; i+1 < len(params); i += 2 {
+			p.Params[strings.ToUpper(params[i])] = params[i+1]
+		}
+	}
+	c.Properties = append(c.Properties, p)
+}
+
+// AddText appends a property with a TEXT value, escaping it
+func (c *Component) AddText(name, text string, params ...string) {
+	c.Add(name, EscapeText(text), params...)
+}
+
+// Append adds a child component
+func (c *Component) Append(child *Component) {
+	c.Components = append(c.Components, child)
+}
+
+// Children returns the child components with the given name
+func (c *Component) Children(name string) []*Component {
+	name = strings.ToUpper(name)
+	var out []*Component
+	for _, child := range c.Components {
+		if child.Name == name {
+			out = append(out, child)
+		}
+	}
+	return out
+}
+
+// EscapeText escapes a TEXT value
+func EscapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+// Decode reads the top-level components of a stream, usually one VCALENDAR
+func Decode(r io.Reader) ([]*Component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	var top []*Component
+	var stack []*Component
+	for n, line := range lines {
+		if line == "" {
+			continue
+		}
+		p, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalid, n+1, err)
+		}
+		switch p.Name {
+		case "BEGIN":
+			if len(stack) >= maxDepth {
+				return nil, fmt.Errorf("%w: components nested too deeply", ErrInvalid)
+			}
+			c := NewComponent(p.Value)
+			if len(stack) == 0 {
+				top = append(top, c)
+			} else {
+				stack[len(stack)-1].Append(c)
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(p.Value) {
+				return nil, fmt.Errorf("%w: line %d: unexpected END:%s", ErrInvalid, n+1, p.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("%w: line %d: property outside a component", ErrInvalid, n+1)
+			}
+			cur := stack[len(stack)-1]
+			cur.Properties = append(cur.Properties, p)
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%w: missing END:%s", ErrInvalid, stack[len(stack)-1].Name)
+	}
+	if len(top) == 0 {
+		return nil, fmt.Errorf("%w: no components", ErrInvalid)
+	}
+	return top, nil
+}
+
+// unfold splits the input in logical lines, joining continuation lines
+func unfold(r io.Reader) ([]string, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxLineLength)
+	var lines []string
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if len(lines) == 0 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			last := len(lines) - 1
+			if len(lines[last])+len(line) > maxLineLength {
+				return nil, fmt.Errorf("%w: line too long", ErrInvalid)
+			}
+			lines[last] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := sc.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, fmt.Errorf("%w: line too long", ErrInvalid)
+		}
+		return nil, err
+	}
+	return lines, nil
+}
+
+// parseLine reads NAME;PARAM=value;PARAM="quoted value":VALUE
+func parseLine(line string) (Property, error) {
+	p := Property{}
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return p, errors.New("missing value")
+	}
+	p.Name = strings.ToUpper(line[:i])
+	for line[i] == ';' {
+		line = line[i+1:]
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 {
+			return p, errors.New("bad parameter")
+		}
+		name := strings.ToUpper(line[:eq])
+		line = line[eq+1:]
+		var value string
+		if strings.HasPrefix(line, `"`) {
+			end := strings.IndexByte(line[1:], '"')
+			if end < 0 {
+				return p, errors.New("unterminated quoted parameter")
+			}
+			value = line[1 : end+1]
+			line = line[end+2:]
+			i = 0
+		} else {
+			i = strings.IndexAny(line, ";:")
+			if i < 0 {
+				return p, errors.New("missing value")
+			}
+			value = line[:i]
+		}
+		if len(line) == 0 || line[i] != ';' && line[i] != ':' {
+			return p, errors.New("bad parameter")
+		}
+		if p.Params == nil {
+			p.Params = map[string]string{}
+		}
+		p.Params[name] = value
+		if line[i] == ':' {
+			break
+		}
+	}
+	p.Value = line[i+1:]
+	return p, nil
+}
+
+// Encode writes a component and its children with CRLF line endings and folded lines
+func Encode(w io.Writer, c *Component) error {
+	bw := bufio.NewWriter(w)
+	encodeComponent(bw, c)
+	return bw.Flush()
+}
+
+func encodeComponent(w *bufio.Writer, c *Component) {
+	writeFolded(w, "BEGIN:"+c.Name)
+	for _, p := range c.Properties {
+		var b strings.Builder
+		b.WriteString(p.Name)
+		for _, name := range sortedKeys(p.Params) {
+			value := p.Params[name]
+			b.WriteString(";" + name + "=")
+			if strings.ContainsAny(value, ";:,") {
+				b.WriteString(`"` + strings.ReplaceAll(value, `"`, "") + `"`)
+			} else {
+				b.WriteString(value)
+			}
+		}
+		b.WriteString(":" + p.Value)
+		writeFolded(w, b.String())
+	}
+	for _, child := range c.Components {
+		encodeComponent(w, child)
+	}
+	writeFolded(w, "END:"+c.Name)
+}
+
+// writeFolded writes a line split in chunks of at most foldLength octets, never inside a
+// UTF-8 sequence
+func writeFolded(w *bufio.Writer, line string) {
+	limit := foldLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		// The leading space of a continuation counts towards its length
+		limit = foldLength - 1
+	}
+	w.WriteString(line + "\r\n")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ParseDuration reads a DURATION value such as PT1H30M, P1D or -P2W. Days and weeks count
+// as 24 hours.
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	sign := time.Duration(1)
+	if strings.HasPrefix(s, "-") {
+		sign, s = -1, s[1:]
+	} else {
+		s = strings.TrimPrefix(s, "+")
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, ErrInvalid
+	}
+	s = s[1:]
+	var d time.Duration
+	inTime := false
+	num := ""
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			num += string(r)
+			continue
+		case r == 'T':
+			if inTime || num != "" {
+				return 0, ErrInvalid
+			}
+			inTime = true
+			continue
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, ErrInvalid
+		}
+		num = ""
+		var unit time.Duration
+		switch {
+		case !inTime && r == 'W':
+			unit = 7 * 24 * time.Hour
+		case !inTime && r == 'D':
+			unit = 24 * time.Hour
+		case inTime && r == 'H':
+			unit = time.Hour
+		case inTime && r == 'M':
+			unit = time.Minute
+		case inTime && r == 'S':
+			unit = time.Second
+		default:
+			return 0, ErrInvalid
+		}
+		d += time.Duration(n) * unit
+	}
+	if num != "" {
+		return 0, ErrInvalid
+	}
+	return sign * d, nil
+}
+
+// FormatDuration writes a non-negative duration as a DURATION value
+func FormatDuration(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("P%dD", d/(24*time.Hour))
+	}
+	s := "PT"
+	if h := d / time.Hour; h > 0 {
+		s += fmt.Sprintf("%dH", h)
+	}
+	if m := d % time.Hour / time.Minute; m > 0 {
+		s += fmt.Sprintf("%dM", m)
+	}
+	if sec := d % time.Minute / time.Second; sec > 0 || s == "PT" {
+		s += fmt.Sprintf("%dS", sec)
+	}
+	return s
+}
@@ -0,0 +1,288 @@
+package ical
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+// decodeOne reads a single VCALENDAR wrapping the given lines
+func decodeOne(t *testing.T, lines ...string) *Component {
+	t.Helper()
+	data := "BEGIN:VCALENDAR\r\n" + strings.Join(lines, "\r\n") + "\r\nEND:VCALENDAR\r\n"
+	cals, err := Decode(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cals[0]
+}
+
+func TestFolding(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "short", value: "Lunch"},
+		{name: "exactly one line", value: strings.Repeat("a", foldLength-len("SUMMARY:"))},
+		{name: "one octet over", value: strings.Repeat("a", foldLength-len("SUMMARY:")+1)},
+		{name: "several lines", value: strings.Repeat("abcdefghij", 30)},
+		{name: "multi-byte runes across the fold", value: strings.Repeat("ñ", 100)},
+		{name: "four-byte runes", value: strings.Repeat("😀", 40)},
+		{name: "escaped text", value: EscapeText(strings.Repeat("a, b; c\n", 20))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cal := NewComponent("VCALENDAR")
+			cal.Add("SUMMARY", tt.value)
+			var buf bytes.Buffer
+			if err := Encode(&buf, cal); err != nil {
+				t.Fatal(err)
+			}
+			out := buf.String()
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("output does not end with CRLF: %q", out)
+			}
+			for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+				if len(line) > foldLength {
+					t.Errorf("line of %d octets: %q", len(line), line)
+				}
+				if !utf8Valid(line) {
+					t.Errorf("line splits a UTF-8 sequence: %q", line)
+				}
+			}
+			cals, err := Decode(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cals[0].Get("SUMMARY"); got == nil || got.Value != tt.value {
+				t.Errorf("round trip = %+v, want %q", got, tt.value)
+			}
+		})
+	}
+}
+
+func utf8Valid(s string) bool {
+	return strings.ToValidUTF8(s, "\uFFFD") == s
+}
+
+func TestUnfold(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "space continuation", data: "BEGIN:VEVENT\r\nSUMMARY:Team\r\n  meeting\r\nEND:VEVENT\r\n", want: "Team meeting"},
+		{name: "tab continuation", data: "BEGIN:VEVENT\r\nSUMMARY:Team\r\n\tmeeting\r\nEND:VEVENT\r\n", want: "Teammeeting"},
+		{name: "bare LF line endings", data: "BEGIN:VEVENT\nSUMMARY:Te\n am\nEND:VEVENT\n", want: "Team"},
+		{name: "byte order mark", data: "\ufeffBEGIN:VEVENT\r\nSUMMARY:Team\r\nEND:VEVENT\r\n", want: "Team"},
+		{name: "folded inside a rune", data: "BEGIN:VEVENT\r\nSUMMARY:Espa\xc3\r\n \xb1a\r\nEND:VEVENT\r\n", want: "España"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cals, err := Decode(strings.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cals[0].Text("SUMMARY"); got != tt.want {
+				t.Errorf("SUMMARY = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "empty", data: ""},
+		{name: "missing END", data: "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"},
+		{name: "mismatched END", data: "BEGIN:VCALENDAR\r\nEND:VEVENT\r\n"},
+		{name: "property outside a component", data: "VERSION:2.0\r\n"},
+		{name: "missing value", data: "BEGIN:VCALENDAR\r\nVERSION\r\nEND:VCALENDAR\r\n"},
+		{name: "unterminated quoted parameter", data: "BEGIN:VCALENDAR\r\nX-A;P=\"x:1\r\nEND:VCALENDAR\r\n"},
+		{name: "nested too deeply", data: strings.Repeat("BEGIN:X\r\n", maxDepth+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(strings.NewReader(tt.data)); !errors.Is(err, ErrInvalid) {
+				t.Errorf("Decode error = %v, want ErrInvalid", err)
+			}
+		})
+	}
+}
+
+func TestParams(t *testing.T) {
+	cal := decodeOne(t, `ATTENDEE;cn="Doe, Jane";ROLE=REQ-PARTICIPANT:mailto:jane@example.com`)
+	p := cal.Get("ATTENDEE")
+	if p.Param("CN") != "Doe, Jane" || p.Param("role") != "REQ-PARTICIPANT" || p.Value != "mailto:jane@example.com" {
+		t.Errorf("parsed %+v", p)
+	}
+	var buf bytes.Buffer
+	Encode(&buf, cal)
+	if want := `ATTENDEE;CN="Doe, Jane";ROLE=REQ-PARTICIPANT:mailto:jane@example.com`; !strings.Contains(buf.String(), want) {
+		t.Errorf("encoded %q, want a line %q", buf.String(), want)
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		escaped string
+	}{
+		{name: "plain", text: "Lunch", escaped: "Lunch"},
+		{name: "comma and semicolon", text: "Bread, milk; eggs", escaped: `Bread\, milk\; eggs`},
+		{name: "backslash", text: `C:\temp`, escaped: `C:\\temp`},
+		{name: "newlines", text: "one\ntwo\r\nthree\rfour", escaped: `one\ntwo\nthree\nfour`},
+		{name: "colon is not escaped", text: "10:30", escaped: "10:30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EscapeText(tt.text); got != tt.escaped {
+				t.Errorf("EscapeText(%q) = %q, want %q", tt.text, got, tt.escaped)
+			}
+			want := strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(tt.text)
+			p := Property{Value: tt.escaped}
+			if got := p.Text(); got != want {
+				t.Errorf("Text(%q) = %q, want %q", tt.escaped, got, want)
+			}
+		})
+	}
+	// Clients write \N for newlines too
+	p := Property{Value: `a\Nb\,c`}
+	if got := p.Text(); got != "a\nb,c" {
+		t.Errorf("Text = %q", got)
+	}
+}
+
+func TestDateTime(t *testing.T) {
+	madrid := mustLocation(t, "Europe/Madrid")
+	newYork := mustLocation(t, "America/New_York")
+	tests := []struct {
+		name     string
+		line     string
+		want     time.Time
+		date     bool
+		floating bool
+		tzid     string
+	}{
+		{
+			name: "utc",
+			line: "DTSTART:20250301T090000Z",
+			want: time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC),
+			tzid: "UTC",
+		},
+		{
+			name: "tzid",
+			line: "DTSTART;TZID=Europe/Madrid:20250701T090000",
+			want: time.Date(2025, 7, 1, 9, 0, 0, 0, madrid),
+			tzid: "Europe/Madrid",
+		},
+		{
+			name: "vendor tzid",
+			line: "DTSTART;TZID=/mozilla.org/20050126_1/America/New_York:20250115T083000",
+			want: time.Date(2025, 1, 15, 8, 30, 0, 0, newYork),
+			tzid: "America/New_York",
+		},
+		{
+			name:     "unknown tzid falls back to the default",
+			line:     "DTSTART;TZID=Mars/Olympus:20250301T090000",
+			want:     time.Date(2025, 3, 1, 9, 0, 0, 0, madrid),
+			floating: true,
+		},
+		{
+			name:     "floating",
+			line:     "DTSTART:20250301T090000",
+			want:     time.Date(2025, 3, 1, 9, 0, 0, 0, madrid),
+			floating: true,
+		},
+		{
+			name: "date",
+			line: "DTSTART;VALUE=DATE:20250301",
+			want: time.Date(2025, 3, 1, 0, 0, 0, 0, madrid),
+			date: true,
+		},
+		{
+			name: "date without VALUE",
+			line: "DTSTART:20250301",
+			want: time.Date(2025, 3, 1, 0, 0, 0, 0, madrid),
+			date: true,
+		},
+		{
+			name: "date with tzid",
+			line: "DTSTART;VALUE=DATE;TZID=America/New_York:20250301",
+			want: time.Date(2025, 3, 1, 0, 0, 0, 0, newYork),
+			date: true,
+			tzid: "America/New_York",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cal := decodeOne(t, tt.line)
+			got, err := cal.Get("DTSTART").DateTime(madrid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Time.Equal(tt.want) || got.Date != tt.date || got.Floating != tt.floating || got.TZID != tt.tzid {
+				t.Errorf("DateTime = %+v, want %v date=%v floating=%v tzid=%q", got, tt.want, tt.date, tt.floating, tt.tzid)
+			}
+		})
+	}
+}
+
+func TestDateTimes(t *testing.T) {
+	cal := decodeOne(t, "EXDATE;TZID=Europe/Madrid:20250301T090000, 20250308T090000")
+	got, err := cal.Get("EXDATE").DateTimes(time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Time.UTC().Hour() != 8 || got[1].Time.Day() != 8 {
+		t.Errorf("DateTimes = %+v", got)
+	}
+	for _, line := range []string{"DTSTART:2025-03-01", "DTSTART:20250301T0900", "DTSTART;VALUE=DATE:20250301T090000", "DTSTART:20250301T090000Z,20250302T090000Z"} {
+		if _, err := decodeOne(t, line).Get("DTSTART").DateTime(time.UTC); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: error = %v, want ErrInvalid", line, err)
+		}
+	}
+}
+
+func TestAddDateTime(t *testing.T) {
+	madrid := mustLocation(t, "Europe/Madrid")
+	ts := time.Date(2025, 7, 1, 9, 0, 0, 0, madrid)
+	tests := []struct {
+		name string
+		add  func(c *Component)
+		want string
+	}{
+		{name: "tzid", add: func(c *Component) { c.AddDateTime("DTSTART", "Europe/Madrid", ts) }, want: "DTSTART;TZID=Europe/Madrid:20250701T090000"},
+		{name: "utc", add: func(c *Component) { c.AddDateTime("DTSTART", "UTC", ts) }, want: "DTSTART:20250701T070000Z"},
+		{name: "unknown zone is written in utc", add: func(c *Component) { c.AddDateTime("DTSTART", "Local", ts) }, want: "DTSTART:20250701T070000Z"},
+		{name: "list", add: func(c *Component) { c.AddDateTime("EXDATE", "Europe/Madrid", ts, ts.AddDate(0, 0, 7)) }, want: "EXDATE;TZID=Europe/Madrid:20250701T090000,20250708T090000"},
+		{name: "date", add: func(c *Component) { c.AddDate("DTSTART", ts) }, want: "DTSTART;VALUE=DATE:20250701"},
+		{name: "stamp", add: func(c *Component) { c.AddUTC("DTSTAMP", ts) }, want: "DTSTAMP:20250701T070000Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewComponent("VEVENT")
+			tt.add(c)
+			var buf bytes.Buffer
+			Encode(&buf, c)
+			if lines := strings.Split(buf.String(), "\r\n"); lines[1] != tt.want {
+				t.Errorf("wrote %q, want %q", lines[1], tt.want)
+			}
+		})
+	}
+}
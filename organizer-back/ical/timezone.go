@@ -0,0 +1,238 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Offset changes are written as yearly rules when every year up to this many years from now
+// follows the first one, otherwise one by one up to then
+const timezoneYearsAhead = 10
+
+// AddTimezones inserts a VTIMEZONE for every TZID used by the properties of cal and its
+// children, as RFC 5545 requires of a calendar that references one. Each zone is described
+// from the earliest year written with it. TZIDs that already have a VTIMEZONE, or that
+// LoadLocation does not know, are left alone.
+func AddTimezones(cal *Component) {
+	first := map[string]int{}
+	var order []string
+	defined := map[string]bool{}
+	var walk func(c *Component)
+	walk = func(c *Component) {
+		if c.Name == "VTIMEZONE" {
+			defined[c.Text("TZID")] = true
+			return
+		}
+		for i := range c.Properties {
+			tzid := c.Properties[i].Param("TZID")
+			if tzid == "" {
+				continue
+			}
+			year := earliestYear(c.Properties[i].Value)
+			if y, ok := first[tzid]; !ok {
+				order = append(order, tzid)
+				first[tzid] = year
+			} else if year < y {
+				first[tzid] = year
+			}
+		}
+		for _, child := range c.Components {
+			walk(child)
+		}
+	}
+	walk(cal)
+	var zones []*Component
+	for _, tzid := range order {
+		if defined[tzid] {
+			continue
+		}
+		loc := LoadLocation(tzid)
+		if loc == nil || loc.String() != tzid {
+			continue
+		}
+		zones = append(zones, Timezone(loc, first[tzid]))
+	}
+	cal.Components = append(zones, cal.Components...)
+}
+
+// earliestYear reads the year of the earliest value in a comma separated DATE or DATE-TIME
+// list, the current year when there is none
+func earliestYear(value string) int {
+	year := 0
+	for start := 0; start <= len(value); {
+		end := start
+		for end < len(value) && value[end] != ',' {
+			end++
+		}
+		if end-start >= 4 {
+			if y, err := strconv.Atoi(value[start : start+4]); err == nil && (year == 0 || y < year) {
+				year = y
+			}
+		}
+		start = end + 1
+	}
+	if year == 0 {
+		year = time.Now().Year()
+	}
+	return year
+}
+
+// Timezone describes loc from the start of year on, or from 1970 for earlier years. Each
+// offset change of that year becomes a STANDARD or DAYLIGHT observance repeated by a yearly
+// BYMONTH/BYDAY rule; zones whose later changes do not follow such a rule get the offset at
+// the start of the year and one observance per change instead.
+func Timezone(loc *time.Location, year int) *Component {
+	year = max(year, 1970)
+	tz := NewComponent("VTIMEZONE")
+	tz.Add("TZID", loc.String())
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, loc)
+	end := time.Date(max(year, time.Now().Year())+timezoneYearsAhead+1, 1, 1, 0, 0, 0, 0, loc)
+	all := zoneTransitions(loc, start, end)
+	var firstYear []transition
+	for _, tr := range all {
+		if tr.wall().Year() == year {
+			firstYear = append(firstYear, tr)
+		}
+	}
+	if len(firstYear) > 0 && followsYearlyRules(firstYear, all) {
+		for _, tr := range firstYear {
+			obs := tr.observance()
+			obs.Add("RRULE", tr.rule())
+			tz.Append(obs)
+		}
+		return tz
+	}
+	// The offset in force at the start of the year, then every change up to the end
+	name, offset := start.Zone()
+	tz.Append(transition{at: start, from: offset, to: offset, name: name, dst: start.IsDST()}.observance())
+	for _, tr := range all {
+		tz.Append(tr.observance())
+	}
+	return tz
+}
+
+// transition is an instant at which a zone changes its UTC offset
+type transition struct {
+	at       time.Time
+	from, to int
+	name     string
+	dst      bool
+}
+
+// wall is the local time at which the transition happens on the clock in force before it,
+// which is how the DTSTART of an observance is written
+func (tr transition) wall() time.Time {
+	return tr.at.In(time.FixedZone("", tr.from))
+}
+
+func (tr transition) observance() *Component {
+	kind := "STANDARD"
+	if tr.dst {
+		kind = "DAYLIGHT"
+	}
+	obs := NewComponent(kind)
+	obs.Add("DTSTART", tr.wall().Format(localLayout))
+	obs.Add("TZOFFSETFROM", formatOffset(tr.from))
+	obs.Add("TZOFFSETTO", formatOffset(tr.to))
+	obs.Add("TZNAME", tr.name)
+	return obs
+}
+
+// rule is the yearly RRULE matching the weekday of the transition within its month: the
+// last one when the month has no later such weekday, its ordinal otherwise
+func (tr transition) rule() string {
+	month, nth, weekday := weekdayRule(tr.wall())
+	return fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", month, nth, weekdayCodes[weekday])
+}
+
+var weekdayCodes = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+func weekdayRule(t time.Time) (time.Month, int, time.Weekday) {
+	daysInMonth := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if t.Day()+7 > daysInMonth {
+		return t.Month(), -1, t.Weekday()
+	}
+	return t.Month(), (t.Day()-1)/7 + 1, t.Weekday()
+}
+
+// followsYearlyRules reports whether every later transition repeats one of the first year
+// on the same weekday rule, wall time and offsets, in the same order
+func followsYearlyRules(firstYear, all []transition) bool {
+	if len(all)%len(firstYear) != 0 {
+		return false
+	}
+	for i, tr := range all {
+		ref := firstYear[i%len(firstYear)]
+		if tr.from != ref.from || tr.to != ref.to || tr.dst != ref.dst || tr.name != ref.name {
+			return false
+		}
+		w, rw := tr.wall(), ref.wall()
+		if w.Year() != rw.Year()+i/len(firstYear) {
+			return false
+		}
+		m, n, d := weekdayRule(w)
+		rm, rn, rd := weekdayRule(rw)
+		if m != rm || n != rn || d != rd || w.Hour() != rw.Hour() || w.Minute() != rw.Minute() || w.Second() != rw.Second() {
+			return false
+		}
+	}
+	return true
+}
+
+var transitionCache sync.Map
+
+type transitionKey struct {
+	zone       string
+	start, end int64
+}
+
+// zoneTransitions lists the offset changes of loc in [start, end). It checks the offset once
+// a day and searches the exact second of each change, so zones changing twice within a day
+// are not described exactly.
+func zoneTransitions(loc *time.Location, start, end time.Time) []transition {
+	key := transitionKey{loc.String(), start.Unix(), end.Unix()}
+	if cached, ok := transitionCache.Load(key); ok {
+		return cached.([]transition)
+	}
+	var out []transition
+	_, offset := start.Zone()
+	for t := start; t.Before(end); {
+		next := t.Add(24 * time.Hour)
+		if next.After(end) {
+			next = end
+		}
+		if _, o := next.In(loc).Zone(); o != offset {
+			lo, hi := t.Unix(), next.Unix()
+			for hi-lo > 1 {
+				mid := lo + (hi-lo)/2
+				if _, o := time.Unix(mid, 0).In(loc).Zone(); o == offset {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+			at := time.Unix(hi, 0).In(loc)
+			name, to := at.Zone()
+			out = append(out, transition{at: at, from: offset, to: to, name: name, dst: at.IsDST()})
+			offset = to
+		}
+		t = next
+	}
+	transitionCache.Store(key, out)
+	return out
+}
+
+// formatOffset writes a UTC-OFFSET value such as +0100 or -0330
+func formatOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign, seconds = "-", -seconds
+	}
+	s := fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds/60%60)
+	if seconds%60 != 0 {
+		s += fmt.Sprintf("%02d", seconds%60)
+	}
+	return s
+}
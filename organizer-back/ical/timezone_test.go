@@ -0,0 +1,131 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// observances summarizes the children of a VTIMEZONE as "KIND DTSTART FROM>TO RRULE"
+func observances(tz *Component) []string {
+	var out []string
+	for _, obs := range tz.Components {
+		s := obs.Name + " " + obs.Text("DTSTART") + " " + obs.Text("TZOFFSETFROM") + ">" + obs.Text("TZOFFSETTO")
+		if rule := obs.Text("RRULE"); rule != "" {
+			s += " " + rule
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func TestTimezone(t *testing.T) {
+	tests := []struct {
+		zone string
+		year int
+		want []string
+	}{
+		{
+			zone: "Europe/Madrid",
+			year: 2025,
+			want: []string{
+				"DAYLIGHT 20250330T020000 +0100>+0200 FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU",
+				"STANDARD 20251026T030000 +0200>+0100 FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU",
+			},
+		},
+		{
+			zone: "America/New_York",
+			year: 2024,
+			want: []string{
+				"DAYLIGHT 20240310T020000 -0500>-0400 FREQ=YEARLY;BYMONTH=3;BYDAY=2SU",
+				"STANDARD 20241103T020000 -0400>-0500 FREQ=YEARLY;BYMONTH=11;BYDAY=1SU",
+			},
+		},
+		{
+			zone: "Australia/Sydney",
+			year: 2025,
+			want: []string{
+				"STANDARD 20250406T030000 +1100>+1000 FREQ=YEARLY;BYMONTH=4;BYDAY=1SU",
+				"DAYLIGHT 20251005T020000 +1000>+1100 FREQ=YEARLY;BYMONTH=10;BYDAY=1SU",
+			},
+		},
+		{
+			zone: "Asia/Kolkata",
+			year: 2025,
+			want: []string{"STANDARD 20250101T000000 +0530>+0530"},
+		},
+		{
+			zone: "UTC",
+			year: 2025,
+			want: []string{"STANDARD 20250101T000000 +0000>+0000"},
+		},
+		{
+			// Brazil stopped observing daylight saving time in 2019
+			zone: "America/Sao_Paulo",
+			year: 2018,
+			want: []string{
+				"DAYLIGHT 20180101T000000 -0200>-0200",
+				"STANDARD 20180218T000000 -0200>-0300",
+				"DAYLIGHT 20181104T000000 -0300>-0200",
+				"STANDARD 20190217T000000 -0200>-0300",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.zone, func(t *testing.T) {
+			tz := Timezone(mustLocation(t, tt.zone), tt.year)
+			if got := tz.Text("TZID"); got != tt.zone {
+				t.Errorf("TZID = %q", got)
+			}
+			if got := observances(tz); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("observances:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestAddTimezones(t *testing.T) {
+	madrid := mustLocation(t, "Europe/Madrid")
+	cal := NewComponent("VCALENDAR")
+	cal.Add("VERSION", "2.0")
+	ev := NewComponent("VEVENT")
+	ev.AddDateTime("DTSTART", "Europe/Madrid", time.Date(2025, 7, 1, 9, 0, 0, 0, madrid))
+	ev.AddDateTime("EXDATE", "Europe/Madrid", time.Date(2023, 7, 8, 9, 0, 0, 0, madrid))
+	cal.Append(ev)
+	other := NewComponent("VEVENT")
+	other.AddDateTime("DTSTART", "UTC", time.Date(2025, 7, 1, 9, 0, 0, 0, time.UTC))
+	other.AddDateTime("DUE", "America/New_York", time.Date(2025, 7, 1, 9, 0, 0, 0, time.UTC))
+	other.Add("X-UNKNOWN", "20250701T090000", "TZID", "Mars/Olympus")
+	cal.Append(other)
+
+	AddTimezones(cal)
+	AddTimezones(cal)
+	var zones []string
+	for _, c := range cal.Components {
+		if c.Name == "VTIMEZONE" {
+			zones = append(zones, c.Text("TZID"))
+		}
+	}
+	if strings.Join(zones, ",") != "Europe/Madrid,America/New_York" {
+		t.Fatalf("VTIMEZONEs = %v", zones)
+	}
+	if got := cal.Children("VTIMEZONE")[0].Components[0].Text("DTSTART"); !strings.HasPrefix(got, "2023") {
+		t.Errorf("Madrid is described from %s, want the year of the earliest EXDATE", got)
+	}
+
+	// The definitions come before the components that use them and survive a round trip
+	var buf bytes.Buffer
+	Encode(&buf, cal)
+	out := buf.String()
+	if strings.Index(out, "BEGIN:VTIMEZONE") > strings.Index(out, "BEGIN:VEVENT") {
+		t.Errorf("VTIMEZONE written after VEVENT:\n%s", out)
+	}
+	cals, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(cals[0].Children("VTIMEZONE")); n != 2 {
+		t.Errorf("decoded %d VTIMEZONEs", n)
+	}
+}
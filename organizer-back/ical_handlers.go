@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"organizer-back/ical"
+	"organizer-back/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Largest .ics file accepted by POST /events/import
+const maxICalImportBytes = 5 << 20
+
+// registerICalRoutes wires the secret subscription feed, its management and .ics imports. The
+// feed URL is a credential, so managing it needs a session token with a valid signature.
+func registerICalRoutes(r *gin.Engine, api *gin.RouterGroup, icalService *services.ICalService, usersService *services.UsersService, authService *services.AuthService) {
+	api.GET("/ical/feed", func(c *gin.Context) {
+		userID := verifiedUserIDFromAuthHeader(authService, c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		feed, err := icalService.Feed(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, feed)
+	})
+
+	// Creates the feed or rotates its token; the URL is only shown in this response
+	api.POST("/ical/feed", func(c *gin.Context) {
+		userID := verifiedUserIDFromAuthHeader(authService, c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		feed, err := icalService.RotateFeed(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, feed)
+	})
+
+	api.DELETE("/ical/feed", func(c *gin.Context) {
+		userID := verifiedUserIDFromAuthHeader(authService, c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		if err := icalService.DeleteFeed(userID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+	})
+
+	// Accepts a multipart "file" field or the .ics as the request body
+	api.POST("/events/import", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		now, ok := requestNow(c, usersService, userID)
+		if !ok {
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxICalImportBytes+1<<20)
+		var src io.Reader
+		if strings.HasPrefix(c.ContentType(), "multipart/") {
+			fh, err := c.FormFile("file")
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+					return
+				}
+				c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+				return
+			}
+			if fh.Size > maxICalImportBytes {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+				return
+			}
+			f, err := fh.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			defer f.Close()
+			src = f
+		} else {
+			body, err := io.ReadAll(c.Request.Body)
+			if err != nil || len(body) > maxICalImportBytes {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file too large"})
+				return
+			}
+			src = bytes.NewReader(body)
+		}
+
+		res, err := icalService.Import(userID, src, now.Location().String())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, res)
+	})
+
+	// Calendar apps subscribe to /ical/<token>.ics without any other credentials
+	r.GET("/ical/:file", func(c *gin.Context) {
+		token, ok := strings.CutSuffix(c.Param("file"), ".ics")
+		if !ok || token == "" {
+			c.String(http.StatusNotFound, "not found")
+			return
+		}
+		cal, err := icalService.FeedCalendar(token)
+		if err != nil {
+			if err.Error() == "calendar feed not found" {
+				c.String(http.StatusNotFound, "not found")
+				return
+			}
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Header("Content-Type", "text/calendar; charset=utf-8")
+		c.Header("Content-Disposition", `inline; filename="organizer.ics"`)
+		c.Header("Cache-Control", "private, no-cache")
+		c.Header("X-Robots-Tag", "noindex")
+		c.Status(http.StatusOK)
+		ical.Encode(c.Writer, cal)
+	})
+}
@@ -0,0 +1,86 @@
+package keyring
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+// GrantStore keeps unlocked data keys in memory behind short-lived opaque tokens. Grants are
+// lost on restart and are not shared between instances; clients simply unlock again.
+type GrantStore struct {
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+type grant struct {
+	userID    int
+	key       []byte
+	expiresAt time.Time
+}
+
+func NewGrantStore() *GrantStore {
+	return &GrantStore{grants: map[string]grant{}}
+}
+
+// Issue stores key for userID and returns the token that unlocks it until the expiry
+func (s *GrantStore) Issue(userID int, key []byte, ttl time.Duration) (string, time.Time, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	expiresAt := time.Now().Add(ttl)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep()
+	s.grants[token] = grant{userID: userID, key: key, expiresAt: expiresAt}
+	return token, expiresAt, nil
+}
+
+// Lookup returns the data key behind token if it belongs to userID and has not expired
+func (s *GrantStore) Lookup(userID int, token string) ([]byte, bool) {
+	if token == "" {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.grants[token]
+	if !ok || g.userID != userID {
+		return nil, false
+	}
+	if time.Now().After(g.expiresAt) {
+		delete(s.grants, token)
+		return nil, false
+	}
+	return g.key, true
+}
+
+// Revoke forgets a grant, e.g. when the user locks their notes again
+func (s *GrantStore) Revoke(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.grants, token)
+}
+
+// RevokeUser forgets every grant of a user, e.g. after a passphrase change
+func (s *GrantStore) RevokeUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token, g := range s.grants {
+		if g.userID == userID {
+			delete(s.grants, token)
+		}
+	}
+}
+
+func (s *GrantStore) sweep() {
+	now := time.Now()
+	for token, g := range s.grants {
+		if now.After(g.expiresAt) {
+			delete(s.grants, token)
+		}
+	}
+}
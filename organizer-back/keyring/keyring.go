@@ -0,0 +1,122 @@
+// Package keyring implements envelope encryption for note content. Each user has a random
+// data key that encrypts their notes; the data key is stored wrapped by a key-encryption key
+// (KEK) derived from the user's passphrase with Argon2id, or by a server-wide KEK.
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const KeySize = 32 // AES-256
+
+// ErrWrongKey is returned when a wrapped key or sealed content cannot be authenticated,
+// typically because the passphrase is wrong
+var ErrWrongKey = errors.New("wrong key")
+
+// KDFParams are the Argon2id cost parameters, stored with each wrapped key so they can be
+// raised later without breaking existing users
+type KDFParams struct {
+	Time      uint32 `json:"time"`
+	MemoryKiB uint32 `json:"memory_kib"`
+	Threads   uint8  `json:"threads"`
+}
+
+var DefaultKDF = KDFParams{Time: 3, MemoryKiB: 64 * 1024, Threads: 2}
+
+var wrapAAD = []byte("organizer:data-key:v1")
+
+// NewKey returns a random key, used for data keys
+func NewKey() ([]byte, error) {
+	return randomBytes(KeySize)
+}
+
+// NewSalt returns a random salt for DeriveKEK
+func NewSalt() ([]byte, error) {
+	return randomBytes(16)
+}
+
+// DeriveKEK turns a passphrase into a key-encryption key
+func DeriveKEK(passphrase string, salt []byte, p KDFParams) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, p.Time, p.MemoryKiB, p.Threads, KeySize)
+}
+
+// Wrap encrypts a data key with a KEK
+func Wrap(kek, dataKey []byte) ([]byte, error) {
+	return Seal(kek, dataKey, wrapAAD)
+}
+
+// Unwrap decrypts a data key; ErrWrongKey means the KEK does not match
+func Unwrap(kek, wrapped []byte) ([]byte, error) {
+	return Open(kek, wrapped, wrapAAD)
+}
+
+// Seal encrypts plaintext with AES-GCM; the random nonce is prepended to the result. aad
+// binds the ciphertext to its context (e.g. the owner) so it cannot be moved elsewhere.
+func Seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomBytes(gcm.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// Open reverses Seal
+func Open(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrWrongKey
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], aad)
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	return plaintext, nil
+}
+
+// ServerKEKFromEnv reads the server-wide KEK from NOTES_KEK (32 bytes, base64). It returns
+// nil when unset, which disables the server-managed mode.
+func ServerKEKFromEnv() ([]byte, error) {
+	v := os.Getenv("NOTES_KEK")
+	if v == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		return nil, fmt.Errorf("NOTES_KEK must be base64: %v", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("NOTES_KEK must decode to %d bytes", KeySize)
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
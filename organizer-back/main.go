@@ -6,10 +6,14 @@ import (
 	"net/http"
 	"organizer-back/database"
 	"organizer-back/models"
+	"organizer-back/realtime"
 	"organizer-back/services"
+	"organizer-back/storage"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
+	_ "time/tzdata" // user timezones must resolve even without system zoneinfo
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -34,18 +38,53 @@ func main() {
 	}
 	defer db.CloseDB()
 
+	blobStore, err := storage.NewBlobStoreFromEnv()
+	if err != nil {
+		log.Fatal("Failed to initialize blob store:", err)
+	}
+
 	// Initialize services
 	authService := services.NewAuthService()
 	usersService := services.NewUsersService()
-	notesService := services.NewNotesService()
+	attachmentsService := services.NewAttachmentsService(blobStore)
+	encryptionService, err := services.NewEncryptionService()
+	if err != nil {
+		log.Fatal("Failed to initialize note encryption:", err)
+	}
+	templatesService := services.NewTemplatesService()
+	notesService := services.NewNotesService(attachmentsService, templatesService, encryptionService)
+	publicLinksService := services.NewPublicLinksService()
+	importService := services.NewImportService(blobStore, encryptionService)
+	statsService := services.NewStatsService()
+	todosService := services.NewTodosService()
+	projectsService := services.NewProjectsService()
+	boardsService := services.NewBoardsService()
+	emotionsService := services.NewEmotionsService()
+	habitsService := services.NewHabitsService()
+	eventsService := services.NewEventsService()
+	icalService := services.NewICalService()
+	accessTokensService := services.NewAccessTokensService()
+	caldavService := services.NewCalDAVService()
+	preferencesService := services.NewPreferencesService()
+	broker := realtime.NewBroker(database.DB, database.ConnInfo)
+	remindersService := services.NewRemindersService(broker)
+	pomodorosService := services.NewPomodorosService(broker, preferencesService)
 
-	r := gin.Default()
+	// Pick up imports interrupted by a restart
+	importService.ResumePending()
+	statsService.RefreshRollupPeriodically()
+	remindersService.StartScheduler()
+	pomodorosService.StartScheduler()
+
+	// gin.Default with a logger that keeps query string tokens out of the access log
+	r := gin.New()
+	r.Use(gin.LoggerWithFormatter(accessLogFormatter), gin.Recovery())
 
 	// CORS for Angular dev server (adjust origin/ports if needed)
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:4200", "http://127.0.0.1:4200"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Decryption-Grant", "X-Timezone"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 	}))
@@ -141,11 +180,15 @@ func main() {
 			}
 			date := c.Query("date")
 			if date == "" {
-				// default to today in YYYY-MM-DD
-				date = timeNow().Format("2006-01-02")
+				// default to the caller's today in YYYY-MM-DD
+				now, ok := requestNow(c, usersService, userID)
+				if !ok {
+					return
+				}
+				date = now.Format("2006-01-02")
 			}
 			includeHidden := c.Query("include_hidden") == "true"
-			notes, err := notesService.ListByUserAndDate(userID, date, includeHidden)
+			notes, err := notesService.ListByUserAndDate(userID, date, includeHidden, c.Query("sort"), c.GetHeader("X-Decryption-Grant"))
 			if err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
@@ -153,6 +196,35 @@ func main() {
 			c.JSON(http.StatusOK, notes)
 		})
 
+		api.GET("/notes/calendar", func(c *gin.Context) {
+			userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+			if userID == 0 {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+				return
+			}
+			var q models.NoteCalendarQuery
+			if err := c.ShouldBindQuery(&q); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year or month"})
+				return
+			}
+			now, ok := requestNow(c, usersService, userID)
+			if !ok {
+				return
+			}
+			if q.Year == 0 {
+				q.Year = now.Year()
+			}
+			if q.Month == 0 {
+				q.Month = int(now.Month())
+			}
+			calendar, err := notesService.Calendar(userID, q.Year, q.Month, q.IncludeMood)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, calendar)
+		})
+
 		api.POST("/notes", func(c *gin.Context) {
 			userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
 			if userID == 0 {
@@ -164,9 +236,9 @@ func main() {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
 				return
 			}
-			note, err := notesService.Create(userID, &req)
+			note, err := notesService.Create(userID, &req, c.GetHeader("X-Decryption-Grant"))
 			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				c.JSON(encryptionErrorStatus(err, http.StatusBadRequest), gin.H{"error": err.Error()})
 				return
 			}
 			c.JSON(http.StatusCreated, note)
@@ -184,7 +256,7 @@ func main() {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 				return
 			}
-			note, err := notesService.Get(userID, id)
+			note, err := notesService.Get(userID, id, c.GetHeader("X-Decryption-Grant"))
 			if err != nil {
 				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 				return
@@ -209,9 +281,13 @@ func main() {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
 				return
 			}
-			note, err := notesService.Update(userID, id, &req)
+			note, err := notesService.Update(userID, id, &req, c.GetHeader("X-Decryption-Grant"))
 			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				status := encryptionErrorStatus(err, http.StatusBadRequest)
+				if err.Error() == "forbidden" {
+					status = http.StatusForbidden
+				}
+				c.JSON(status, gin.H{"error": err.Error()})
 				return
 			}
 			c.JSON(http.StatusOK, note)
@@ -230,11 +306,72 @@ func main() {
 				return
 			}
 			if err := notesService.Delete(userID, id); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				status := http.StatusBadRequest
+				if err.Error() == "forbidden" {
+					status = http.StatusForbidden
+				}
+				c.JSON(status, gin.H{"error": err.Error()})
 				return
 			}
 			c.JSON(http.StatusOK, gin.H{"message": "deleted"})
 		})
+
+		api.POST("/notes/:id/move", func(c *gin.Context) {
+			userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+			if userID == 0 {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+				return
+			}
+			id, err := strconv.Atoi(c.Param("id"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+				return
+			}
+			var req models.NoteMoveRequest
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+				return
+			}
+			note, err := notesService.Move(userID, id, &req, c.GetHeader("X-Decryption-Grant"))
+			if err != nil {
+				status := http.StatusBadRequest
+				switch err.Error() {
+				case "note not found", "target note not found":
+					status = http.StatusNotFound
+				case "target note changed, retry":
+					status = http.StatusConflict
+				}
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, note)
+		})
+
+		registerNoteShareRoutes(api, notesService)
+		registerNoteBulkRoutes(api, notesService)
+		registerNoteLinkRoutes(api, notesService)
+		registerNoteTemplateRoutes(api, templatesService, usersService)
+		registerEncryptionRoutes(api, encryptionService, authService)
+		registerNoteExportRoutes(api, notesService)
+		registerNoteImportRoutes(api, importService)
+		registerStatsRoutes(api, statsService, usersService)
+		registerTimezoneRoutes(api, usersService)
+		registerTodoRoutes(api, todosService, usersService)
+		registerProjectRoutes(api, projectsService, usersService)
+		registerBoardRoutes(api, boardsService, usersService)
+		registerEmotionRoutes(api, emotionsService, usersService)
+		registerHabitRoutes(api, habitsService, usersService)
+		registerPomodoroRoutes(api, pomodorosService, usersService)
+		registerPreferenceRoutes(api, preferencesService)
+		registerReminderRoutes(api, remindersService, usersService)
+		registerEventRoutes(api, broker)
+		registerCalendarEventRoutes(api, eventsService, usersService)
+		registerICalRoutes(r, api, icalService, usersService, authService)
+		registerAccessTokenRoutes(api, accessTokensService, authService)
+		registerCalDAVRoutes(r, caldavService, accessTokensService)
+		registerPublicLinkRoutes(r, api, publicLinksService, authService)
+
+		registerAttachmentRoutes(api, attachmentsService)
 	}
 
 	r.Run(":8080")
@@ -311,6 +448,40 @@ func extractRoleFromAuthHeader(header string) string {
 	return ""
 }
 
+// accessLogSecrets match the bearer credentials that travel in URLs: the token the event
+// stream accepts in its query string, the iCalendar feed token and the public link token
+var accessLogSecrets = []*regexp.Regexp{
+	regexp.MustCompile(`([?&]access_token=)[^&]*`),
+	regexp.MustCompile(`^(/ical/)[^/?]+`),
+	regexp.MustCompile(`^(/p/)[^/?]+`),
+}
+
+// accessLogFormatter is gin's default access log line with URL credentials redacted
+func accessLogFormatter(param gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	path := param.Path
+	for _, secret := range accessLogSecrets {
+		path = secret.ReplaceAllString(path, "${1}REDACTED")
+	}
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		path,
+		param.ErrorMessage,
+	)
+}
+
 // extractUserIDFromAuthHeader reads the Bearer token without verifying signature and extracts user_id claim
 func extractUserIDFromAuthHeader(header string) int {
 	if header == "" {
@@ -338,5 +509,19 @@ func extractUserIDFromAuthHeader(header string) int {
 	return 0
 }
 
+// verifiedUserIDFromAuthHeader is extractUserIDFromAuthHeader with the token signature and
+// expiry checked, for requests that issue or manage other credentials
+func verifiedUserIDFromAuthHeader(authService *services.AuthService, header string) int {
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 {
+		return 0
+	}
+	userID, err := authService.VerifyToken(parts[1])
+	if err != nil {
+		return 0
+	}
+	return userID
+}
+
 // timeNow is a seam for testing
 var timeNow = func() time.Time { return time.Now() }
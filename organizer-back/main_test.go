@@ -0,0 +1,54 @@
+package main
+
+import (
+	"organizer-back/models"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+func TestAccessLogFormatterRedactsTokens(t *testing.T) {
+	tests := []struct {
+		path, want, secret string
+	}{
+		{"/api/v1/events/stream?access_token=eyJ.abc.def", `"/api/v1/events/stream?access_token=REDACTED"`, "eyJ"},
+		{"/api/v1/events/stream?x=1&access_token=eyJ.abc&y=2", `"/api/v1/events/stream?x=1&access_token=REDACTED&y=2"`, "eyJ"},
+		{"/ical/s3cr3tFeedToken.ics", `"/ical/REDACTED"`, "s3cr3t"},
+		{"/p/s3cr3tLinkToken", `"/p/REDACTED"`, "s3cr3t"},
+		{"/p/s3cr3tLinkToken?raw=1", `"/p/REDACTED?raw=1"`, "s3cr3t"},
+		{"/api/v1/notes?date=2025-03-01", `"/api/v1/notes?date=2025-03-01"`, ""},
+	}
+	for _, tt := range tests {
+		line := accessLogFormatter(gin.LogFormatterParams{Method: "GET", StatusCode: 200, Path: tt.path})
+		if !strings.Contains(line, tt.want) || (tt.secret != "" && strings.Contains(line, tt.secret)) {
+			t.Errorf("log line for %s = %q, want it to contain %s", tt.path, line, tt.want)
+		}
+	}
+}
+
+// The timezone binding must refuse what loadTimezone refuses, so a bad zone is a 400 from
+// binding rather than a service error
+func TestTimezoneBinding(t *testing.T) {
+	requests := map[string]func() any{
+		"user create": func() any { return &models.UserCreateRequest{} },
+		"user update": func() any { return &models.UserUpdateRequest{} },
+		"timezone":    func() any { return &models.UserTimezoneRequest{} },
+		"event":       func() any { return &models.EventRequest{} },
+		"reminder":    func() any { return &models.ReminderRequest{} },
+	}
+	base := `"first_name":"a","last_name":"b","email":"a@example.com","username":"ab","password":"secret","title":"t","starts_at":"2025-01-01T10:00"`
+	for name, newReq := range requests {
+		for _, tz := range []string{"Local", "local", "Mars/Olympus", "../etc/passwd"} {
+			body := `{` + base + `,"timezone":"` + tz + `"}`
+			if err := binding.JSON.BindBody([]byte(body), newReq()); err == nil {
+				t.Errorf("%s: timezone %q accepted", name, tz)
+			}
+		}
+		body := `{` + base + `,"timezone":"Europe/Madrid"}`
+		if err := binding.JSON.BindBody([]byte(body), newReq()); err != nil {
+			t.Errorf("%s: Europe/Madrid rejected: %v", name, err)
+		}
+	}
+}
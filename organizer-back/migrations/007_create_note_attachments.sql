@@ -0,0 +1,20 @@
+-- Migration: 007_create_note_attachments.sql
+-- Description: Store file attachments metadata for notes and per-user storage quotas
+
+CREATE TABLE IF NOT EXISTS note_attachments (
+    id SERIAL PRIMARY KEY,
+    note_id INTEGER NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    file_name VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size_bytes BIGINT NOT NULL CHECK (size_bytes >= 0),
+    storage_key VARCHAR(512) UNIQUE NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_note_attachments_note ON note_attachments(note_id);
+CREATE INDEX IF NOT EXISTS idx_note_attachments_user ON note_attachments(user_id);
+
+-- NULL means the user gets the server default quota
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS attachment_quota_bytes BIGINT;
@@ -0,0 +1,33 @@
+-- Migration: 008_create_note_shares_and_audit_log.sql
+-- Description: Allow owners to share notes with other users and keep an audit trail
+
+CREATE TABLE IF NOT EXISTS note_shares (
+    note_id INTEGER NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    permission VARCHAR(10) NOT NULL CHECK (permission IN ('viewer', 'editor')),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (note_id, user_id)
+);
+
+-- Lookups for "shared with me"
+CREATE INDEX IF NOT EXISTS idx_note_shares_user ON note_shares(user_id);
+
+DROP TRIGGER IF EXISTS set_timestamp_on_note_shares ON note_shares;
+CREATE TRIGGER set_timestamp_on_note_shares
+BEFORE UPDATE ON note_shares
+FOR EACH ROW
+EXECUTE PROCEDURE update_updated_at_column();
+
+-- Generic audit trail; actor may be NULL once the user is deleted
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    action VARCHAR(64) NOT NULL,
+    entity_type VARCHAR(32) NOT NULL,
+    entity_id INTEGER NOT NULL,
+    details JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity_type, entity_id, created_at);
@@ -0,0 +1,17 @@
+-- Migration: 009_create_note_public_links.sql
+-- Description: Public read-only links to notes (tokens are stored hashed)
+
+CREATE TABLE IF NOT EXISTS note_public_links (
+    id SERIAL PRIMARY KEY,
+    note_id INTEGER NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    password_hash VARCHAR(255),
+    hidden_confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    last_accessed_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_note_public_links_note ON note_public_links(note_id);
@@ -0,0 +1,8 @@
+-- Migration: 010_add_tags_to_notes.sql
+-- Description: Free-form tags on notes (lowercase, no leading '#')
+
+ALTER TABLE notes
+  ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+-- Containment queries (tags @> ARRAY['x']) for filters and stats
+CREATE INDEX IF NOT EXISTS idx_notes_tags ON notes USING GIN (tags);
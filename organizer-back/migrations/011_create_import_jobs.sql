@@ -0,0 +1,53 @@
+-- Migration: 011_create_import_jobs.sql
+-- Description: Resumable note import jobs with a per-item report and source tracking for dedupe
+
+CREATE TABLE IF NOT EXISTS import_jobs (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(16) NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'completed', 'failed')),
+    format VARCHAR(16) NOT NULL CHECK (format IN ('markdown', 'json', 'dayone', 'obsidian')),
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    duplicate_strategy VARCHAR(16) NOT NULL DEFAULT 'skip' CHECK (duplicate_strategy IN ('skip', 'overwrite', 'keep_both')),
+    file_name VARCHAR(255) NOT NULL,
+    storage_key VARCHAR(512) NOT NULL,
+    -- Items before this index are done; a resumed job continues from here
+    processed_items INTEGER NOT NULL DEFAULT 0,
+    created_count INTEGER NOT NULL DEFAULT 0,
+    overwritten_count INTEGER NOT NULL DEFAULT 0,
+    skipped_count INTEGER NOT NULL DEFAULT 0,
+    failed_count INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    heartbeat_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_jobs_user ON import_jobs(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_import_jobs_status ON import_jobs(status);
+
+DROP TRIGGER IF EXISTS set_timestamp_on_import_jobs ON import_jobs;
+CREATE TRIGGER set_timestamp_on_import_jobs
+BEFORE UPDATE ON import_jobs
+FOR EACH ROW
+EXECUTE PROCEDURE update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS import_job_items (
+    job_id INTEGER NOT NULL REFERENCES import_jobs(id) ON DELETE CASCADE,
+    item_index INTEGER NOT NULL,
+    source TEXT NOT NULL,
+    note_date DATE,
+    action VARCHAR(16) NOT NULL CHECK (action IN ('created', 'overwritten', 'skipped', 'failed')),
+    note_id INTEGER REFERENCES notes(id) ON DELETE SET NULL,
+    message TEXT,
+    PRIMARY KEY (job_id, item_index)
+);
+
+-- Remembers which source item produced which note so re-imports are recognized
+CREATE TABLE IF NOT EXISTS note_import_sources (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source_key TEXT NOT NULL,
+    note_id INTEGER NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, source_key)
+);
@@ -0,0 +1,19 @@
+-- Migration: 012_add_position_to_notes.sql
+-- Description: Manual ordering of notes within a day using lexicographic ranks (see package rank)
+
+-- COLLATE "C" compares bytes, which is the order ranks are generated in
+ALTER TABLE notes
+  ADD COLUMN IF NOT EXISTS position TEXT COLLATE "C";
+
+-- Existing notes keep their creation order. Ranks must not end in '0', hence the 'V' suffix.
+UPDATE notes n SET position = r.position
+FROM (
+  SELECT id, 'a' || lpad(row_number() OVER (PARTITION BY user_id, note_date ORDER BY created_at, id)::text, 6, '0') || 'V' AS position
+  FROM notes
+) r
+WHERE n.id = r.id AND n.position IS NULL;
+
+ALTER TABLE notes ALTER COLUMN position SET NOT NULL;
+
+-- Two writers can never end up with the same rank on the same day
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notes_user_date_position ON notes(user_id, note_date, position);
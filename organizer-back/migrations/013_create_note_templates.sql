@@ -0,0 +1,33 @@
+-- Migration: 013_create_note_templates.sql
+-- Description: User-defined note templates and optional auto-created daily notes
+
+CREATE TABLE IF NOT EXISTS note_templates (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
+
+DROP TRIGGER IF EXISTS set_timestamp_on_note_templates ON note_templates;
+CREATE TRIGGER set_timestamp_on_note_templates
+BEFORE UPDATE ON note_templates
+FOR EACH ROW
+EXECUTE PROCEDURE update_updated_at_column();
+
+-- Template materialized on days without notes; only days from daily_template_since on qualify
+ALTER TABLE users
+  ADD COLUMN IF NOT EXISTS daily_template_id INTEGER REFERENCES note_templates(id) ON DELETE SET NULL,
+  ADD COLUMN IF NOT EXISTS daily_template_since DATE;
+
+-- One row per day the daily template was considered, so a note the user deletes is not recreated
+CREATE TABLE IF NOT EXISTS daily_note_materializations (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    note_date DATE NOT NULL,
+    note_id INTEGER REFERENCES notes(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, note_date)
+);
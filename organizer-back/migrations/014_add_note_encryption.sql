@@ -0,0 +1,25 @@
+-- Migration: 014_add_note_encryption.sql
+-- Description: Opt-in encryption at rest of note content with a per-user data key
+
+-- The user's data key, wrapped by a passphrase-derived KEK (Argon2id) or the server KEK
+CREATE TABLE IF NOT EXISTS user_encryption_keys (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    mode VARCHAR(12) NOT NULL CHECK (mode IN ('passphrase', 'server')),
+    wrapped_key BYTEA NOT NULL,
+    kdf_salt BYTEA,
+    kdf_params JSONB,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+DROP TRIGGER IF EXISTS set_timestamp_on_user_encryption_keys ON user_encryption_keys;
+CREATE TRIGGER set_timestamp_on_user_encryption_keys
+BEFORE UPDATE ON user_encryption_keys
+FOR EACH ROW
+EXECUTE PROCEDURE update_updated_at_column();
+
+-- Encrypted notes keep content empty and store nonce||ciphertext instead. Date, flags and
+-- tags stay in plaintext so listings and filters keep working.
+ALTER TABLE notes
+  ADD COLUMN IF NOT EXISTS encrypted BOOLEAN NOT NULL DEFAULT FALSE,
+  ADD COLUMN IF NOT EXISTS content_ciphertext BYTEA;
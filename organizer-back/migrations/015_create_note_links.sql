@@ -0,0 +1,45 @@
+-- Migration: 015_create_note_links.sql
+-- Description: Wiki-style [[YYYY-MM-DD]] and [[note:ID]] links between notes, parsed on save
+
+CREATE TABLE IF NOT EXISTS note_links (
+    source_note_id INTEGER NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+    target_ref VARCHAR(32) NOT NULL, -- as written: "2025-03-02" or "note:123"
+    kind VARCHAR(8) NOT NULL CHECK (kind IN ('date', 'note')),
+    -- Date links resolve to the notes of that day at query time
+    target_date DATE,
+    -- NULL for note links whose target does not exist (anymore) or belongs to someone else
+    target_note_id INTEGER REFERENCES notes(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (source_note_id, target_ref)
+);
+
+CREATE INDEX IF NOT EXISTS idx_note_links_target_date ON note_links(target_date) WHERE target_date IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_note_links_target_note ON note_links(target_note_id) WHERE target_note_id IS NOT NULL;
+
+-- Index links already present in existing (unencrypted) notes
+DO $$
+DECLARE
+  r RECORD;
+BEGIN
+  FOR r IN
+    SELECT DISTINCT n.id, m[1] AS ref
+    FROM notes n, regexp_matches(n.content, '\[\[\s*(\d{4}-\d{2}-\d{2})\s*(?:\|[^\]]*)?\]\]', 'g') AS m
+    WHERE NOT n.encrypted
+  LOOP
+    BEGIN
+      INSERT INTO note_links (source_note_id, target_ref, kind, target_date)
+      VALUES (r.id, r.ref, 'date', r.ref::date)
+      ON CONFLICT DO NOTHING;
+    EXCEPTION WHEN invalid_datetime_format OR datetime_field_overflow THEN
+      NULL; -- not a calendar date, e.g. [[2025-02-30]]
+    END;
+  END LOOP;
+END $$;
+
+INSERT INTO note_links (source_note_id, target_ref, kind, target_note_id)
+SELECT DISTINCT n.id, 'note:' || (m[1]::int)::text, 'note', t.id
+FROM notes n
+CROSS JOIN LATERAL regexp_matches(n.content, '\[\[\s*note:(\d{1,9})\s*(?:\|[^\]]*)?\]\]', 'g') AS m
+LEFT JOIN notes t ON t.id = m[1]::int AND t.user_id = n.user_id
+WHERE NOT n.encrypted AND m[1]::int <> n.id
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,14 @@
+-- Migration: 016_create_note_daily_stats.sql
+-- Description: Per user and day rollup of note and word counts backing /stats/notes when NOTE_STATS_ROLLUP is enabled
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS note_daily_stats AS
+SELECT user_id,
+       note_date,
+       COUNT(*)::int AS note_count,
+       -- Encrypted notes keep no plaintext, so they add no words
+       COALESCE(SUM((SELECT COUNT(*) FROM regexp_matches(content, '\S+', 'g'))), 0)::int AS word_count
+FROM notes
+GROUP BY user_id, note_date;
+
+-- Required by REFRESH MATERIALIZED VIEW CONCURRENTLY
+CREATE UNIQUE INDEX IF NOT EXISTS idx_note_daily_stats_user_date ON note_daily_stats(user_id, note_date);
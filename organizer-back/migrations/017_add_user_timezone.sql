@@ -0,0 +1,22 @@
+-- Migration: 017_add_user_timezone.sql
+-- Description: Per-user IANA timezone and timezone-aware timestamps
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+-- Existing values were written by NOW() in the session timezone and are read back in it, so
+-- run this with the same TimeZone setting the application uses
+DO $$
+DECLARE
+  c RECORD;
+BEGIN
+  FOR c IN
+    SELECT col.table_name, col.column_name
+    FROM information_schema.columns col
+    JOIN information_schema.tables t ON t.table_schema = col.table_schema AND t.table_name = col.table_name
+    WHERE col.table_schema = current_schema()
+      AND t.table_type = 'BASE TABLE'
+      AND col.data_type = 'timestamp without time zone'
+  LOOP
+    EXECUTE format('ALTER TABLE %I ALTER COLUMN %I TYPE TIMESTAMPTZ', c.table_name, c.column_name);
+  END LOOP;
+END $$;
@@ -0,0 +1,30 @@
+-- Migration: 018_create_todos.sql
+-- Description: Per-user todos with one level of subtasks and manual ordering
+
+CREATE TABLE IF NOT EXISTS todos (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- Subtasks point at their todo; only one level deep
+    parent_id INTEGER REFERENCES todos(id) ON DELETE CASCADE,
+    title VARCHAR(200) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    due_date DATE,
+    priority VARCHAR(10) NOT NULL DEFAULT 'normal' CHECK (priority IN ('low', 'normal', 'high', 'urgent')),
+    status VARCHAR(12) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'in_progress', 'done', 'cancelled')),
+    completed_at TIMESTAMPTZ,
+    -- Rank among siblings (see package rank); byte order, hence COLLATE "C"
+    position TEXT COLLATE "C" NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_todos_user_parent_position ON todos(user_id, COALESCE(parent_id, 0), position);
+CREATE INDEX IF NOT EXISTS idx_todos_parent ON todos(parent_id) WHERE parent_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_todos_user_due ON todos(user_id, due_date) WHERE status IN ('open', 'in_progress');
+CREATE INDEX IF NOT EXISTS idx_todos_user_completed ON todos(user_id, completed_at) WHERE completed_at IS NOT NULL;
+
+DROP TRIGGER IF EXISTS set_timestamp_on_todos ON todos;
+CREATE TRIGGER set_timestamp_on_todos
+BEFORE UPDATE ON todos
+FOR EACH ROW
+EXECUTE PROCEDURE update_updated_at_column();
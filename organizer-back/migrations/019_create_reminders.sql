@@ -0,0 +1,52 @@
+-- Migration: 019_create_reminders.sql
+-- Description: One-off and recurring reminders with a delivery log processed by the scheduler
+
+CREATE TABLE IF NOT EXISTS reminders (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title VARCHAR(200) NOT NULL,
+    message TEXT NOT NULL DEFAULT '',
+    note_id INTEGER REFERENCES notes(id) ON DELETE SET NULL,
+    todo_id INTEGER REFERENCES todos(id) ON DELETE SET NULL,
+    -- First occurrence; recurring rules repeat its wall-clock time in timezone
+    starts_at TIMESTAMPTZ NOT NULL,
+    timezone VARCHAR(64) NOT NULL,
+    rrule TEXT, -- NULL for one-off reminders
+    channels TEXT[] NOT NULL DEFAULT '{sse}',
+    webhook_url TEXT,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    -- NULL once a reminder has no more occurrences
+    next_fire_at TIMESTAMPTZ,
+    last_fired_at TIMESTAMPTZ,
+    fire_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (channels <@ ARRAY['email', 'webhook', 'sse']::TEXT[])
+);
+
+CREATE INDEX IF NOT EXISTS idx_reminders_user ON reminders(user_id);
+CREATE INDEX IF NOT EXISTS idx_reminders_due ON reminders(next_fire_at) WHERE active AND next_fire_at IS NOT NULL;
+
+DROP TRIGGER IF EXISTS set_timestamp_on_reminders ON reminders;
+CREATE TRIGGER set_timestamp_on_reminders
+BEFORE UPDATE ON reminders
+FOR EACH ROW
+EXECUTE PROCEDURE update_updated_at_column();
+
+-- One row per occurrence and channel. Pending rows are claimed with a lease (next_attempt_at)
+-- so a crashed instance's deliveries are retried by another one.
+CREATE TABLE IF NOT EXISTS reminder_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    reminder_id INTEGER NOT NULL REFERENCES reminders(id) ON DELETE CASCADE,
+    channel VARCHAR(10) NOT NULL CHECK (channel IN ('email', 'webhook', 'sse')),
+    fire_at TIMESTAMPTZ NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_reminder_deliveries_reminder ON reminder_deliveries(reminder_id, fire_at DESC);
+CREATE INDEX IF NOT EXISTS idx_reminder_deliveries_pending ON reminder_deliveries(next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,43 @@
+-- Migration: 020_create_emotion_entries.sql
+-- Description: Mood journal entries with a per-user catalog of emotion labels
+
+CREATE TABLE IF NOT EXISTS emotion_labels (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    color VARCHAR(7), -- #rrggbb
+    -- Labels used by entries are archived instead of deleted so history keeps them
+    archived BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS emotion_entries (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    mood SMALLINT NOT NULL CHECK (mood BETWEEN 1 AND 5),
+    intensity SMALLINT CHECK (intensity BETWEEN 1 AND 5),
+    comment TEXT NOT NULL DEFAULT '',
+    note_id INTEGER REFERENCES notes(id) ON DELETE SET NULL,
+    felt_at TIMESTAMPTZ NOT NULL,
+    -- Day of felt_at in the user's zone when recorded; trends and the calendar group by it
+    entry_date DATE NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_emotion_entries_user_date ON emotion_entries(user_id, entry_date);
+
+DROP TRIGGER IF EXISTS set_timestamp_on_emotion_entries ON emotion_entries;
+CREATE TRIGGER set_timestamp_on_emotion_entries
+BEFORE UPDATE ON emotion_entries
+FOR EACH ROW
+EXECUTE PROCEDURE update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS emotion_entry_labels (
+    entry_id INTEGER NOT NULL REFERENCES emotion_entries(id) ON DELETE CASCADE,
+    label_id INTEGER NOT NULL REFERENCES emotion_labels(id) ON DELETE CASCADE,
+    PRIMARY KEY (entry_id, label_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_emotion_entry_labels_label ON emotion_entry_labels(label_id);
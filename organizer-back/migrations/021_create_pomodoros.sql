@@ -0,0 +1,53 @@
+-- Migration: 021_create_pomodoros.sql
+-- Description: Pomodoro cycles and the focus/break intervals recorded in them
+
+CREATE TABLE IF NOT EXISTS pomodoro_cycles (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    todo_id INTEGER REFERENCES todos(id) ON DELETE SET NULL,
+    -- Timer settings the cycle was run with
+    focus_minutes SMALLINT NOT NULL CHECK (focus_minutes BETWEEN 1 AND 180),
+    short_break_minutes SMALLINT NOT NULL CHECK (short_break_minutes BETWEEN 1 AND 60),
+    long_break_minutes SMALLINT NOT NULL CHECK (long_break_minutes BETWEEN 1 AND 120),
+    long_break_interval SMALLINT NOT NULL CHECK (long_break_interval BETWEEN 1 AND 12),
+    iterations_per_cycle SMALLINT NOT NULL CHECK (iterations_per_cycle BETWEEN 1 AND 24),
+    started_at TIMESTAMPTZ NOT NULL,
+    ended_at TIMESTAMPTZ,
+    -- Day of started_at in the user's zone; the history screen lists cycles by it
+    cycle_date DATE NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (ended_at IS NULL OR ended_at >= started_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pomodoro_cycles_user_date ON pomodoro_cycles(user_id, cycle_date);
+
+DROP TRIGGER IF EXISTS set_timestamp_on_pomodoro_cycles ON pomodoro_cycles;
+CREATE TRIGGER set_timestamp_on_pomodoro_cycles
+BEFORE UPDATE ON pomodoro_cycles
+FOR EACH ROW
+EXECUTE PROCEDURE update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS pomodoro_intervals (
+    id SERIAL PRIMARY KEY,
+    cycle_id INTEGER NOT NULL REFERENCES pomodoro_cycles(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    todo_id INTEGER REFERENCES todos(id) ON DELETE SET NULL,
+    phase VARCHAR(10) NOT NULL CHECK (phase IN ('focus', 'short', 'long')),
+    iteration SMALLINT NOT NULL DEFAULT 1 CHECK (iteration >= 1),
+    planned_seconds INTEGER NOT NULL CHECK (planned_seconds > 0),
+    -- Time actually run, pauses excluded
+    actual_seconds INTEGER NOT NULL CHECK (actual_seconds >= 0),
+    interruptions INTEGER NOT NULL DEFAULT 0 CHECK (interruptions >= 0),
+    -- FALSE when the interval was skipped or the cycle ended before it ran out
+    completed BOOLEAN NOT NULL DEFAULT TRUE,
+    started_at TIMESTAMPTZ NOT NULL,
+    ended_at TIMESTAMPTZ NOT NULL,
+    -- Day of started_at in the user's zone; focus aggregates group by it
+    interval_date DATE NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (ended_at >= started_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pomodoro_intervals_cycle ON pomodoro_intervals(cycle_id, started_at);
+CREATE INDEX IF NOT EXISTS idx_pomodoro_intervals_user_date ON pomodoro_intervals(user_id, interval_date);
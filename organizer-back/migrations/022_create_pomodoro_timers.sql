@@ -0,0 +1,32 @@
+-- Migration: 022_create_pomodoro_timers.sql
+-- Description: Server-side pomodoro timer state, one per user, shared by all their devices
+
+CREATE TABLE IF NOT EXISTS pomodoro_timers (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    -- Cycle being run; NULL when idle
+    cycle_id INTEGER REFERENCES pomodoro_cycles(id) ON DELETE SET NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'idle' CHECK (status IN ('idle', 'running', 'paused')),
+    phase VARCHAR(10) NOT NULL DEFAULT 'focus' CHECK (phase IN ('focus', 'short', 'long')),
+    iteration SMALLINT NOT NULL DEFAULT 1,
+    auto_continue BOOLEAN NOT NULL DEFAULT TRUE,
+    -- When the current phase first ran; NULL for a phase waiting to be resumed
+    phase_started_at TIMESTAMPTZ,
+    -- Server time the running phase ends at
+    phase_ends_at TIMESTAMPTZ,
+    -- Time left of a paused phase
+    remaining_seconds INTEGER NOT NULL DEFAULT 0,
+    interruptions INTEGER NOT NULL DEFAULT 0,
+    -- Bumped on every change so clients can drop stale states
+    version INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (status <> 'running' OR phase_ends_at IS NOT NULL)
+);
+
+-- The scheduler looks for running phases that are over
+CREATE INDEX IF NOT EXISTS idx_pomodoro_timers_due ON pomodoro_timers(phase_ends_at) WHERE status = 'running';
+
+DROP TRIGGER IF EXISTS set_timestamp_on_pomodoro_timers ON pomodoro_timers;
+CREATE TRIGGER set_timestamp_on_pomodoro_timers
+BEFORE UPDATE ON pomodoro_timers
+FOR EACH ROW
+EXECUTE PROCEDURE update_updated_at_column();
@@ -0,0 +1,35 @@
+-- Migration: 023_create_user_preferences.sql
+-- Description: Per-namespace user preferences and the admin-defined defaults they inherit
+
+-- Only the keys a user changed are stored; the rest come from the defaults
+CREATE TABLE IF NOT EXISTS user_preferences (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    namespace VARCHAR(50) NOT NULL,
+    -- Version of the namespace schema data was written with; older data is upgraded on read
+    schema_version INTEGER NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, namespace)
+);
+
+DROP TRIGGER IF EXISTS set_timestamp_on_user_preferences ON user_preferences;
+CREATE TRIGGER set_timestamp_on_user_preferences
+BEFORE UPDATE ON user_preferences
+FOR EACH ROW
+EXECUTE PROCEDURE update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS preference_defaults (
+    namespace VARCHAR(50) PRIMARY KEY,
+    schema_version INTEGER NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}'::jsonb,
+    updated_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+DROP TRIGGER IF EXISTS set_timestamp_on_preference_defaults ON preference_defaults;
+CREATE TRIGGER set_timestamp_on_preference_defaults
+BEFORE UPDATE ON preference_defaults
+FOR EACH ROW
+EXECUTE PROCEDURE update_updated_at_column();
@@ -0,0 +1,44 @@
+-- Migration: 024_create_events.sql
+-- Description: Calendar events with recurrence rules, exception dates and attendees
+
+CREATE TABLE IF NOT EXISTS events (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- Stable identifier shared with calendar clients (iCalendar UID)
+    uid VARCHAR(255) NOT NULL,
+    title VARCHAR(200) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    location VARCHAR(200) NOT NULL DEFAULT '',
+    -- First occurrence; all-day events start at midnight in timezone and end exclusively
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    all_day BOOLEAN NOT NULL DEFAULT FALSE,
+    timezone VARCHAR(64) NOT NULL,
+    rrule TEXT, -- NULL for single events
+    -- Starts of the occurrences removed from the rule (EXDATE)
+    exdates TIMESTAMPTZ[] NOT NULL DEFAULT '{}',
+    -- End of the last occurrence, NULL while the rule is unbounded; limits range queries
+    recurrence_end TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, uid),
+    CHECK (ends_at >= starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_user_start ON events(user_id, starts_at);
+
+DROP TRIGGER IF EXISTS set_timestamp_on_events ON events;
+CREATE TRIGGER set_timestamp_on_events
+BEFORE UPDATE ON events
+FOR EACH ROW
+EXECUTE PROCEDURE update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS event_attendees (
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'needs_action' CHECK (status IN ('needs_action', 'accepted', 'declined', 'tentative')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (event_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_attendees_user ON event_attendees(user_id);
@@ -0,0 +1,16 @@
+-- Migration: 025_create_calendar_feeds.sql
+-- Description: Secret iCalendar subscription feeds per user and iCalendar UIDs for todos
+
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    -- SHA-256 of the token in the feed URL; rotating the token replaces it
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    last_accessed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Todos are exported as VTODO and imported back by UID
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS uid VARCHAR(255);
+UPDATE todos SET uid = 'todo-' || id || '@organizer' WHERE uid IS NULL;
+ALTER TABLE todos ALTER COLUMN uid SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_todos_user_uid ON todos(user_id, uid);
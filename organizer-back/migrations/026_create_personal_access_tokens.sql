@@ -0,0 +1,22 @@
+-- Migration: 026_create_personal_access_tokens.sql
+-- Description: Personal access tokens for clients that use basic auth (CalDAV) and the
+-- resource names CalDAV clients chose for events and todos
+
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    -- SHA-256 of the token; the token itself is only shown when it is created
+    token_hash CHAR(64) UNIQUE NOT NULL,
+    last_used_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_user ON personal_access_tokens(user_id);
+
+-- NULL means the default name, the UID followed by .ics
+ALTER TABLE events ADD COLUMN IF NOT EXISTS dav_name VARCHAR(255);
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS dav_name VARCHAR(255);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_events_user_dav_name ON events(user_id, dav_name) WHERE dav_name IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_todos_user_dav_name ON todos(user_id, dav_name) WHERE dav_name IS NOT NULL;
@@ -0,0 +1,50 @@
+-- Migration: 027_create_habits.sql
+-- Description: Habits with a schedule and optional numeric target, and their daily check-ins
+
+CREATE TABLE IF NOT EXISTS habits (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    color VARCHAR(7), -- #rrggbb
+    -- daily: every day; weekly: times_per_week days of any week; weekdays: the listed weekdays
+    schedule VARCHAR(10) NOT NULL DEFAULT 'daily' CHECK (schedule IN ('daily', 'weekly', 'weekdays')),
+    times_per_week SMALLINT CHECK (times_per_week BETWEEN 1 AND 7),
+    -- 0 (Sunday) to 6 (Saturday), as EXTRACT(DOW)
+    weekdays SMALLINT[] NOT NULL DEFAULT '{}',
+    -- With a target a check-in counts once its value reaches it; without one any check-in counts
+    target DOUBLE PRECISION CHECK (target > 0),
+    unit VARCHAR(30) NOT NULL DEFAULT '',
+    -- First day the schedule applies, in the user's zone
+    start_date DATE NOT NULL,
+    archived BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (schedule <> 'weekly' OR times_per_week IS NOT NULL),
+    CHECK (schedule <> 'weekdays' OR cardinality(weekdays) > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_habits_user ON habits(user_id);
+
+DROP TRIGGER IF EXISTS set_timestamp_on_habits ON habits;
+CREATE TRIGGER set_timestamp_on_habits
+BEFORE UPDATE ON habits
+FOR EACH ROW
+EXECUTE PROCEDURE update_updated_at_column();
+
+-- One check-in per habit and day; check_date is a note_date-like local day
+CREATE TABLE IF NOT EXISTS habit_checkins (
+    habit_id INTEGER NOT NULL REFERENCES habits(id) ON DELETE CASCADE,
+    check_date DATE NOT NULL,
+    value DOUBLE PRECISION CHECK (value >= 0),
+    comment TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (habit_id, check_date)
+);
+
+DROP TRIGGER IF EXISTS set_timestamp_on_habit_checkins ON habit_checkins;
+CREATE TRIGGER set_timestamp_on_habit_checkins
+BEFORE UPDATE ON habit_checkins
+FOR EACH ROW
+EXECUTE PROCEDURE update_updated_at_column();
@@ -0,0 +1,34 @@
+-- Migration: 028_create_projects.sql
+-- Description: Projects grouping todos, referenced from notes with [[project:ID]] links
+
+CREATE TABLE IF NOT EXISTS projects (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    goal TEXT NOT NULL DEFAULT '', -- what the project should achieve
+    deadline DATE,
+    status VARCHAR(12) NOT NULL DEFAULT 'active' CHECK (status IN ('planned', 'active', 'on_hold', 'completed')),
+    color VARCHAR(7), -- #rrggbb
+    -- Archived projects are hidden from lists but keep their todos and links
+    archived_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_projects_user ON projects(user_id);
+
+DROP TRIGGER IF EXISTS set_timestamp_on_projects ON projects;
+CREATE TRIGGER set_timestamp_on_projects
+BEFORE UPDATE ON projects
+FOR EACH ROW
+EXECUTE PROCEDURE update_updated_at_column();
+
+-- Only top-level todos belong to a project; subtasks follow their parent
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS project_id INTEGER REFERENCES projects(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_todos_project ON todos(project_id) WHERE project_id IS NOT NULL;
+
+ALTER TABLE note_links ADD COLUMN IF NOT EXISTS target_project_id INTEGER REFERENCES projects(id) ON DELETE SET NULL;
+ALTER TABLE note_links DROP CONSTRAINT IF EXISTS note_links_kind_check;
+ALTER TABLE note_links ADD CONSTRAINT note_links_kind_check CHECK (kind IN ('date', 'note', 'project'));
+CREATE INDEX IF NOT EXISTS idx_note_links_target_project ON note_links(target_project_id) WHERE target_project_id IS NOT NULL;
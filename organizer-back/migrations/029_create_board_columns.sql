@@ -0,0 +1,29 @@
+-- Migration: 029_create_board_columns.sql
+-- Description: Kanban columns per project with WIP limits and status mapping, and the place
+-- of each todo on its project's board
+
+CREATE TABLE IF NOT EXISTS board_columns (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    -- Lexicographic rank (see package rank); byte order keeps it independent of the locale
+    position TEXT COLLATE "C" NOT NULL,
+    wip_limit SMALLINT CHECK (wip_limit > 0),
+    -- Cards moved here take this todo status, and todos with it are shown here
+    status VARCHAR(20) CHECK (status IN ('open', 'in_progress', 'done', 'cancelled')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (project_id, position)
+);
+
+DROP TRIGGER IF EXISTS set_timestamp_on_board_columns ON board_columns;
+CREATE TRIGGER set_timestamp_on_board_columns
+BEFORE UPDATE ON board_columns
+FOR EACH ROW
+EXECUTE PROCEDURE update_updated_at_column();
+
+-- NULL until the card is first moved, or after its column is deleted: it is then shown in
+-- the column its status maps to
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS board_column_id INTEGER REFERENCES board_columns(id) ON DELETE SET NULL;
+ALTER TABLE todos ADD COLUMN IF NOT EXISTS board_position TEXT COLLATE "C";
+CREATE INDEX IF NOT EXISTS idx_todos_board_column ON todos(board_column_id) WHERE board_column_id IS NOT NULL;
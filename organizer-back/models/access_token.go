@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// PersonalAccessToken lets a user's clients sign in with basic auth (the username and the
+// token as password) where a browser session is not possible, such as CalDAV
+type PersonalAccessToken struct {
+	ID         int        `json:"id" db:"id"`
+	UserID     int        `json:"-" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	// Only present in the response that creates the token
+	Token string `json:"token,omitempty" db:"-"`
+}
+
+// PersonalAccessTokenRequest payload for creating a token
+type PersonalAccessTokenRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
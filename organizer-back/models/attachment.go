@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Attachment is a file uploaded to a note; the bytes live in the blob store under StorageKey
+type Attachment struct {
+	ID          int       `json:"id" db:"id"`
+	NoteID      int       `json:"note_id" db:"note_id"`
+	UserID      int       `json:"user_id" db:"user_id"`
+	FileName    string    `json:"file_name" db:"file_name"`
+	ContentType string    `json:"content_type" db:"content_type"`
+	SizeBytes   int64     `json:"size_bytes" db:"size_bytes"`
+	StorageKey  string    `json:"-" db:"storage_key"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// AttachmentResponse is returned to clients (storage key is never exposed)
+type AttachmentResponse struct {
+	ID          int       `json:"id"`
+	NoteID      int       `json:"note_id"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ToResponse converts Attachment to AttachmentResponse
+func (a *Attachment) ToResponse() AttachmentResponse {
+	return AttachmentResponse{
+		ID:          a.ID,
+		NoteID:      a.NoteID,
+		FileName:    a.FileName,
+		ContentType: a.ContentType,
+		SizeBytes:   a.SizeBytes,
+		CreatedAt:   a.CreatedAt,
+	}
+}
+
+// AttachmentUsageResponse reports how much of the quota a user has consumed
+type AttachmentUsageResponse struct {
+	UsedBytes  int64 `json:"used_bytes"`
+	QuotaBytes int64 `json:"quota_bytes"`
+	MaxFile    int64 `json:"max_file_bytes"`
+}
+
+// AttachmentQuotaRequest payload for overriding a user's quota (null resets to default)
+type AttachmentQuotaRequest struct {
+	QuotaBytes *int64 `json:"quota_bytes"`
+}
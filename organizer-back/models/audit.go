@@ -0,0 +1,17 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry records who did what to which entity
+type AuditEntry struct {
+	ID          int64           `json:"id" db:"id"`
+	ActorUserID *int            `json:"actor_user_id" db:"actor_user_id"`
+	Action      string          `json:"action" db:"action"`
+	EntityType  string          `json:"entity_type" db:"entity_type"`
+	EntityID    int             `json:"entity_id" db:"entity_id"`
+	Details     json.RawMessage `json:"details" db:"details"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
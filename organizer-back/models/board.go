@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// BoardColumn is a kanban column of a project's board. Cards moved into a column with a
+// Status take that status; at most WIPLimit cards may be moved into it.
+type BoardColumn struct {
+	ID        int       `json:"id" db:"id"`
+	ProjectID int       `json:"project_id" db:"project_id"`
+	Name      string    `json:"name" db:"name"`
+	Position  string    `json:"-" db:"position"`
+	WIPLimit  *int      `json:"wip_limit" db:"wip_limit"`
+	Status    *string   `json:"status" db:"status"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// BoardColumnRequest payload for creating or replacing a column; new columns go last
+type BoardColumnRequest struct {
+	Name     string  `json:"name" binding:"required,max=50"`
+	WIPLimit *int    `json:"wip_limit,omitempty" binding:"omitempty,min=1,max=1000"`
+	Status   *string `json:"status,omitempty" binding:"omitempty,oneof=open in_progress done cancelled"`
+}
+
+// BoardColumnMoveRequest payload for reordering a column relative to another one
+type BoardColumnMoveRequest struct {
+	Before *int `json:"before,omitempty"`
+	After  *int `json:"after,omitempty"`
+}
+
+// BoardCardMoveRequest payload for moving a card to a column, right before or after one of
+// its cards, or last when neither is set
+type BoardCardMoveRequest struct {
+	ColumnID int  `json:"column_id" binding:"required"`
+	Before   *int `json:"before,omitempty"`
+	After    *int `json:"after,omitempty"`
+}
+
+// BoardColumnSnapshot is a column with its cards in order. OverLimit is set when status
+// changes made outside the board left more cards than the WIP limit allows.
+type BoardColumnSnapshot struct {
+	BoardColumn
+	Count     int            `json:"count"`
+	OverLimit bool           `json:"over_limit"`
+	Cards     []TodoResponse `json:"cards"`
+}
+
+// BoardSnapshot is everything needed to render a project's board
+type BoardSnapshot struct {
+	Project ProjectResponse       `json:"project"`
+	Columns []BoardColumnSnapshot `json:"columns"`
+}
@@ -0,0 +1,88 @@
+package models
+
+import "time"
+
+// EmotionLabel is an entry of a user's emotion catalog
+type EmotionLabel struct {
+	ID        int       `json:"id" db:"id"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	Name      string    `json:"name" db:"name"`
+	Color     *string   `json:"color" db:"color"`
+	Archived  bool      `json:"archived" db:"archived"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// EmotionLabelRequest payload for creating or renaming a label
+type EmotionLabelRequest struct {
+	Name  string  `json:"name" binding:"required,max=50"`
+	Color *string `json:"color,omitempty" binding:"omitempty,hexcolor,len=7"`
+}
+
+// EmotionEntry records how the user felt: Mood from 1 (very bad) to 5 (very good), an
+// optional Intensity from 1 to 5 and the emotions felt
+type EmotionEntry struct {
+	ID        int            `json:"id" db:"id"`
+	UserID    int            `json:"user_id" db:"user_id"`
+	Mood      int            `json:"mood" db:"mood"`
+	Intensity *int           `json:"intensity" db:"intensity"`
+	Comment   string         `json:"comment" db:"comment"`
+	NoteID    *int           `json:"note_id" db:"note_id"`
+	FeltAt    time.Time      `json:"felt_at" db:"felt_at"`
+	EntryDate time.Time      `json:"-" db:"entry_date"`
+	Labels    []EmotionLabel `json:"labels"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// EmotionEntryResponse adds the local date of the entry
+type EmotionEntryResponse struct {
+	EmotionEntry
+	EntryDate string `json:"entry_date"`
+}
+
+func (e *EmotionEntry) ToResponse() EmotionEntryResponse {
+	labels := e.Labels
+	if labels == nil {
+		labels = []EmotionLabel{}
+	}
+	r := EmotionEntryResponse{EmotionEntry: *e, EntryDate: e.EntryDate.Format("2006-01-02")}
+	r.Labels = labels
+	return r
+}
+
+// EmotionEntryRequest payload for creating or replacing an entry; FeltAt is RFC 3339 and
+// defaults to now
+type EmotionEntryRequest struct {
+	Mood      int    `json:"mood" binding:"required,min=1,max=5"`
+	Intensity *int   `json:"intensity,omitempty" binding:"omitempty,min=1,max=5"`
+	Comment   string `json:"comment,omitempty"`
+	NoteID    *int   `json:"note_id,omitempty"`
+	FeltAt    string `json:"felt_at,omitempty"`
+	LabelIDs  []int  `json:"label_ids,omitempty" binding:"omitempty,max=20"`
+}
+
+// EmotionRangeQuery inclusive YYYY-MM-DD range of entry dates
+type EmotionRangeQuery struct {
+	From  string `form:"from"`
+	To    string `form:"to"`
+	Group string `form:"group" binding:"omitempty,oneof=day week"`
+}
+
+// MoodTrendPoint aggregates the entries of a day or week (its Monday)
+type MoodTrendPoint struct {
+	Period       string   `json:"period"`
+	Entries      int      `json:"entries"`
+	AvgMood      float64  `json:"avg_mood"`
+	MinMood      int      `json:"min_mood"`
+	MaxMood      int      `json:"max_mood"`
+	AvgIntensity *float64 `json:"avg_intensity"`
+}
+
+// MoodTagCorrelation compares the mood of days with notes tagged Tag to the overall mood
+type MoodTagCorrelation struct {
+	Tag        string  `json:"tag"`
+	Days       int     `json:"days"`
+	Entries    int     `json:"entries"`
+	AvgMood    float64 `json:"avg_mood"`
+	Difference float64 `json:"difference"` // AvgMood minus the average of all entries in range
+}
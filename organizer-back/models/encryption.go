@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// UserEncryptionKey is a user's data key wrapped by their passphrase-derived KEK or the server KEK
+type UserEncryptionKey struct {
+	UserID     int       `db:"user_id"`
+	Mode       string    `db:"mode"` // passphrase or server
+	WrappedKey []byte    `db:"wrapped_key"`
+	KDFSalt    []byte    `db:"kdf_salt"`
+	KDFParams  []byte    `db:"kdf_params"` // JSON keyring.KDFParams, passphrase mode only
+	CreatedAt  time.Time `db:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+// EncryptionStatus tells clients whether encrypted notes are available and how to unlock them
+type EncryptionStatus struct {
+	Enabled             bool       `json:"enabled"`
+	Mode                string     `json:"mode,omitempty"`
+	ServerModeAvailable bool       `json:"server_mode_available"`
+	CreatedAt           *time.Time `json:"created_at,omitempty"`
+}
+
+// EncryptionSetupRequest payload for enabling encryption; passphrase is required in passphrase mode
+type EncryptionSetupRequest struct {
+	Mode       string `json:"mode" binding:"required,oneof=passphrase server"`
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+// EncryptionUnlockRequest payload for obtaining a decryption grant
+type EncryptionUnlockRequest struct {
+	Passphrase string `json:"passphrase"`
+}
+
+// EncryptionPassphraseChangeRequest payload for re-wrapping the data key with a new passphrase
+type EncryptionPassphraseChangeRequest struct {
+	Current string `json:"current" binding:"required"`
+	New     string `json:"new" binding:"required,min=8"`
+}
+
+// DecryptionGrantResponse is a short-lived token sent back in the X-Decryption-Grant header
+type DecryptionGrantResponse struct {
+	Grant     string    `json:"grant"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,111 @@
+package models
+
+import "time"
+
+// Event is a calendar entry of its owner (UserID), repeated by RRule minus ExDates when set
+type Event struct {
+	ID            int             `json:"id" db:"id"`
+	UserID        int             `json:"user_id" db:"user_id"`
+	UID           string          `json:"uid" db:"uid"`
+	Title         string          `json:"title" db:"title"`
+	Description   string          `json:"description" db:"description"`
+	Location      string          `json:"location" db:"location"`
+	StartsAt      time.Time       `json:"starts_at" db:"starts_at"`
+	EndsAt        time.Time       `json:"ends_at" db:"ends_at"`
+	AllDay        bool            `json:"all_day" db:"all_day"`
+	Timezone      string          `json:"timezone" db:"timezone"`
+	RRule         *string         `json:"rrule" db:"rrule"`
+	ExDates       []time.Time     `json:"exdates" db:"exdates"`
+	RecurrenceEnd *time.Time      `json:"-" db:"recurrence_end"`
+	DavName       *string         `json:"-" db:"dav_name"` // CalDAV resource name, when not UID.ics
+	Attendees     []EventAttendee `json:"attendees"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// EventAttendee is an organizer user invited to an event and their answer
+type EventAttendee struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Status   string `json:"status"` // needs_action, accepted, declined or tentative
+}
+
+// EventRequest payload for creating or replacing an event. Times are RFC 3339 or a local
+// "2006-01-02T15:04" read in Timezone (the caller's zone by default); all-day events take
+// "2006-01-02" dates and their end defaults to the next day. ExDates use the same formats.
+type EventRequest struct {
+	Title       string   `json:"title" binding:"required,max=200"`
+	Description string   `json:"description,omitempty"`
+	Location    string   `json:"location,omitempty" binding:"omitempty,max=200"`
+	StartsAt    string   `json:"starts_at" binding:"required"`
+	EndsAt      string   `json:"ends_at,omitempty"`
+	AllDay      bool     `json:"all_day,omitempty"`
+	Timezone    string   `json:"timezone,omitempty" binding:"omitempty,timezone"`
+	RRule       string   `json:"rrule,omitempty"`
+	ExDates     []string `json:"exdates,omitempty" binding:"omitempty,max=500"`
+	AttendeeIDs []int    `json:"attendee_ids,omitempty" binding:"omitempty,max=50"`
+}
+
+// EventAttendanceRequest payload for answering an invitation
+type EventAttendanceRequest struct {
+	Status string `json:"status" binding:"required,oneof=needs_action accepted declined tentative"`
+}
+
+// EventRangeQuery window of GET /events: RFC 3339 times or YYYY-MM-DD dates in the caller's
+// zone, to being exclusive
+type EventRangeQuery struct {
+	From string `form:"from" binding:"required"`
+	To   string `form:"to" binding:"required"`
+}
+
+// EventOccurrence is one instance of an event inside the requested window
+type EventOccurrence struct {
+	EventID     int       `json:"event_id"`
+	UID         string    `json:"uid"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Location    string    `json:"location"`
+	StartsAt    time.Time `json:"starts_at"`
+	EndsAt      time.Time `json:"ends_at"`
+	AllDay      bool      `json:"all_day"`
+	Timezone    string    `json:"timezone"`
+	Recurring   bool      `json:"recurring"`
+	OwnerID     int       `json:"owner_id"`
+	// The caller's answer when they are an attendee rather than the owner
+	Attendance *string `json:"attendance,omitempty"`
+}
+
+// CalendarFeed is the secret iCalendar subscription of a user
+type CalendarFeed struct {
+	UserID         int        `json:"-" db:"user_id"`
+	TokenHash      string     `json:"-" db:"token_hash"`
+	LastAccessedAt *time.Time `json:"last_accessed_at" db:"last_accessed_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+// CalendarFeedResponse describes the feed; Token and URL are only present right after it is
+// created or rotated
+type CalendarFeedResponse struct {
+	Enabled        bool       `json:"enabled"`
+	Token          string     `json:"token,omitempty"`
+	URL            string     `json:"url,omitempty"`
+	LastAccessedAt *time.Time `json:"last_accessed_at"`
+	CreatedAt      *time.Time `json:"created_at"`
+}
+
+// CalendarImportResult counts what an .ics import created and updated; Skipped lists the
+// components that could not be imported
+type CalendarImportResult struct {
+	EventsCreated int                  `json:"events_created"`
+	EventsUpdated int                  `json:"events_updated"`
+	TodosCreated  int                  `json:"todos_created"`
+	TodosUpdated  int                  `json:"todos_updated"`
+	Skipped       []CalendarImportSkip `json:"skipped"`
+}
+
+type CalendarImportSkip struct {
+	Component string `json:"component"`
+	UID       string `json:"uid"`
+	Reason    string `json:"reason"`
+}
@@ -0,0 +1,101 @@
+package models
+
+import "time"
+
+// Habit is something a user wants to do regularly. Schedule is daily, weekly (TimesPerWeek
+// days of each week) or weekdays (only on Weekdays, 0 being Sunday).
+type Habit struct {
+	ID           int       `json:"id" db:"id"`
+	UserID       int       `json:"user_id" db:"user_id"`
+	Name         string    `json:"name" db:"name"`
+	Description  string    `json:"description" db:"description"`
+	Color        *string   `json:"color" db:"color"`
+	Schedule     string    `json:"schedule" db:"schedule"`
+	TimesPerWeek *int      `json:"times_per_week" db:"times_per_week"`
+	Weekdays     []int64   `json:"weekdays" db:"weekdays"`
+	Target       *float64  `json:"target" db:"target"` // value a check-in must reach to count
+	Unit         string    `json:"unit" db:"unit"`
+	StartDate    time.Time `json:"-" db:"start_date"`
+	Archived     bool      `json:"archived" db:"archived"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// HabitResponse adds the start date formatted as YYYY-MM-DD
+type HabitResponse struct {
+	Habit
+	StartDate string `json:"start_date"`
+}
+
+func (h *Habit) ToResponse() HabitResponse {
+	r := HabitResponse{Habit: *h, StartDate: h.StartDate.Format("2006-01-02")}
+	if r.Weekdays == nil {
+		r.Weekdays = []int64{}
+	}
+	return r
+}
+
+// HabitRequest payload for creating or replacing a habit; StartDate (YYYY-MM-DD) defaults
+// to today and Archived to false
+type HabitRequest struct {
+	Name         string   `json:"name" binding:"required,max=100"`
+	Description  string   `json:"description,omitempty"`
+	Color        *string  `json:"color,omitempty" binding:"omitempty,hexcolor,len=7"`
+	Schedule     string   `json:"schedule,omitempty" binding:"omitempty,oneof=daily weekly weekdays"`
+	TimesPerWeek *int     `json:"times_per_week,omitempty" binding:"omitempty,min=1,max=7"`
+	Weekdays     []int64  `json:"weekdays,omitempty" binding:"omitempty,max=7,dive,min=0,max=6"`
+	Target       *float64 `json:"target,omitempty" binding:"omitempty,gt=0"`
+	Unit         string   `json:"unit,omitempty" binding:"max=30"`
+	StartDate    string   `json:"start_date,omitempty"`
+	Archived     *bool    `json:"archived,omitempty"`
+}
+
+// HabitCheckin records a habit on a day; Done tells whether it counts toward the schedule
+type HabitCheckin struct {
+	HabitID   int       `json:"habit_id" db:"habit_id"`
+	Date      string    `json:"date" db:"check_date"` // YYYY-MM-DD
+	Value     *float64  `json:"value" db:"value"`
+	Comment   string    `json:"comment" db:"comment"`
+	Done      bool      `json:"done"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// HabitCheckinRequest payload for checking in a habit on a day
+type HabitCheckinRequest struct {
+	Value   *float64 `json:"value,omitempty" binding:"omitempty,min=0"`
+	Comment string   `json:"comment,omitempty"`
+}
+
+// HabitRangeQuery inclusive YYYY-MM-DD range of days
+type HabitRangeQuery struct {
+	From string `form:"from"`
+	To   string `form:"to"`
+}
+
+// HabitStats measures a habit against its schedule over a range. Streaks count scheduled
+// days in a row, or weeks in a row that reached TimesPerWeek for weekly habits.
+type HabitStats struct {
+	HabitID        int     `json:"habit_id"`
+	From           string  `json:"from"`
+	To             string  `json:"to"`
+	Scheduled      int     `json:"scheduled"`
+	Completed      int     `json:"completed"`
+	CompletionRate float64 `json:"completion_rate"` // Completed / Scheduled, 0 to 1
+	CurrentStreak  int     `json:"current_streak"`
+	LongestStreak  int     `json:"longest_streak"`
+	StreakUnit     string  `json:"streak_unit"` // day or week
+}
+
+// HabitDay is a habit as shown in the daily view of a date. For weekly habits WeekDone is
+// how many days of that week are done so far.
+type HabitDay struct {
+	HabitResponse
+	Date          string        `json:"date"`
+	Scheduled     bool          `json:"scheduled"`
+	Checkin       *HabitCheckin `json:"checkin"`
+	Done          bool          `json:"done"`
+	WeekDone      *int          `json:"week_done,omitempty"`
+	CurrentStreak int           `json:"current_streak"`
+	StreakUnit    string        `json:"streak_unit"`
+}
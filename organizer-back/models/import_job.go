@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// ImportJob tracks a (possibly resumed) import of an uploaded archive
+type ImportJob struct {
+	ID                int        `json:"id" db:"id"`
+	UserID            int        `json:"user_id" db:"user_id"`
+	Status            string     `json:"status" db:"status"`
+	Format            string     `json:"format" db:"format"`
+	DryRun            bool       `json:"dry_run" db:"dry_run"`
+	DuplicateStrategy string     `json:"duplicate_strategy" db:"duplicate_strategy"`
+	FileName          string     `json:"file_name" db:"file_name"`
+	StorageKey        string     `json:"-" db:"storage_key"`
+	ProcessedItems    int        `json:"processed_items" db:"processed_items"`
+	CreatedCount      int        `json:"created_count" db:"created_count"`
+	OverwrittenCount  int        `json:"overwritten_count" db:"overwritten_count"`
+	SkippedCount      int        `json:"skipped_count" db:"skipped_count"`
+	FailedCount       int        `json:"failed_count" db:"failed_count"`
+	Error             *string    `json:"error" db:"error"`
+	FinishedAt        *time.Time `json:"finished_at" db:"finished_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// ImportJobItem is one line of an import report. For dry runs it describes what would happen.
+type ImportJobItem struct {
+	Index    int     `json:"index" db:"item_index"`
+	Source   string  `json:"source" db:"source"`
+	NoteDate *string `json:"note_date" db:"note_date"`
+	Action   string  `json:"action" db:"action"`
+	NoteID   *int    `json:"note_id" db:"note_id"`
+	Message  string  `json:"message,omitempty" db:"message"`
+}
+
+// ImportJobReport is the job with its per-item results
+type ImportJobReport struct {
+	ImportJob
+	Items []ImportJobItem `json:"items"`
+}
+
+// NoteImportRequest form fields accompanying the uploaded file
+type NoteImportRequest struct {
+	Format     string `form:"format" binding:"omitempty,oneof=auto markdown json dayone obsidian"`
+	DryRun     bool   `form:"dry_run"`
+	Duplicates string `form:"duplicates" binding:"omitempty,oneof=skip overwrite keep_both"`
+}
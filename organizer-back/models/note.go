@@ -4,30 +4,41 @@ import "time"
 
 // Note represents a personal note associated to a user and a specific date
 type Note struct {
-	ID        int       `json:"id" db:"id"`
-	UserID    int       `json:"user_id" db:"user_id"`
-	NoteDate  time.Time `json:"-" db:"note_date"`
-	Content   string    `json:"content" db:"content"`
-	Hidden    bool      `json:"hidden" db:"hidden"`
-	Starred   bool      `json:"starred" db:"starred"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID         int       `json:"id" db:"id"`
+	UserID     int       `json:"user_id" db:"user_id"`
+	NoteDate   time.Time `json:"-" db:"note_date"`
+	Content    string    `json:"content" db:"content"`
+	Hidden     bool      `json:"hidden" db:"hidden"`
+	Starred    bool      `json:"starred" db:"starred"`
+	Tags       []string  `json:"tags" db:"tags"`
+	Encrypted  bool      `json:"encrypted" db:"encrypted"`
+	Ciphertext []byte    `json:"-" db:"content_ciphertext"` // sealed content; cleared once decrypted
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // NoteResponse is returned to clients with date formatted as YYYY-MM-DD
 type NoteResponse struct {
-	ID        int       `json:"id"`
-	UserID    int       `json:"user_id"`
-	NoteDate  string    `json:"note_date"`
-	Content   string    `json:"content"`
-	Hidden    bool      `json:"hidden"`
-	Starred   bool      `json:"starred"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	NoteDate  string     `json:"note_date"`
+	Content   string     `json:"content"`
+	Hidden    bool       `json:"hidden"`
+	Starred   bool       `json:"starred"`
+	Tags      []string   `json:"tags"`
+	Encrypted bool       `json:"encrypted"`
+	Locked    bool       `json:"locked,omitempty"` // encrypted and returned without a valid decryption grant
+	Links     []NoteLink `json:"links,omitempty"`  // resolved [[...]] links, only when reading a single note
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
 }
 
 // ToResponse converts Note to NoteResponse formatting the date
 func (n *Note) ToResponse() NoteResponse {
+	tags := n.Tags
+	if tags == nil {
+		tags = []string{}
+	}
 	return NoteResponse{
 		ID:        n.ID,
 		UserID:    n.UserID,
@@ -35,6 +46,9 @@ func (n *Note) ToResponse() NoteResponse {
 		Content:   n.Content,
 		Hidden:    n.Hidden,
 		Starred:   n.Starred,
+		Tags:      tags,
+		Encrypted: n.Encrypted,
+		Locked:    n.Encrypted && n.Ciphertext != nil,
 		CreatedAt: n.CreatedAt,
 		UpdatedAt: n.UpdatedAt,
 	}
@@ -42,14 +56,68 @@ func (n *Note) ToResponse() NoteResponse {
 
 // NoteCreateRequest payload for creating a note
 type NoteCreateRequest struct {
-	NoteDate string `json:"note_date" binding:"required"`
-	Content  string `json:"content" binding:"required"`
+	NoteDate string   `json:"note_date" binding:"required"`
+	Content  string   `json:"content" binding:"required"`
+	Tags     []string `json:"tags,omitempty"`
+	// Encrypted stores the content encrypted with the user's key (requires encryption set up)
+	Encrypted bool `json:"encrypted,omitempty"`
 }
 
 // NoteUpdateRequest payload for updating a note
 type NoteUpdateRequest struct {
-	NoteDate *string `json:"note_date,omitempty"`
-	Content  *string `json:"content,omitempty"`
-	Hidden   *bool   `json:"hidden,omitempty"`
-	Starred  *bool   `json:"starred,omitempty"`
+	NoteDate *string   `json:"note_date,omitempty"`
+	Content  *string   `json:"content,omitempty"`
+	Hidden   *bool     `json:"hidden,omitempty"`
+	Starred  *bool     `json:"starred,omitempty"`
+	Tags     *[]string `json:"tags,omitempty"`
+	// Encrypted switches the note between plaintext and encrypted storage
+	Encrypted *bool `json:"encrypted,omitempty"`
+}
+
+// NoteMoveRequest payload for reordering a note: the id of the note to place it before or after
+type NoteMoveRequest struct {
+	Before *int `json:"before,omitempty"`
+	After  *int `json:"after,omitempty"`
+}
+
+// NoteExportQuery query parameters for exporting notes; from/to are inclusive YYYY-MM-DD dates
+type NoteExportQuery struct {
+	Format string `form:"format" binding:"omitempty,oneof=json markdown zip"`
+	From   string `form:"from"`
+	To     string `form:"to"`
+}
+
+// NoteExportDocument is the envelope of the JSON export; Notes is streamed element by element
+type NoteExportDocument struct {
+	Format     string         `json:"format"`
+	Version    int            `json:"version"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Notes      []NoteResponse `json:"notes"`
+}
+
+// NoteCalendarQuery query parameters for the monthly calendar; year and month default to the
+// current month. IncludeMood adds each day's average mood.
+type NoteCalendarQuery struct {
+	Year        int  `form:"year" binding:"omitempty,min=1,max=9999"`
+	Month       int  `form:"month" binding:"omitempty,min=1,max=12"`
+	IncludeMood bool `form:"include_mood"`
+}
+
+// NoteCalendarDay summarizes one day that has notes; Preview is the first line of the first
+// visible note (starred first, then manual order). Mood is the average mood of the day's
+// emotion entries, when requested.
+type NoteCalendarDay struct {
+	Date         string   `json:"date"`
+	Count        int      `json:"count"`
+	StarredCount int      `json:"starred_count"`
+	HiddenCount  int      `json:"hidden_count"`
+	Preview      string   `json:"preview"`
+	Mood         *float64 `json:"mood,omitempty"`
+}
+
+// NoteCalendarResponse lists the days of a month that have notes (or a mood, when requested)
+type NoteCalendarResponse struct {
+	Year  int               `json:"year"`
+	Month int               `json:"month"`
+	Days  []NoteCalendarDay `json:"days"`
 }
@@ -0,0 +1,40 @@
+package models
+
+// NoteBulkFilter selects the caller's notes for a bulk operation; dates are YYYY-MM-DD and
+// from/to are inclusive
+type NoteBulkFilter struct {
+	Date    string `json:"date,omitempty"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+	Starred *bool  `json:"starred,omitempty"`
+	Hidden  *bool  `json:"hidden,omitempty"`
+	Tag     string `json:"tag,omitempty"`
+}
+
+// NoteBulkRequest payload for applying one action to many notes. Targets are either ids or
+// a filter. Date is required by "move", Tags by "tag" and "untag".
+type NoteBulkRequest struct {
+	Action string          `json:"action" binding:"required,oneof=hide unhide star unstar move tag untag delete"`
+	IDs    []int           `json:"ids,omitempty" binding:"omitempty,max=1000"`
+	Filter *NoteBulkFilter `json:"filter,omitempty"`
+	Date   string          `json:"date,omitempty"`
+	Tags   []string        `json:"tags,omitempty"`
+	Mode   string          `json:"mode,omitempty" binding:"omitempty,oneof=atomic partial"`
+}
+
+// NoteBulkResult is the outcome for a single note
+type NoteBulkResult struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"` // ok or failed
+	Error  string `json:"error,omitempty"`
+}
+
+// NoteBulkResponse summarizes a bulk operation
+type NoteBulkResponse struct {
+	Action    string           `json:"action"`
+	Mode      string           `json:"mode"`
+	Matched   int              `json:"matched"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []NoteBulkResult `json:"results"`
+}
@@ -0,0 +1,33 @@
+package models
+
+// NoteLink is an outgoing [[...]] link of a note together with what it resolves to
+type NoteLink struct {
+	Ref          string           `json:"ref"`  // as written: "2025-03-02", "note:123" or "project:7"
+	Kind         string           `json:"kind"` // date, note or project
+	TargetDate   *string          `json:"target_date,omitempty"`
+	TargetNoteID *int             `json:"target_note_id,omitempty"`
+	Project      *NoteLinkProject `json:"project,omitempty"`
+	Resolved     bool             `json:"resolved"`
+	Targets      []NoteLinkTarget `json:"targets"`
+}
+
+// NoteLinkProject is the project a [[project:ID]] link resolves to
+type NoteLinkProject struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// NoteLinkTarget is a short description of a linked or linking note
+type NoteLinkTarget struct {
+	ID        int    `json:"id"`
+	NoteDate  string `json:"note_date"`
+	Preview   string `json:"preview"`
+	Encrypted bool   `json:"encrypted,omitempty"`
+}
+
+// NoteBacklink is a note that links to another one, either by id or by its date
+type NoteBacklink struct {
+	NoteLinkTarget
+	Via string `json:"via"` // date or note
+	Ref string `json:"ref"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// NoteShare grants another user access to a note
+type NoteShare struct {
+	NoteID     int       `json:"note_id" db:"note_id"`
+	UserID     int       `json:"user_id" db:"user_id"`
+	Username   string    `json:"username" db:"username"`
+	Permission string    `json:"permission" db:"permission"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// NoteShareRequest payload for sharing a note; either user_id or username identifies the recipient
+type NoteShareRequest struct {
+	UserID     int    `json:"user_id"`
+	Username   string `json:"username"`
+	Permission string `json:"permission" binding:"required,oneof=viewer editor"`
+}
+
+// SharedNoteResponse is a note someone else owns, along with the caller's access level
+type SharedNoteResponse struct {
+	NoteResponse
+	OwnerUsername string `json:"owner_username"`
+	Permission    string `json:"permission"`
+}
@@ -0,0 +1,30 @@
+package models
+
+// NoteStats journaling statistics of a user
+type NoteStats struct {
+	CurrentStreak int               `json:"current_streak"` // consecutive days with notes ending today or yesterday
+	LongestStreak int               `json:"longest_streak"`
+	TotalNotes    int               `json:"total_notes"`
+	TotalWords    int               `json:"total_words"`
+	Weekly        []NoteStatsPeriod `json:"weekly"`  // last weeks, oldest first; weeks start on Monday
+	Monthly       []NoteStatsPeriod `json:"monthly"` // last months, oldest first
+	TopTags       []NoteTagCount    `json:"top_tags"`
+	Heatmap       []NoteHeatmapDay  `json:"heatmap"` // days with notes in the last 365 days
+}
+
+// NoteStatsPeriod notes and words written in a week (start date, YYYY-MM-DD) or month (YYYY-MM)
+type NoteStatsPeriod struct {
+	Period string `json:"period"`
+	Notes  int    `json:"notes"`
+	Words  int    `json:"words"`
+}
+
+type NoteTagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+type NoteHeatmapDay struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
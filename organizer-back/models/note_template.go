@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// NoteTemplate is a reusable skeleton for new notes. Content may contain the placeholders
+// {{date}}, {{weekday}}, {{username}} and {{carryover}}.
+type NoteTemplate struct {
+	ID        int       `json:"id" db:"id"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	Name      string    `json:"name" db:"name"`
+	Content   string    `json:"content" db:"content"`
+	Tags      []string  `json:"tags" db:"tags"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// NoteTemplateRequest payload for creating or replacing a template
+type NoteTemplateRequest struct {
+	Name    string   `json:"name" binding:"required,max=100"`
+	Content string   `json:"content" binding:"required"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+// NoteFromTemplateRequest payload for creating a note from a template; the date defaults to today
+type NoteFromTemplateRequest struct {
+	TemplateID int    `json:"template_id" binding:"required"`
+	NoteDate   string `json:"note_date,omitempty"`
+}
+
+// DailyTemplateSetting tells which template, if any, is materialized on days without notes
+type DailyTemplateSetting struct {
+	TemplateID *int    `json:"template_id"`
+	Since      *string `json:"since,omitempty"`
+}
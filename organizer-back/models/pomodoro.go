@@ -0,0 +1,155 @@
+package models
+
+import "time"
+
+// PomodoroSettings are the timer settings of a cycle; zero values take the defaults
+// (25/5/15 minutes, a long break every 4 iterations, 4 iterations per cycle)
+type PomodoroSettings struct {
+	FocusMinutes       int `json:"focus_minutes" binding:"omitempty,min=1,max=180"`
+	ShortBreakMinutes  int `json:"short_break_minutes" binding:"omitempty,min=1,max=60"`
+	LongBreakMinutes   int `json:"long_break_minutes" binding:"omitempty,min=1,max=120"`
+	LongBreakInterval  int `json:"long_break_interval" binding:"omitempty,min=1,max=12"`
+	IterationsPerCycle int `json:"iterations_per_cycle" binding:"omitempty,min=1,max=24"`
+}
+
+// PomodoroCycle is one run of the timer: focus intervals separated by breaks
+type PomodoroCycle struct {
+	ID     int  `json:"id" db:"id"`
+	UserID int  `json:"user_id" db:"user_id"`
+	TodoID *int `json:"todo_id" db:"todo_id"`
+	PomodoroSettings
+	StartedAt time.Time          `json:"started_at" db:"started_at"`
+	EndedAt   *time.Time         `json:"ended_at" db:"ended_at"`
+	CycleDate time.Time          `json:"-" db:"cycle_date"`
+	Intervals []PomodoroInterval `json:"intervals"`
+	CreatedAt time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at" db:"updated_at"`
+}
+
+// PomodoroInterval is a focus period or a break (phase focus, short or long)
+type PomodoroInterval struct {
+	ID             int       `json:"id" db:"id"`
+	CycleID        int       `json:"cycle_id" db:"cycle_id"`
+	TodoID         *int      `json:"todo_id" db:"todo_id"`
+	Phase          string    `json:"phase" db:"phase"`
+	Iteration      int       `json:"iteration" db:"iteration"`
+	PlannedSeconds int       `json:"planned_seconds" db:"planned_seconds"`
+	ActualSeconds  int       `json:"actual_seconds" db:"actual_seconds"`
+	Interruptions  int       `json:"interruptions" db:"interruptions"`
+	Completed      bool      `json:"completed" db:"completed"`
+	StartedAt      time.Time `json:"started_at" db:"started_at"`
+	EndedAt        time.Time `json:"ended_at" db:"ended_at"`
+	IntervalDate   time.Time `json:"-" db:"interval_date"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// PomodoroCycleResponse adds the local date and the focus totals of a cycle
+type PomodoroCycleResponse struct {
+	PomodoroCycle
+	Date           string `json:"date"`
+	FocusSeconds   int    `json:"focus_seconds"`
+	CompletedFocus int    `json:"completed_focus"`
+	Interruptions  int    `json:"interruptions"`
+}
+
+func (p *PomodoroCycle) ToResponse() PomodoroCycleResponse {
+	r := PomodoroCycleResponse{PomodoroCycle: *p, Date: p.CycleDate.Format("2006-01-02")}
+	if r.Intervals == nil {
+		r.Intervals = []PomodoroInterval{}
+	}
+	for _, iv := range r.Intervals {
+		r.Interruptions += iv.Interruptions
+		if iv.Phase != "focus" {
+			continue
+		}
+		r.FocusSeconds += iv.ActualSeconds
+		if iv.Completed {
+			r.CompletedFocus++
+		}
+	}
+	return r
+}
+
+// PomodoroCycleRequest payload for recording a cycle, optionally with its intervals.
+// Times are RFC 3339; started_at defaults to now.
+type PomodoroCycleRequest struct {
+	PomodoroSettings
+	TodoID    *int                      `json:"todo_id,omitempty"`
+	StartedAt string                    `json:"started_at,omitempty"`
+	EndedAt   string                    `json:"ended_at,omitempty"`
+	Intervals []PomodoroIntervalRequest `json:"intervals,omitempty" binding:"omitempty,max=100,dive"`
+}
+
+// PomodoroCycleUpdateRequest payload for ending a cycle or changing its todo; todo_id 0
+// unlinks it
+type PomodoroCycleUpdateRequest struct {
+	TodoID  *int    `json:"todo_id,omitempty"`
+	EndedAt *string `json:"ended_at,omitempty"`
+}
+
+// PomodoroIntervalRequest payload for recording an interval. actual_seconds defaults to the
+// time between started_at and ended_at, completed to whether it reached planned_seconds and
+// todo_id to the cycle's todo.
+type PomodoroIntervalRequest struct {
+	Phase          string `json:"phase" binding:"required,oneof=focus short long"`
+	Iteration      int    `json:"iteration,omitempty" binding:"omitempty,min=1,max=100"`
+	PlannedSeconds int    `json:"planned_seconds" binding:"required,min=1,max=86400"`
+	ActualSeconds  *int   `json:"actual_seconds,omitempty" binding:"omitempty,min=0,max=86400"`
+	Interruptions  int    `json:"interruptions,omitempty" binding:"omitempty,min=0,max=1000"`
+	Completed      *bool  `json:"completed,omitempty"`
+	TodoID         *int   `json:"todo_id,omitempty"`
+	StartedAt      string `json:"started_at" binding:"required"`
+	EndedAt        string `json:"ended_at" binding:"required"`
+}
+
+// PomodoroStatsQuery inclusive YYYY-MM-DD range for focus aggregates grouped by day or week
+type PomodoroStatsQuery struct {
+	From  string `form:"from"`
+	To    string `form:"to"`
+	Group string `form:"group" binding:"omitempty,oneof=day week"`
+}
+
+// PomodoroFocusPoint aggregates the intervals of a day or week (its Monday)
+type PomodoroFocusPoint struct {
+	Period         string `json:"period"`
+	FocusSeconds   int    `json:"focus_seconds"`
+	FocusIntervals int    `json:"focus_intervals"`
+	CompletedFocus int    `json:"completed_focus"`
+	BreakSeconds   int    `json:"break_seconds"`
+	Interruptions  int    `json:"interruptions"`
+}
+
+// PomodoroTimer is the server-side state of a user's timer
+type PomodoroTimer struct {
+	UserID           int        `json:"-" db:"user_id"`
+	CycleID          *int       `json:"cycle_id" db:"cycle_id"`
+	Status           string     `json:"status" db:"status"` // idle, running or paused
+	Phase            string     `json:"phase" db:"phase"`   // focus, short or long
+	Iteration        int        `json:"iteration" db:"iteration"`
+	AutoContinue     bool       `json:"auto_continue" db:"auto_continue"`
+	PhaseStartedAt   *time.Time `json:"phase_started_at" db:"phase_started_at"`
+	PhaseEndsAt      *time.Time `json:"phase_ends_at" db:"phase_ends_at"`
+	RemainingSeconds int        `json:"-" db:"remaining_seconds"`
+	Interruptions    int        `json:"interruptions" db:"interruptions"`
+	Version          int        `json:"version" db:"version"`
+	UpdatedAt        time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// PomodoroTimerResponse is the timer as shown by every client: the countdown is
+// phase_ends_at minus server_time while running, remaining_seconds otherwise
+type PomodoroTimerResponse struct {
+	PomodoroTimer
+	Settings            *PomodoroSettings `json:"settings"`
+	TodoID              *int              `json:"todo_id"`
+	PlannedSeconds      int               `json:"planned_seconds"`
+	RemainingSeconds    int               `json:"remaining_seconds"`
+	CompletedIterations int               `json:"completed_iterations"`
+	ServerTime          time.Time         `json:"server_time"`
+}
+
+// PomodoroTimerStartRequest payload for starting a new cycle on the timer
+type PomodoroTimerStartRequest struct {
+	PomodoroSettings
+	TodoID       *int  `json:"todo_id,omitempty"`
+	AutoContinue *bool `json:"auto_continue,omitempty"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PreferenceSet is the stored data of one namespace, for a user or as the admin defaults
+type PreferenceSet struct {
+	Namespace     string          `json:"namespace" db:"namespace"`
+	SchemaVersion int             `json:"schema_version" db:"schema_version"`
+	Data          json.RawMessage `json:"data" db:"data"`
+	UpdatedAt     time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// PreferencesResponse shows the effective values of a namespace and the keys set at this
+// level (by the user, or by an admin for the defaults)
+type PreferencesResponse struct {
+	Namespace     string                 `json:"namespace"`
+	SchemaVersion int                    `json:"schema_version"`
+	Values        map[string]interface{} `json:"values"`
+	Overrides     map[string]interface{} `json:"overrides"`
+	UpdatedAt     *time.Time             `json:"updated_at"`
+}
+
+// PreferenceSchema describes a namespace as a JSON Schema document
+type PreferenceSchema struct {
+	Namespace string                 `json:"namespace"`
+	Version   int                    `json:"version"`
+	Schema    map[string]interface{} `json:"schema"`
+}
@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// Project groups top-level todos toward a goal; notes refer to it with [[project:ID]]
+type Project struct {
+	ID          int        `json:"id" db:"id"`
+	UserID      int        `json:"user_id" db:"user_id"`
+	Name        string     `json:"name" db:"name"`
+	Description string     `json:"description" db:"description"`
+	Goal        string     `json:"goal" db:"goal"`
+	Deadline    *time.Time `json:"-" db:"deadline"`
+	Status      string     `json:"status" db:"status"` // planned, active, on_hold or completed
+	Color       *string    `json:"color" db:"color"`
+	ArchivedAt  *time.Time `json:"archived_at" db:"archived_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// ProjectProgress counts the project's top-level todos by status. Progress is the share of
+// done todos among those not cancelled, from 0 to 1.
+type ProjectProgress struct {
+	Total      int     `json:"total"`
+	Open       int     `json:"open"`
+	InProgress int     `json:"in_progress"`
+	Done       int     `json:"done"`
+	Cancelled  int     `json:"cancelled"`
+	Overdue    int     `json:"overdue"`
+	Progress   float64 `json:"progress"`
+}
+
+// ProjectResponse adds the deadline as YYYY-MM-DD and the progress of the project
+type ProjectResponse struct {
+	Project
+	Deadline *string         `json:"deadline"`
+	Archived bool            `json:"archived"`
+	Progress ProjectProgress `json:"progress"`
+}
+
+func (p *Project) ToResponse(progress ProjectProgress) ProjectResponse {
+	r := ProjectResponse{Project: *p, Archived: p.ArchivedAt != nil, Progress: progress}
+	if p.Deadline != nil {
+		d := p.Deadline.Format("2006-01-02")
+		r.Deadline = &d
+	}
+	return r
+}
+
+// ProjectCreateRequest payload for creating a project; Deadline is YYYY-MM-DD
+type ProjectCreateRequest struct {
+	Name        string  `json:"name" binding:"required,max=100"`
+	Description string  `json:"description,omitempty"`
+	Goal        string  `json:"goal,omitempty"`
+	Deadline    string  `json:"deadline,omitempty"`
+	Status      string  `json:"status,omitempty" binding:"omitempty,oneof=planned active on_hold completed"`
+	Color       *string `json:"color,omitempty" binding:"omitempty,hexcolor,len=7"`
+}
+
+// ProjectUpdateRequest payload for updating a project; an empty deadline or color clears it
+type ProjectUpdateRequest struct {
+	Name        *string `json:"name,omitempty" binding:"omitempty,min=1,max=100"`
+	Description *string `json:"description,omitempty"`
+	Goal        *string `json:"goal,omitempty"`
+	Deadline    *string `json:"deadline,omitempty"`
+	Status      *string `json:"status,omitempty" binding:"omitempty,oneof=planned active on_hold completed"`
+	Color       *string `json:"color,omitempty" binding:"omitempty,eq=|hexcolor"`
+}
+
+// ProjectListQuery filters projects by status; archived ones are only listed on request
+type ProjectListQuery struct {
+	Status          string `form:"status" binding:"omitempty,oneof=planned active on_hold completed"`
+	IncludeArchived bool   `form:"include_archived"`
+}
+
+// ProjectTimelineQuery inclusive YYYY-MM-DD bounds on the dates of the notes
+type ProjectTimelineQuery struct {
+	From string `form:"from"`
+	To   string `form:"to"`
+}
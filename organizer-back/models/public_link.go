@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// PublicLink is an unauthenticated read-only link to a note
+type PublicLink struct {
+	ID              int        `json:"id" db:"id"`
+	NoteID          int        `json:"note_id" db:"note_id"`
+	UserID          int        `json:"user_id" db:"user_id"`
+	TokenHash       string     `json:"-" db:"token_hash"`
+	PasswordHash    *string    `json:"-" db:"password_hash"`
+	HiddenConfirmed bool       `json:"hidden_confirmed" db:"hidden_confirmed"`
+	ExpiresAt       *time.Time `json:"expires_at" db:"expires_at"`
+	RevokedAt       *time.Time `json:"revoked_at" db:"revoked_at"`
+	LastAccessedAt  *time.Time `json:"last_accessed_at" db:"last_accessed_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+}
+
+// PublicLinkResponse describes a link to its owner; Token is only present right after creation
+type PublicLinkResponse struct {
+	ID             int        `json:"id"`
+	NoteID         int        `json:"note_id"`
+	Token          string     `json:"token,omitempty"`
+	URL            string     `json:"url,omitempty"`
+	HasPassword    bool       `json:"has_password"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	LastAccessedAt *time.Time `json:"last_accessed_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// ToResponse converts PublicLink to PublicLinkResponse (without the token)
+func (l *PublicLink) ToResponse() PublicLinkResponse {
+	return PublicLinkResponse{
+		ID:             l.ID,
+		NoteID:         l.NoteID,
+		HasPassword:    l.PasswordHash != nil,
+		ExpiresAt:      l.ExpiresAt,
+		RevokedAt:      l.RevokedAt,
+		LastAccessedAt: l.LastAccessedAt,
+		CreatedAt:      l.CreatedAt,
+	}
+}
+
+// PublicLinkCreateRequest payload for publishing a note
+type PublicLinkCreateRequest struct {
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	Password      string     `json:"password,omitempty"`
+	ConfirmHidden bool       `json:"confirm_hidden"`
+}
+
+// PublicNoteResponse is what anonymous readers get; it omits owner details
+type PublicNoteResponse struct {
+	NoteDate  string    `json:"note_date"`
+	Content   string    `json:"content"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// Reminder notifies its owner at StartsAt, or at every occurrence of RRule, through Channels
+// (email, webhook and/or sse)
+type Reminder struct {
+	ID          int        `json:"id" db:"id"`
+	UserID      int        `json:"user_id" db:"user_id"`
+	Title       string     `json:"title" db:"title"`
+	Message     string     `json:"message" db:"message"`
+	NoteID      *int       `json:"note_id" db:"note_id"`
+	TodoID      *int       `json:"todo_id" db:"todo_id"`
+	StartsAt    time.Time  `json:"starts_at" db:"starts_at"`
+	Timezone    string     `json:"timezone" db:"timezone"`
+	RRule       *string    `json:"rrule" db:"rrule"`
+	Channels    []string   `json:"channels" db:"channels"`
+	WebhookURL  *string    `json:"webhook_url" db:"webhook_url"`
+	Active      bool       `json:"active" db:"active"`
+	NextFireAt  *time.Time `json:"next_fire_at" db:"next_fire_at"`
+	LastFiredAt *time.Time `json:"last_fired_at" db:"last_fired_at"`
+	FireCount   int        `json:"fire_count" db:"fire_count"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// ReminderRequest payload for creating or replacing a reminder. StartsAt is RFC 3339, or a
+// local "2006-01-02T15:04" read in Timezone (the caller's zone by default).
+type ReminderRequest struct {
+	Title      string   `json:"title" binding:"required,max=200"`
+	Message    string   `json:"message,omitempty"`
+	NoteID     *int     `json:"note_id,omitempty"`
+	TodoID     *int     `json:"todo_id,omitempty"`
+	StartsAt   string   `json:"starts_at" binding:"required"`
+	Timezone   string   `json:"timezone,omitempty" binding:"omitempty,timezone"`
+	RRule      string   `json:"rrule,omitempty"`
+	Channels   []string `json:"channels,omitempty" binding:"omitempty,dive,oneof=email webhook sse"`
+	WebhookURL string   `json:"webhook_url,omitempty" binding:"omitempty,url"`
+	Active     *bool    `json:"active,omitempty"`
+}
+
+// ReminderDelivery is one occurrence of a reminder sent through one channel
+type ReminderDelivery struct {
+	ID          int64      `json:"id"`
+	ReminderID  int        `json:"reminder_id"`
+	Channel     string     `json:"channel"`
+	FireAt      time.Time  `json:"fire_at"`
+	Status      string     `json:"status"` // pending, sent or failed
+	Attempts    int        `json:"attempts"`
+	LastError   *string    `json:"last_error"`
+	DeliveredAt *time.Time `json:"delivered_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ReminderNotification is what channels send for a delivery
+type ReminderNotification struct {
+	ReminderID int       `json:"reminder_id"`
+	Title      string    `json:"title"`
+	Message    string    `json:"message"`
+	FireAt     time.Time `json:"fire_at"`
+	NoteID     *int      `json:"note_id,omitempty"`
+	TodoID     *int      `json:"todo_id,omitempty"`
+}
@@ -0,0 +1,96 @@
+package models
+
+import "time"
+
+// Todo is a task of a user; subtasks are todos with a parent
+type Todo struct {
+	ID          int        `json:"id" db:"id"`
+	UserID      int        `json:"user_id" db:"user_id"`
+	UID         string     `json:"-" db:"uid"`      // iCalendar UID
+	DavName     *string    `json:"-" db:"dav_name"` // CalDAV resource name, when not UID.ics
+	ParentID    *int       `json:"parent_id" db:"parent_id"`
+	ProjectID   *int       `json:"project_id" db:"project_id"` // top-level todos only
+	Title       string     `json:"title" db:"title"`
+	Description string     `json:"description" db:"description"`
+	DueDate     *time.Time `json:"-" db:"due_date"`
+	Priority    string     `json:"priority" db:"priority"` // low, normal, high or urgent
+	Status      string     `json:"status" db:"status"`     // open, in_progress, done or cancelled
+	CompletedAt *time.Time `json:"completed_at" db:"completed_at"`
+	Position    string     `json:"-" db:"position"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// TodoResponse is returned to clients with the due date formatted as YYYY-MM-DD
+type TodoResponse struct {
+	ID          int            `json:"id"`
+	ParentID    *int           `json:"parent_id"`
+	ProjectID   *int           `json:"project_id"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	DueDate     *string        `json:"due_date"`
+	Priority    string         `json:"priority"`
+	Status      string         `json:"status"`
+	CompletedAt *time.Time     `json:"completed_at"`
+	Subtasks    []TodoResponse `json:"subtasks,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+func (t *Todo) ToResponse() TodoResponse {
+	r := TodoResponse{
+		ID:          t.ID,
+		ParentID:    t.ParentID,
+		ProjectID:   t.ProjectID,
+		Title:       t.Title,
+		Description: t.Description,
+		Priority:    t.Priority,
+		Status:      t.Status,
+		CompletedAt: t.CompletedAt,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+	}
+	if t.DueDate != nil {
+		d := t.DueDate.Format("2006-01-02")
+		r.DueDate = &d
+	}
+	return r
+}
+
+// TodoCreateRequest payload for creating a todo, or a subtask when ParentID is set
+type TodoCreateRequest struct {
+	ParentID    *int   `json:"parent_id,omitempty"`
+	ProjectID   *int   `json:"project_id,omitempty"`
+	Title       string `json:"title" binding:"required,max=200"`
+	Description string `json:"description,omitempty"`
+	DueDate     string `json:"due_date,omitempty"`
+	Priority    string `json:"priority,omitempty" binding:"omitempty,oneof=low normal high urgent"`
+	Status      string `json:"status,omitempty" binding:"omitempty,oneof=open in_progress done cancelled"`
+}
+
+// TodoUpdateRequest payload for updating a todo; an empty due_date clears it and a
+// project_id of 0 takes the todo out of its project
+type TodoUpdateRequest struct {
+	ProjectID   *int    `json:"project_id,omitempty" binding:"omitempty,min=0"`
+	Title       *string `json:"title,omitempty" binding:"omitempty,min=1,max=200"`
+	Description *string `json:"description,omitempty"`
+	DueDate     *string `json:"due_date,omitempty"`
+	Priority    *string `json:"priority,omitempty" binding:"omitempty,oneof=low normal high urgent"`
+	Status      *string `json:"status,omitempty" binding:"omitempty,oneof=open in_progress done cancelled"`
+}
+
+// TodoMoveRequest payload for reordering a todo among its siblings
+type TodoMoveRequest struct {
+	Before *int `json:"before,omitempty"`
+	After  *int `json:"after,omitempty"`
+}
+
+// TodoListQuery query parameters for listing todos. View is all (default), open, overdue,
+// today or completed; from/to are inclusive YYYY-MM-DD bounds on the completion date.
+type TodoListQuery struct {
+	View      string `form:"view" binding:"omitempty,oneof=all open overdue today completed"`
+	Priority  string `form:"priority" binding:"omitempty,oneof=low normal high urgent"`
+	ProjectID int    `form:"project_id" binding:"omitempty,min=1"`
+	From      string `form:"from"`
+	To        string `form:"to"`
+}
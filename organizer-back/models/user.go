@@ -13,6 +13,7 @@ type User struct {
 	Username     string    `json:"username" db:"username"`
 	PasswordHash string    `json:"-" db:"password_hash"`
 	Role         string    `json:"role" db:"role"`
+	Timezone     string    `json:"timezone" db:"timezone"` // IANA name, e.g. Europe/Madrid
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -25,6 +26,7 @@ type UserCreateRequest struct {
 	Username  string `json:"username" binding:"required"`
 	Password  string `json:"password" binding:"required,min=6"`
 	Role      string `json:"role" binding:"omitempty,oneof=admin generic"`
+	Timezone  string `json:"timezone" binding:"omitempty,timezone"`
 }
 
 // UserResponse represents the user data returned in API responses
@@ -35,6 +37,7 @@ type UserResponse struct {
 	Email     string    `json:"email"`
 	Username  string    `json:"username"`
 	Role      string    `json:"role"`
+	Timezone  string    `json:"timezone"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -48,6 +51,7 @@ func (u *User) ToResponse() UserResponse {
 		Email:     u.Email,
 		Username:  u.Username,
 		Role:      u.Role,
+		Timezone:  u.Timezone,
 		CreatedAt: u.CreatedAt,
 		UpdatedAt: u.UpdatedAt,
 	}
@@ -61,4 +65,11 @@ type UserUpdateRequest struct {
 	Username  string `json:"username" binding:"required"`
 	Password  string `json:"password"`
 	Role      string `json:"role" binding:"omitempty,oneof=admin generic"`
+	Timezone  string `json:"timezone" binding:"omitempty,timezone"`
+}
+
+// UserTimezoneRequest changes the caller's timezone. The timezone validator takes IANA names
+// only, refusing "" and "Local" like loadTimezone does.
+type UserTimezoneRequest struct {
+	Timezone string `json:"timezone" binding:"required,timezone"`
 }
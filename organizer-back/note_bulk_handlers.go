@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"organizer-back/models"
+	"organizer-back/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerNoteBulkRoutes wires bulk actions over many notes
+func registerNoteBulkRoutes(api *gin.RouterGroup, notesService *services.NotesService) {
+	api.POST("/notes/bulk", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		var req models.NoteBulkRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		res, err := notesService.Bulk(userID, &req)
+		if err != nil {
+			status := http.StatusBadRequest
+			if strings.HasSuffix(err.Error(), "not found") {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, res)
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"organizer-back/models"
+	"organizer-back/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerNoteExportRoutes wires the streaming notes export
+func registerNoteExportRoutes(api *gin.RouterGroup, notesService *services.NotesService) {
+	api.GET("/notes/export", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		var q models.NoteExportQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid format"})
+			return
+		}
+		from, to, err := services.ParseExportRange(&q)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		stamp := timeNow().Format("20060102")
+		switch q.Format {
+		case "markdown":
+			c.Header("Content-Type", "text/markdown; charset=utf-8")
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="notes-%s.md"`, stamp))
+		case "zip":
+			c.Header("Content-Type", "application/zip")
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="notes-%s.zip"`, stamp))
+		default:
+			c.Header("Content-Type", "application/json; charset=utf-8")
+			c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="notes-%s.json"`, stamp))
+		}
+		c.Status(http.StatusOK)
+		// Once bytes are on the wire the status cannot change, so a failure can only be logged
+		if err := notesService.Export(userID, q.Format, from, to, c.GetHeader("X-Decryption-Grant"), c.Writer); err != nil {
+			log.Printf("notes export for user %d failed: %v", userID, err)
+			c.Abort()
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"organizer-back/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerNoteLinkRoutes wires outgoing link resolution and backlinks of a note
+func registerNoteLinkRoutes(api *gin.RouterGroup, notesService *services.NotesService) {
+	api.GET("/notes/:id/links", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		links, err := notesService.Links(userID, id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, links)
+	})
+
+	api.GET("/notes/:id/backlinks", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		backlinks, err := notesService.Backlinks(userID, id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, backlinks)
+	})
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"net/http"
+	"organizer-back/models"
+	"organizer-back/services"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerPomodoroRoutes wires the history of pomodoro cycles and the focus aggregates
+func registerPomodoroRoutes(api *gin.RouterGroup, pomodorosService *services.PomodorosService, usersService *services.UsersService) {
+	pomodoroErrorStatus := func(err error) int {
+		if strings.HasSuffix(err.Error(), "not found") {
+			return http.StatusNotFound
+		}
+		if strings.HasPrefix(err.Error(), "timer is ") {
+			return http.StatusConflict
+		}
+		return http.StatusBadRequest
+	}
+
+	api.GET("/pomodoros", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		date := c.Query("date")
+		if date == "" {
+			now, ok := requestNow(c, usersService, userID)
+			if !ok {
+				return
+			}
+			date = now.Format("2006-01-02")
+		}
+		cycles, err := pomodorosService.ListByDate(userID, date)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, cycles)
+	})
+
+	api.GET("/pomodoros/stats", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		var q models.PomodoroStatsQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query"})
+			return
+		}
+		now, ok := requestNow(c, usersService, userID)
+		if !ok {
+			return
+		}
+		stats, err := pomodorosService.FocusStats(userID, &q, now)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, stats)
+	})
+
+	api.POST("/pomodoros", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		var req models.PomodoroCycleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		now, ok := requestNow(c, usersService, userID)
+		if !ok {
+			return
+		}
+		cycle, err := pomodorosService.Create(userID, &req, now)
+		if err != nil {
+			c.JSON(pomodoroErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, cycle)
+	})
+
+	api.GET("/pomodoros/:id", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		cycle, err := pomodorosService.Get(userID, id)
+		if err != nil {
+			c.JSON(pomodoroErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, cycle)
+	})
+
+	api.PUT("/pomodoros/:id", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		var req models.PomodoroCycleUpdateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		cycle, err := pomodorosService.Update(userID, id, &req)
+		if err != nil {
+			c.JSON(pomodoroErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, cycle)
+	})
+
+	api.DELETE("/pomodoros/:id", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		if err := pomodorosService.Delete(userID, id); err != nil {
+			c.JSON(pomodoroErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+	})
+
+	api.POST("/pomodoros/:id/intervals", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		var req models.PomodoroIntervalRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		now, ok := requestNow(c, usersService, userID)
+		if !ok {
+			return
+		}
+		interval, err := pomodorosService.AddInterval(userID, id, &req, now)
+		if err != nil {
+			c.JSON(pomodoroErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, interval)
+	})
+
+	api.GET("/pomodoros/timer", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		timer, err := pomodorosService.Timer(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, timer)
+	})
+
+	api.POST("/pomodoros/timer/start", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		var req models.PomodoroTimerStartRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+				return
+			}
+		}
+		timer, err := pomodorosService.StartTimer(userID, &req)
+		if err != nil {
+			c.JSON(pomodoroErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, timer)
+	})
+
+	// The other commands take no payload; the server's clock decides the timing
+	timerCommands := map[string]func(int) (*models.PomodoroTimerResponse, error){
+		"pause":  pomodorosService.PauseTimer,
+		"resume": pomodorosService.ResumeTimer,
+		"skip":   pomodorosService.SkipTimer,
+		"stop":   pomodorosService.StopTimer,
+	}
+	for name, command := range timerCommands {
+		command := command
+		api.POST("/pomodoros/timer/"+name, func(c *gin.Context) {
+			userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+			if userID == 0 {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+				return
+			}
+			timer, err := command(userID)
+			if err != nil {
+				c.JSON(pomodoroErrorStatus(err), gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, timer)
+		})
+	}
+}
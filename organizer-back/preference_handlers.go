@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"organizer-back/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Preferences are a handful of small values; anything bigger is not a valid patch
+const maxPreferencesPatchBytes = 16 << 10
+
+// registerPreferenceRoutes wires the caller's preferences and the admin-defined defaults
+func registerPreferenceRoutes(api *gin.RouterGroup, preferencesService *services.PreferencesService) {
+	preferenceErrorStatus := func(err error) int {
+		if strings.HasSuffix(err.Error(), "not found") {
+			return http.StatusNotFound
+		}
+		return http.StatusBadRequest
+	}
+
+	// readMergePatch reads an application/merge-patch+json (or plain JSON) body, writing the
+	// error response itself when it cannot
+	readMergePatch := func(c *gin.Context) ([]byte, bool) {
+		if ct := c.ContentType(); ct != "application/merge-patch+json" && ct != "application/json" {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "content type must be application/merge-patch+json"})
+			return nil, false
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxPreferencesPatchBytes)
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "payload too large"})
+				return nil, false
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return nil, false
+		}
+		return body, true
+	}
+
+	api.GET("/preferences/schemas", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.JSON(http.StatusOK, preferencesService.Schemas())
+	})
+
+	api.GET("/me/preferences", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		prefs, err := preferencesService.All(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, prefs)
+	})
+
+	api.GET("/me/preferences/:namespace", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		prefs, err := preferencesService.Get(userID, c.Param("namespace"))
+		if err != nil {
+			c.JSON(preferenceErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, prefs)
+	})
+
+	api.PATCH("/me/preferences/:namespace", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		patch, ok := readMergePatch(c)
+		if !ok {
+			return
+		}
+		prefs, err := preferencesService.Patch(userID, c.Param("namespace"), patch)
+		if err != nil {
+			c.JSON(preferenceErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, prefs)
+	})
+
+	api.GET("/preferences/defaults/:namespace", requireAdmin(), func(c *gin.Context) {
+		prefs, err := preferencesService.Defaults(c.Param("namespace"))
+		if err != nil {
+			c.JSON(preferenceErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, prefs)
+	})
+
+	api.PATCH("/preferences/defaults/:namespace", requireAdmin(), func(c *gin.Context) {
+		patch, ok := readMergePatch(c)
+		if !ok {
+			return
+		}
+		adminID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		prefs, err := preferencesService.PatchDefaults(adminID, c.Param("namespace"), patch)
+		if err != nil {
+			c.JSON(preferenceErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, prefs)
+	})
+}
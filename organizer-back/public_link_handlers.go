@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"html/template"
+	"math"
+	"net/http"
+	"organizer-back/models"
+	"organizer-back/services"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var publicNoteTemplate = template.Must(template.New("public-note").Parse(`<!doctype html>
+<html lang="es">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{if .Note}}Nota {{.Note.NoteDate}}{{else}}Nota protegida{{end}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 42rem; margin: 2rem auto; padding: 0 1rem; color: #222; }
+.content { white-space: pre-wrap; line-height: 1.5; }
+.meta { color: #777; font-size: .85rem; }
+</style>
+</head>
+<body>
+{{if .Note}}
+<h1>{{.Note.NoteDate}}</h1>
+<div class="content">{{.Note.Content}}</div>
+<p class="meta">Actualizada {{.Note.UpdatedAt.Format "2006-01-02 15:04"}}</p>
+{{else}}
+<h1>Nota protegida</h1>
+{{if .Error}}<p>{{.Error}}</p>{{end}}
+<form method="post">
+<input type="password" name="password" placeholder="Contraseña" autofocus required>
+<button type="submit">Ver nota</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+// registerPublicLinkRoutes wires link management for owners and the anonymous /p/:token reader.
+// Creating and revoking links needs a session token with a valid signature.
+func registerPublicLinkRoutes(r *gin.Engine, api *gin.RouterGroup, publicLinksService *services.PublicLinksService, authService *services.AuthService) {
+	api.POST("/notes/:id/public-link", func(c *gin.Context) {
+		userID := verifiedUserIDFromAuthHeader(authService, c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		var req models.PublicLinkCreateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+		link, err := publicLinksService.Create(userID, id, &req)
+		if err != nil {
+			status := http.StatusBadRequest
+			if err.Error() == "note not found" {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, link)
+	})
+
+	api.GET("/notes/:id/public-link", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		links, err := publicLinksService.List(userID, id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, links)
+	})
+
+	api.DELETE("/notes/:id/public-link/:linkId", func(c *gin.Context) {
+		userID := verifiedUserIDFromAuthHeader(authService, c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		linkID, err := strconv.Atoi(c.Param("linkId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid link id"})
+			return
+		}
+		if err := publicLinksService.Revoke(userID, id, linkID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "revoked"})
+	})
+
+	// Anonymous readers: GET shows the note (or a password form), POST submits the password.
+	// Wrong passwords lock the link out for a growing delay, answered with 429 and Retry-After.
+	servePublicNote := func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		c.Header("X-Robots-Tag", "noindex")
+		password := c.GetHeader("X-Link-Password")
+		if password == "" {
+			password = c.PostForm("password")
+		}
+		wantHTML := c.Query("format") == "html" ||
+			(c.Query("format") != "json" && strings.Contains(c.GetHeader("Accept"), "text/html"))
+
+		note, err := publicLinksService.Resolve(c.Param("token"), password)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch err.Error() {
+			case "link not found":
+				status = http.StatusNotFound
+			case "password required", "invalid password":
+				status = http.StatusUnauthorized
+			case "too many attempts":
+				status = http.StatusTooManyRequests
+			}
+			var locked *services.TooManyAttemptsError
+			if errors.As(err, &locked) {
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+			}
+			if wantHTML && (status == http.StatusUnauthorized || status == http.StatusTooManyRequests) {
+				msg := ""
+				switch err.Error() {
+				case "invalid password":
+					msg = "Contraseña incorrecta"
+				case "too many attempts":
+					msg = "Demasiados intentos, vuelve a probar en unos minutos"
+				}
+				c.Header("Content-Type", "text/html; charset=utf-8")
+				c.Status(status)
+				publicNoteTemplate.Execute(c.Writer, gin.H{"Note": nil, "Error": msg})
+				return
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if wantHTML {
+			c.Header("Content-Type", "text/html; charset=utf-8")
+			c.Status(http.StatusOK)
+			publicNoteTemplate.Execute(c.Writer, gin.H{"Note": note})
+			return
+		}
+		c.JSON(http.StatusOK, note)
+	}
+	r.GET("/p/:token", servePublicNote)
+	r.POST("/p/:token", servePublicNote)
+}
@@ -0,0 +1,112 @@
+// Package rank generates lexicographic ranks for user-defined ordering. A rank is a string
+// over a base-62 alphabet whose byte order matches the digit order, so ranks sort correctly
+// with plain byte comparison (COLLATE "C" in PostgreSQL). There is always a rank between two
+// distinct ranks, which lets an item move without renumbering its neighbours.
+package rank
+
+import (
+	"errors"
+	"strings"
+)
+
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var ErrInvalid = errors.New("invalid rank")
+
+// Between returns a rank strictly between a and b. An empty a means "before everything" and
+// an empty b "after everything", so Between("", "") gives a first rank. Ranks must not end in
+// the zero digit; ranks produced by this package never do.
+func Between(a, b string) (string, error) {
+	if !valid(a) || !valid(b) {
+		return "", ErrInvalid
+	}
+	if b != "" && a >= b {
+		return "", errors.New("rank bounds out of order")
+	}
+	return midpoint(a, b), nil
+}
+
+// After returns a rank greater than a, e.g. to append at the end of a list. It increments
+// the last digit that is not the maximum one, dropping the maximum digits after it, and only
+// extends a when every digit is already the maximum, so ranks grow by one digit every 61
+// appends instead of every few as a midpoint towards the end would.
+func After(a string) (string, error) {
+	if !valid(a) {
+		return "", ErrInvalid
+	}
+	if a == "" {
+		return midpoint("", ""), nil
+	}
+	top := digits[len(digits)-1]
+	for i := len(a) - 1; i >= 0; i-- {
+		if a[i] != top {
+			return a[:i] + string(digits[strings.IndexByte(digits, a[i])+1]), nil
+		}
+	}
+	return a + string(digits[1]), nil
+}
+
+// Before returns a rank smaller than b, e.g. to insert at the start of a list
+func Before(b string) (string, error) {
+	return Between("", b)
+}
+
+func valid(s string) bool {
+	if s == "" {
+		return true
+	}
+	if s[len(s)-1] == digits[0] {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(digits, s[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// midpoint assumes a < b (b == "" is +infinity) and that neither ends in the zero digit
+func midpoint(a, b string) string {
+	if b != "" {
+		// Skip the common prefix, reading missing digits of a as zero
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + midpoint(rest, b[n:])
+		}
+	}
+	lo := 0
+	if a != "" {
+		lo = strings.IndexByte(digits, a[0])
+	}
+	hi := len(digits)
+	if b != "" {
+		hi = strings.IndexByte(digits, b[0])
+	}
+	if hi-lo > 1 {
+		return string(digits[(lo+hi+1)/2])
+	}
+	// Adjacent first digits: b's first digit alone is small enough if b is longer
+	if b != "" && len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return string(digits[lo]) + midpoint(rest, "")
+}
+
+func digitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return digits[0]
+}
@@ -0,0 +1,66 @@
+package rank
+
+import "testing"
+
+func TestAfterIncrements(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", "V"},
+		{"V", "W"},
+		{"y", "z"},
+		{"z", "z1"},
+		{"Az", "B"},
+		{"A0z", "A1"},
+		{"zzy", "zzz"},
+		{"zzz", "zzz1"},
+	}
+	for _, tt := range tests {
+		got, err := After(tt.in)
+		if err != nil {
+			t.Fatalf("After(%q): %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("After(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAfterRejectsInvalid(t *testing.T) {
+	for _, in := range []string{"A0", "A-", "é"} {
+		if _, err := After(in); err != ErrInvalid {
+			t.Errorf("After(%q) error = %v, want ErrInvalid", in, err)
+		}
+	}
+}
+
+// Appending keeps ranks ordered and grows them by at most one digit per 61 appends
+func TestAfterKeyLengthBound(t *testing.T) {
+	const n = 10000
+	last := ""
+	for i := 0; i < n; i++ {
+		next, err := After(last)
+		if err != nil {
+			t.Fatalf("append %d: %v", i, err)
+		}
+		if !valid(next) || next <= last {
+			t.Fatalf("append %d: After(%q) = %q", i, last, next)
+		}
+		if max := 2 + i/(len(digits)-1); len(next) > max {
+			t.Fatalf("append %d: rank %q longer than %d", i, next, max)
+		}
+		last = next
+	}
+}
+
+func TestBetweenAfterAppends(t *testing.T) {
+	a, _ := After("")
+	b, _ := After(a)
+	c, err := Between(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(a < c && c < b) || !valid(c) {
+		t.Errorf("Between(%q, %q) = %q", a, b, c)
+	}
+}
@@ -0,0 +1,144 @@
+// Package realtime fans out per-user events to the server-sent event streams of connected
+// browsers. Events travel through PostgreSQL NOTIFY, so an event published by any instance
+// reaches the streams held by every instance. Delivery is best effort: a browser that is not
+// connected, or an instance whose listener is reconnecting, misses the event.
+package realtime
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	notifyChannel = "organizer_events"
+	// NOTIFY payloads are limited to 8000 bytes
+	maxPayload = 7900
+	// Events queued for a slow stream beyond this are dropped
+	subscriberBuffer = 32
+)
+
+var ErrTooLarge = errors.New("event too large")
+
+// Event is a message for one user's streams
+type Event struct {
+	UserID int             `json:"user_id"`
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type Broker struct {
+	db   *sql.DB
+	mu   sync.Mutex
+	subs map[int]map[chan Event]struct{}
+}
+
+// NewBroker publishes through db and listens with a dedicated connection to connInfo. With a
+// nil db events are only delivered to this instance's streams.
+func NewBroker(db *sql.DB, connInfo string) *Broker {
+	b := &Broker{db: db, subs: map[int]map[chan Event]struct{}{}}
+	if db != nil && connInfo != "" {
+		go b.listen(connInfo)
+	}
+	return b
+}
+
+// Publish sends an event of the given type to all streams of a user
+func (b *Broker) Publish(userID int, eventType string, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	ev := Event{UserID: userID, Type: eventType, Data: raw}
+	if b.db == nil {
+		b.dispatch(ev)
+		return nil
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxPayload {
+		return ErrTooLarge
+	}
+	_, err = b.db.Exec(`SELECT pg_notify($1, $2)`, notifyChannel, string(payload))
+	return err
+}
+
+// Subscribe returns a stream of the user's events and a function that ends the subscription
+func (b *Broker) Subscribe(userID int) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	if b.subs[userID] == nil {
+		b.subs[userID] = map[chan Event]struct{}{}
+	}
+	b.subs[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs[userID], ch)
+			if len(b.subs[userID]) == 0 {
+				delete(b.subs, userID)
+			}
+			b.mu.Unlock()
+		})
+	}
+}
+
+func (b *Broker) dispatch(ev Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[ev.UserID] {
+		select {
+		case ch <- ev:
+		default:
+			log.Printf("realtime: dropping %s event for user %d, stream is not keeping up", ev.Type, ev.UserID)
+		}
+	}
+}
+
+func (b *Broker) listen(connInfo string) {
+	l := pq.NewListener(connInfo, 10*time.Second, time.Minute, func(_ pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("realtime listener: %v", err)
+		}
+	})
+	listening := false
+	backoff := time.Second
+	for {
+		if !listening {
+			// Listen waits for a connection, so an error comes from the server (permissions,
+			// a failing LISTEN); keep retrying rather than leaving this instance deaf
+			if err := l.Listen(notifyChannel); err != nil && !errors.Is(err, pq.ErrChannelAlreadyOpen) {
+				log.Printf("realtime: cannot listen on %s, retrying in %s: %v", notifyChannel, backoff, err)
+				time.Sleep(backoff)
+				backoff = min(2*backoff, time.Minute)
+				continue
+			}
+			listening = true
+		}
+		select {
+		case n := <-l.Notify:
+			if n == nil {
+				// Reconnected; anything sent meanwhile is lost
+				continue
+			}
+			var ev Event
+			if err := json.Unmarshal([]byte(n.Extra), &ev); err != nil {
+				log.Printf("realtime: bad event: %v", err)
+				continue
+			}
+			b.dispatch(ev)
+		case <-time.After(90 * time.Second):
+			go l.Ping()
+		}
+	}
+}
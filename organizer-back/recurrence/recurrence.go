@@ -0,0 +1,478 @@
+// Package recurrence implements the subset of RFC 5545 recurrence rules (RRULE) used for
+// reminders and events: FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, COUNT, UNTIL,
+// BYDAY, BYMONTHDAY and BYMONTH. Occurrences keep the wall-clock time of the start in its
+// location, so "every day at 09:00" stays at 09:00 across daylight saving changes.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalid = errors.New("invalid recurrence rule")
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// WeekdayNum is a BYDAY entry: a weekday, optionally the Nth (or, negative, Nth from last)
+// of the month
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int
+}
+
+// Rule is a parsed RRULE
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int // 0 means unbounded
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+
+	// untilFloating marks an UNTIL without a zone, read in the start's location
+	untilFloating bool
+}
+
+// A rule that matches nothing for this many consecutive periods is considered exhausted
+const maxEmptyPeriods = 1000
+
+// Bounds on COUNT and INTERVAL, far beyond any real schedule, so a rule cannot make
+// expansion walk millions of periods
+const (
+	maxCount    = 10000
+	maxInterval = 1000
+)
+
+var weekdayCodes = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// Parse reads a rule such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10"; an "RRULE:" prefix is allowed
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 6 && strings.EqualFold(s[:6], "RRULE:") {
+		s = s[6:]
+	}
+	if s == "" {
+		return nil, ErrInvalid
+	}
+	r := &Rule{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		key = strings.ToUpper(strings.TrimSpace(key))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || value == "" || seen[key] {
+			return nil, ErrInvalid
+		}
+		seen[key] = true
+		var err error
+		switch key {
+		case "FREQ":
+			r.Freq = Frequency(value)
+			switch r.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				return nil, fmt.Errorf("%w: unsupported FREQ %s", ErrInvalid, value)
+			}
+		case "INTERVAL":
+			r.Interval, err = positive(value, maxInterval)
+		case "COUNT":
+			r.Count, err = positive(value, maxCount)
+		case "UNTIL":
+			r.Until, r.untilFloating, err = parseUntil(value)
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseInts(value, 1, 31, true)
+		case "BYMONTH":
+			var months []int
+			months, err = parseInts(value, 1, 12, false)
+			for _, m := range months {
+				r.ByMonth = append(r.ByMonth, time.Month(m))
+			}
+		case "BYSETPOS":
+			// Selecting by position within the period set ("last weekday of the month") is
+			// not implemented; ignoring it would silently widen the rule
+			err = fmt.Errorf("%w: BYSETPOS is not supported", ErrInvalid)
+		case "WKST":
+			// Weeks always start on Monday here
+			if value != "MO" {
+				err = fmt.Errorf("%w: only WKST=MO is supported", ErrInvalid)
+			}
+		default:
+			err = fmt.Errorf("%w: unsupported %s", ErrInvalid, key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalid)
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL are exclusive", ErrInvalid)
+	}
+	for _, d := range r.ByDay {
+		if d.N != 0 && r.Freq != Monthly && r.Freq != Yearly {
+			return nil, fmt.Errorf("%w: numbered BYDAY needs FREQ=MONTHLY or YEARLY", ErrInvalid)
+		}
+	}
+	if r.Freq == Yearly && len(r.ByDay) > 0 && len(r.ByMonth) == 0 {
+		return nil, fmt.Errorf("%w: yearly BYDAY needs BYMONTH", ErrInvalid)
+	}
+	return r, nil
+}
+
+// String renders the rule in canonical form, without the "RRULE:" prefix
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		if r.untilFloating {
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102T150405"))
+		} else {
+			parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+		}
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, d := range r.ByDay {
+			code := strings.ToUpper(d.Weekday.String()[:2])
+			if d.N != 0 {
+				code = strconv.Itoa(d.N) + code
+			}
+			days = append(days, code)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]int, 0, len(r.ByMonth))
+		for _, m := range r.ByMonth {
+			months = append(months, int(m))
+		}
+		parts = append(parts, "BYMONTH="+joinInts(months))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence strictly after after, or false when there is none
+func (r *Rule) Next(start, after time.Time) (time.Time, bool) {
+	var next time.Time
+	found := false
+	r.each(start, r.skipTo(start, after), func(t time.Time) bool {
+		if t.After(after) {
+			next, found = t, true
+			return false
+		}
+		return true
+	})
+	return next, found
+}
+
+// Between returns up to limit occurrences in [from, to)
+func (r *Rule) Between(start, from, to time.Time, limit int) []time.Time {
+	var out []time.Time
+	r.each(start, r.skipTo(start, from), func(t time.Time) bool {
+		if !t.Before(to) || len(out) >= limit {
+			return false
+		}
+		if !t.Before(from) {
+			out = append(out, t)
+		}
+		return true
+	})
+	return out
+}
+
+// each calls yield with the occurrences in order, starting at period k, until yield returns
+// false or the rule ends
+func (r *Rule) each(start time.Time, k int, yield func(time.Time) bool) {
+	until := r.Until
+	if r.untilFloating {
+		until = time.Date(until.Year(), until.Month(), until.Day(), until.Hour(), until.Minute(), until.Second(), 0, start.Location())
+	}
+	emitted, empty := 0, 0
+	for ; ; k++ {
+		candidates := r.candidates(start, k)
+		if len(candidates) == 0 {
+			if empty++; empty > maxEmptyPeriods {
+				return
+			}
+			continue
+		}
+		empty = 0
+		for _, t := range candidates {
+			if t.Before(start) {
+				continue
+			}
+			if !until.IsZero() && t.After(until) {
+				return
+			}
+			if !yield(t) {
+				return
+			}
+			if emitted++; r.Count > 0 && emitted >= r.Count {
+				return
+			}
+		}
+	}
+}
+
+// skipTo returns a period index safely before the one containing t. Rules with COUNT must be
+// walked from the start, since earlier occurrences count.
+func (r *Rule) skipTo(start, t time.Time) int {
+	if r.Count > 0 || !t.After(start) {
+		return 0
+	}
+	t = t.In(start.Location())
+	var periods int
+	switch r.Freq {
+	case Daily:
+		periods = daysBetween(start, t)
+	case Weekly:
+		periods = daysBetween(weekStart(start), weekStart(t)) / 7
+	case Monthly:
+		periods = (t.Year()-start.Year())*12 + int(t.Month()-start.Month())
+	case Yearly:
+		periods = t.Year() - start.Year()
+	}
+	if k := periods/r.Interval - 1; k > 0 {
+		return k
+	}
+	return 0
+}
+
+// candidates lists the matching times of the k-th period, in order
+func (r *Rule) candidates(start time.Time, k int) []time.Time {
+	y, m, d := start.Date()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+	}
+	var out []time.Time
+	switch r.Freq {
+	case Daily:
+		day := time.Date(y, m, d+k*r.Interval, 0, 0, 0, 0, time.UTC)
+		if r.monthAllowed(day.Month()) && r.dayMatches(day) {
+			out = append(out, at(day.Year(), day.Month(), day.Day()))
+		}
+	case Weekly:
+		monday := weekStart(start).AddDate(0, 0, 7*k*r.Interval)
+		weekdays := []time.Weekday{start.Weekday()}
+		if len(r.ByDay) > 0 {
+			weekdays = weekdays[:0]
+			for _, wd := range r.ByDay {
+				weekdays = append(weekdays, wd.Weekday)
+			}
+		}
+		for _, wd := range weekdays {
+			day := monday.AddDate(0, 0, (int(wd)+6)%7)
+			if r.monthAllowed(day.Month()) {
+				out = append(out, at(day.Year(), day.Month(), day.Day()))
+			}
+		}
+	case Monthly:
+		first := time.Date(y, m+time.Month(k*r.Interval), 1, 0, 0, 0, 0, time.UTC)
+		if r.monthAllowed(first.Month()) {
+			for _, day := range r.monthDays(first.Year(), first.Month(), d) {
+				out = append(out, at(first.Year(), first.Month(), day))
+			}
+		}
+	case Yearly:
+		year := y + k*r.Interval
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{m}
+		}
+		for _, month := range months {
+			for _, day := range r.monthDays(year, month, d) {
+				out = append(out, at(year, month, day))
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	// Duplicated BYDAY entries would otherwise repeat an occurrence
+	uniq := out[:0]
+	for i, t := range out {
+		if i == 0 || !t.Equal(out[i-1]) {
+			uniq = append(uniq, t)
+		}
+	}
+	return uniq
+}
+
+// monthDays returns the days of a month selected by BYDAY/BYMONTHDAY, or defaultDay when
+// neither is set. Days the month does not have (e.g. the 31st) are skipped.
+func (r *Rule) monthDays(year int, month time.Month, defaultDay int) []int {
+	n := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	var days []int
+	switch {
+	case len(r.ByDay) > 0:
+		for day := 1; day <= n; day++ {
+			if r.weekdayInMonthMatches(time.Date(year, month, day, 0, 0, 0, 0, time.UTC), n) && (len(r.ByMonthDay) == 0 || r.monthDayMatches(day, n)) {
+				days = append(days, day)
+			}
+		}
+	case len(r.ByMonthDay) > 0:
+		for day := 1; day <= n; day++ {
+			if r.monthDayMatches(day, n) {
+				days = append(days, day)
+			}
+		}
+	case defaultDay <= n:
+		days = append(days, defaultDay)
+	}
+	return days
+}
+
+func (r *Rule) weekdayInMonthMatches(day time.Time, daysInMonth int) bool {
+	for _, wd := range r.ByDay {
+		if wd.Weekday != day.Weekday() {
+			continue
+		}
+		switch {
+		case wd.N == 0,
+			wd.N > 0 && (day.Day()-1)/7+1 == wd.N,
+			wd.N < 0 && (daysInMonth-day.Day())/7+1 == -wd.N:
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) monthDayMatches(day, daysInMonth int) bool {
+	for _, md := range r.ByMonthDay {
+		if md == day || (md < 0 && daysInMonth+md+1 == day) {
+			return true
+		}
+	}
+	return false
+}
+
+// dayMatches applies BYDAY and BYMONTHDAY as filters, as used by DAILY rules
+func (r *Rule) dayMatches(day time.Time) bool {
+	n := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if len(r.ByMonthDay) > 0 && !r.monthDayMatches(day.Day(), n) {
+		return false
+	}
+	return len(r.ByDay) == 0 || r.weekdayInMonthMatches(day, n)
+}
+
+func (r *Rule) monthAllowed(m time.Month) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, bm := range r.ByMonth {
+		if bm == m {
+			return true
+		}
+	}
+	return false
+}
+
+// weekStart returns the Monday of t's week as a UTC date
+func weekStart(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, time.UTC)
+}
+
+// daysBetween counts calendar days from a to b, ignoring clock time and zone offsets
+func daysBetween(a, b time.Time) int {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return int(time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC).Sub(time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+}
+
+func positive(s string, max int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > max {
+		return 0, ErrInvalid
+	}
+	return n, nil
+}
+
+func parseUntil(s string) (time.Time, bool, error) {
+	if t, err := time.Parse("20060102T150405Z", s); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.Parse("20060102T150405", s); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse("20060102", s); err == nil {
+		// A date-only UNTIL includes that whole day
+		return t.Add(24*time.Hour - time.Second), true, nil
+	}
+	return time.Time{}, false, ErrInvalid
+}
+
+func parseByDay(s string) ([]WeekdayNum, error) {
+	var out []WeekdayNum
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) < 2 {
+			return nil, ErrInvalid
+		}
+		wd, ok := weekdayCodes[item[len(item)-2:]]
+		if !ok {
+			return nil, ErrInvalid
+		}
+		n := 0
+		if prefix := item[:len(item)-2]; prefix != "" {
+			v, err := strconv.Atoi(prefix)
+			if err != nil || v == 0 || v < -5 || v > 5 {
+				return nil, ErrInvalid
+			}
+			n = v
+		}
+		out = append(out, WeekdayNum{Weekday: wd, N: n})
+	}
+	return out, nil
+}
+
+func parseInts(s string, min, max int, allowNegative bool) ([]int, error) {
+	var out []int
+	for _, item := range strings.Split(s, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			return nil, ErrInvalid
+		}
+		abs := v
+		if allowNegative && v < 0 {
+			abs = -v
+		}
+		if abs < min || abs > max {
+			return nil, ErrInvalid
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, strconv.Itoa(v))
+	}
+	return strings.Join(parts, ",")
+}
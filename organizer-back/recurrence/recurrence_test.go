@@ -0,0 +1,237 @@
+package recurrence
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+// all returns every occurrence of a bounded rule, or the first 50
+func all(r *Rule, start time.Time) []time.Time {
+	return r.Between(start, start, start.AddDate(20, 0, 0), 50)
+}
+
+func formatAll(ts []time.Time, loc *time.Location) []string {
+	out := make([]string, len(ts))
+	for i, t := range ts {
+		out[i] = t.In(loc).Format("2006-01-02 15:04 MST")
+	}
+	return out
+}
+
+func TestOccurrences(t *testing.T) {
+	madrid := mustLocation(t, "Europe/Madrid")
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		want  []string
+	}{
+		{
+			name:  "monthly on the 31st skips short months",
+			rule:  "FREQ=MONTHLY;COUNT=5",
+			start: time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC),
+			want:  []string{"2025-01-31 09:00 UTC", "2025-03-31 09:00 UTC", "2025-05-31 09:00 UTC", "2025-07-31 09:00 UTC", "2025-08-31 09:00 UTC"},
+		},
+		{
+			name:  "last day of the month",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=4",
+			start: time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC),
+			want:  []string{"2024-01-31 09:00 UTC", "2024-02-29 09:00 UTC", "2024-03-31 09:00 UTC", "2024-04-30 09:00 UTC"},
+		},
+		{
+			name:  "last friday of the month",
+			rule:  "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			start: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC),
+			want:  []string{"2025-01-31 10:00 UTC", "2025-02-28 10:00 UTC", "2025-03-28 10:00 UTC"},
+		},
+		{
+			name:  "count",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC),
+			want:  []string{"2025-03-01 09:00 UTC", "2025-03-02 09:00 UTC", "2025-03-03 09:00 UTC"},
+		},
+		{
+			name:  "utc until is inclusive",
+			rule:  "FREQ=DAILY;UNTIL=20250303T090000Z",
+			start: time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC),
+			want:  []string{"2025-03-01 09:00 UTC", "2025-03-02 09:00 UTC", "2025-03-03 09:00 UTC"},
+		},
+		{
+			// 08:30 in Madrid; read as UTC it would be 09:30 and include the 3rd
+			name:  "floating until is read in the start's location",
+			rule:  "FREQ=DAILY;UNTIL=20250303T083000",
+			start: time.Date(2025, 3, 1, 9, 0, 0, 0, madrid),
+			want:  []string{"2025-03-01 09:00 CET", "2025-03-02 09:00 CET"},
+		},
+		{
+			name:  "date-only until includes the whole day",
+			rule:  "FREQ=DAILY;UNTIL=20250303",
+			start: time.Date(2025, 3, 1, 21, 0, 0, 0, madrid),
+			want:  []string{"2025-03-01 21:00 CET", "2025-03-02 21:00 CET", "2025-03-03 21:00 CET"},
+		},
+		{
+			name:  "interval with byday",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=5",
+			start: time.Date(2025, 1, 6, 8, 0, 0, 0, time.UTC),
+			want:  []string{"2025-01-06 08:00 UTC", "2025-01-10 08:00 UTC", "2025-01-20 08:00 UTC", "2025-01-24 08:00 UTC", "2025-02-03 08:00 UTC"},
+		},
+		{
+			name:  "byday before the start in its first week is skipped",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=3",
+			start: time.Date(2025, 1, 8, 8, 0, 0, 0, time.UTC),
+			want:  []string{"2025-01-09 08:00 UTC", "2025-01-13 08:00 UTC", "2025-01-16 08:00 UTC"},
+		},
+		{
+			name:  "february 29 only in leap years",
+			rule:  "FREQ=YEARLY;COUNT=3",
+			start: time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC),
+			want:  []string{"2024-02-29 12:00 UTC", "2028-02-29 12:00 UTC", "2032-02-29 12:00 UTC"},
+		},
+		{
+			name:  "wall clock kept across spring forward",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: time.Date(2025, 3, 29, 9, 0, 0, 0, madrid),
+			want:  []string{"2025-03-29 09:00 CET", "2025-03-30 09:00 CEST", "2025-03-31 09:00 CEST"},
+		},
+		{
+			name:  "wall clock kept across fall back",
+			rule:  "FREQ=WEEKLY;COUNT=2",
+			start: time.Date(2025, 10, 20, 9, 0, 0, 0, madrid),
+			want:  []string{"2025-10-20 09:00 CEST", "2025-10-27 09:00 CET"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.rule, err)
+			}
+			got := formatAll(all(r, tt.start), tt.start.Location())
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("occurrences\n got: %v\nwant: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Chaining Next from the start must give the same occurrences as Between, also when
+// Between starts mid-series and skips periods
+func TestNextMatchesBetween(t *testing.T) {
+	madrid := mustLocation(t, "Europe/Madrid")
+	start := time.Date(2024, 1, 31, 9, 0, 0, 0, madrid)
+	end := start.AddDate(3, 0, 0)
+	rules := []string{
+		"FREQ=DAILY;INTERVAL=3",
+		"FREQ=DAILY;BYDAY=SA,SU",
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR",
+		"FREQ=MONTHLY",
+		"FREQ=MONTHLY;BYMONTHDAY=-1,15",
+		"FREQ=MONTHLY;INTERVAL=2;BYDAY=-1FR,1MO",
+		"FREQ=YEARLY;BYMONTH=2,3;BYMONTHDAY=29",
+		"FREQ=DAILY;COUNT=40",
+		"FREQ=WEEKLY;UNTIL=20250101T000000",
+	}
+	for _, s := range rules {
+		t.Run(s, func(t *testing.T) {
+			r, err := Parse(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var chained []time.Time
+			after := start.Add(-time.Nanosecond)
+			for len(chained) < 1000 {
+				next, ok := r.Next(start, after)
+				if !ok || !next.Before(end) {
+					break
+				}
+				chained = append(chained, next)
+				after = next
+			}
+			between := r.Between(start, start, end, 1000)
+			if len(chained) == 0 || strings.Join(formatAll(chained, madrid), ",") != strings.Join(formatAll(between, madrid), ",") {
+				t.Fatalf("Next and Between disagree\nnext:    %v\nbetween: %v", formatAll(chained, madrid), formatAll(between, madrid))
+			}
+			from := start.AddDate(1, 4, 0)
+			var suffix []time.Time
+			for _, o := range between {
+				if !o.Before(from) {
+					suffix = append(suffix, o)
+				}
+			}
+			if got := r.Between(start, from, end, 1000); strings.Join(formatAll(got, madrid), ",") != strings.Join(formatAll(suffix, madrid), ",") {
+				t.Errorf("Between from %v\n got: %v\nwant: %v", from, formatAll(got, madrid), formatAll(suffix, madrid))
+			}
+		})
+	}
+}
+
+func TestNextEndsWithRule(t *testing.T) {
+	start := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	r, err := Parse("FREQ=DAILY;COUNT=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Next(start, start.AddDate(0, 0, 1)); ok {
+		t.Error("Next found an occurrence past COUNT")
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;COUNT=10001",
+		"FREQ=DAILY;COUNT=99999999999999999999",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;INTERVAL=1001",
+		"FREQ=DAILY;COUNT=2;UNTIL=20250101",
+		"FREQ=DAILY;COUNT=2;COUNT=3",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=YEARLY;BYDAY=MO",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+		"FREQ=WEEKLY;WKST=SU",
+	} {
+		if _, err := Parse(s); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalid", s, err)
+		}
+	}
+	for _, s := range []string{"FREQ=DAILY;COUNT=10000", "FREQ=YEARLY;INTERVAL=1000"} {
+		if _, err := Parse(s); err != nil {
+			t.Errorf("Parse(%q) error = %v", s, err)
+		}
+	}
+	if _, err := Parse("FREQ=MONTHLY;BYDAY=FR;BYSETPOS=-1"); err == nil || !strings.Contains(err.Error(), "BYSETPOS") {
+		t.Errorf("BYSETPOS error = %v", err)
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	for _, s := range []string{
+		"FREQ=WEEKLY;INTERVAL=2;COUNT=5;BYDAY=MO,FR",
+		"FREQ=MONTHLY;UNTIL=20250303T083000;BYDAY=-1FR",
+		"FREQ=YEARLY;UNTIL=20300101T000000Z;BYMONTHDAY=-1;BYMONTH=2",
+	} {
+		r, err := Parse("RRULE:" + s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.String(); got != s {
+			t.Errorf("String() = %q, want %q", got, s)
+		}
+	}
+}
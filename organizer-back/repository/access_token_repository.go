@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+)
+
+type AccessTokenRepository struct {
+	db *sql.DB
+}
+
+func NewAccessTokenRepository() *AccessTokenRepository {
+	return &AccessTokenRepository{db: database.DB}
+}
+
+func (r *AccessTokenRepository) Create(t *models.PersonalAccessToken) error {
+	query := `INSERT INTO personal_access_tokens (user_id, name, token_hash, expires_at) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	if err := r.db.QueryRow(query, t.UserID, t.Name, t.TokenHash, t.ExpiresAt).Scan(&t.ID, &t.CreatedAt); err != nil {
+		return fmt.Errorf("error creating access token: %v", err)
+	}
+	return nil
+}
+
+func (r *AccessTokenRepository) List(userID int) ([]models.PersonalAccessToken, error) {
+	query := `SELECT id, user_id, name, token_hash, last_used_at, expires_at, created_at FROM personal_access_tokens WHERE user_id=$1 ORDER BY created_at DESC, id DESC`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing access tokens: %v", err)
+	}
+	defer rows.Close()
+
+	tokens := []models.PersonalAccessToken{}
+	for rows.Next() {
+		var t models.PersonalAccessToken
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.TokenHash, &t.LastUsedAt, &t.ExpiresAt, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning access token: %v", err)
+		}
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating access tokens: %v", err)
+	}
+	return tokens, nil
+}
+
+func (r *AccessTokenRepository) Delete(userID, id int) error {
+	res, err := r.db.Exec(`DELETE FROM personal_access_tokens WHERE id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting access token: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error deleting access token: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("access token not found")
+	}
+	return nil
+}
+
+// Resolve returns the owner of an unexpired token and records its use
+func (r *AccessTokenRepository) Resolve(tokenHash string) (int, error) {
+	query := `
+		UPDATE personal_access_tokens SET last_used_at=NOW()
+		WHERE token_hash=$1 AND (expires_at IS NULL OR expires_at > NOW())
+		RETURNING user_id
+	`
+	var userID int
+	err := r.db.QueryRow(query, tokenHash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("access token not found")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error resolving access token: %v", err)
+	}
+	return userID, nil
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+)
+
+type AttachmentRepository struct {
+	db *sql.DB
+}
+
+func NewAttachmentRepository() *AttachmentRepository {
+	return &AttachmentRepository{db: database.DB}
+}
+
+// CreateWithinQuota inserts the attachment only if the user's total usage stays within quota.
+// The user row is locked so concurrent uploads cannot both slip under the limit.
+func (r *AttachmentRepository) CreateWithinQuota(a *models.Attachment, defaultQuota int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error creating attachment: %v", err)
+	}
+	defer tx.Rollback()
+
+	var quota sql.NullInt64
+	if err := tx.QueryRow(`SELECT attachment_quota_bytes FROM users WHERE id=$1 FOR UPDATE`, a.UserID).Scan(&quota); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("user not found")
+		}
+		return fmt.Errorf("error checking attachment quota: %v", err)
+	}
+	limit := defaultQuota
+	if quota.Valid {
+		limit = quota.Int64
+	}
+	var used int64
+	if err := tx.QueryRow(`SELECT COALESCE(SUM(size_bytes), 0) FROM note_attachments WHERE user_id=$1`, a.UserID).Scan(&used); err != nil {
+		return fmt.Errorf("error checking attachment quota: %v", err)
+	}
+	if used+a.SizeBytes > limit {
+		return fmt.Errorf("attachment quota exceeded")
+	}
+
+	query := `INSERT INTO note_attachments (note_id, user_id, file_name, content_type, size_bytes, storage_key) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	if err := tx.QueryRow(query, a.NoteID, a.UserID, a.FileName, a.ContentType, a.SizeBytes, a.StorageKey).Scan(&a.ID, &a.CreatedAt); err != nil {
+		return fmt.Errorf("error creating attachment: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error creating attachment: %v", err)
+	}
+	return nil
+}
+
+func (r *AttachmentRepository) ListByNote(noteID int) ([]models.Attachment, error) {
+	query := `SELECT id, note_id, user_id, file_name, content_type, size_bytes, storage_key, created_at FROM note_attachments WHERE note_id=$1 ORDER BY created_at ASC, id ASC`
+	rows, err := r.db.Query(query, noteID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing attachments: %v", err)
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		var a models.Attachment
+		if err := rows.Scan(&a.ID, &a.NoteID, &a.UserID, &a.FileName, &a.ContentType, &a.SizeBytes, &a.StorageKey, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning attachment: %v", err)
+		}
+		attachments = append(attachments, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating attachments: %v", err)
+	}
+	return attachments, nil
+}
+
+func (r *AttachmentRepository) GetByID(noteID, id int) (*models.Attachment, error) {
+	query := `SELECT id, note_id, user_id, file_name, content_type, size_bytes, storage_key, created_at FROM note_attachments WHERE id=$1 AND note_id=$2`
+	var a models.Attachment
+	if err := r.db.QueryRow(query, id, noteID).Scan(&a.ID, &a.NoteID, &a.UserID, &a.FileName, &a.ContentType, &a.SizeBytes, &a.StorageKey, &a.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("attachment not found")
+		}
+		return nil, fmt.Errorf("error getting attachment: %v", err)
+	}
+	return &a, nil
+}
+
+// Delete removes the attachment row and returns its storage key so the blob can be removed
+func (r *AttachmentRepository) Delete(noteID, id int) (string, error) {
+	var key string
+	if err := r.db.QueryRow(`DELETE FROM note_attachments WHERE id=$1 AND note_id=$2 RETURNING storage_key`, id, noteID).Scan(&key); err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("attachment not found")
+		}
+		return "", fmt.Errorf("error deleting attachment: %v", err)
+	}
+	return key, nil
+}
+
+// StorageKeysByNote returns the blob keys of every attachment on a note
+func (r *AttachmentRepository) StorageKeysByNote(noteID int) ([]string, error) {
+	rows, err := r.db.Query(`SELECT storage_key FROM note_attachments WHERE note_id=$1`, noteID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing attachment keys: %v", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			return nil, fmt.Errorf("error scanning attachment key: %v", err)
+		}
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating attachment keys: %v", err)
+	}
+	return keys, nil
+}
+
+// Usage returns the bytes stored by a user and their quota override, if any
+func (r *AttachmentRepository) Usage(userID int) (int64, sql.NullInt64, error) {
+	query := `SELECT COALESCE((SELECT SUM(size_bytes) FROM note_attachments WHERE user_id=$1), 0), attachment_quota_bytes FROM users WHERE id=$1`
+	var used int64
+	var quota sql.NullInt64
+	if err := r.db.QueryRow(query, userID).Scan(&used, &quota); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, quota, fmt.Errorf("user not found")
+		}
+		return 0, quota, fmt.Errorf("error getting attachment usage: %v", err)
+	}
+	return used, quota, nil
+}
+
+// SetQuota overrides a user's quota; nil resets it to the server default
+func (r *AttachmentRepository) SetQuota(userID int, quota *int64) error {
+	res, err := r.db.Exec(`UPDATE users SET attachment_quota_bytes=$1, updated_at=NOW() WHERE id=$2`, quota, userID)
+	if err != nil {
+		return fmt.Errorf("error setting attachment quota: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error setting attachment quota: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+)
+
+type AuditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository() *AuditRepository {
+	return &AuditRepository{db: database.DB}
+}
+
+// Record appends an entry to the audit trail
+func (r *AuditRepository) Record(actorUserID int, action, entityType string, entityID int, details map[string]interface{}) error {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	payload, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("error encoding audit details: %v", err)
+	}
+	query := `INSERT INTO audit_log (actor_user_id, action, entity_type, entity_id, details) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := r.db.Exec(query, actorUserID, action, entityType, entityID, string(payload)); err != nil {
+		return fmt.Errorf("error recording audit entry: %v", err)
+	}
+	return nil
+}
+
+// ListByEntity returns the most recent entries for an entity, newest first
+func (r *AuditRepository) ListByEntity(entityType string, entityID, limit int) ([]models.AuditEntry, error) {
+	query := `SELECT id, actor_user_id, action, entity_type, entity_id, details, created_at FROM audit_log WHERE entity_type=$1 AND entity_id=$2 ORDER BY created_at DESC, id DESC LIMIT $3`
+	rows, err := r.db.Query(query, entityType, entityID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing audit entries: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		var actor sql.NullInt64
+		var details []byte
+		if err := rows.Scan(&e.ID, &actor, &e.Action, &e.EntityType, &e.EntityID, &details, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning audit entry: %v", err)
+		}
+		if actor.Valid {
+			id := int(actor.Int64)
+			e.ActorUserID = &id
+		}
+		e.Details = json.RawMessage(details)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit entries: %v", err)
+	}
+	return entries, nil
+}
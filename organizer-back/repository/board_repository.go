@@ -0,0 +1,485 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+	"organizer-back/rank"
+	"sort"
+
+	"github.com/lib/pq"
+)
+
+type BoardRepository struct {
+	db *sql.DB
+}
+
+func NewBoardRepository() *BoardRepository {
+	return &BoardRepository{db: database.DB}
+}
+
+const boardColumnColumns = `id, project_id, name, position, wip_limit, status, created_at, updated_at`
+
+func boardColumnFields(c *models.BoardColumn) []interface{} {
+	return []interface{}{&c.ID, &c.ProjectID, &c.Name, &c.Position, &c.WIPLimit, &c.Status, &c.CreatedAt, &c.UpdatedAt}
+}
+
+// BoardColumnSeed is a column of the board a project starts with
+type BoardColumnSeed struct {
+	Name   string
+	Status string
+}
+
+// BoardLane is a column together with the todos shown in it, in order
+type BoardLane struct {
+	Column models.BoardColumn
+	Todos  []models.Todo
+}
+
+// boardCard is a top-level todo of a project with its stored place on the board
+type boardCard struct {
+	todo     models.Todo
+	columnID int    // 0 when never placed or its column was deleted
+	position string // "" when never placed
+}
+
+type boardQueryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// lockBoard serializes changes to the columns and cards of a project's board
+func lockBoard(tx *sql.Tx, projectID int) error {
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('board'), $1)`, projectID); err != nil {
+		return fmt.Errorf("error locking board: %v", err)
+	}
+	return nil
+}
+
+// SeedColumns gives a project its default columns, only if it never had any
+func (r *BoardRepository) SeedColumns(projectID int, seeds []BoardColumnSeed) error {
+	names := make([]string, len(seeds))
+	statuses := make([]string, len(seeds))
+	positions := make([]string, len(seeds))
+	last := ""
+	for i, s := range seeds {
+		position, err := rank.After(last)
+		if err != nil {
+			return fmt.Errorf("error computing position: %v", err)
+		}
+		names[i], statuses[i], positions[i], last = s.Name, s.Status, position, position
+	}
+	query := `
+		INSERT INTO board_columns (project_id, name, status, position)
+		SELECT $1, s.name, NULLIF(s.status, ''), s.position
+		FROM unnest($2::text[], $3::text[], $4::text[]) AS s(name, status, position)
+		WHERE NOT EXISTS (SELECT 1 FROM board_columns WHERE project_id=$1)
+		ON CONFLICT (project_id, position) DO NOTHING
+	`
+	if _, err := r.db.Exec(query, projectID, pq.Array(names), pq.Array(statuses), pq.Array(positions)); err != nil {
+		return fmt.Errorf("error seeding board columns: %v", err)
+	}
+	return nil
+}
+
+func listBoardColumns(q boardQueryer, projectID int) ([]models.BoardColumn, error) {
+	rows, err := q.Query(`SELECT `+boardColumnColumns+` FROM board_columns WHERE project_id=$1 ORDER BY position ASC`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing board columns: %v", err)
+	}
+	defer rows.Close()
+
+	columns := []models.BoardColumn{}
+	for rows.Next() {
+		var c models.BoardColumn
+		if err := rows.Scan(boardColumnFields(&c)...); err != nil {
+			return nil, fmt.Errorf("error scanning board column: %v", err)
+		}
+		columns = append(columns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating board columns: %v", err)
+	}
+	return columns, nil
+}
+
+func listBoardCards(q boardQueryer, userID, projectID int) ([]boardCard, error) {
+	query := `SELECT ` + todoColumns + `, COALESCE(board_column_id, 0), COALESCE(board_position, '')
+		FROM todos WHERE user_id=$1 AND project_id=$2 AND parent_id IS NULL`
+	rows, err := q.Query(query, userID, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing board cards: %v", err)
+	}
+	defer rows.Close()
+
+	cards := []boardCard{}
+	for rows.Next() {
+		var c boardCard
+		if err := rows.Scan(append(todoFields(&c.todo), &c.columnID, &c.position)...); err != nil {
+			return nil, fmt.Errorf("error scanning board card: %v", err)
+		}
+		cards = append(cards, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating board cards: %v", err)
+	}
+	return cards, nil
+}
+
+// layoutBoard decides the column of every card. A card stays where it was placed unless
+// that column maps to another status than the todo has, e.g. after it was completed
+// outside the board; it then goes, like cards never placed, to the first column mapped to
+// its status, or to the first column. Cancelled todos are only shown when a column maps
+// to them. Placed cards come first in rank order, followed by the rest in todo order.
+func layoutBoard(columns []models.BoardColumn, cards []boardCard) [][]boardCard {
+	lanes := make([][]boardCard, len(columns))
+	if len(columns) == 0 {
+		return lanes
+	}
+	index := map[int]int{}
+	byStatus := map[string]int{}
+	for i, c := range columns {
+		index[c.ID] = i
+		if c.Status != nil {
+			if _, ok := byStatus[*c.Status]; !ok {
+				byStatus[*c.Status] = i
+			}
+		}
+	}
+	for _, card := range cards {
+		lane, placed := index[card.columnID]
+		if placed {
+			if s := columns[lane].Status; s != nil && *s != card.todo.Status {
+				placed = false
+			}
+		}
+		if !placed {
+			mapped, ok := byStatus[card.todo.Status]
+			switch {
+			case ok:
+				lane = mapped
+			case card.todo.Status == "cancelled":
+				continue
+			default:
+				lane = 0
+			}
+			card.position = ""
+			if card.columnID != columns[lane].ID {
+				card.columnID = 0
+			}
+		}
+		lanes[lane] = append(lanes[lane], card)
+	}
+	for _, lane := range lanes {
+		sort.SliceStable(lane, func(i, j int) bool {
+			a, b := lane[i], lane[j]
+			if (a.position == "") != (b.position == "") {
+				return a.position != ""
+			}
+			if a.position != b.position {
+				return a.position < b.position
+			}
+			if a.todo.Position != b.todo.Position {
+				return a.todo.Position < b.todo.Position
+			}
+			return a.todo.ID < b.todo.ID
+		})
+	}
+	return lanes
+}
+
+// Board returns the columns of a project's board with their todos in order
+func (r *BoardRepository) Board(userID, projectID int) ([]BoardLane, error) {
+	columns, err := listBoardColumns(r.db, projectID)
+	if err != nil {
+		return nil, err
+	}
+	cards, err := listBoardCards(r.db, userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	lanes := layoutBoard(columns, cards)
+	res := make([]BoardLane, len(columns))
+	for i := range columns {
+		res[i] = BoardLane{Column: columns[i], Todos: make([]models.Todo, len(lanes[i]))}
+		for j, card := range lanes[i] {
+			res[i].Todos[j] = card.todo
+		}
+	}
+	return res, nil
+}
+
+// CreateColumn appends a column to the board
+func (r *BoardRepository) CreateColumn(c *models.BoardColumn) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error creating board column: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockBoard(tx, c.ProjectID); err != nil {
+		return err
+	}
+	var last sql.NullString
+	if err := tx.QueryRow(`SELECT MAX(position) FROM board_columns WHERE project_id=$1`, c.ProjectID).Scan(&last); err != nil {
+		return fmt.Errorf("error reading positions: %v", err)
+	}
+	position, err := rank.After(last.String)
+	if err != nil {
+		return fmt.Errorf("error computing position: %v", err)
+	}
+	query := `
+		INSERT INTO board_columns (project_id, name, position, wip_limit, status)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + boardColumnColumns
+	if err := tx.QueryRow(query, c.ProjectID, c.Name, position, c.WIPLimit, c.Status).Scan(boardColumnFields(c)...); err != nil {
+		return fmt.Errorf("error creating board column: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error creating board column: %v", err)
+	}
+	return nil
+}
+
+func (r *BoardRepository) UpdateColumn(c *models.BoardColumn) error {
+	query := `
+		UPDATE board_columns SET name=$1, wip_limit=$2, status=$3, updated_at=NOW()
+		WHERE id=$4 AND project_id=$5
+		RETURNING ` + boardColumnColumns
+	if err := r.db.QueryRow(query, c.Name, c.WIPLimit, c.Status, c.ID, c.ProjectID).Scan(boardColumnFields(c)...); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("board column not found")
+		}
+		return fmt.Errorf("error updating board column: %v", err)
+	}
+	return nil
+}
+
+// DeleteColumn removes a column but never the last one; its cards go back to the column
+// their status maps to
+func (r *BoardRepository) DeleteColumn(projectID, id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error deleting board column: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockBoard(tx, projectID); err != nil {
+		return err
+	}
+	var count int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM board_columns WHERE project_id=$1`, projectID).Scan(&count); err != nil {
+		return fmt.Errorf("error deleting board column: %v", err)
+	}
+	res, err := tx.Exec(`DELETE FROM board_columns WHERE id=$1 AND project_id=$2`, id, projectID)
+	if err != nil {
+		return fmt.Errorf("error deleting board column: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error deleting board column: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("board column not found")
+	}
+	if count <= 1 {
+		return fmt.Errorf("a board needs at least one column")
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error deleting board column: %v", err)
+	}
+	return nil
+}
+
+// MoveColumn places a column right before or after another column of the board
+func (r *BoardRepository) MoveColumn(projectID, id, targetID int, after bool) (*models.BoardColumn, error) {
+	if id == targetID {
+		return nil, fmt.Errorf("cannot move a column relative to itself")
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error moving board column: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockBoard(tx, projectID); err != nil {
+		return nil, err
+	}
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM board_columns WHERE id=$1 AND project_id=$2)`, id, projectID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("error moving board column: %v", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("board column not found")
+	}
+	var targetPos string
+	if err := tx.QueryRow(`SELECT position FROM board_columns WHERE id=$1 AND project_id=$2`, targetID, projectID).Scan(&targetPos); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("target board column not found")
+		}
+		return nil, fmt.Errorf("error moving board column: %v", err)
+	}
+
+	var neighbour sql.NullString
+	var lo, hi string
+	if after {
+		err = tx.QueryRow(`SELECT MIN(position) FROM board_columns WHERE project_id=$1 AND position > $2 AND id <> $3`, projectID, targetPos, id).Scan(&neighbour)
+		lo, hi = targetPos, neighbour.String
+	} else {
+		err = tx.QueryRow(`SELECT MAX(position) FROM board_columns WHERE project_id=$1 AND position < $2 AND id <> $3`, projectID, targetPos, id).Scan(&neighbour)
+		lo, hi = neighbour.String, targetPos
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading positions: %v", err)
+	}
+	position, err := rank.Between(lo, hi)
+	if err != nil {
+		return nil, fmt.Errorf("error computing position: %v", err)
+	}
+
+	var c models.BoardColumn
+	if err := tx.QueryRow(`UPDATE board_columns SET position=$1, updated_at=NOW() WHERE id=$2 AND project_id=$3 RETURNING `+boardColumnColumns, position, id, projectID).Scan(boardColumnFields(&c)...); err != nil {
+		return nil, fmt.Errorf("error moving board column: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error moving board column: %v", err)
+	}
+	return &c, nil
+}
+
+// MoveCard puts a todo of the project in a column, right before or after one of the cards
+// shown there (beforeID/afterID, 0 for none), or last. Moving into another column respects
+// its WIP limit, and a column mapped to a status gives it to the todo.
+func (r *BoardRepository) MoveCard(userID, projectID, todoID, columnID, beforeID, afterID int) (*models.Todo, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error moving card: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockBoard(tx, projectID); err != nil {
+		return nil, err
+	}
+	columns, err := listBoardColumns(tx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	cards, err := listBoardCards(tx, userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	target := -1
+	for i := range columns {
+		if columns[i].ID == columnID {
+			target = i
+		}
+	}
+	if target < 0 {
+		return nil, fmt.Errorf("board column not found")
+	}
+	var moving *boardCard
+	for i := range cards {
+		if cards[i].todo.ID == todoID {
+			moving = &cards[i]
+		}
+	}
+	if moving == nil {
+		return nil, fmt.Errorf("card not found")
+	}
+
+	lanes := layoutBoard(columns, cards)
+	var lane []boardCard
+	sameColumn := false
+	for i, l := range lanes {
+		for _, card := range l {
+			if card.todo.ID == todoID {
+				sameColumn = i == target
+				continue
+			}
+			if i == target {
+				lane = append(lane, card)
+			}
+		}
+	}
+	col := columns[target]
+	if !sameColumn && col.WIPLimit != nil && len(lane) >= *col.WIPLimit {
+		return nil, fmt.Errorf("column %q reached its WIP limit of %d", col.Name, *col.WIPLimit)
+	}
+
+	at := len(lane)
+	if beforeID != 0 || afterID != 0 {
+		ref := beforeID
+		if afterID != 0 {
+			ref = afterID
+		}
+		at = -1
+		for i, card := range lane {
+			if card.todo.ID == ref {
+				at = i
+			}
+		}
+		if at < 0 {
+			return nil, fmt.Errorf("target card not found")
+		}
+		if afterID != 0 {
+			at++
+		}
+	}
+
+	// Cards shown in the column only by the status rules have no rank there yet; the
+	// column is then ranked again in its current order
+	renumber := false
+	for _, card := range lane {
+		if card.columnID != columnID || card.position == "" {
+			renumber = true
+		}
+	}
+	var position string
+	if renumber {
+		ordered := append(append(append([]boardCard{}, lane[:at]...), *moving), lane[at:]...)
+		last := ""
+		for _, card := range ordered {
+			p, err := rank.After(last)
+			if err != nil {
+				return nil, fmt.Errorf("error computing position: %v", err)
+			}
+			last = p
+			if card.todo.ID == todoID {
+				position = p
+				continue
+			}
+			if _, err := tx.Exec(`UPDATE todos SET board_column_id=$1, board_position=$2 WHERE id=$3 AND user_id=$4`, columnID, p, card.todo.ID, userID); err != nil {
+				return nil, fmt.Errorf("error moving card: %v", err)
+			}
+		}
+	} else {
+		var lo, hi string
+		if at > 0 {
+			lo = lane[at-1].position
+		}
+		if at < len(lane) {
+			hi = lane[at].position
+		}
+		if position, err = rank.Between(lo, hi); err != nil {
+			return nil, fmt.Errorf("error computing position: %v", err)
+		}
+	}
+
+	status := moving.todo.Status
+	if col.Status != nil {
+		status = *col.Status
+	}
+	var t models.Todo
+	query := `
+		UPDATE todos SET board_column_id=$1, board_position=$2, status=$3,
+		       completed_at=CASE WHEN $4 THEN COALESCE(completed_at, NOW()) END, updated_at=NOW()
+		WHERE id=$5 AND user_id=$6
+		RETURNING ` + todoColumns
+	if err := tx.QueryRow(query, columnID, position, status, status == "done", todoID, userID).Scan(todoFields(&t)...); err != nil {
+		return nil, fmt.Errorf("error moving card: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error moving card: %v", err)
+	}
+	return &t, nil
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+)
+
+type CalendarFeedRepository struct {
+	db *sql.DB
+}
+
+func NewCalendarFeedRepository() *CalendarFeedRepository {
+	return &CalendarFeedRepository{db: database.DB}
+}
+
+// Get returns the user's feed, or nil when they have none
+func (r *CalendarFeedRepository) Get(userID int) (*models.CalendarFeed, error) {
+	var f models.CalendarFeed
+	err := r.db.QueryRow(`SELECT user_id, token_hash, last_accessed_at, created_at FROM calendar_feeds WHERE user_id=$1`, userID).
+		Scan(&f.UserID, &f.TokenHash, &f.LastAccessedAt, &f.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting calendar feed: %v", err)
+	}
+	return &f, nil
+}
+
+// Rotate stores a new token for the user's feed, invalidating the previous one
+func (r *CalendarFeedRepository) Rotate(userID int, tokenHash string) (*models.CalendarFeed, error) {
+	query := `
+		INSERT INTO calendar_feeds (user_id, token_hash) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, last_accessed_at = NULL, created_at = NOW()
+		RETURNING user_id, token_hash, last_accessed_at, created_at
+	`
+	var f models.CalendarFeed
+	if err := r.db.QueryRow(query, userID, tokenHash).Scan(&f.UserID, &f.TokenHash, &f.LastAccessedAt, &f.CreatedAt); err != nil {
+		return nil, fmt.Errorf("error saving calendar feed: %v", err)
+	}
+	return &f, nil
+}
+
+func (r *CalendarFeedRepository) Delete(userID int) error {
+	res, err := r.db.Exec(`DELETE FROM calendar_feeds WHERE user_id=$1`, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting calendar feed: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error deleting calendar feed: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("calendar feed not found")
+	}
+	return nil
+}
+
+// Resolve returns the owner of a feed token and records the access
+func (r *CalendarFeedRepository) Resolve(tokenHash string) (int, error) {
+	var userID int
+	err := r.db.QueryRow(`UPDATE calendar_feeds SET last_accessed_at=NOW() WHERE token_hash=$1 RETURNING user_id`, tokenHash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("calendar feed not found")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error resolving calendar feed: %v", err)
+	}
+	return userID, nil
+}
@@ -0,0 +1,402 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"organizer-back/database"
+	"organizer-back/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type EmotionRepository struct {
+	db *sql.DB
+}
+
+func NewEmotionRepository() *EmotionRepository {
+	return &EmotionRepository{db: database.DB}
+}
+
+const emotionLabelColumns = `id, user_id, name, color, archived, created_at`
+
+const emotionEntryColumns = `id, user_id, mood, intensity, comment, note_id, felt_at, entry_date, created_at, updated_at`
+
+func emotionEntryFields(e *models.EmotionEntry) []interface{} {
+	return []interface{}{&e.ID, &e.UserID, &e.Mood, &e.Intensity, &e.Comment, &e.NoteID, &e.FeltAt, &e.EntryDate, &e.CreatedAt, &e.UpdatedAt}
+}
+
+// SeedLabels gives a user the default catalog, only if they never had any label
+func (r *EmotionRepository) SeedLabels(userID int, names []string) error {
+	query := `
+		INSERT INTO emotion_labels (user_id, name)
+		SELECT $1, unnest($2::text[])
+		WHERE NOT EXISTS (SELECT 1 FROM emotion_labels WHERE user_id=$1)
+		ON CONFLICT (user_id, name) DO NOTHING
+	`
+	if _, err := r.db.Exec(query, userID, pq.Array(names)); err != nil {
+		return fmt.Errorf("error seeding emotion labels: %v", err)
+	}
+	return nil
+}
+
+func (r *EmotionRepository) ListLabels(userID int, includeArchived bool) ([]models.EmotionLabel, error) {
+	query := `SELECT ` + emotionLabelColumns + ` FROM emotion_labels WHERE user_id=$1 AND ($2 OR NOT archived) ORDER BY name ASC`
+	rows, err := r.db.Query(query, userID, includeArchived)
+	if err != nil {
+		return nil, fmt.Errorf("error listing emotion labels: %v", err)
+	}
+	defer rows.Close()
+
+	labels := []models.EmotionLabel{}
+	for rows.Next() {
+		var l models.EmotionLabel
+		if err := rows.Scan(&l.ID, &l.UserID, &l.Name, &l.Color, &l.Archived, &l.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning emotion label: %v", err)
+		}
+		labels = append(labels, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating emotion labels: %v", err)
+	}
+	return labels, nil
+}
+
+// CreateLabel adds a label; re-creating an archived label restores it
+func (r *EmotionRepository) CreateLabel(l *models.EmotionLabel) error {
+	query := `
+		INSERT INTO emotion_labels (user_id, name, color) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, name) DO UPDATE SET archived=FALSE, color=EXCLUDED.color WHERE emotion_labels.archived
+		RETURNING ` + emotionLabelColumns
+	if err := r.db.QueryRow(query, l.UserID, l.Name, l.Color).Scan(&l.ID, &l.UserID, &l.Name, &l.Color, &l.Archived, &l.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("an emotion label with that name already exists")
+		}
+		return fmt.Errorf("error creating emotion label: %v", err)
+	}
+	return nil
+}
+
+func (r *EmotionRepository) UpdateLabel(l *models.EmotionLabel) error {
+	query := `UPDATE emotion_labels SET name=$1, color=$2 WHERE id=$3 AND user_id=$4 RETURNING ` + emotionLabelColumns
+	if err := r.db.QueryRow(query, l.Name, l.Color, l.ID, l.UserID).Scan(&l.ID, &l.UserID, &l.Name, &l.Color, &l.Archived, &l.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("emotion label not found")
+		}
+		if isUniqueViolation(err) {
+			return fmt.Errorf("an emotion label with that name already exists")
+		}
+		return fmt.Errorf("error updating emotion label: %v", err)
+	}
+	return nil
+}
+
+// DeleteLabel deletes an unused label and archives one that entries refer to. It reports
+// whether the label was archived.
+func (r *EmotionRepository) DeleteLabel(userID, id int) (bool, error) {
+	res, err := r.db.Exec(`DELETE FROM emotion_labels WHERE id=$1 AND user_id=$2 AND NOT EXISTS (SELECT 1 FROM emotion_entry_labels WHERE label_id=$1)`, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("error deleting emotion label: %v", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return false, fmt.Errorf("error deleting emotion label: %v", err)
+	} else if affected > 0 {
+		return false, nil
+	}
+	res, err = r.db.Exec(`UPDATE emotion_labels SET archived=TRUE WHERE id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("error archiving emotion label: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error archiving emotion label: %v", err)
+	}
+	if affected == 0 {
+		return false, fmt.Errorf("emotion label not found")
+	}
+	return true, nil
+}
+
+// ListEntries returns the user's entries dated within [from, to], latest first
+func (r *EmotionRepository) ListEntries(userID int, from, to time.Time) ([]models.EmotionEntry, error) {
+	query := `SELECT ` + emotionEntryColumns + ` FROM emotion_entries WHERE user_id=$1 AND entry_date BETWEEN $2 AND $3 ORDER BY felt_at DESC, id DESC`
+	rows, err := r.db.Query(query, userID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing emotion entries: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []models.EmotionEntry{}
+	for rows.Next() {
+		var e models.EmotionEntry
+		if err := rows.Scan(emotionEntryFields(&e)...); err != nil {
+			return nil, fmt.Errorf("error scanning emotion entry: %v", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating emotion entries: %v", err)
+	}
+	if err := r.attachLabels(entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *EmotionRepository) GetEntry(userID, id int) (*models.EmotionEntry, error) {
+	var e models.EmotionEntry
+	if err := r.db.QueryRow(`SELECT `+emotionEntryColumns+` FROM emotion_entries WHERE id=$1 AND user_id=$2`, id, userID).Scan(emotionEntryFields(&e)...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("emotion entry not found")
+		}
+		return nil, fmt.Errorf("error getting emotion entry: %v", err)
+	}
+	entries := []models.EmotionEntry{e}
+	if err := r.attachLabels(entries); err != nil {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+// attachLabels loads the labels of all entries with one query
+func (r *EmotionRepository) attachLabels(entries []models.EmotionEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	ids := make([]int, len(entries))
+	index := map[int]int{}
+	for i := range entries {
+		ids[i] = entries[i].ID
+		index[entries[i].ID] = i
+		entries[i].Labels = []models.EmotionLabel{}
+	}
+	query := `
+		SELECT el.entry_id, l.id, l.user_id, l.name, l.color, l.archived, l.created_at
+		FROM emotion_entry_labels el
+		JOIN emotion_labels l ON l.id = el.label_id
+		WHERE el.entry_id = ANY($1)
+		ORDER BY l.name ASC
+	`
+	rows, err := r.db.Query(query, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("error listing entry labels: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entryID int
+		var l models.EmotionLabel
+		if err := rows.Scan(&entryID, &l.ID, &l.UserID, &l.Name, &l.Color, &l.Archived, &l.CreatedAt); err != nil {
+			return fmt.Errorf("error scanning entry label: %v", err)
+		}
+		e := &entries[index[entryID]]
+		e.Labels = append(e.Labels, l)
+	}
+	return rows.Err()
+}
+
+func (r *EmotionRepository) CreateEntry(e *models.EmotionEntry, labelIDs []int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error creating emotion entry: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO emotion_entries (user_id, mood, intensity, comment, note_id, felt_at, entry_date)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + emotionEntryColumns
+	if err := tx.QueryRow(query, e.UserID, e.Mood, e.Intensity, e.Comment, e.NoteID, e.FeltAt, e.EntryDate.Format("2006-01-02")).Scan(emotionEntryFields(e)...); err != nil {
+		return fmt.Errorf("error creating emotion entry: %v", err)
+	}
+	if err := setEntryLabels(tx, e.UserID, e.ID, labelIDs); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error creating emotion entry: %v", err)
+	}
+	return nil
+}
+
+func (r *EmotionRepository) UpdateEntry(e *models.EmotionEntry, labelIDs []int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error updating emotion entry: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE emotion_entries SET mood=$1, intensity=$2, comment=$3, note_id=$4, felt_at=$5, entry_date=$6, updated_at=NOW()
+		WHERE id=$7 AND user_id=$8
+		RETURNING ` + emotionEntryColumns
+	if err := tx.QueryRow(query, e.Mood, e.Intensity, e.Comment, e.NoteID, e.FeltAt, e.EntryDate.Format("2006-01-02"), e.ID, e.UserID).Scan(emotionEntryFields(e)...); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("emotion entry not found")
+		}
+		return fmt.Errorf("error updating emotion entry: %v", err)
+	}
+	if err := setEntryLabels(tx, e.UserID, e.ID, labelIDs); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error updating emotion entry: %v", err)
+	}
+	return nil
+}
+
+// setEntryLabels replaces the labels of an entry; every label must be an active one of the user
+func setEntryLabels(tx *sql.Tx, userID, entryID int, labelIDs []int) error {
+	if _, err := tx.Exec(`DELETE FROM emotion_entry_labels WHERE entry_id=$1`, entryID); err != nil {
+		return fmt.Errorf("error setting entry labels: %v", err)
+	}
+	unique := map[int]bool{}
+	for _, id := range labelIDs {
+		unique[id] = true
+	}
+	if len(unique) == 0 {
+		return nil
+	}
+	res, err := tx.Exec(`
+		INSERT INTO emotion_entry_labels (entry_id, label_id)
+		SELECT $1, id FROM emotion_labels WHERE user_id=$2 AND NOT archived AND id = ANY($3)`,
+		entryID, userID, pq.Array(labelIDs))
+	if err != nil {
+		return fmt.Errorf("error setting entry labels: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error setting entry labels: %v", err)
+	}
+	if int(affected) != len(unique) {
+		return fmt.Errorf("emotion label not found")
+	}
+	return nil
+}
+
+func (r *EmotionRepository) DeleteEntry(userID, id int) error {
+	res, err := r.db.Exec(`DELETE FROM emotion_entries WHERE id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting emotion entry: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error deleting emotion entry: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("emotion entry not found")
+	}
+	return nil
+}
+
+// Trends aggregates mood per day or per week (keyed by its Monday) within [from, to]
+func (r *EmotionRepository) Trends(userID int, group string, from, to time.Time) ([]models.MoodTrendPoint, error) {
+	if group != "day" && group != "week" {
+		return nil, fmt.Errorf("invalid group %q", group)
+	}
+	query := `
+		SELECT to_char(date_trunc($2, entry_date::timestamp), 'YYYY-MM-DD') AS period,
+		       COUNT(*), AVG(mood)::float8, MIN(mood), MAX(mood), AVG(intensity)::float8
+		FROM emotion_entries
+		WHERE user_id=$1 AND entry_date BETWEEN $3 AND $4
+		GROUP BY period
+		ORDER BY period ASC
+	`
+	rows, err := r.db.Query(query, userID, group, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("error computing mood trends: %v", err)
+	}
+	defer rows.Close()
+
+	points := []models.MoodTrendPoint{}
+	for rows.Next() {
+		var p models.MoodTrendPoint
+		if err := rows.Scan(&p.Period, &p.Entries, &p.AvgMood, &p.MinMood, &p.MaxMood, &p.AvgIntensity); err != nil {
+			return nil, fmt.Errorf("error scanning mood trend: %v", err)
+		}
+		p.AvgMood = round2(p.AvgMood)
+		if p.AvgIntensity != nil {
+			v := round2(*p.AvgIntensity)
+			p.AvgIntensity = &v
+		}
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating mood trends: %v", err)
+	}
+	return points, nil
+}
+
+// TagCorrelations compares, for each tag of the user's notes, the mood of entries on days
+// with notes carrying that tag against all entries in [from, to]. Tags seen on fewer than
+// minDays days are left out; the tags whose mood differs most come first.
+func (r *EmotionRepository) TagCorrelations(userID int, from, to time.Time, minDays, limit int) ([]models.MoodTagCorrelation, error) {
+	query := `
+		WITH e AS (
+			SELECT entry_date, mood FROM emotion_entries
+			WHERE user_id=$1 AND entry_date BETWEEN $2 AND $3
+		), tagged AS (
+			SELECT DISTINCT n.note_date, t.tag
+			FROM notes n CROSS JOIN LATERAL unnest(n.tags) AS t(tag)
+			WHERE n.user_id=$1 AND n.note_date BETWEEN $2 AND $3
+		), overall AS (
+			SELECT AVG(mood)::float8 AS avg_mood FROM e
+		)
+		SELECT t.tag, COUNT(DISTINCT e.entry_date), COUNT(*), AVG(e.mood)::float8, AVG(e.mood)::float8 - overall.avg_mood AS diff
+		FROM e
+		JOIN tagged t ON t.note_date = e.entry_date
+		CROSS JOIN overall
+		GROUP BY t.tag, overall.avg_mood
+		HAVING COUNT(DISTINCT e.entry_date) >= $4
+		ORDER BY ABS(AVG(e.mood)::float8 - overall.avg_mood) DESC, t.tag ASC
+		LIMIT $5
+	`
+	rows, err := r.db.Query(query, userID, from.Format("2006-01-02"), to.Format("2006-01-02"), minDays, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error computing mood correlations: %v", err)
+	}
+	defer rows.Close()
+
+	out := []models.MoodTagCorrelation{}
+	for rows.Next() {
+		var c models.MoodTagCorrelation
+		if err := rows.Scan(&c.Tag, &c.Days, &c.Entries, &c.AvgMood, &c.Difference); err != nil {
+			return nil, fmt.Errorf("error scanning mood correlation: %v", err)
+		}
+		c.AvgMood, c.Difference = round2(c.AvgMood), round2(c.Difference)
+		out = append(out, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating mood correlations: %v", err)
+	}
+	return out, nil
+}
+
+// DailyMood returns the average mood of each day with entries in [from, to)
+func (r *EmotionRepository) DailyMood(userID int, from, to time.Time) (map[string]float64, error) {
+	query := `
+		SELECT to_char(entry_date, 'YYYY-MM-DD'), AVG(mood)::float8
+		FROM emotion_entries
+		WHERE user_id=$1 AND entry_date >= $2 AND entry_date < $3
+		GROUP BY entry_date
+	`
+	rows, err := r.db.Query(query, userID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("error computing daily mood: %v", err)
+	}
+	defer rows.Close()
+
+	moods := map[string]float64{}
+	for rows.Next() {
+		var day string
+		var mood float64
+		if err := rows.Scan(&day, &mood); err != nil {
+			return nil, fmt.Errorf("error scanning daily mood: %v", err)
+		}
+		moods[day] = round2(mood)
+	}
+	return moods, rows.Err()
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+)
+
+type EncryptionRepository struct {
+	db *sql.DB
+}
+
+func NewEncryptionRepository() *EncryptionRepository {
+	return &EncryptionRepository{db: database.DB}
+}
+
+func (r *EncryptionRepository) Get(userID int) (*models.UserEncryptionKey, error) {
+	var k models.UserEncryptionKey
+	query := `SELECT user_id, mode, wrapped_key, kdf_salt, kdf_params, created_at, updated_at FROM user_encryption_keys WHERE user_id=$1`
+	if err := r.db.QueryRow(query, userID).Scan(&k.UserID, &k.Mode, &k.WrappedKey, &k.KDFSalt, &k.KDFParams, &k.CreatedAt, &k.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("encryption not set up")
+		}
+		return nil, fmt.Errorf("error getting encryption key: %v", err)
+	}
+	return &k, nil
+}
+
+func (r *EncryptionRepository) Create(k *models.UserEncryptionKey) error {
+	query := `INSERT INTO user_encryption_keys (user_id, mode, wrapped_key, kdf_salt, kdf_params) VALUES ($1, $2, $3, $4, $5) RETURNING created_at, updated_at`
+	if err := r.db.QueryRow(query, k.UserID, k.Mode, k.WrappedKey, k.KDFSalt, jsonParam(k.KDFParams)).Scan(&k.CreatedAt, &k.UpdatedAt); err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("encryption already set up")
+		}
+		return fmt.Errorf("error creating encryption key: %v", err)
+	}
+	return nil
+}
+
+// Rewrap replaces the wrapped data key, e.g. after a passphrase change; notes are untouched
+func (r *EncryptionRepository) Rewrap(k *models.UserEncryptionKey) error {
+	query := `UPDATE user_encryption_keys SET wrapped_key=$1, kdf_salt=$2, kdf_params=$3, updated_at=NOW() WHERE user_id=$4`
+	if _, err := r.db.Exec(query, k.WrappedKey, k.KDFSalt, jsonParam(k.KDFParams), k.UserID); err != nil {
+		return fmt.Errorf("error updating encryption key: %v", err)
+	}
+	return nil
+}
+
+// jsonParam passes raw JSON as text: lib/pq would otherwise send []byte as bytea
+func jsonParam(b []byte) interface{} {
+	if b == nil {
+		return nil
+	}
+	return string(b)
+}
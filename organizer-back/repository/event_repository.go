@@ -0,0 +1,355 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type EventRepository struct {
+	db *sql.DB
+}
+
+func NewEventRepository() *EventRepository {
+	return &EventRepository{db: database.DB}
+}
+
+// exdates are read as JSON so their time zone survives whatever the session's DateStyle is
+const eventColumns = `e.id, e.user_id, e.uid, e.title, e.description, e.location, e.starts_at, e.ends_at, e.all_day, e.timezone, e.rrule, to_json(e.exdates), e.recurrence_end, e.dav_name, e.created_at, e.updated_at`
+
+func scanEvent(row interface{ Scan(...interface{}) error }, e *models.Event) error {
+	var exdates []byte
+	if err := row.Scan(&e.ID, &e.UserID, &e.UID, &e.Title, &e.Description, &e.Location, &e.StartsAt, &e.EndsAt, &e.AllDay,
+		&e.Timezone, &e.RRule, &exdates, &e.RecurrenceEnd, &e.DavName, &e.CreatedAt, &e.UpdatedAt); err != nil {
+		return err
+	}
+	e.ExDates = []time.Time{}
+	return json.Unmarshal(exdates, &e.ExDates)
+}
+
+// exdateArray formats exception dates for a $n::timestamptz[] parameter
+func exdateArray(dates []time.Time) interface{} {
+	values := make([]string, len(dates))
+	for i, d := range dates {
+		values[i] = d.Format(time.RFC3339Nano)
+	}
+	return pq.Array(values)
+}
+
+// ListInRange returns the events the user owns or attends that may have an occurrence
+// overlapping [from, to), with their attendees
+func (r *EventRepository) ListInRange(userID int, from, to time.Time) ([]models.Event, error) {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events e
+		WHERE (e.user_id = $1 OR EXISTS (SELECT 1 FROM event_attendees a WHERE a.event_id = e.id AND a.user_id = $1))
+		  AND e.starts_at < $3
+		  AND (CASE WHEN e.rrule IS NULL THEN e.ends_at > $2 OR e.ends_at = e.starts_at AND e.starts_at >= $2
+		            ELSE e.recurrence_end IS NULL OR e.recurrence_end > $2 END)
+		ORDER BY e.starts_at ASC, e.id ASC
+	`
+	rows, err := r.db.Query(query, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error listing events: %v", err)
+	}
+	defer rows.Close()
+
+	events := []models.Event{}
+	for rows.Next() {
+		var e models.Event
+		if err := scanEvent(rows, &e); err != nil {
+			return nil, fmt.Errorf("error scanning event: %v", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating events: %v", err)
+	}
+	if err := r.attachAttendees(events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// GetByID returns an event the user owns or attends
+func (r *EventRepository) GetByID(userID, id int) (*models.Event, error) {
+	query := `
+		SELECT ` + eventColumns + ` FROM events e
+		WHERE e.id = $1 AND (e.user_id = $2 OR EXISTS (SELECT 1 FROM event_attendees a WHERE a.event_id = e.id AND a.user_id = $2))
+	`
+	var e models.Event
+	if err := scanEvent(r.db.QueryRow(query, id, userID), &e); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("event not found")
+		}
+		return nil, fmt.Errorf("error getting event: %v", err)
+	}
+	events := []models.Event{e}
+	if err := r.attachAttendees(events); err != nil {
+		return nil, err
+	}
+	return &events[0], nil
+}
+
+// GetByUID returns one of the user's own events by its iCalendar UID
+func (r *EventRepository) GetByUID(userID int, uid string) (*models.Event, error) {
+	var e models.Event
+	if err := scanEvent(r.db.QueryRow(`SELECT `+eventColumns+` FROM events e WHERE e.user_id = $1 AND e.uid = $2`, userID, uid), &e); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("event not found")
+		}
+		return nil, fmt.Errorf("error getting event: %v", err)
+	}
+	events := []models.Event{e}
+	if err := r.attachAttendees(events); err != nil {
+		return nil, err
+	}
+	return &events[0], nil
+}
+
+// ListOwned returns all of the user's own events, without attendees
+func (r *EventRepository) ListOwned(userID int) ([]models.Event, error) {
+	rows, err := r.db.Query(`SELECT `+eventColumns+` FROM events e WHERE e.user_id = $1 ORDER BY e.starts_at ASC, e.id ASC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing events: %v", err)
+	}
+	defer rows.Close()
+
+	events := []models.Event{}
+	for rows.Next() {
+		var e models.Event
+		if err := scanEvent(rows, &e); err != nil {
+			return nil, fmt.Errorf("error scanning event: %v", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating events: %v", err)
+	}
+	return events, nil
+}
+
+// GetByDavName returns one of the user's own events by its CalDAV resource name
+func (r *EventRepository) GetByDavName(userID int, name string) (*models.Event, error) {
+	query := `SELECT ` + eventColumns + ` FROM events e WHERE e.user_id = $1 AND COALESCE(e.dav_name, e.uid || '.ics') = $2`
+	var e models.Event
+	if err := scanEvent(r.db.QueryRow(query, userID, name), &e); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("event not found")
+		}
+		return nil, fmt.Errorf("error getting event: %v", err)
+	}
+	events := []models.Event{e}
+	if err := r.attachAttendees(events); err != nil {
+		return nil, err
+	}
+	return &events[0], nil
+}
+
+// Stamp summarizes the user's own events; it changes whenever one is added, changed or
+// removed
+func (r *EventRepository) Stamp(userID int) (int, *time.Time, error) {
+	var count int
+	var last *time.Time
+	if err := r.db.QueryRow(`SELECT COUNT(*), MAX(updated_at) FROM events WHERE user_id=$1`, userID).Scan(&count, &last); err != nil {
+		return 0, nil, fmt.Errorf("error reading events: %v", err)
+	}
+	return count, last, nil
+}
+
+// ListForFeed returns every event the user owns or has not declined, with their attendees
+func (r *EventRepository) ListForFeed(userID int) ([]models.Event, error) {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events e
+		WHERE e.user_id = $1
+		   OR EXISTS (SELECT 1 FROM event_attendees a WHERE a.event_id = e.id AND a.user_id = $1 AND a.status <> 'declined')
+		ORDER BY e.starts_at ASC, e.id ASC
+	`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing events: %v", err)
+	}
+	defer rows.Close()
+
+	events := []models.Event{}
+	for rows.Next() {
+		var e models.Event
+		if err := scanEvent(rows, &e); err != nil {
+			return nil, fmt.Errorf("error scanning event: %v", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating events: %v", err)
+	}
+	if err := r.attachAttendees(events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// attachAttendees loads the attendees of all events with one query
+func (r *EventRepository) attachAttendees(events []models.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	ids := make([]int, len(events))
+	index := map[int]int{}
+	for i := range events {
+		ids[i] = events[i].ID
+		index[events[i].ID] = i
+		events[i].Attendees = []models.EventAttendee{}
+	}
+	query := `
+		SELECT a.event_id, u.id, u.username, btrim(u.first_name || ' ' || u.last_name), a.status
+		FROM event_attendees a
+		JOIN users u ON u.id = a.user_id
+		WHERE a.event_id = ANY($1)
+		ORDER BY u.username ASC
+	`
+	rows, err := r.db.Query(query, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("error listing attendees: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var eventID int
+		var a models.EventAttendee
+		if err := rows.Scan(&eventID, &a.UserID, &a.Username, &a.Name, &a.Status); err != nil {
+			return fmt.Errorf("error scanning attendee: %v", err)
+		}
+		e := &events[index[eventID]]
+		e.Attendees = append(e.Attendees, a)
+	}
+	return rows.Err()
+}
+
+func (r *EventRepository) Create(e *models.Event, attendeeIDs []int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error creating event: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO events (user_id, uid, title, description, location, starts_at, ends_at, all_day, timezone, rrule, exdates, recurrence_end, dav_name)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11::timestamptz[], $12, $13)
+		RETURNING id, created_at, updated_at
+	`
+	if err := tx.QueryRow(query, e.UserID, e.UID, e.Title, e.Description, e.Location, e.StartsAt, e.EndsAt, e.AllDay, e.Timezone, e.RRule,
+		exdateArray(e.ExDates), e.RecurrenceEnd, e.DavName).Scan(&e.ID, &e.CreatedAt, &e.UpdatedAt); err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("an event with that uid already exists")
+		}
+		return fmt.Errorf("error creating event: %v", err)
+	}
+	if err := setAttendees(tx, e.ID, e.UserID, attendeeIDs); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error creating event: %v", err)
+	}
+	return nil
+}
+
+// Update replaces one of the user's own events; attendees kept keep their answer
+func (r *EventRepository) Update(e *models.Event, attendeeIDs []int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error updating event: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE events SET title=$1, description=$2, location=$3, starts_at=$4, ends_at=$5, all_day=$6, timezone=$7, rrule=$8,
+		       exdates=$9::timestamptz[], recurrence_end=$10, updated_at=NOW()
+		WHERE id=$11 AND user_id=$12
+		RETURNING created_at, updated_at
+	`
+	if err := tx.QueryRow(query, e.Title, e.Description, e.Location, e.StartsAt, e.EndsAt, e.AllDay, e.Timezone, e.RRule,
+		exdateArray(e.ExDates), e.RecurrenceEnd, e.ID, e.UserID).Scan(&e.CreatedAt, &e.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("event not found")
+		}
+		return fmt.Errorf("error updating event: %v", err)
+	}
+	if err := setAttendees(tx, e.ID, e.UserID, attendeeIDs); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error updating event: %v", err)
+	}
+	return nil
+}
+
+// setAttendees makes userIDs the attendees of an event; the owner cannot attend their own
+// event and every id must be an existing user
+func setAttendees(tx *sql.Tx, eventID, ownerID int, userIDs []int) error {
+	if userIDs == nil {
+		// A nil slice would be sent as NULL and match nothing below
+		userIDs = []int{}
+	}
+	unique := map[int]bool{}
+	for _, id := range userIDs {
+		if id == ownerID {
+			return fmt.Errorf("the owner cannot be an attendee")
+		}
+		unique[id] = true
+	}
+	if _, err := tx.Exec(`DELETE FROM event_attendees WHERE event_id=$1 AND NOT (user_id = ANY($2))`, eventID, pq.Array(userIDs)); err != nil {
+		return fmt.Errorf("error setting attendees: %v", err)
+	}
+	if len(unique) == 0 {
+		return nil
+	}
+	var found int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM users WHERE id = ANY($1)`, pq.Array(userIDs)).Scan(&found); err != nil {
+		return fmt.Errorf("error setting attendees: %v", err)
+	}
+	if found != len(unique) {
+		return fmt.Errorf("attendee not found")
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO event_attendees (event_id, user_id) SELECT $1, unnest($2::int[])
+		ON CONFLICT (event_id, user_id) DO NOTHING`, eventID, pq.Array(userIDs)); err != nil {
+		return fmt.Errorf("error setting attendees: %v", err)
+	}
+	return nil
+}
+
+func (r *EventRepository) Delete(userID, id int) error {
+	res, err := r.db.Exec(`DELETE FROM events WHERE id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting event: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error deleting event: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("event not found")
+	}
+	return nil
+}
+
+// SetAttendance records the answer of an attendee
+func (r *EventRepository) SetAttendance(userID, eventID int, status string) error {
+	res, err := r.db.Exec(`UPDATE event_attendees SET status=$1 WHERE event_id=$2 AND user_id=$3`, status, eventID, userID)
+	if err != nil {
+		return fmt.Errorf("error answering invitation: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error answering invitation: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("invitation not found")
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type HabitRepository struct {
+	db *sql.DB
+}
+
+func NewHabitRepository() *HabitRepository {
+	return &HabitRepository{db: database.DB}
+}
+
+const habitColumns = `id, user_id, name, description, color, schedule, times_per_week, weekdays, target, unit, start_date, archived, created_at, updated_at`
+
+func habitFields(h *models.Habit) []interface{} {
+	return []interface{}{&h.ID, &h.UserID, &h.Name, &h.Description, &h.Color, &h.Schedule, &h.TimesPerWeek, pq.Array(&h.Weekdays), &h.Target, &h.Unit, &h.StartDate, &h.Archived, &h.CreatedAt, &h.UpdatedAt}
+}
+
+const habitCheckinColumns = `habit_id, to_char(check_date, 'YYYY-MM-DD'), value, comment, created_at, updated_at`
+
+func habitCheckinFields(c *models.HabitCheckin) []interface{} {
+	return []interface{}{&c.HabitID, &c.Date, &c.Value, &c.Comment, &c.CreatedAt, &c.UpdatedAt}
+}
+
+func (r *HabitRepository) List(userID int, includeArchived bool) ([]models.Habit, error) {
+	query := `SELECT ` + habitColumns + ` FROM habits WHERE user_id=$1 AND ($2 OR NOT archived) ORDER BY name ASC, id ASC`
+	rows, err := r.db.Query(query, userID, includeArchived)
+	if err != nil {
+		return nil, fmt.Errorf("error listing habits: %v", err)
+	}
+	defer rows.Close()
+
+	habits := []models.Habit{}
+	for rows.Next() {
+		var h models.Habit
+		if err := rows.Scan(habitFields(&h)...); err != nil {
+			return nil, fmt.Errorf("error scanning habit: %v", err)
+		}
+		habits = append(habits, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating habits: %v", err)
+	}
+	return habits, nil
+}
+
+func (r *HabitRepository) GetByID(userID, id int) (*models.Habit, error) {
+	var h models.Habit
+	if err := r.db.QueryRow(`SELECT `+habitColumns+` FROM habits WHERE id=$1 AND user_id=$2`, id, userID).Scan(habitFields(&h)...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("habit not found")
+		}
+		return nil, fmt.Errorf("error getting habit: %v", err)
+	}
+	return &h, nil
+}
+
+func (r *HabitRepository) Create(h *models.Habit) error {
+	query := `
+		INSERT INTO habits (user_id, name, description, color, schedule, times_per_week, weekdays, target, unit, start_date, archived)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING ` + habitColumns
+	if err := r.db.QueryRow(query, h.UserID, h.Name, h.Description, h.Color, h.Schedule, h.TimesPerWeek, pq.Array(h.Weekdays), h.Target, h.Unit, h.StartDate.Format("2006-01-02"), h.Archived).Scan(habitFields(h)...); err != nil {
+		return fmt.Errorf("error creating habit: %v", err)
+	}
+	return nil
+}
+
+func (r *HabitRepository) Update(h *models.Habit) error {
+	query := `
+		UPDATE habits SET name=$1, description=$2, color=$3, schedule=$4, times_per_week=$5, weekdays=$6, target=$7, unit=$8, start_date=$9, archived=$10, updated_at=NOW()
+		WHERE id=$11 AND user_id=$12
+		RETURNING ` + habitColumns
+	if err := r.db.QueryRow(query, h.Name, h.Description, h.Color, h.Schedule, h.TimesPerWeek, pq.Array(h.Weekdays), h.Target, h.Unit, h.StartDate.Format("2006-01-02"), h.Archived, h.ID, h.UserID).Scan(habitFields(h)...); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("habit not found")
+		}
+		return fmt.Errorf("error updating habit: %v", err)
+	}
+	return nil
+}
+
+func (r *HabitRepository) Delete(userID, id int) error {
+	res, err := r.db.Exec(`DELETE FROM habits WHERE id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting habit: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error deleting habit: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("habit not found")
+	}
+	return nil
+}
+
+// ListCheckins returns the check-ins of the given habits dated within [from, to], oldest first
+func (r *HabitRepository) ListCheckins(habitIDs []int, from, to time.Time) ([]models.HabitCheckin, error) {
+	checkins := []models.HabitCheckin{}
+	if len(habitIDs) == 0 {
+		return checkins, nil
+	}
+	ids := make([]int64, len(habitIDs))
+	for i, id := range habitIDs {
+		ids[i] = int64(id)
+	}
+	query := `SELECT ` + habitCheckinColumns + ` FROM habit_checkins WHERE habit_id = ANY($1) AND check_date BETWEEN $2 AND $3 ORDER BY check_date ASC, habit_id ASC`
+	rows, err := r.db.Query(query, pq.Array(ids), from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing habit check-ins: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.HabitCheckin
+		if err := rows.Scan(habitCheckinFields(&c)...); err != nil {
+			return nil, fmt.Errorf("error scanning habit check-in: %v", err)
+		}
+		checkins = append(checkins, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating habit check-ins: %v", err)
+	}
+	return checkins, nil
+}
+
+// UpsertCheckin records the check-in of a day, replacing the one already there
+func (r *HabitRepository) UpsertCheckin(c *models.HabitCheckin) error {
+	query := `
+		INSERT INTO habit_checkins (habit_id, check_date, value, comment) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (habit_id, check_date) DO UPDATE SET value=EXCLUDED.value, comment=EXCLUDED.comment, updated_at=NOW()
+		RETURNING ` + habitCheckinColumns
+	if err := r.db.QueryRow(query, c.HabitID, c.Date, c.Value, c.Comment).Scan(habitCheckinFields(c)...); err != nil {
+		return fmt.Errorf("error saving habit check-in: %v", err)
+	}
+	return nil
+}
+
+func (r *HabitRepository) DeleteCheckin(habitID int, date time.Time) error {
+	res, err := r.db.Exec(`DELETE FROM habit_checkins WHERE habit_id=$1 AND check_date=$2`, habitID, date.Format("2006-01-02"))
+	if err != nil {
+		return fmt.Errorf("error deleting habit check-in: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error deleting habit check-in: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("habit check-in not found")
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ImportRepository struct {
+	db *sql.DB
+}
+
+func NewImportRepository() *ImportRepository {
+	return &ImportRepository{db: database.DB}
+}
+
+const importJobColumns = `id, user_id, status, format, dry_run, duplicate_strategy, file_name, storage_key, processed_items, created_count, overwritten_count, skipped_count, failed_count, error, finished_at, created_at, updated_at`
+
+func scanImportJob(row interface{ Scan(...interface{}) error }, j *models.ImportJob) error {
+	return row.Scan(&j.ID, &j.UserID, &j.Status, &j.Format, &j.DryRun, &j.DuplicateStrategy, &j.FileName, &j.StorageKey,
+		&j.ProcessedItems, &j.CreatedCount, &j.OverwrittenCount, &j.SkippedCount, &j.FailedCount, &j.Error, &j.FinishedAt, &j.CreatedAt, &j.UpdatedAt)
+}
+
+func (r *ImportRepository) CreateJob(j *models.ImportJob) error {
+	query := `INSERT INTO import_jobs (user_id, format, dry_run, duplicate_strategy, file_name, storage_key) VALUES ($1, $2, $3, $4, $5, $6) RETURNING ` + importJobColumns
+	if err := scanImportJob(r.db.QueryRow(query, j.UserID, j.Format, j.DryRun, j.DuplicateStrategy, j.FileName, j.StorageKey), j); err != nil {
+		return fmt.Errorf("error creating import job: %v", err)
+	}
+	return nil
+}
+
+func (r *ImportRepository) GetJob(userID, id int) (*models.ImportJob, error) {
+	var j models.ImportJob
+	if err := scanImportJob(r.db.QueryRow(`SELECT `+importJobColumns+` FROM import_jobs WHERE id=$1 AND user_id=$2`, id, userID), &j); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("import job not found")
+		}
+		return nil, fmt.Errorf("error getting import job: %v", err)
+	}
+	return &j, nil
+}
+
+func (r *ImportRepository) ListJobs(userID int) ([]models.ImportJob, error) {
+	rows, err := r.db.Query(`SELECT `+importJobColumns+` FROM import_jobs WHERE user_id=$1 ORDER BY created_at DESC LIMIT 50`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing import jobs: %v", err)
+	}
+	defer rows.Close()
+
+	jobs := []models.ImportJob{}
+	for rows.Next() {
+		var j models.ImportJob
+		if err := scanImportJob(rows, &j); err != nil {
+			return nil, fmt.Errorf("error scanning import job: %v", err)
+		}
+		jobs = append(jobs, j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating import jobs: %v", err)
+	}
+	return jobs, nil
+}
+
+func (r *ImportRepository) ListItems(jobID int) ([]models.ImportJobItem, error) {
+	rows, err := r.db.Query(`SELECT item_index, source, to_char(note_date, 'YYYY-MM-DD'), action, note_id, COALESCE(message, '') FROM import_job_items WHERE job_id=$1 ORDER BY item_index ASC`, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing import items: %v", err)
+	}
+	defer rows.Close()
+
+	items := []models.ImportJobItem{}
+	for rows.Next() {
+		var it models.ImportJobItem
+		if err := rows.Scan(&it.Index, &it.Source, &it.NoteDate, &it.Action, &it.NoteID, &it.Message); err != nil {
+			return nil, fmt.Errorf("error scanning import item: %v", err)
+		}
+		items = append(items, it)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating import items: %v", err)
+	}
+	return items, nil
+}
+
+// ClaimJob marks a job as running if it is queued or its previous runner stopped heartbeating.
+// It returns false when another runner owns the job.
+func (r *ImportRepository) ClaimJob(id int, staleAfter time.Duration) (*models.ImportJob, bool, error) {
+	query := `
+		UPDATE import_jobs SET status='running', heartbeat_at=NOW(), error=NULL
+		WHERE id=$1 AND (status='queued' OR (status='running' AND (heartbeat_at IS NULL OR heartbeat_at < NOW() - make_interval(secs => $2))))
+		RETURNING ` + importJobColumns
+	var j models.ImportJob
+	if err := scanImportJob(r.db.QueryRow(query, id, int(staleAfter.Seconds())), &j); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("error claiming import job: %v", err)
+	}
+	return &j, true, nil
+}
+
+// Heartbeat tells other instances the running job is still owned
+func (r *ImportRepository) Heartbeat(id int) error {
+	if _, err := r.db.Exec(`UPDATE import_jobs SET heartbeat_at=NOW() WHERE id=$1 AND status='running'`, id); err != nil {
+		return fmt.Errorf("error updating import job: %v", err)
+	}
+	return nil
+}
+
+// PendingJobIDs returns jobs that should be (re)started, e.g. after a restart
+func (r *ImportRepository) PendingJobIDs(staleAfter time.Duration) ([]int, error) {
+	rows, err := r.db.Query(`SELECT id FROM import_jobs WHERE status='queued' OR (status='running' AND (heartbeat_at IS NULL OR heartbeat_at < NOW() - make_interval(secs => $1))) ORDER BY id`, int(staleAfter.Seconds()))
+	if err != nil {
+		return nil, fmt.Errorf("error listing pending import jobs: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning import job: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Requeue puts a failed job back in the queue so it continues from its last processed item
+func (r *ImportRepository) Requeue(userID, id int) error {
+	res, err := r.db.Exec(`UPDATE import_jobs SET status='queued', finished_at=NULL WHERE id=$1 AND user_id=$2 AND status='failed'`, id, userID)
+	if err != nil {
+		return fmt.Errorf("error resuming import job: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error resuming import job: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("only failed import jobs can be resumed")
+	}
+	return nil
+}
+
+func (r *ImportRepository) FinishJob(id int, status string, errMsg *string) error {
+	if _, err := r.db.Exec(`UPDATE import_jobs SET status=$1, error=$2, finished_at=NOW() WHERE id=$3`, status, errMsg, id); err != nil {
+		return fmt.Errorf("error finishing import job: %v", err)
+	}
+	return nil
+}
+
+// RecordItem runs apply and stores the item's result in the same transaction, advancing the
+// job cursor. Either both the note change and the progress are committed, or neither is.
+func (r *ImportRepository) RecordItem(jobID int, item *models.ImportJobItem, apply func(tx *sql.Tx) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error recording import item: %v", err)
+	}
+	defer tx.Rollback()
+
+	if apply != nil {
+		if err := apply(tx); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(`INSERT INTO import_job_items (job_id, item_index, source, note_date, action, note_id, message) VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))`,
+		jobID, item.Index, item.Source, item.NoteDate, item.Action, item.NoteID, item.Message)
+	if err != nil {
+		return fmt.Errorf("error recording import item: %v", err)
+	}
+	_, err = tx.Exec(`
+		UPDATE import_jobs SET
+			processed_items = $2 + 1,
+			created_count = created_count + CASE WHEN $3 = 'created' THEN 1 ELSE 0 END,
+			overwritten_count = overwritten_count + CASE WHEN $3 = 'overwritten' THEN 1 ELSE 0 END,
+			skipped_count = skipped_count + CASE WHEN $3 = 'skipped' THEN 1 ELSE 0 END,
+			failed_count = failed_count + CASE WHEN $3 = 'failed' THEN 1 ELSE 0 END,
+			heartbeat_at = NOW()
+		WHERE id = $1`, jobID, item.Index, item.Action)
+	if err != nil {
+		return fmt.Errorf("error updating import job: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error recording import item: %v", err)
+	}
+	return nil
+}
+
+// FindDuplicate looks for a note previously produced by the same source item, or a note on
+// the same date with identical content
+func (r *ImportRepository) FindDuplicate(tx *sql.Tx, userID int, sourceKey string, date time.Time, content string) (int, bool, error) {
+	query := `
+		SELECT note_id FROM (
+			SELECT s.note_id, 0 AS rank FROM note_import_sources s WHERE s.user_id=$1 AND s.source_key=$2
+			UNION ALL
+			SELECT n.id, 1 AS rank FROM notes n WHERE n.user_id=$1 AND n.note_date=$3 AND NOT n.encrypted AND btrim(n.content) = btrim($4)
+		) d ORDER BY rank LIMIT 1
+	`
+	var id int
+	if err := tx.QueryRow(query, userID, sourceKey, date.Format("2006-01-02"), content).Scan(&id); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("error checking duplicates: %v", err)
+	}
+	return id, true, nil
+}
+
+func (r *ImportRepository) InsertNote(tx *sql.Tx, n *models.Note) error {
+	return insertNote(tx, n)
+}
+
+func (r *ImportRepository) OverwriteNote(tx *sql.Tx, n *models.Note) error {
+	position, err := appendPosition(tx, n.UserID, n.NoteDate)
+	if err != nil {
+		return err
+	}
+	query := `UPDATE notes SET position=CASE WHEN note_date=$1 THEN position ELSE $8 END, note_date=$1, content=$2, hidden=$3, starred=$4, tags=$5, encrypted=$9, content_ciphertext=$10, updated_at=NOW() WHERE id=$6 AND user_id=$7`
+	if _, err := tx.Exec(query, n.NoteDate.Format("2006-01-02"), n.Content, n.Hidden, n.Starred, pq.Array(n.Tags), n.ID, n.UserID, position, n.Encrypted, n.Ciphertext); err != nil {
+		return fmt.Errorf("error overwriting note: %v", err)
+	}
+	return replaceNoteLinks(tx, n)
+}
+
+func (r *ImportRepository) RememberSource(tx *sql.Tx, userID int, sourceKey string, noteID int) error {
+	query := `INSERT INTO note_import_sources (user_id, source_key, note_id) VALUES ($1, $2, $3) ON CONFLICT (user_id, source_key) DO UPDATE SET note_id = EXCLUDED.note_id`
+	if _, err := tx.Exec(query, userID, sourceKey, noteID); err != nil {
+		return fmt.Errorf("error recording import source: %v", err)
+	}
+	return nil
+}
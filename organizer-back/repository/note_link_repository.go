@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// noteLinkPattern matches [[2025-03-02]], [[note:123]] and [[project:7]], optionally with an
+// alias: [[note:123|standup]]
+var noteLinkPattern = regexp.MustCompile(`\[\[\s*(?:(\d{4}-\d{2}-\d{2})|note:(\d{1,9})|project:(\d{1,9}))\s*(?:\|[^\]]*)?\]\]`)
+
+// notePreviewLength is how many characters of a linked note are returned as preview
+const notePreviewLength = 200
+
+type NoteLinkRepository struct {
+	db *sql.DB
+}
+
+func NewNoteLinkRepository() *NoteLinkRepository {
+	return &NoteLinkRepository{db: database.DB}
+}
+
+type parsedNoteLink struct {
+	ref       string
+	date      *time.Time
+	noteID    int
+	projectID int
+}
+
+// parseNoteLinks extracts the distinct links of a note's content, skipping links to itself
+// and strings that look like dates but are not
+func parseNoteLinks(noteID int, content string) []parsedNoteLink {
+	var links []parsedNoteLink
+	seen := map[string]bool{}
+	for _, m := range noteLinkPattern.FindAllStringSubmatch(content, -1) {
+		var l parsedNoteLink
+		if m[1] != "" {
+			d, err := time.Parse("2006-01-02", m[1])
+			if err != nil {
+				continue
+			}
+			l = parsedNoteLink{ref: m[1], date: &d}
+		} else if m[3] != "" {
+			id, _ := strconv.Atoi(m[3])
+			l = parsedNoteLink{ref: "project:" + strconv.Itoa(id), projectID: id}
+		} else {
+			id, _ := strconv.Atoi(m[2])
+			if id == noteID {
+				continue
+			}
+			l = parsedNoteLink{ref: "note:" + strconv.Itoa(id), noteID: id}
+		}
+		if !seen[l.ref] {
+			seen[l.ref] = true
+			links = append(links, l)
+		}
+	}
+	return links
+}
+
+// replaceNoteLinks re-indexes the links of a note after its content was written. Links in
+// encrypted notes are not indexed, as they would reveal what the note refers to.
+func replaceNoteLinks(tx *sql.Tx, n *models.Note) error {
+	if _, err := tx.Exec(`DELETE FROM note_links WHERE source_note_id=$1`, n.ID); err != nil {
+		return fmt.Errorf("error updating note links: %v", err)
+	}
+	if n.Encrypted {
+		return nil
+	}
+	for _, l := range parseNoteLinks(n.ID, n.Content) {
+		var err error
+		switch {
+		case l.date != nil:
+			_, err = tx.Exec(`INSERT INTO note_links (source_note_id, target_ref, kind, target_date) VALUES ($1, $2, 'date', $3)`,
+				n.ID, l.ref, l.date.Format("2006-01-02"))
+		case strings.HasPrefix(l.ref, "project:"):
+			_, err = tx.Exec(`INSERT INTO note_links (source_note_id, target_ref, kind, target_project_id)
+				VALUES ($1, $2, 'project', (SELECT id FROM projects WHERE id=$3 AND user_id=$4))`,
+				n.ID, l.ref, l.projectID, n.UserID)
+		default:
+			// Only the author's own notes resolve; anything else stays a dangling link
+			_, err = tx.Exec(`INSERT INTO note_links (source_note_id, target_ref, kind, target_note_id)
+				VALUES ($1, $2, 'note', (SELECT id FROM notes WHERE id=$3 AND user_id=$4))`,
+				n.ID, l.ref, l.noteID, n.UserID)
+		}
+		if err != nil {
+			return fmt.Errorf("error updating note links: %v", err)
+		}
+	}
+	return nil
+}
+
+// ListOutgoing resolves the links of a note: date links to the author's notes of that day,
+// note links to their target and project links to the project
+func (r *NoteLinkRepository) ListOutgoing(userID, noteID int) ([]models.NoteLink, error) {
+	query := `
+		SELECT l.target_ref, l.kind, to_char(l.target_date, 'YYYY-MM-DD'), l.target_note_id,
+		       t.id, to_char(t.note_date, 'YYYY-MM-DD'), left(t.content, $3), t.encrypted,
+		       p.id, p.name
+		FROM note_links l
+		LEFT JOIN notes t ON t.user_id = $2 AND t.id <> l.source_note_id AND (
+			(l.kind = 'note' AND t.id = l.target_note_id) OR (l.kind = 'date' AND t.note_date = l.target_date)
+		)
+		LEFT JOIN projects p ON l.kind = 'project' AND p.id = l.target_project_id AND p.user_id = $2
+		WHERE l.source_note_id = $1
+		ORDER BY l.kind ASC, l.target_ref ASC, t.position ASC
+	`
+	rows, err := r.db.Query(query, noteID, userID, notePreviewLength)
+	if err != nil {
+		return nil, fmt.Errorf("error listing note links: %v", err)
+	}
+	defer rows.Close()
+
+	links := []models.NoteLink{}
+	for rows.Next() {
+		var ref, kind string
+		var targetDate sql.NullString
+		var targetNoteID, id sql.NullInt64
+		var noteDate, preview sql.NullString
+		var encrypted sql.NullBool
+		var projectID sql.NullInt64
+		var projectName sql.NullString
+		if err := rows.Scan(&ref, &kind, &targetDate, &targetNoteID, &id, &noteDate, &preview, &encrypted, &projectID, &projectName); err != nil {
+			return nil, fmt.Errorf("error scanning note link: %v", err)
+		}
+		if len(links) == 0 || links[len(links)-1].Ref != ref {
+			l := models.NoteLink{Ref: ref, Kind: kind, Targets: []models.NoteLinkTarget{}}
+			if targetDate.Valid {
+				l.TargetDate = &targetDate.String
+			}
+			if targetNoteID.Valid {
+				v := int(targetNoteID.Int64)
+				l.TargetNoteID = &v
+			}
+			if projectID.Valid {
+				l.Resolved = true
+				l.Project = &models.NoteLinkProject{ID: int(projectID.Int64), Name: projectName.String}
+			}
+			links = append(links, l)
+		}
+		if id.Valid {
+			l := &links[len(links)-1]
+			l.Resolved = true
+			l.Targets = append(l.Targets, models.NoteLinkTarget{ID: int(id.Int64), NoteDate: noteDate.String, Preview: preview.String, Encrypted: encrypted.Bool})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating note links: %v", err)
+	}
+	return links, nil
+}
+
+// ListBacklinks returns the owner's notes that link to the note, by id or by its current
+// date; date links therefore follow the note when its date changes
+func (r *NoteLinkRepository) ListBacklinks(userID, noteID int, noteDate time.Time) ([]models.NoteBacklink, error) {
+	// One row per source note, preferring its link by id; DISTINCT ON needs its own leading
+	// ORDER BY, so the display order is applied outside
+	query := `
+		SELECT id, note_date, preview, kind, target_ref FROM (
+			SELECT DISTINCT ON (s.id) s.id, to_char(s.note_date, 'YYYY-MM-DD') AS note_date, s.position,
+			       left(s.content, $4) AS preview, l.kind, l.target_ref
+			FROM note_links l
+			JOIN notes s ON s.id = l.source_note_id
+			WHERE s.user_id = $1 AND s.id <> $2
+			  AND (l.target_note_id = $2 OR (l.kind = 'date' AND l.target_date = $3))
+			ORDER BY s.id, l.kind DESC
+		) b
+		ORDER BY note_date DESC, position ASC, id ASC
+	`
+	rows, err := r.db.Query(query, userID, noteID, noteDate.Format("2006-01-02"), notePreviewLength)
+	if err != nil {
+		return nil, fmt.Errorf("error listing backlinks: %v", err)
+	}
+	defer rows.Close()
+
+	res := []models.NoteBacklink{}
+	for rows.Next() {
+		var b models.NoteBacklink
+		if err := rows.Scan(&b.ID, &b.NoteDate, &b.Preview, &b.Via, &b.Ref); err != nil {
+			return nil, fmt.Errorf("error scanning backlink: %v", err)
+		}
+		res = append(res, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating backlinks: %v", err)
+	}
+	return res, nil
+}
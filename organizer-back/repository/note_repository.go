@@ -5,23 +5,39 @@ import (
 	"fmt"
 	"organizer-back/database"
 	"organizer-back/models"
+	"organizer-back/rank"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type NoteRepository struct {
 	db *sql.DB
 }
 
+// noteColumns is the column list matching noteFields
+const noteColumns = `id, user_id, note_date, content, hidden, starred, tags, encrypted, content_ciphertext, created_at, updated_at`
+
+func noteFields(n *models.Note) []interface{} {
+	return []interface{}{&n.ID, &n.UserID, &n.NoteDate, &n.Content, &n.Hidden, &n.Starred, pq.Array(&n.Tags), &n.Encrypted, &n.Ciphertext, &n.CreatedAt, &n.UpdatedAt}
+}
+
 func NewNoteRepository() *NoteRepository {
 	return &NoteRepository{db: database.DB}
 }
 
-func (r *NoteRepository) ListByUserAndDate(userID int, date time.Time, includeHidden bool) ([]models.Note, error) {
-	q := `SELECT id, user_id, note_date, content, hidden, starred, created_at, updated_at FROM notes WHERE user_id=$1 AND note_date=$2`
+// ListByUserAndDate lists a day's notes. sort is "manual" (the user's arrangement) or
+// "starred" (starred first, hidden last, then the manual order).
+func (r *NoteRepository) ListByUserAndDate(userID int, date time.Time, includeHidden bool, sort string) ([]models.Note, error) {
+	q := `SELECT ` + noteColumns + ` FROM notes WHERE user_id=$1 AND note_date=$2`
 	if !includeHidden {
 		q += ` AND hidden = FALSE`
 	}
-	q += ` ORDER BY hidden ASC, starred DESC, created_at ASC`
+	if sort == "starred" {
+		q += ` ORDER BY hidden ASC, starred DESC, position ASC`
+	} else {
+		q += ` ORDER BY position ASC`
+	}
 	rows, err := r.db.Query(q, userID, date.Format("2006-01-02"))
 	if err != nil {
 		return nil, fmt.Errorf("error listing notes: %v", err)
@@ -31,7 +47,7 @@ func (r *NoteRepository) ListByUserAndDate(userID int, date time.Time, includeHi
 	var notes []models.Note
 	for rows.Next() {
 		var n models.Note
-		if err := rows.Scan(&n.ID, &n.UserID, &n.NoteDate, &n.Content, &n.Hidden, &n.Starred, &n.CreatedAt, &n.UpdatedAt); err != nil {
+		if err := rows.Scan(noteFields(&n)...); err != nil {
 			return nil, fmt.Errorf("error scanning note: %v", err)
 		}
 		notes = append(notes, n)
@@ -42,19 +58,62 @@ func (r *NoteRepository) ListByUserAndDate(userID int, date time.Time, includeHi
 	return notes, nil
 }
 
-func (r *NoteRepository) Create(userID int, date time.Time, content string) (*models.Note, error) {
-	query := `INSERT INTO notes (user_id, note_date, content) VALUES ($1, $2, $3) RETURNING id, hidden, starred, created_at, updated_at`
-	n := &models.Note{UserID: userID, NoteDate: date, Content: content}
-	if err := r.db.QueryRow(query, userID, date.Format("2006-01-02"), content).Scan(&n.ID, &n.Hidden, &n.Starred, &n.CreatedAt, &n.UpdatedAt); err != nil {
-		return nil, fmt.Errorf("error creating note: %v", err)
+// Create stores a new note at the end of its day, filling in its id and timestamps
+func (r *NoteRepository) Create(n *models.Note) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error creating note: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertNote(tx, n); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error creating note: %v", err)
+	}
+	return nil
+}
+
+// insertNote adds n at the end of its day within tx, filling in its id and timestamps
+func insertNote(tx *sql.Tx, n *models.Note) error {
+	position, err := appendPosition(tx, n.UserID, n.NoteDate)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO notes (user_id, note_date, content, hidden, starred, tags, encrypted, content_ciphertext, position) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at, updated_at`
+	if err := tx.QueryRow(query, n.UserID, n.NoteDate.Format("2006-01-02"), n.Content, n.Hidden, n.Starred, pq.Array(n.Tags), n.Encrypted, n.Ciphertext, position).Scan(&n.ID, &n.CreatedAt, &n.UpdatedAt); err != nil {
+		return fmt.Errorf("error creating note: %v", err)
+	}
+	return replaceNoteLinks(tx, n)
+}
+
+// lockDay serializes writers that assign positions on one user's day. The lock is released
+// when the transaction ends.
+func lockDay(tx *sql.Tx, userID int, date time.Time) error {
+	days := int32(date.Unix() / 86400)
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, $2)`, userID, days); err != nil {
+		return fmt.Errorf("error locking day: %v", err)
+	}
+	return nil
+}
+
+// appendPosition locks the day and returns a rank after its last note
+func appendPosition(tx *sql.Tx, userID int, date time.Time) (string, error) {
+	if err := lockDay(tx, userID, date); err != nil {
+		return "", err
 	}
-	return n, nil
+	var last sql.NullString
+	if err := tx.QueryRow(`SELECT MAX(position) FROM notes WHERE user_id=$1 AND note_date=$2`, userID, date.Format("2006-01-02")).Scan(&last); err != nil {
+		return "", fmt.Errorf("error reading positions: %v", err)
+	}
+	return rank.After(last.String)
 }
 
 func (r *NoteRepository) GetByID(userID, id int) (*models.Note, error) {
-	query := `SELECT id, user_id, note_date, content, hidden, starred, created_at, updated_at FROM notes WHERE id=$1 AND user_id=$2`
+	query := `SELECT ` + noteColumns + ` FROM notes WHERE id=$1 AND user_id=$2`
 	var n models.Note
-	if err := r.db.QueryRow(query, id, userID).Scan(&n.ID, &n.UserID, &n.NoteDate, &n.Content, &n.Hidden, &n.Starred, &n.CreatedAt, &n.UpdatedAt); err != nil {
+	if err := r.db.QueryRow(query, id, userID).Scan(noteFields(&n)...); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("note not found")
 		}
@@ -63,20 +122,60 @@ func (r *NoteRepository) GetByID(userID, id int) (*models.Note, error) {
 	return &n, nil
 }
 
-func (r *NoteRepository) Update(userID, id int, date *time.Time, content *string, hidden *bool, starred *bool) (*models.Note, error) {
+// GetAccessible returns a note the user owns or has been shared, with the caller's permission
+// ("owner", "editor" or "viewer")
+func (r *NoteRepository) GetAccessible(userID, id int) (*models.Note, string, error) {
+	query := `
+		SELECT n.id, n.user_id, n.note_date, n.content, n.hidden, n.starred, n.tags, n.encrypted, n.content_ciphertext, n.created_at, n.updated_at,
+		       CASE WHEN n.user_id = $2 THEN 'owner' ELSE s.permission END
+		FROM notes n
+		LEFT JOIN note_shares s ON s.note_id = n.id AND s.user_id = $2
+		WHERE n.id = $1 AND (n.user_id = $2 OR (s.user_id IS NOT NULL AND NOT n.encrypted))
+	`
+	var n models.Note
+	var permission string
+	if err := r.db.QueryRow(query, id, userID).Scan(append(noteFields(&n), &permission)...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", fmt.Errorf("note not found")
+		}
+		return nil, "", fmt.Errorf("error getting note: %v", err)
+	}
+	return &n, permission, nil
+}
+
+// NoteContent is the stored form of a note's content: plaintext, or empty content with the
+// sealed ciphertext when encrypted
+type NoteContent struct {
+	Content    string
+	Encrypted  bool
+	Ciphertext []byte
+}
+
+func (r *NoteRepository) Update(userID, id int, date *time.Time, content *NoteContent, hidden *bool, starred *bool, tags *[]string) (*models.Note, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error updating note: %v", err)
+	}
+	defer tx.Rollback()
+
 	// Build dynamic update
 	setClause := ""
 	args := []interface{}{}
 	idx := 1
 	if date != nil {
-		setClause += fmt.Sprintf("note_date=$%d, ", idx)
-		args = append(args, date.Format("2006-01-02"))
-		idx++
+		// A note moved to another day goes to the end of that day
+		position, err := appendPosition(tx, userID, *date)
+		if err != nil {
+			return nil, err
+		}
+		setClause += fmt.Sprintf("position=CASE WHEN note_date=$%d THEN position ELSE $%d END, note_date=$%d, ", idx, idx+1, idx)
+		args = append(args, date.Format("2006-01-02"), position)
+		idx += 2
 	}
 	if content != nil {
-		setClause += fmt.Sprintf("content=$%d, ", idx)
-		args = append(args, *content)
-		idx++
+		setClause += fmt.Sprintf("content=$%d, encrypted=$%d, content_ciphertext=$%d, ", idx, idx+1, idx+2)
+		args = append(args, content.Content, content.Encrypted, content.Ciphertext)
+		idx += 3
 	}
 	if hidden != nil {
 		setClause += fmt.Sprintf("hidden=$%d, ", idx)
@@ -88,6 +187,11 @@ func (r *NoteRepository) Update(userID, id int, date *time.Time, content *string
 		args = append(args, *starred)
 		idx++
 	}
+	if tags != nil {
+		setClause += fmt.Sprintf("tags=$%d, ", idx)
+		args = append(args, pq.Array(*tags))
+		idx++
+	}
 	if setClause == "" {
 		// nothing to update
 		return r.GetByID(userID, id)
@@ -95,15 +199,92 @@ func (r *NoteRepository) Update(userID, id int, date *time.Time, content *string
 	// trim trailing comma and space
 	setClause = setClause[:len(setClause)-2]
 	args = append(args, id, userID)
-	query := fmt.Sprintf("UPDATE notes SET %s, updated_at=NOW() WHERE id=$%d AND user_id=$%d RETURNING id, user_id, note_date, content, hidden, starred, created_at, updated_at", setClause, idx, idx+1)
+	query := fmt.Sprintf("UPDATE notes SET %s, updated_at=NOW() WHERE id=$%d AND user_id=$%d RETURNING "+noteColumns, setClause, idx, idx+1)
 
 	var n models.Note
-	if err := r.db.QueryRow(query, args...).Scan(&n.ID, &n.UserID, &n.NoteDate, &n.Content, &n.Hidden, &n.Starred, &n.CreatedAt, &n.UpdatedAt); err != nil {
+	if err := tx.QueryRow(query, args...).Scan(noteFields(&n)...); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("note not found")
 		}
 		return nil, fmt.Errorf("error updating note: %v", err)
 	}
+	if content != nil {
+		if err := replaceNoteLinks(tx, &n); err != nil {
+			return nil, err
+		}
+	}
+	// Encrypting a note revokes its public links, so decrypting it later does not republish it
+	if n.Encrypted {
+		if _, err := tx.Exec(`UPDATE note_public_links SET revoked_at=NOW() WHERE note_id=$1 AND revoked_at IS NULL`, n.ID); err != nil {
+			return nil, fmt.Errorf("error revoking public links: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error updating note: %v", err)
+	}
+	return &n, nil
+}
+
+// Move places a note right before or after another of the user's notes, taking the target's
+// date. Only the moved note gets a new rank, so concurrent moves of different notes do not
+// rewrite each other; moves on the same day are serialized by the day lock.
+func (r *NoteRepository) Move(userID, id, targetID int, after bool) (*models.Note, error) {
+	if id == targetID {
+		return nil, fmt.Errorf("cannot move a note relative to itself")
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error moving note: %v", err)
+	}
+	defer tx.Rollback()
+
+	var date time.Time
+	if err := tx.QueryRow(`SELECT note_date FROM notes WHERE id=$1 AND user_id=$2`, targetID, userID).Scan(&date); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("target note not found")
+		}
+		return nil, fmt.Errorf("error moving note: %v", err)
+	}
+	if err := lockDay(tx, userID, date); err != nil {
+		return nil, err
+	}
+	// Re-read the target under the lock: it may have been moved meanwhile
+	var targetPos string
+	if err := tx.QueryRow(`SELECT position FROM notes WHERE id=$1 AND user_id=$2 AND note_date=$3`, targetID, userID, date.Format("2006-01-02")).Scan(&targetPos); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("target note changed, retry")
+		}
+		return nil, fmt.Errorf("error moving note: %v", err)
+	}
+
+	var neighbour sql.NullString
+	var lo, hi string
+	if after {
+		err = tx.QueryRow(`SELECT MIN(position) FROM notes WHERE user_id=$1 AND note_date=$2 AND position > $3 AND id <> $4`, userID, date.Format("2006-01-02"), targetPos, id).Scan(&neighbour)
+		lo, hi = targetPos, neighbour.String
+	} else {
+		err = tx.QueryRow(`SELECT MAX(position) FROM notes WHERE user_id=$1 AND note_date=$2 AND position < $3 AND id <> $4`, userID, date.Format("2006-01-02"), targetPos, id).Scan(&neighbour)
+		lo, hi = neighbour.String, targetPos
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading positions: %v", err)
+	}
+	position, err := rank.Between(lo, hi)
+	if err != nil {
+		return nil, fmt.Errorf("error computing position: %v", err)
+	}
+
+	query := `UPDATE notes SET note_date=$1, position=$2, updated_at=NOW() WHERE id=$3 AND user_id=$4 RETURNING ` + noteColumns
+	var n models.Note
+	if err := tx.QueryRow(query, date.Format("2006-01-02"), position, id, userID).Scan(noteFields(&n)...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("note not found")
+		}
+		return nil, fmt.Errorf("error moving note: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error moving note: %v", err)
+	}
 	return &n, nil
 }
 
@@ -121,3 +302,305 @@ func (r *NoteRepository) Delete(userID, id int) error {
 	}
 	return nil
 }
+
+// CalendarMonth aggregates the user's notes per day for [from, to) in a single query
+func (r *NoteRepository) CalendarMonth(userID int, from, to time.Time, previewLength int) ([]models.NoteCalendarDay, error) {
+	query := `
+		SELECT to_char(note_date, 'YYYY-MM-DD'),
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE starred),
+		       COUNT(*) FILTER (WHERE hidden),
+		       COALESCE((array_agg(left(split_part(btrim(content), E'\n', 1), $4) ORDER BY starred DESC, position ASC)
+		                 FILTER (WHERE NOT hidden AND NOT encrypted AND btrim(content) <> ''))[1], '')
+		FROM notes
+		WHERE user_id = $1 AND note_date >= $2 AND note_date < $3
+		GROUP BY note_date
+		ORDER BY note_date ASC
+	`
+	rows, err := r.db.Query(query, userID, from.Format("2006-01-02"), to.Format("2006-01-02"), previewLength)
+	if err != nil {
+		return nil, fmt.Errorf("error building calendar: %v", err)
+	}
+	defer rows.Close()
+
+	days := []models.NoteCalendarDay{}
+	for rows.Next() {
+		var d models.NoteCalendarDay
+		if err := rows.Scan(&d.Date, &d.Count, &d.StarredCount, &d.HiddenCount, &d.Preview); err != nil {
+			return nil, fmt.Errorf("error scanning calendar day: %v", err)
+		}
+		days = append(days, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating calendar: %v", err)
+	}
+	return days, nil
+}
+
+// ListPreviousDay returns the notes of the most recent day before date that has any
+func (r *NoteRepository) ListPreviousDay(userID int, date time.Time) ([]models.Note, error) {
+	query := `SELECT ` + noteColumns + ` FROM notes
+		WHERE user_id=$1 AND note_date = (SELECT MAX(note_date) FROM notes WHERE user_id=$1 AND note_date < $2)
+		ORDER BY position ASC`
+	rows, err := r.db.Query(query, userID, date.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing notes: %v", err)
+	}
+	defer rows.Close()
+
+	var notes []models.Note
+	for rows.Next() {
+		var n models.Note
+		if err := rows.Scan(noteFields(&n)...); err != nil {
+			return nil, fmt.Errorf("error scanning note: %v", err)
+		}
+		notes = append(notes, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating notes: %v", err)
+	}
+	return notes, nil
+}
+
+// StreamByUser calls fn for each of the user's notes in date order without loading them all
+// into memory. from/to are inclusive and optional.
+func (r *NoteRepository) StreamByUser(userID int, from, to *time.Time, fn func(*models.Note) error) error {
+	q := `SELECT ` + noteColumns + ` FROM notes WHERE user_id=$1`
+	args := []interface{}{userID}
+	if from != nil {
+		args = append(args, from.Format("2006-01-02"))
+		q += fmt.Sprintf(" AND note_date >= $%d", len(args))
+	}
+	if to != nil {
+		args = append(args, to.Format("2006-01-02"))
+		q += fmt.Sprintf(" AND note_date <= $%d", len(args))
+	}
+	q += ` ORDER BY note_date ASC, position ASC, id ASC`
+	rows, err := r.db.Query(q, args...)
+	if err != nil {
+		return fmt.Errorf("error exporting notes: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var n models.Note
+		if err := rows.Scan(noteFields(&n)...); err != nil {
+			return fmt.Errorf("error scanning note: %v", err)
+		}
+		if err := fn(&n); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating notes: %v", err)
+	}
+	return nil
+}
+
+// NoteFilter narrows the caller's notes; nil/empty fields are ignored and dates are inclusive
+type NoteFilter struct {
+	From    *time.Time
+	To      *time.Time
+	Starred *bool
+	Hidden  *bool
+	Tag     string
+}
+
+// NoteBulkChange is what a bulk operation does to each note. Date is used by "move" and
+// Tags by "tag" and "untag".
+type NoteBulkChange struct {
+	Action string
+	Date   time.Time
+	Tags   []string
+}
+
+// BulkApply applies change to the user's notes selected by ids or filter in a single
+// transaction. Targets are locked up front. In atomic mode the first failure rolls back
+// everything; in partial mode each note runs under a savepoint and failures are reported per
+// id while the rest is committed. It also returns the storage keys of attachments that went
+// away with deleted notes, to be purged once the transaction has committed.
+func (r *NoteRepository) BulkApply(userID int, ids []int, filter *NoteFilter, change NoteBulkChange, partial bool, maxTargets int) ([]models.NoteBulkResult, []string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error starting bulk operation: %v", err)
+	}
+	defer tx.Rollback()
+
+	locked, err := r.lockBulkTargets(tx, userID, ids, filter, maxTargets)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// With explicit ids the caller's order is kept and unknown ids are reported
+	found := make(map[int]bool, len(locked))
+	for _, id := range locked {
+		found[id] = true
+	}
+	order := locked
+	if ids != nil {
+		seen := make(map[int]bool, len(ids))
+		order = make([]int, 0, len(ids))
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if !found[id] && !partial {
+				return nil, nil, fmt.Errorf("note %d not found", id)
+			}
+			order = append(order, id)
+		}
+	}
+
+	results := make([]models.NoteBulkResult, 0, len(order))
+	var keys []string
+	for _, id := range order {
+		if !found[id] {
+			results = append(results, models.NoteBulkResult{ID: id, Status: "failed", Error: "note not found"})
+			continue
+		}
+		if partial {
+			if _, err := tx.Exec("SAVEPOINT bulk_note"); err != nil {
+				return nil, nil, fmt.Errorf("error in bulk operation: %v", err)
+			}
+		}
+		noteKeys, err := r.applyBulkChange(tx, userID, id, change)
+		if err != nil {
+			if !partial {
+				return nil, nil, fmt.Errorf("note %d: %v", id, err)
+			}
+			if _, rbErr := tx.Exec("ROLLBACK TO SAVEPOINT bulk_note"); rbErr != nil {
+				return nil, nil, fmt.Errorf("error in bulk operation: %v", rbErr)
+			}
+			results = append(results, models.NoteBulkResult{ID: id, Status: "failed", Error: err.Error()})
+			continue
+		}
+		if partial {
+			if _, err := tx.Exec("RELEASE SAVEPOINT bulk_note"); err != nil {
+				return nil, nil, fmt.Errorf("error in bulk operation: %v", err)
+			}
+		}
+		keys = append(keys, noteKeys...)
+		results = append(results, models.NoteBulkResult{ID: id, Status: "ok"})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("error committing bulk operation: %v", err)
+	}
+	return results, keys, nil
+}
+
+// lockBulkTargets returns the ids of the user's notes matched by ids or filter, locked FOR UPDATE
+func (r *NoteRepository) lockBulkTargets(tx *sql.Tx, userID int, ids []int, filter *NoteFilter, maxTargets int) ([]int, error) {
+	q := `SELECT id FROM notes WHERE user_id=$1`
+	args := []interface{}{userID}
+	if ids != nil {
+		ids64 := make([]int64, len(ids))
+		for i, id := range ids {
+			ids64[i] = int64(id)
+		}
+		args = append(args, pq.Array(ids64))
+		q += fmt.Sprintf(" AND id = ANY($%d)", len(args))
+	}
+	if filter != nil {
+		if filter.From != nil {
+			args = append(args, filter.From.Format("2006-01-02"))
+			q += fmt.Sprintf(" AND note_date >= $%d", len(args))
+		}
+		if filter.To != nil {
+			args = append(args, filter.To.Format("2006-01-02"))
+			q += fmt.Sprintf(" AND note_date <= $%d", len(args))
+		}
+		if filter.Starred != nil {
+			args = append(args, *filter.Starred)
+			q += fmt.Sprintf(" AND starred = $%d", len(args))
+		}
+		if filter.Hidden != nil {
+			args = append(args, *filter.Hidden)
+			q += fmt.Sprintf(" AND hidden = $%d", len(args))
+		}
+		if filter.Tag != "" {
+			args = append(args, filter.Tag)
+			q += fmt.Sprintf(" AND $%d = ANY(tags)", len(args))
+		}
+	}
+	// Fetch one extra row to detect filters that match too many notes
+	args = append(args, maxTargets+1)
+	q += fmt.Sprintf(" ORDER BY note_date ASC, id ASC LIMIT $%d FOR UPDATE", len(args))
+
+	rows, err := tx.Query(q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting notes: %v", err)
+	}
+	defer rows.Close()
+
+	var out []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning note: %v", err)
+		}
+		out = append(out, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating notes: %v", err)
+	}
+	if len(out) > maxTargets {
+		return nil, fmt.Errorf("too many notes matched (max %d)", maxTargets)
+	}
+	return out, nil
+}
+
+func (r *NoteRepository) applyBulkChange(tx *sql.Tx, userID, id int, change NoteBulkChange) ([]string, error) {
+	var query string
+	var args []interface{}
+	switch change.Action {
+	case "hide", "unhide":
+		query, args = `UPDATE notes SET hidden=$1, updated_at=NOW() WHERE id=$2 AND user_id=$3`, []interface{}{change.Action == "hide", id, userID}
+	case "star", "unstar":
+		query, args = `UPDATE notes SET starred=$1, updated_at=NOW() WHERE id=$2 AND user_id=$3`, []interface{}{change.Action == "star", id, userID}
+	case "move":
+		position, err := appendPosition(tx, userID, change.Date)
+		if err != nil {
+			return nil, err
+		}
+		query = `UPDATE notes SET position=CASE WHEN note_date=$1 THEN position ELSE $2 END, note_date=$1, updated_at=NOW() WHERE id=$3 AND user_id=$4`
+		args = []interface{}{change.Date.Format("2006-01-02"), position, id, userID}
+	case "tag":
+		// Append only the tags the note does not have yet, keeping existing order
+		query = `UPDATE notes SET tags = tags || ARRAY(SELECT t FROM unnest($1::text[]) WITH ORDINALITY AS u(t, i) WHERE NOT (t = ANY(tags)) ORDER BY i), updated_at=NOW() WHERE id=$2 AND user_id=$3`
+		args = []interface{}{pq.Array(change.Tags), id, userID}
+	case "untag":
+		query = `UPDATE notes SET tags = ARRAY(SELECT t FROM unnest(tags) WITH ORDINALITY AS u(t, i) WHERE NOT (t = ANY($1::text[])) ORDER BY i), updated_at=NOW() WHERE id=$2 AND user_id=$3`
+		args = []interface{}{pq.Array(change.Tags), id, userID}
+	case "delete":
+		// Attachment rows cascade with the note; their blobs are purged by the caller after commit
+		rows, err := tx.Query(`SELECT storage_key FROM note_attachments WHERE note_id=$1`, id)
+		if err != nil {
+			return nil, fmt.Errorf("error listing attachments: %v", err)
+		}
+		var keys []string
+		for rows.Next() {
+			var k string
+			if err := rows.Scan(&k); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("error scanning attachment: %v", err)
+			}
+			keys = append(keys, k)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error listing attachments: %v", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM notes WHERE id=$1 AND user_id=$2`, id, userID); err != nil {
+			return nil, fmt.Errorf("error deleting note: %v", err)
+		}
+		return keys, nil
+	default:
+		return nil, fmt.Errorf("unsupported action %q", change.Action)
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return nil, fmt.Errorf("error updating note: %v", err)
+	}
+	return nil, nil
+}
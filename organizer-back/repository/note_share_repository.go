@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+
+	"github.com/lib/pq"
+)
+
+type NoteShareRepository struct {
+	db *sql.DB
+}
+
+func NewNoteShareRepository() *NoteShareRepository {
+	return &NoteShareRepository{db: database.DB}
+}
+
+// Upsert grants or changes the permission a user has on a note
+func (r *NoteShareRepository) Upsert(noteID, userID int, permission string) (*models.NoteShare, error) {
+	query := `
+		INSERT INTO note_shares (note_id, user_id, permission) VALUES ($1, $2, $3)
+		ON CONFLICT (note_id, user_id) DO UPDATE SET permission = EXCLUDED.permission
+		RETURNING note_id, user_id, permission, created_at, updated_at, (SELECT username FROM users WHERE id = $2)
+	`
+	var s models.NoteShare
+	if err := r.db.QueryRow(query, noteID, userID, permission).Scan(&s.NoteID, &s.UserID, &s.Permission, &s.CreatedAt, &s.UpdatedAt, &s.Username); err != nil {
+		return nil, fmt.Errorf("error sharing note: %v", err)
+	}
+	return &s, nil
+}
+
+func (r *NoteShareRepository) Delete(noteID, userID int) error {
+	res, err := r.db.Exec(`DELETE FROM note_shares WHERE note_id=$1 AND user_id=$2`, noteID, userID)
+	if err != nil {
+		return fmt.Errorf("error revoking share: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error revoking share: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("share not found")
+	}
+	return nil
+}
+
+func (r *NoteShareRepository) ListByNote(noteID int) ([]models.NoteShare, error) {
+	query := `
+		SELECT s.note_id, s.user_id, u.username, s.permission, s.created_at, s.updated_at
+		FROM note_shares s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.note_id = $1
+		ORDER BY u.username ASC
+	`
+	rows, err := r.db.Query(query, noteID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing shares: %v", err)
+	}
+	defer rows.Close()
+
+	shares := []models.NoteShare{}
+	for rows.Next() {
+		var s models.NoteShare
+		if err := rows.Scan(&s.NoteID, &s.UserID, &s.Username, &s.Permission, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning share: %v", err)
+		}
+		shares = append(shares, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating shares: %v", err)
+	}
+	return shares, nil
+}
+
+// ListSharedWith returns notes other users have shared with userID
+func (r *NoteShareRepository) ListSharedWith(userID int, includeHidden bool) ([]models.SharedNoteResponse, error) {
+	q := `
+		SELECT n.id, n.user_id, n.note_date, n.content, n.hidden, n.starred, n.tags, n.created_at, n.updated_at, u.username, s.permission
+		FROM note_shares s
+		JOIN notes n ON n.id = s.note_id
+		JOIN users u ON u.id = n.user_id
+		WHERE s.user_id = $1 AND NOT n.encrypted
+	`
+	if !includeHidden {
+		q += ` AND n.hidden = FALSE`
+	}
+	q += ` ORDER BY n.note_date DESC, n.created_at ASC`
+	rows, err := r.db.Query(q, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing shared notes: %v", err)
+	}
+	defer rows.Close()
+
+	res := []models.SharedNoteResponse{}
+	for rows.Next() {
+		var n models.Note
+		var owner, permission string
+		if err := rows.Scan(&n.ID, &n.UserID, &n.NoteDate, &n.Content, &n.Hidden, &n.Starred, pq.Array(&n.Tags), &n.CreatedAt, &n.UpdatedAt, &owner, &permission); err != nil {
+			return nil, fmt.Errorf("error scanning shared note: %v", err)
+		}
+		res = append(res, models.SharedNoteResponse{NoteResponse: n.ToResponse(), OwnerUsername: owner, Permission: permission})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating shared notes: %v", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+	"time"
+)
+
+// noteDailyStatsLive computes the same rows as the note_daily_stats rollup straight from notes
+const noteDailyStatsLive = `(
+	SELECT user_id, note_date, COUNT(*)::int AS note_count,
+	       COALESCE(SUM((SELECT COUNT(*) FROM regexp_matches(content, '\S+', 'g'))), 0)::int AS word_count
+	FROM notes WHERE user_id = $1
+	GROUP BY user_id, note_date
+)`
+
+type NoteStatsRepository struct {
+	db *sql.DB
+	// source is either the materialized rollup or the live query
+	source string
+}
+
+// NewNoteStatsRepository reads from the note_daily_stats materialized view when useRollup is
+// set; it is only as fresh as its last refresh
+func NewNoteStatsRepository(useRollup bool) *NoteStatsRepository {
+	source := noteDailyStatsLive
+	if useRollup {
+		source = "note_daily_stats"
+	}
+	return &NoteStatsRepository{db: database.DB, source: source}
+}
+
+// RefreshRollup recomputes the materialized rollup without blocking readers
+func (r *NoteStatsRepository) RefreshRollup() error {
+	if _, err := r.db.Exec(`REFRESH MATERIALIZED VIEW CONCURRENTLY note_daily_stats`); err != nil {
+		return fmt.Errorf("error refreshing note stats: %v", err)
+	}
+	return nil
+}
+
+// Streaks returns the current streak of consecutive days with notes, which counts while it
+// ends today or yesterday, and the longest one up to today
+func (r *NoteStatsRepository) Streaks(userID int, today time.Time) (int, int, error) {
+	query := `
+		WITH days AS (
+			SELECT note_date FROM ` + r.source + ` d
+			WHERE d.user_id = $1 AND d.note_date <= $2::date
+		), islands AS (
+			SELECT MAX(note_date) AS last_day, COUNT(*) AS length
+			FROM (SELECT note_date, note_date - (ROW_NUMBER() OVER (ORDER BY note_date))::int AS grp FROM days) g
+			GROUP BY grp
+		)
+		SELECT COALESCE(MAX(length) FILTER (WHERE last_day >= $2::date - 1), 0), COALESCE(MAX(length), 0)
+		FROM islands
+	`
+	var current, longest int
+	if err := r.db.QueryRow(query, userID, today.Format("2006-01-02")).Scan(&current, &longest); err != nil {
+		return 0, 0, fmt.Errorf("error computing streaks: %v", err)
+	}
+	return current, longest, nil
+}
+
+// Totals returns the number of notes and words the user has written
+func (r *NoteStatsRepository) Totals(userID int) (int, int, error) {
+	query := `SELECT COALESCE(SUM(note_count), 0), COALESCE(SUM(word_count), 0) FROM ` + r.source + ` d WHERE d.user_id = $1`
+	var notes, words int
+	if err := r.db.QueryRow(query, userID).Scan(&notes, &words); err != nil {
+		return 0, 0, fmt.Errorf("error computing note totals: %v", err)
+	}
+	return notes, words, nil
+}
+
+// Periods sums notes and words per week or month between the periods containing from and to,
+// including empty ones
+func (r *NoteStatsRepository) Periods(userID int, unit string, from, to time.Time) ([]models.NoteStatsPeriod, error) {
+	var format string
+	switch unit {
+	case "week":
+		format = "YYYY-MM-DD"
+	case "month":
+		format = "YYYY-MM"
+	default:
+		return nil, fmt.Errorf("invalid period %q", unit)
+	}
+	query := `
+		SELECT to_char(p, '` + format + `'), COALESCE(SUM(d.note_count), 0), COALESCE(SUM(d.word_count), 0)
+		FROM generate_series(date_trunc($2, $3::date::timestamp), date_trunc($2, $4::date::timestamp), ('1 ' || $2)::interval) AS p
+		LEFT JOIN ` + r.source + ` d ON d.user_id = $1 AND date_trunc($2, d.note_date::timestamp) = p
+		GROUP BY p
+		ORDER BY p ASC
+	`
+	rows, err := r.db.Query(query, userID, unit, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("error computing note stats: %v", err)
+	}
+	defer rows.Close()
+
+	periods := []models.NoteStatsPeriod{}
+	for rows.Next() {
+		var p models.NoteStatsPeriod
+		if err := rows.Scan(&p.Period, &p.Notes, &p.Words); err != nil {
+			return nil, fmt.Errorf("error scanning note stats: %v", err)
+		}
+		periods = append(periods, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating note stats: %v", err)
+	}
+	return periods, nil
+}
+
+// Heatmap returns the number of notes of each day with notes in [from, to]
+func (r *NoteStatsRepository) Heatmap(userID int, from, to time.Time) ([]models.NoteHeatmapDay, error) {
+	query := `
+		SELECT to_char(note_date, 'YYYY-MM-DD'), note_count FROM ` + r.source + ` d
+		WHERE d.user_id = $1 AND d.note_date BETWEEN $2 AND $3
+		ORDER BY note_date ASC
+	`
+	rows, err := r.db.Query(query, userID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("error computing heatmap: %v", err)
+	}
+	defer rows.Close()
+
+	days := []models.NoteHeatmapDay{}
+	for rows.Next() {
+		var d models.NoteHeatmapDay
+		if err := rows.Scan(&d.Date, &d.Count); err != nil {
+			return nil, fmt.Errorf("error scanning heatmap: %v", err)
+		}
+		days = append(days, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating heatmap: %v", err)
+	}
+	return days, nil
+}
+
+// TopTags returns the user's most used tags. Tags are not part of the rollup, so this always
+// reads notes.
+func (r *NoteStatsRepository) TopTags(userID, limit int) ([]models.NoteTagCount, error) {
+	query := `
+		SELECT t.tag, COUNT(*) FROM notes n CROSS JOIN LATERAL unnest(n.tags) AS t(tag)
+		WHERE n.user_id = $1
+		GROUP BY t.tag
+		ORDER BY COUNT(*) DESC, t.tag ASC
+		LIMIT $2
+	`
+	rows, err := r.db.Query(query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing top tags: %v", err)
+	}
+	defer rows.Close()
+
+	tags := []models.NoteTagCount{}
+	for rows.Next() {
+		var t models.NoteTagCount
+		if err := rows.Scan(&t.Tag, &t.Count); err != nil {
+			return nil, fmt.Errorf("error scanning tag: %v", err)
+		}
+		tags = append(tags, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tags: %v", err)
+	}
+	return tags, nil
+}
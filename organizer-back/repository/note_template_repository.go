@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type NoteTemplateRepository struct {
+	db *sql.DB
+}
+
+func NewNoteTemplateRepository() *NoteTemplateRepository {
+	return &NoteTemplateRepository{db: database.DB}
+}
+
+func (r *NoteTemplateRepository) Create(t *models.NoteTemplate) error {
+	query := `INSERT INTO note_templates (user_id, name, content, tags) VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at`
+	if err := r.db.QueryRow(query, t.UserID, t.Name, t.Content, pq.Array(t.Tags)).Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("a template with that name already exists")
+		}
+		return fmt.Errorf("error creating note template: %v", err)
+	}
+	return nil
+}
+
+func (r *NoteTemplateRepository) List(userID int) ([]models.NoteTemplate, error) {
+	rows, err := r.db.Query(`SELECT id, user_id, name, content, tags, created_at, updated_at FROM note_templates WHERE user_id=$1 ORDER BY name ASC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing note templates: %v", err)
+	}
+	defer rows.Close()
+
+	templates := []models.NoteTemplate{}
+	for rows.Next() {
+		var t models.NoteTemplate
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Content, pq.Array(&t.Tags), &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning note template: %v", err)
+		}
+		templates = append(templates, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating note templates: %v", err)
+	}
+	return templates, nil
+}
+
+func (r *NoteTemplateRepository) GetByID(userID, id int) (*models.NoteTemplate, error) {
+	var t models.NoteTemplate
+	query := `SELECT id, user_id, name, content, tags, created_at, updated_at FROM note_templates WHERE id=$1 AND user_id=$2`
+	if err := r.db.QueryRow(query, id, userID).Scan(&t.ID, &t.UserID, &t.Name, &t.Content, pq.Array(&t.Tags), &t.CreatedAt, &t.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("note template not found")
+		}
+		return nil, fmt.Errorf("error getting note template: %v", err)
+	}
+	return &t, nil
+}
+
+func (r *NoteTemplateRepository) Update(t *models.NoteTemplate) error {
+	query := `UPDATE note_templates SET name=$1, content=$2, tags=$3, updated_at=NOW() WHERE id=$4 AND user_id=$5 RETURNING created_at, updated_at`
+	if err := r.db.QueryRow(query, t.Name, t.Content, pq.Array(t.Tags), t.ID, t.UserID).Scan(&t.CreatedAt, &t.UpdatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("note template not found")
+		}
+		if isUniqueViolation(err) {
+			return fmt.Errorf("a template with that name already exists")
+		}
+		return fmt.Errorf("error updating note template: %v", err)
+	}
+	return nil
+}
+
+func (r *NoteTemplateRepository) Delete(userID, id int) error {
+	res, err := r.db.Exec(`DELETE FROM note_templates WHERE id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting note template: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error deleting note template: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("note template not found")
+	}
+	return nil
+}
+
+// GetDailySetting returns the user's daily template and the first day it applies to
+func (r *NoteTemplateRepository) GetDailySetting(userID int) (*int, *time.Time, error) {
+	var id sql.NullInt64
+	var since sql.NullTime
+	if err := r.db.QueryRow(`SELECT daily_template_id, daily_template_since FROM users WHERE id=$1`, userID).Scan(&id, &since); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, fmt.Errorf("user not found")
+		}
+		return nil, nil, fmt.Errorf("error getting daily template: %v", err)
+	}
+	if !id.Valid {
+		return nil, nil, nil
+	}
+	tid := int(id.Int64)
+	var sincePtr *time.Time
+	if since.Valid {
+		sincePtr = &since.Time
+	}
+	return &tid, sincePtr, nil
+}
+
+// SetDailySetting sets or clears (templateID nil) the user's daily template
+func (r *NoteTemplateRepository) SetDailySetting(userID int, templateID *int, since time.Time) error {
+	if _, err := r.db.Exec(`UPDATE users SET daily_template_id=$1, daily_template_since=$2 WHERE id=$3`, templateID, since.Format("2006-01-02"), userID); err != nil {
+		return fmt.Errorf("error setting daily template: %v", err)
+	}
+	return nil
+}
+
+// MaterializeDaily creates the daily note for date unless that day was already considered
+// or already has notes. render is only called when a note will actually be created. The
+// claim row makes concurrent first requests for the same day produce a single note.
+func (r *NoteTemplateRepository) MaterializeDaily(userID int, date time.Time, render func() (*models.Note, error)) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("error creating daily note: %v", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO daily_note_materializations (user_id, note_date) VALUES ($1, $2) ON CONFLICT DO NOTHING`, userID, date.Format("2006-01-02"))
+	if err != nil {
+		return false, fmt.Errorf("error creating daily note: %v", err)
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		return false, err
+	}
+
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM notes WHERE user_id=$1 AND note_date=$2)`, userID, date.Format("2006-01-02")).Scan(&exists); err != nil {
+		return false, fmt.Errorf("error creating daily note: %v", err)
+	}
+	if exists {
+		// The day already has content; remember it was considered and leave it alone
+		if err := tx.Commit(); err != nil {
+			return false, fmt.Errorf("error creating daily note: %v", err)
+		}
+		return false, nil
+	}
+
+	n, err := render()
+	if err != nil {
+		return false, err
+	}
+	if err := insertNote(tx, n); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`UPDATE daily_note_materializations SET note_id=$1 WHERE user_id=$2 AND note_date=$3`, n.ID, userID, date.Format("2006-01-02")); err != nil {
+		return false, fmt.Errorf("error creating daily note: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error creating daily note: %v", err)
+	}
+	return true, nil
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique constraint violation
+func isUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505"
+}
@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type PomodoroRepository struct {
+	db *sql.DB
+}
+
+func NewPomodoroRepository() *PomodoroRepository {
+	return &PomodoroRepository{db: database.DB}
+}
+
+const pomodoroCycleColumns = `id, user_id, todo_id, focus_minutes, short_break_minutes, long_break_minutes, long_break_interval, iterations_per_cycle, started_at, ended_at, cycle_date, created_at, updated_at`
+
+const pomodoroIntervalColumns = `id, cycle_id, todo_id, phase, iteration, planned_seconds, actual_seconds, interruptions, completed, started_at, ended_at, interval_date, created_at`
+
+func pomodoroCycleFields(c *models.PomodoroCycle) []interface{} {
+	return []interface{}{&c.ID, &c.UserID, &c.TodoID, &c.FocusMinutes, &c.ShortBreakMinutes, &c.LongBreakMinutes, &c.LongBreakInterval, &c.IterationsPerCycle, &c.StartedAt, &c.EndedAt, &c.CycleDate, &c.CreatedAt, &c.UpdatedAt}
+}
+
+func pomodoroIntervalFields(iv *models.PomodoroInterval) []interface{} {
+	return []interface{}{&iv.ID, &iv.CycleID, &iv.TodoID, &iv.Phase, &iv.Iteration, &iv.PlannedSeconds, &iv.ActualSeconds, &iv.Interruptions, &iv.Completed, &iv.StartedAt, &iv.EndedAt, &iv.IntervalDate, &iv.CreatedAt}
+}
+
+// ListByDate returns the cycles started on a day with their intervals, in start order
+func (r *PomodoroRepository) ListByDate(userID int, date time.Time) ([]models.PomodoroCycle, error) {
+	query := `SELECT ` + pomodoroCycleColumns + ` FROM pomodoro_cycles WHERE user_id=$1 AND cycle_date=$2 ORDER BY started_at ASC, id ASC`
+	rows, err := r.db.Query(query, userID, date.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("error listing pomodoro cycles: %v", err)
+	}
+	defer rows.Close()
+
+	cycles := []models.PomodoroCycle{}
+	for rows.Next() {
+		var c models.PomodoroCycle
+		if err := rows.Scan(pomodoroCycleFields(&c)...); err != nil {
+			return nil, fmt.Errorf("error scanning pomodoro cycle: %v", err)
+		}
+		cycles = append(cycles, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating pomodoro cycles: %v", err)
+	}
+	if err := r.attachIntervals(cycles); err != nil {
+		return nil, err
+	}
+	return cycles, nil
+}
+
+func (r *PomodoroRepository) GetByID(userID, id int) (*models.PomodoroCycle, error) {
+	var c models.PomodoroCycle
+	if err := r.db.QueryRow(`SELECT `+pomodoroCycleColumns+` FROM pomodoro_cycles WHERE id=$1 AND user_id=$2`, id, userID).Scan(pomodoroCycleFields(&c)...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("pomodoro cycle not found")
+		}
+		return nil, fmt.Errorf("error getting pomodoro cycle: %v", err)
+	}
+	cycles := []models.PomodoroCycle{c}
+	if err := r.attachIntervals(cycles); err != nil {
+		return nil, err
+	}
+	return &cycles[0], nil
+}
+
+// attachIntervals loads the intervals of all cycles with one query
+func (r *PomodoroRepository) attachIntervals(cycles []models.PomodoroCycle) error {
+	if len(cycles) == 0 {
+		return nil
+	}
+	ids := make([]int, len(cycles))
+	index := map[int]int{}
+	for i := range cycles {
+		ids[i] = cycles[i].ID
+		index[cycles[i].ID] = i
+		cycles[i].Intervals = []models.PomodoroInterval{}
+	}
+	query := `SELECT ` + pomodoroIntervalColumns + ` FROM pomodoro_intervals WHERE cycle_id = ANY($1) ORDER BY started_at ASC, id ASC`
+	rows, err := r.db.Query(query, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("error listing pomodoro intervals: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var iv models.PomodoroInterval
+		if err := rows.Scan(pomodoroIntervalFields(&iv)...); err != nil {
+			return fmt.Errorf("error scanning pomodoro interval: %v", err)
+		}
+		c := &cycles[index[iv.CycleID]]
+		c.Intervals = append(c.Intervals, iv)
+	}
+	return rows.Err()
+}
+
+// Create stores a cycle together with the intervals already run in it
+func (r *PomodoroRepository) Create(c *models.PomodoroCycle, intervals []models.PomodoroInterval) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error creating pomodoro cycle: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertPomodoroCycle(tx, c); err != nil {
+		return err
+	}
+	for i := range intervals {
+		intervals[i].CycleID = c.ID
+		if err := insertPomodoroInterval(tx, c.UserID, &intervals[i]); err != nil {
+			return err
+		}
+		c.Intervals = append(c.Intervals, intervals[i])
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error creating pomodoro cycle: %v", err)
+	}
+	return nil
+}
+
+func insertPomodoroCycle(tx *sql.Tx, c *models.PomodoroCycle) error {
+	query := `
+		INSERT INTO pomodoro_cycles (user_id, todo_id, focus_minutes, short_break_minutes, long_break_minutes, long_break_interval, iterations_per_cycle, started_at, ended_at, cycle_date)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING ` + pomodoroCycleColumns
+	if err := tx.QueryRow(query, c.UserID, c.TodoID, c.FocusMinutes, c.ShortBreakMinutes, c.LongBreakMinutes, c.LongBreakInterval, c.IterationsPerCycle,
+		c.StartedAt, c.EndedAt, c.CycleDate.Format("2006-01-02")).Scan(pomodoroCycleFields(c)...); err != nil {
+		return fmt.Errorf("error creating pomodoro cycle: %v", err)
+	}
+	c.Intervals = []models.PomodoroInterval{}
+	return nil
+}
+
+// AddInterval records an interval in one of the user's cycles
+func (r *PomodoroRepository) AddInterval(userID int, iv *models.PomodoroInterval) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error recording pomodoro interval: %v", err)
+	}
+	defer tx.Rollback()
+	if err := insertPomodoroInterval(tx, userID, iv); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error recording pomodoro interval: %v", err)
+	}
+	return nil
+}
+
+// insertPomodoroInterval adds an interval to iv.CycleID, provided the cycle is the user's
+func insertPomodoroInterval(tx *sql.Tx, userID int, iv *models.PomodoroInterval) error {
+	query := `
+		INSERT INTO pomodoro_intervals (cycle_id, user_id, todo_id, phase, iteration, planned_seconds, actual_seconds, interruptions, completed, started_at, ended_at, interval_date)
+		SELECT id, user_id, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12 FROM pomodoro_cycles WHERE id=$1 AND user_id=$2
+		RETURNING ` + pomodoroIntervalColumns
+	if err := tx.QueryRow(query, iv.CycleID, userID, iv.TodoID, iv.Phase, iv.Iteration, iv.PlannedSeconds, iv.ActualSeconds, iv.Interruptions, iv.Completed,
+		iv.StartedAt, iv.EndedAt, iv.IntervalDate.Format("2006-01-02")).Scan(pomodoroIntervalFields(iv)...); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("pomodoro cycle not found")
+		}
+		return fmt.Errorf("error recording pomodoro interval: %v", err)
+	}
+	return nil
+}
+
+// Update saves the todo and end time of a cycle
+func (r *PomodoroRepository) Update(c *models.PomodoroCycle) error {
+	query := `UPDATE pomodoro_cycles SET todo_id=$1, ended_at=$2, updated_at=NOW() WHERE id=$3 AND user_id=$4 RETURNING ` + pomodoroCycleColumns
+	intervals := c.Intervals
+	if err := r.db.QueryRow(query, c.TodoID, c.EndedAt, c.ID, c.UserID).Scan(pomodoroCycleFields(c)...); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("pomodoro cycle not found")
+		}
+		return fmt.Errorf("error updating pomodoro cycle: %v", err)
+	}
+	c.Intervals = intervals
+	return nil
+}
+
+func (r *PomodoroRepository) Delete(userID, id int) error {
+	res, err := r.db.Exec(`DELETE FROM pomodoro_cycles WHERE id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting pomodoro cycle: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error deleting pomodoro cycle: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("pomodoro cycle not found")
+	}
+	return nil
+}
+
+// FocusStats aggregates intervals per day or per week (keyed by its Monday) within
+// [from, to]. Periods without intervals are included with zeros.
+func (r *PomodoroRepository) FocusStats(userID int, group string, from, to time.Time) ([]models.PomodoroFocusPoint, error) {
+	if group != "day" && group != "week" {
+		return nil, fmt.Errorf("invalid group %q", group)
+	}
+	query := `
+		WITH periods AS (
+			SELECT generate_series(date_trunc($2, $3::date::timestamp), $4::date::timestamp, ('1 ' || $2)::interval) AS period
+		), totals AS (
+			SELECT date_trunc($2, interval_date::timestamp) AS period,
+			       SUM(actual_seconds) FILTER (WHERE phase = 'focus') AS focus_seconds,
+			       COUNT(*) FILTER (WHERE phase = 'focus') AS focus_intervals,
+			       COUNT(*) FILTER (WHERE phase = 'focus' AND completed) AS completed_focus,
+			       SUM(actual_seconds) FILTER (WHERE phase <> 'focus') AS break_seconds,
+			       SUM(interruptions) AS interruptions
+			FROM pomodoro_intervals
+			WHERE user_id=$1 AND interval_date BETWEEN $3 AND $4
+			GROUP BY 1
+		)
+		SELECT to_char(p.period, 'YYYY-MM-DD'),
+		       COALESCE(t.focus_seconds, 0), COALESCE(t.focus_intervals, 0), COALESCE(t.completed_focus, 0),
+		       COALESCE(t.break_seconds, 0), COALESCE(t.interruptions, 0)
+		FROM periods p
+		LEFT JOIN totals t ON t.period = p.period
+		ORDER BY p.period ASC
+	`
+	rows, err := r.db.Query(query, userID, group, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("error computing focus stats: %v", err)
+	}
+	defer rows.Close()
+
+	points := []models.PomodoroFocusPoint{}
+	for rows.Next() {
+		var p models.PomodoroFocusPoint
+		if err := rows.Scan(&p.Period, &p.FocusSeconds, &p.FocusIntervals, &p.CompletedFocus, &p.BreakSeconds, &p.Interruptions); err != nil {
+			return nil, fmt.Errorf("error scanning focus stats: %v", err)
+		}
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating focus stats: %v", err)
+	}
+	return points, nil
+}
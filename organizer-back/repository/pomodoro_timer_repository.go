@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"organizer-back/models"
+	"time"
+)
+
+const pomodoroTimerColumns = `user_id, cycle_id, status, phase, iteration, auto_continue, phase_started_at, phase_ends_at, remaining_seconds, interruptions, version, updated_at`
+
+func pomodoroTimerFields(t *models.PomodoroTimer) []interface{} {
+	return []interface{}{&t.UserID, &t.CycleID, &t.Status, &t.Phase, &t.Iteration, &t.AutoContinue, &t.PhaseStartedAt, &t.PhaseEndsAt, &t.RemainingSeconds, &t.Interruptions, &t.Version, &t.UpdatedAt}
+}
+
+// TimerChange is what a timer transition records besides the new timer state
+type TimerChange struct {
+	// Intervals finished by the transition, in the timer's current cycle
+	Intervals []models.PomodoroInterval
+	// EndCycle closes the current cycle at that time
+	EndCycle *time.Time
+	// NewCycle is created and the timer attached to it
+	NewCycle *models.PomodoroCycle
+}
+
+// TimerTransition changes a locked timer; cycle is the cycle it is running, nil when idle
+type TimerTransition func(t *models.PomodoroTimer, cycle *models.PomodoroCycle) (*TimerChange, error)
+
+// TimerUpdate is a timer after a transition together with its cycle (without intervals)
+type TimerUpdate struct {
+	Timer models.PomodoroTimer
+	Cycle *models.PomodoroCycle
+}
+
+// GetTimer returns the user's timer, an idle one if they never used it
+func (r *PomodoroRepository) GetTimer(userID int) (*TimerUpdate, error) {
+	u := &TimerUpdate{Timer: models.PomodoroTimer{UserID: userID, Status: "idle", Phase: "focus", Iteration: 1, AutoContinue: true}}
+	err := r.db.QueryRow(`SELECT `+pomodoroTimerColumns+` FROM pomodoro_timers WHERE user_id=$1`, userID).Scan(pomodoroTimerFields(&u.Timer)...)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error getting pomodoro timer: %v", err)
+	}
+	if u.Timer.CycleID != nil {
+		if u.Cycle, err = timerCycle(r.db.QueryRow, *u.Timer.CycleID); err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// ApplyTimer runs a transition on the user's timer with its row locked, so commands from
+// several devices and the scheduler apply one after another
+func (r *PomodoroRepository) ApplyTimer(userID int, transition TimerTransition) (*TimerUpdate, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error updating pomodoro timer: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO pomodoro_timers (user_id) VALUES ($1) ON CONFLICT (user_id) DO NOTHING`, userID); err != nil {
+		return nil, fmt.Errorf("error updating pomodoro timer: %v", err)
+	}
+	var t models.PomodoroTimer
+	if err := tx.QueryRow(`SELECT `+pomodoroTimerColumns+` FROM pomodoro_timers WHERE user_id=$1 FOR UPDATE`, userID).Scan(pomodoroTimerFields(&t)...); err != nil {
+		return nil, fmt.Errorf("error locking pomodoro timer: %v", err)
+	}
+	u, err := applyTimerTransition(tx, &t, transition)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error updating pomodoro timer: %v", err)
+	}
+	return u, nil
+}
+
+// AdvanceDue claims up to limit running timers whose phase ended by now, skipping rows other
+// instances hold, and runs transition on each
+func (r *PomodoroRepository) AdvanceDue(now time.Time, limit int, transition TimerTransition) ([]TimerUpdate, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error advancing pomodoro timers: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT ` + pomodoroTimerColumns + ` FROM pomodoro_timers
+		WHERE status = 'running' AND phase_ends_at <= $1
+		ORDER BY phase_ends_at ASC
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`
+	rows, err := tx.Query(query, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error claiming pomodoro timers: %v", err)
+	}
+	var due []models.PomodoroTimer
+	for rows.Next() {
+		var t models.PomodoroTimer
+		if err := rows.Scan(pomodoroTimerFields(&t)...); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning pomodoro timer: %v", err)
+		}
+		due = append(due, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error claiming pomodoro timers: %v", err)
+	}
+
+	updates := make([]TimerUpdate, 0, len(due))
+	for i := range due {
+		u, err := applyTimerTransition(tx, &due[i], transition)
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, *u)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error advancing pomodoro timers: %v", err)
+	}
+	return updates, nil
+}
+
+// applyTimerTransition runs transition on a timer locked by tx and stores what it changed
+func applyTimerTransition(tx *sql.Tx, t *models.PomodoroTimer, transition TimerTransition) (*TimerUpdate, error) {
+	var cycle *models.PomodoroCycle
+	if t.CycleID != nil {
+		var err error
+		if cycle, err = timerCycle(tx.QueryRow, *t.CycleID); err != nil {
+			return nil, err
+		}
+	}
+	change, err := transition(t, cycle)
+	if err != nil {
+		return nil, err
+	}
+	if change != nil {
+		for i := range change.Intervals {
+			if err := insertPomodoroInterval(tx, t.UserID, &change.Intervals[i]); err != nil {
+				return nil, err
+			}
+		}
+		if change.EndCycle != nil && cycle != nil {
+			if _, err := tx.Exec(`UPDATE pomodoro_cycles SET ended_at=$1, updated_at=NOW() WHERE id=$2`, *change.EndCycle, cycle.ID); err != nil {
+				return nil, fmt.Errorf("error ending pomodoro cycle: %v", err)
+			}
+			cycle.EndedAt = change.EndCycle
+		}
+		if change.NewCycle != nil {
+			if err := insertPomodoroCycle(tx, change.NewCycle); err != nil {
+				return nil, err
+			}
+			t.CycleID = &change.NewCycle.ID
+			cycle = change.NewCycle
+		}
+	}
+	if t.CycleID == nil {
+		cycle = nil
+	}
+
+	query := `
+		UPDATE pomodoro_timers SET cycle_id=$1, status=$2, phase=$3, iteration=$4, auto_continue=$5, phase_started_at=$6,
+		       phase_ends_at=$7, remaining_seconds=$8, interruptions=$9, version=version+1, updated_at=NOW()
+		WHERE user_id=$10
+		RETURNING ` + pomodoroTimerColumns
+	if err := tx.QueryRow(query, t.CycleID, t.Status, t.Phase, t.Iteration, t.AutoContinue, t.PhaseStartedAt,
+		t.PhaseEndsAt, t.RemainingSeconds, t.Interruptions, t.UserID).Scan(pomodoroTimerFields(t)...); err != nil {
+		return nil, fmt.Errorf("error saving pomodoro timer: %v", err)
+	}
+	return &TimerUpdate{Timer: *t, Cycle: cycle}, nil
+}
+
+// timerCycle loads a cycle without its intervals; a deleted cycle is nil
+func timerCycle(queryRow func(string, ...interface{}) *sql.Row, id int) (*models.PomodoroCycle, error) {
+	var c models.PomodoroCycle
+	if err := queryRow(`SELECT `+pomodoroCycleColumns+` FROM pomodoro_cycles WHERE id=$1`, id).Scan(pomodoroCycleFields(&c)...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting pomodoro cycle: %v", err)
+	}
+	return &c, nil
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+)
+
+type PreferenceRepository struct {
+	db *sql.DB
+}
+
+func NewPreferenceRepository() *PreferenceRepository {
+	return &PreferenceRepository{db: database.DB}
+}
+
+// PreferencePatch turns the stored set, nil when there is none, into the data to store
+type PreferencePatch func(current *models.PreferenceSet) (json.RawMessage, error)
+
+// GetUser returns the user's set of a namespace, nil when they never changed it
+func (r *PreferenceRepository) GetUser(userID int, namespace string) (*models.PreferenceSet, error) {
+	var p models.PreferenceSet
+	err := r.db.QueryRow(`SELECT namespace, schema_version, data, updated_at FROM user_preferences WHERE user_id=$1 AND namespace=$2`, userID, namespace).
+		Scan(&p.Namespace, &p.SchemaVersion, &p.Data, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting preferences: %v", err)
+	}
+	return &p, nil
+}
+
+// GetDefaults returns the admin defaults of a namespace, nil when none were set
+func (r *PreferenceRepository) GetDefaults(namespace string) (*models.PreferenceSet, error) {
+	var p models.PreferenceSet
+	err := r.db.QueryRow(`SELECT namespace, schema_version, data, updated_at FROM preference_defaults WHERE namespace=$1`, namespace).
+		Scan(&p.Namespace, &p.SchemaVersion, &p.Data, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting preference defaults: %v", err)
+	}
+	return &p, nil
+}
+
+// PatchUser applies patch to the user's set with the row locked, so concurrent patches
+// from several browsers are merged one after another
+func (r *PreferenceRepository) PatchUser(userID int, namespace string, version int, patch PreferencePatch) (*models.PreferenceSet, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error updating preferences: %v", err)
+	}
+	defer tx.Rollback()
+
+	var current *models.PreferenceSet
+	var p models.PreferenceSet
+	err = tx.QueryRow(`SELECT namespace, schema_version, data, updated_at FROM user_preferences WHERE user_id=$1 AND namespace=$2 FOR UPDATE`, userID, namespace).
+		Scan(&p.Namespace, &p.SchemaVersion, &p.Data, &p.UpdatedAt)
+	switch {
+	case err == nil:
+		current = &p
+	case err != sql.ErrNoRows:
+		return nil, fmt.Errorf("error locking preferences: %v", err)
+	}
+	data, err := patch(current)
+	if err != nil {
+		return nil, err
+	}
+
+	// A concurrent first write is caught by the conflict clause and wins; the row lock
+	// above serializes every later patch
+	query := `
+		INSERT INTO user_preferences (user_id, namespace, schema_version, data) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, namespace) DO UPDATE SET schema_version=EXCLUDED.schema_version, data=EXCLUDED.data, updated_at=NOW()
+		RETURNING namespace, schema_version, data, updated_at
+	`
+	var saved models.PreferenceSet
+	if err := tx.QueryRow(query, userID, namespace, version, []byte(data)).Scan(&saved.Namespace, &saved.SchemaVersion, &saved.Data, &saved.UpdatedAt); err != nil {
+		return nil, fmt.Errorf("error saving preferences: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error updating preferences: %v", err)
+	}
+	return &saved, nil
+}
+
+// PatchDefaults applies patch to the admin defaults of a namespace with the row locked
+func (r *PreferenceRepository) PatchDefaults(namespace string, version, adminID int, patch PreferencePatch) (*models.PreferenceSet, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error updating preference defaults: %v", err)
+	}
+	defer tx.Rollback()
+
+	var current *models.PreferenceSet
+	var p models.PreferenceSet
+	err = tx.QueryRow(`SELECT namespace, schema_version, data, updated_at FROM preference_defaults WHERE namespace=$1 FOR UPDATE`, namespace).
+		Scan(&p.Namespace, &p.SchemaVersion, &p.Data, &p.UpdatedAt)
+	switch {
+	case err == nil:
+		current = &p
+	case err != sql.ErrNoRows:
+		return nil, fmt.Errorf("error locking preference defaults: %v", err)
+	}
+	data, err := patch(current)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO preference_defaults (namespace, schema_version, data, updated_by) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (namespace) DO UPDATE SET schema_version=EXCLUDED.schema_version, data=EXCLUDED.data, updated_by=EXCLUDED.updated_by, updated_at=NOW()
+		RETURNING namespace, schema_version, data, updated_at
+	`
+	var saved models.PreferenceSet
+	if err := tx.QueryRow(query, namespace, version, []byte(data), adminID).Scan(&saved.Namespace, &saved.SchemaVersion, &saved.Data, &saved.UpdatedAt); err != nil {
+		return nil, fmt.Errorf("error saving preference defaults: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error updating preference defaults: %v", err)
+	}
+	return &saved, nil
+}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ProjectRepository struct {
+	db *sql.DB
+}
+
+func NewProjectRepository() *ProjectRepository {
+	return &ProjectRepository{db: database.DB}
+}
+
+const projectColumns = `id, user_id, name, description, goal, deadline, status, color, archived_at, created_at, updated_at`
+
+func projectFields(p *models.Project) []interface{} {
+	return []interface{}{&p.ID, &p.UserID, &p.Name, &p.Description, &p.Goal, &p.Deadline, &p.Status, &p.Color, &p.ArchivedAt, &p.CreatedAt, &p.UpdatedAt}
+}
+
+// List returns the user's projects, those with the nearest deadline first
+func (r *ProjectRepository) List(userID int, status string, includeArchived bool) ([]models.Project, error) {
+	query := `
+		SELECT ` + projectColumns + ` FROM projects
+		WHERE user_id=$1 AND ($2 = '' OR status = $2) AND ($3 OR archived_at IS NULL)
+		ORDER BY deadline ASC NULLS LAST, name ASC, id ASC`
+	rows, err := r.db.Query(query, userID, status, includeArchived)
+	if err != nil {
+		return nil, fmt.Errorf("error listing projects: %v", err)
+	}
+	defer rows.Close()
+
+	projects := []models.Project{}
+	for rows.Next() {
+		var p models.Project
+		if err := rows.Scan(projectFields(&p)...); err != nil {
+			return nil, fmt.Errorf("error scanning project: %v", err)
+		}
+		projects = append(projects, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating projects: %v", err)
+	}
+	return projects, nil
+}
+
+func (r *ProjectRepository) GetByID(userID, id int) (*models.Project, error) {
+	var p models.Project
+	if err := r.db.QueryRow(`SELECT `+projectColumns+` FROM projects WHERE id=$1 AND user_id=$2`, id, userID).Scan(projectFields(&p)...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("project not found")
+		}
+		return nil, fmt.Errorf("error getting project: %v", err)
+	}
+	return &p, nil
+}
+
+func (r *ProjectRepository) Create(p *models.Project) error {
+	query := `
+		INSERT INTO projects (user_id, name, description, goal, deadline, status, color)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + projectColumns
+	if err := r.db.QueryRow(query, p.UserID, p.Name, p.Description, p.Goal, dateParam(p.Deadline), p.Status, p.Color).Scan(projectFields(p)...); err != nil {
+		return fmt.Errorf("error creating project: %v", err)
+	}
+	return nil
+}
+
+func (r *ProjectRepository) Update(p *models.Project) error {
+	query := `
+		UPDATE projects SET name=$1, description=$2, goal=$3, deadline=$4, status=$5, color=$6, updated_at=NOW()
+		WHERE id=$7 AND user_id=$8
+		RETURNING ` + projectColumns
+	if err := r.db.QueryRow(query, p.Name, p.Description, p.Goal, dateParam(p.Deadline), p.Status, p.Color, p.ID, p.UserID).Scan(projectFields(p)...); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("project not found")
+		}
+		return fmt.Errorf("error updating project: %v", err)
+	}
+	return nil
+}
+
+// SetArchived archives or restores a project; archiving an archived project keeps its date
+func (r *ProjectRepository) SetArchived(userID, id int, archived bool) (*models.Project, error) {
+	query := `
+		UPDATE projects SET archived_at=CASE WHEN $1 THEN COALESCE(archived_at, NOW()) END, updated_at=NOW()
+		WHERE id=$2 AND user_id=$3
+		RETURNING ` + projectColumns
+	var p models.Project
+	if err := r.db.QueryRow(query, archived, id, userID).Scan(projectFields(&p)...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("project not found")
+		}
+		return nil, fmt.Errorf("error archiving project: %v", err)
+	}
+	return &p, nil
+}
+
+// Delete removes a project; its todos and the notes linking to it stay
+func (r *ProjectRepository) Delete(userID, id int) error {
+	res, err := r.db.Exec(`DELETE FROM projects WHERE id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting project: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error deleting project: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("project not found")
+	}
+	return nil
+}
+
+// Progress counts the top-level todos of the given projects by status with one query;
+// today is the caller's current date and decides which todos are overdue
+func (r *ProjectRepository) Progress(userID int, projectIDs []int, today time.Time) (map[int]models.ProjectProgress, error) {
+	res := map[int]models.ProjectProgress{}
+	if len(projectIDs) == 0 {
+		return res, nil
+	}
+	ids := make([]int64, len(projectIDs))
+	for i, id := range projectIDs {
+		ids[i] = int64(id)
+	}
+	query := `
+		SELECT project_id,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE status = 'open'),
+		       COUNT(*) FILTER (WHERE status = 'in_progress'),
+		       COUNT(*) FILTER (WHERE status = 'done'),
+		       COUNT(*) FILTER (WHERE status = 'cancelled'),
+		       COUNT(*) FILTER (WHERE status IN ('open', 'in_progress') AND due_date < $3)
+		FROM todos
+		WHERE user_id=$1 AND project_id = ANY($2) AND parent_id IS NULL
+		GROUP BY project_id
+	`
+	rows, err := r.db.Query(query, userID, pq.Array(ids), today.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("error computing project progress: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var p models.ProjectProgress
+		if err := rows.Scan(&id, &p.Total, &p.Open, &p.InProgress, &p.Done, &p.Cancelled, &p.Overdue); err != nil {
+			return nil, fmt.Errorf("error scanning project progress: %v", err)
+		}
+		res[id] = p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating project progress: %v", err)
+	}
+	return res, nil
+}
+
+// Timeline returns the owner's notes that link to the project, latest first, optionally
+// within inclusive note date bounds
+func (r *ProjectRepository) Timeline(userID, projectID int, from, to *time.Time) ([]models.NoteLinkTarget, error) {
+	query := `
+		SELECT n.id, to_char(n.note_date, 'YYYY-MM-DD'), left(n.content, $5), n.encrypted
+		FROM note_links l
+		JOIN notes n ON n.id = l.source_note_id
+		WHERE n.user_id = $1 AND l.kind = 'project' AND l.target_project_id = $2
+		  AND ($3::date IS NULL OR n.note_date >= $3) AND ($4::date IS NULL OR n.note_date <= $4)
+		ORDER BY n.note_date DESC, n.position ASC
+	`
+	rows, err := r.db.Query(query, userID, projectID, dateParam(from), dateParam(to), notePreviewLength)
+	if err != nil {
+		return nil, fmt.Errorf("error listing project timeline: %v", err)
+	}
+	defer rows.Close()
+
+	notes := []models.NoteLinkTarget{}
+	for rows.Next() {
+		var n models.NoteLinkTarget
+		if err := rows.Scan(&n.ID, &n.NoteDate, &n.Preview, &n.Encrypted); err != nil {
+			return nil, fmt.Errorf("error scanning project timeline: %v", err)
+		}
+		notes = append(notes, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating project timeline: %v", err)
+	}
+	return notes, nil
+}
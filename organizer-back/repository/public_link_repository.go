@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+	"time"
+)
+
+type PublicLinkRepository struct {
+	db *sql.DB
+}
+
+func NewPublicLinkRepository() *PublicLinkRepository {
+	return &PublicLinkRepository{db: database.DB}
+}
+
+func (r *PublicLinkRepository) Create(l *models.PublicLink) error {
+	query := `INSERT INTO note_public_links (note_id, user_id, token_hash, password_hash, hidden_confirmed, expires_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	if err := r.db.QueryRow(query, l.NoteID, l.UserID, l.TokenHash, l.PasswordHash, l.HiddenConfirmed, l.ExpiresAt).Scan(&l.ID, &l.CreatedAt); err != nil {
+		return fmt.Errorf("error creating public link: %v", err)
+	}
+	return nil
+}
+
+func (r *PublicLinkRepository) ListByNote(userID, noteID int) ([]models.PublicLink, error) {
+	query := `SELECT id, note_id, user_id, token_hash, password_hash, hidden_confirmed, expires_at, revoked_at, last_accessed_at, created_at FROM note_public_links WHERE note_id=$1 AND user_id=$2 ORDER BY created_at DESC`
+	rows, err := r.db.Query(query, noteID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing public links: %v", err)
+	}
+	defer rows.Close()
+
+	links := []models.PublicLink{}
+	for rows.Next() {
+		var l models.PublicLink
+		if err := rows.Scan(&l.ID, &l.NoteID, &l.UserID, &l.TokenHash, &l.PasswordHash, &l.HiddenConfirmed, &l.ExpiresAt, &l.RevokedAt, &l.LastAccessedAt, &l.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning public link: %v", err)
+		}
+		links = append(links, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating public links: %v", err)
+	}
+	return links, nil
+}
+
+// Revoke marks a link as revoked; revoking twice is reported as not found
+func (r *PublicLinkRepository) Revoke(userID, noteID, id int) error {
+	res, err := r.db.Exec(`UPDATE note_public_links SET revoked_at=NOW() WHERE id=$1 AND note_id=$2 AND user_id=$3 AND revoked_at IS NULL`, id, noteID, userID)
+	if err != nil {
+		return fmt.Errorf("error revoking public link: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error revoking public link: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("public link not found")
+	}
+	return nil
+}
+
+// GetActiveByTokenHash resolves a live (not revoked, not expired) link together with its note
+func (r *PublicLinkRepository) GetActiveByTokenHash(tokenHash string, now time.Time) (*models.PublicLink, *models.Note, error) {
+	query := `
+		SELECT l.id, l.note_id, l.user_id, l.token_hash, l.password_hash, l.hidden_confirmed, l.expires_at, l.revoked_at, l.last_accessed_at, l.created_at,
+		       n.id, n.user_id, n.note_date, n.content, n.hidden, n.starred, n.encrypted, n.created_at, n.updated_at
+		FROM note_public_links l
+		JOIN notes n ON n.id = l.note_id
+		WHERE l.token_hash = $1 AND l.revoked_at IS NULL AND (l.expires_at IS NULL OR l.expires_at > $2)
+	`
+	var l models.PublicLink
+	var n models.Note
+	err := r.db.QueryRow(query, tokenHash, now).Scan(
+		&l.ID, &l.NoteID, &l.UserID, &l.TokenHash, &l.PasswordHash, &l.HiddenConfirmed, &l.ExpiresAt, &l.RevokedAt, &l.LastAccessedAt, &l.CreatedAt,
+		&n.ID, &n.UserID, &n.NoteDate, &n.Content, &n.Hidden, &n.Starred, &n.Encrypted, &n.CreatedAt, &n.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, fmt.Errorf("link not found")
+		}
+		return nil, nil, fmt.Errorf("error getting public link: %v", err)
+	}
+	return &l, &n, nil
+}
+
+func (r *PublicLinkRepository) TouchAccessed(id int) error {
+	if _, err := r.db.Exec(`UPDATE note_public_links SET last_accessed_at=NOW() WHERE id=$1`, id); err != nil {
+		return fmt.Errorf("error updating public link: %v", err)
+	}
+	return nil
+}
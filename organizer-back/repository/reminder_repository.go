@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ReminderRepository struct {
+	db *sql.DB
+}
+
+func NewReminderRepository() *ReminderRepository {
+	return &ReminderRepository{db: database.DB}
+}
+
+const reminderColumns = `id, user_id, title, message, note_id, todo_id, starts_at, timezone, rrule, channels, webhook_url, active, next_fire_at, last_fired_at, fire_count, created_at, updated_at`
+
+func reminderFields(r *models.Reminder) []interface{} {
+	return []interface{}{&r.ID, &r.UserID, &r.Title, &r.Message, &r.NoteID, &r.TodoID, &r.StartsAt, &r.Timezone, &r.RRule,
+		pq.Array(&r.Channels), &r.WebhookURL, &r.Active, &r.NextFireAt, &r.LastFiredAt, &r.FireCount, &r.CreatedAt, &r.UpdatedAt}
+}
+
+// ClaimedDelivery is a pending delivery leased to this instance, with what channels need
+type ClaimedDelivery struct {
+	models.ReminderDelivery
+	UserID       int
+	Email        string
+	WebhookURL   *string
+	Notification models.ReminderNotification
+}
+
+func (r *ReminderRepository) List(userID int) ([]models.Reminder, error) {
+	rows, err := r.db.Query(`SELECT `+reminderColumns+` FROM reminders WHERE user_id=$1 ORDER BY next_fire_at ASC NULLS LAST, id ASC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing reminders: %v", err)
+	}
+	defer rows.Close()
+
+	reminders := []models.Reminder{}
+	for rows.Next() {
+		var rem models.Reminder
+		if err := rows.Scan(reminderFields(&rem)...); err != nil {
+			return nil, fmt.Errorf("error scanning reminder: %v", err)
+		}
+		reminders = append(reminders, rem)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reminders: %v", err)
+	}
+	return reminders, nil
+}
+
+func (r *ReminderRepository) GetByID(userID, id int) (*models.Reminder, error) {
+	var rem models.Reminder
+	if err := r.db.QueryRow(`SELECT `+reminderColumns+` FROM reminders WHERE id=$1 AND user_id=$2`, id, userID).Scan(reminderFields(&rem)...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("reminder not found")
+		}
+		return nil, fmt.Errorf("error getting reminder: %v", err)
+	}
+	return &rem, nil
+}
+
+func (r *ReminderRepository) Create(rem *models.Reminder) error {
+	query := `
+		INSERT INTO reminders (user_id, title, message, note_id, todo_id, starts_at, timezone, rrule, channels, webhook_url, active, next_fire_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING ` + reminderColumns
+	err := r.db.QueryRow(query, rem.UserID, rem.Title, rem.Message, rem.NoteID, rem.TodoID, rem.StartsAt, rem.Timezone, rem.RRule,
+		pq.Array(rem.Channels), rem.WebhookURL, rem.Active, rem.NextFireAt).Scan(reminderFields(rem)...)
+	if err != nil {
+		return fmt.Errorf("error creating reminder: %v", err)
+	}
+	return nil
+}
+
+func (r *ReminderRepository) Update(rem *models.Reminder) error {
+	query := `
+		UPDATE reminders SET title=$1, message=$2, note_id=$3, todo_id=$4, starts_at=$5, timezone=$6, rrule=$7, channels=$8,
+		       webhook_url=$9, active=$10, next_fire_at=$11, updated_at=NOW()
+		WHERE id=$12 AND user_id=$13
+		RETURNING ` + reminderColumns
+	err := r.db.QueryRow(query, rem.Title, rem.Message, rem.NoteID, rem.TodoID, rem.StartsAt, rem.Timezone, rem.RRule,
+		pq.Array(rem.Channels), rem.WebhookURL, rem.Active, rem.NextFireAt, rem.ID, rem.UserID).Scan(reminderFields(rem)...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("reminder not found")
+		}
+		return fmt.Errorf("error updating reminder: %v", err)
+	}
+	return nil
+}
+
+func (r *ReminderRepository) Delete(userID, id int) error {
+	res, err := r.db.Exec(`DELETE FROM reminders WHERE id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting reminder: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error deleting reminder: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("reminder not found")
+	}
+	return nil
+}
+
+// ListDeliveries returns the latest deliveries of a reminder
+func (r *ReminderRepository) ListDeliveries(reminderID, limit int) ([]models.ReminderDelivery, error) {
+	query := `
+		SELECT id, reminder_id, channel, fire_at, status, attempts, last_error, delivered_at, created_at
+		FROM reminder_deliveries WHERE reminder_id=$1
+		ORDER BY fire_at DESC, id DESC
+		LIMIT $2
+	`
+	rows, err := r.db.Query(query, reminderID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.ReminderDelivery{}
+	for rows.Next() {
+		var d models.ReminderDelivery
+		if err := rows.Scan(&d.ID, &d.ReminderID, &d.Channel, &d.FireAt, &d.Status, &d.Attempts, &d.LastError, &d.DeliveredAt, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning delivery: %v", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating deliveries: %v", err)
+	}
+	return deliveries, nil
+}
+
+// FireDue claims up to limit due reminders, skipping rows other instances hold, queues one
+// delivery per channel and advances each reminder to next(reminder), nil when it is over.
+// It returns how many reminders fired.
+func (r *ReminderRepository) FireDue(limit int, next func(*models.Reminder) *time.Time) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error firing reminders: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT ` + reminderColumns + ` FROM reminders
+		WHERE active AND next_fire_at <= NOW()
+		ORDER BY next_fire_at ASC
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+	rows, err := tx.Query(query, limit)
+	if err != nil {
+		return 0, fmt.Errorf("error claiming reminders: %v", err)
+	}
+	var due []models.Reminder
+	for rows.Next() {
+		var rem models.Reminder
+		if err := rows.Scan(reminderFields(&rem)...); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning reminder: %v", err)
+		}
+		due = append(due, rem)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error claiming reminders: %v", err)
+	}
+
+	for i := range due {
+		rem := &due[i]
+		fireAt := *rem.NextFireAt
+		if _, err := tx.Exec(`INSERT INTO reminder_deliveries (reminder_id, channel, fire_at) SELECT $1, unnest($2::text[]), $3`, rem.ID, pq.Array(rem.Channels), fireAt); err != nil {
+			return 0, fmt.Errorf("error queueing deliveries: %v", err)
+		}
+		if _, err := tx.Exec(`UPDATE reminders SET next_fire_at=$1, last_fired_at=$2, fire_count=fire_count+1 WHERE id=$3`, next(rem), fireAt, rem.ID); err != nil {
+			return 0, fmt.Errorf("error advancing reminder: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error firing reminders: %v", err)
+	}
+	return len(due), nil
+}
+
+// ClaimDeliveries leases up to limit pending deliveries for lease; one whose instance dies
+// before finishing becomes claimable again when the lease runs out
+func (r *ReminderRepository) ClaimDeliveries(limit int, lease time.Duration) ([]ClaimedDelivery, error) {
+	query := `
+		UPDATE reminder_deliveries d
+		SET attempts = d.attempts + 1, next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM reminders r
+		JOIN users u ON u.id = r.user_id
+		WHERE r.id = d.reminder_id AND d.id IN (
+			SELECT id FROM reminder_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at ASC
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.reminder_id, d.channel, d.fire_at, d.status, d.attempts, d.last_error, d.delivered_at, d.created_at,
+		          r.user_id, u.email, r.webhook_url, r.title, r.message, r.note_id, r.todo_id
+	`
+	rows, err := r.db.Query(query, limit, int(lease.Seconds()))
+	if err != nil {
+		return nil, fmt.Errorf("error claiming deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	var claimed []ClaimedDelivery
+	for rows.Next() {
+		var c ClaimedDelivery
+		n := &c.Notification
+		if err := rows.Scan(&c.ID, &c.ReminderID, &c.Channel, &c.FireAt, &c.Status, &c.Attempts, &c.LastError, &c.DeliveredAt, &c.CreatedAt,
+			&c.UserID, &c.Email, &c.WebhookURL, &n.Title, &n.Message, &n.NoteID, &n.TodoID); err != nil {
+			return nil, fmt.Errorf("error scanning delivery: %v", err)
+		}
+		n.ReminderID, n.FireAt = c.ReminderID, c.FireAt
+		claimed = append(claimed, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error claiming deliveries: %v", err)
+	}
+	return claimed, nil
+}
+
+// MarkDelivered records a successful delivery
+func (r *ReminderRepository) MarkDelivered(id int64) error {
+	if _, err := r.db.Exec(`UPDATE reminder_deliveries SET status='sent', last_error=NULL, delivered_at=NOW() WHERE id=$1`, id); err != nil {
+		return fmt.Errorf("error updating delivery: %v", err)
+	}
+	return nil
+}
+
+// MarkFailed records a failed attempt; the delivery is retried at retryAt, or given up when nil
+func (r *ReminderRepository) MarkFailed(id int64, reason string, retryAt *time.Time) error {
+	query := `UPDATE reminder_deliveries SET status='failed', last_error=$2 WHERE id=$1`
+	args := []interface{}{id, reason}
+	if retryAt != nil {
+		query = `UPDATE reminder_deliveries SET last_error=$2, next_attempt_at=$3 WHERE id=$1`
+		args = append(args, *retryAt)
+	}
+	if _, err := r.db.Exec(query, args...); err != nil {
+		return fmt.Errorf("error updating delivery: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"organizer-back/database"
+	"organizer-back/models"
+	"organizer-back/rank"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type TodoRepository struct {
+	db *sql.DB
+}
+
+func NewTodoRepository() *TodoRepository {
+	return &TodoRepository{db: database.DB}
+}
+
+const todoColumns = `id, user_id, uid, dav_name, parent_id, project_id, title, description, due_date, priority, status, completed_at, position, created_at, updated_at`
+
+func todoFields(t *models.Todo) []interface{} {
+	return []interface{}{&t.ID, &t.UserID, &t.UID, &t.DavName, &t.ParentID, &t.ProjectID, &t.Title, &t.Description, &t.DueDate, &t.Priority, &t.Status, &t.CompletedAt, &t.Position, &t.CreatedAt, &t.UpdatedAt}
+}
+
+// TodoFilter selects top-level todos. Today is the caller's current date; the completion
+// bounds are instants, From inclusive and To exclusive.
+type TodoFilter struct {
+	View          string
+	Priority      string
+	ProjectID     int
+	Today         time.Time
+	CompletedFrom *time.Time
+	CompletedTo   *time.Time
+}
+
+// lockTodos serializes changes to the order of a user's todos
+func lockTodos(tx *sql.Tx, userID int) error {
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('todos'), $1)`, userID); err != nil {
+		return fmt.Errorf("error locking todos: %v", err)
+	}
+	return nil
+}
+
+// appendTodoPosition returns a rank after the last sibling; the caller holds lockTodos
+func appendTodoPosition(tx *sql.Tx, userID int, parentID *int) (string, error) {
+	var last sql.NullString
+	if err := tx.QueryRow(`SELECT MAX(position) FROM todos WHERE user_id=$1 AND parent_id IS NOT DISTINCT FROM $2`, userID, parentID).Scan(&last); err != nil {
+		return "", fmt.Errorf("error reading positions: %v", err)
+	}
+	return rank.After(last.String)
+}
+
+// List returns the user's top-level todos matching the filter, in manual order except for
+// completed ones, which come most recent first
+func (r *TodoRepository) List(userID int, f TodoFilter) ([]models.Todo, error) {
+	conds := []string{"user_id=$1", "parent_id IS NULL"}
+	args := []interface{}{userID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	order := "position ASC"
+	switch f.View {
+	case "open":
+		conds = append(conds, "status IN ('open', 'in_progress')")
+	case "overdue":
+		conds = append(conds, "status IN ('open', 'in_progress')", "due_date < "+arg(f.Today.Format("2006-01-02")))
+	case "today":
+		conds = append(conds, "status IN ('open', 'in_progress')", "due_date = "+arg(f.Today.Format("2006-01-02")))
+	case "completed":
+		conds = append(conds, "status = 'done'")
+		if f.CompletedFrom != nil {
+			conds = append(conds, "completed_at >= "+arg(*f.CompletedFrom))
+		}
+		if f.CompletedTo != nil {
+			conds = append(conds, "completed_at < "+arg(*f.CompletedTo))
+		}
+		order = "completed_at DESC, id DESC"
+	}
+	if f.Priority != "" {
+		conds = append(conds, "priority = "+arg(f.Priority))
+	}
+	if f.ProjectID != 0 {
+		conds = append(conds, "project_id = "+arg(f.ProjectID))
+	}
+	query := `SELECT ` + todoColumns + ` FROM todos WHERE ` + strings.Join(conds, " AND ") + ` ORDER BY ` + order
+	return r.query("error listing todos", query, args...)
+}
+
+// ListSubtasks returns the subtasks of the given todos grouped by parent, in manual order
+func (r *TodoRepository) ListSubtasks(userID int, parentIDs []int) ([]models.Todo, error) {
+	if len(parentIDs) == 0 {
+		return []models.Todo{}, nil
+	}
+	query := `SELECT ` + todoColumns + ` FROM todos WHERE user_id=$1 AND parent_id = ANY($2) ORDER BY parent_id, position ASC`
+	return r.query("error listing subtasks", query, userID, pq.Array(parentIDs))
+}
+
+func (r *TodoRepository) query(errMsg, query string, args ...interface{}) ([]models.Todo, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", errMsg, err)
+	}
+	defer rows.Close()
+
+	todos := []models.Todo{}
+	for rows.Next() {
+		var t models.Todo
+		if err := rows.Scan(todoFields(&t)...); err != nil {
+			return nil, fmt.Errorf("error scanning todo: %v", err)
+		}
+		todos = append(todos, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", errMsg, err)
+	}
+	return todos, nil
+}
+
+func (r *TodoRepository) GetByID(userID, id int) (*models.Todo, error) {
+	var t models.Todo
+	if err := r.db.QueryRow(`SELECT `+todoColumns+` FROM todos WHERE id=$1 AND user_id=$2`, id, userID).Scan(todoFields(&t)...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("todo not found")
+		}
+		return nil, fmt.Errorf("error getting todo: %v", err)
+	}
+	return &t, nil
+}
+
+// GetByUID returns one of the user's todos by its iCalendar UID
+func (r *TodoRepository) GetByUID(userID int, uid string) (*models.Todo, error) {
+	var t models.Todo
+	if err := r.db.QueryRow(`SELECT `+todoColumns+` FROM todos WHERE user_id=$1 AND uid=$2`, userID, uid).Scan(todoFields(&t)...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("todo not found")
+		}
+		return nil, fmt.Errorf("error getting todo: %v", err)
+	}
+	return &t, nil
+}
+
+// ListWithDueDate returns all the user's todos and subtasks that have a due date
+func (r *TodoRepository) ListWithDueDate(userID int) ([]models.Todo, error) {
+	query := `SELECT ` + todoColumns + ` FROM todos WHERE user_id=$1 AND due_date IS NOT NULL ORDER BY due_date ASC, id ASC`
+	return r.query("error listing todos", query, userID)
+}
+
+// ListAll returns all the user's todos and subtasks
+func (r *TodoRepository) ListAll(userID int) ([]models.Todo, error) {
+	query := `SELECT ` + todoColumns + ` FROM todos WHERE user_id=$1 ORDER BY COALESCE(parent_id, id), parent_id NULLS FIRST, position`
+	return r.query("error listing todos", query, userID)
+}
+
+// GetByDavName returns one of the user's todos by its CalDAV resource name
+func (r *TodoRepository) GetByDavName(userID int, name string) (*models.Todo, error) {
+	var t models.Todo
+	query := `SELECT ` + todoColumns + ` FROM todos WHERE user_id=$1 AND COALESCE(dav_name, uid || '.ics') = $2`
+	if err := r.db.QueryRow(query, userID, name).Scan(todoFields(&t)...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("todo not found")
+		}
+		return nil, fmt.Errorf("error getting todo: %v", err)
+	}
+	return &t, nil
+}
+
+// Stamp summarizes the user's todos; it changes whenever one is added, changed or removed
+func (r *TodoRepository) Stamp(userID int) (int, *time.Time, error) {
+	var count int
+	var last *time.Time
+	if err := r.db.QueryRow(`SELECT COUNT(*), MAX(updated_at) FROM todos WHERE user_id=$1`, userID).Scan(&count, &last); err != nil {
+		return 0, nil, fmt.Errorf("error reading todos: %v", err)
+	}
+	return count, last, nil
+}
+
+// Create inserts a todo at the end of its siblings
+func (r *TodoRepository) Create(t *models.Todo) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error creating todo: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockTodos(tx, t.UserID); err != nil {
+		return err
+	}
+	if t.ParentID != nil {
+		var grandparent sql.NullInt64
+		if err := tx.QueryRow(`SELECT parent_id FROM todos WHERE id=$1 AND user_id=$2`, *t.ParentID, t.UserID).Scan(&grandparent); err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("parent todo not found")
+			}
+			return fmt.Errorf("error creating todo: %v", err)
+		}
+		if grandparent.Valid {
+			return fmt.Errorf("subtasks cannot have subtasks")
+		}
+	}
+	position, err := appendTodoPosition(tx, t.UserID, t.ParentID)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO todos (user_id, uid, dav_name, parent_id, project_id, title, description, due_date, priority, status, completed_at, position)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, CASE WHEN $11 THEN NOW() END, $12)
+		RETURNING ` + todoColumns
+	if err := tx.QueryRow(query, t.UserID, t.UID, t.DavName, t.ParentID, t.ProjectID, t.Title, t.Description, dateParam(t.DueDate), t.Priority, t.Status, t.Status == "done", position).Scan(todoFields(t)...); err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("a todo with that uid already exists")
+		}
+		return fmt.Errorf("error creating todo: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error creating todo: %v", err)
+	}
+	return nil
+}
+
+// Update writes the editable fields of a todo. completed_at is set the first time it becomes
+// done and cleared when it is reopened; changing project takes the todo off its old board.
+func (r *TodoRepository) Update(t *models.Todo) error {
+	query := `
+		UPDATE todos SET title=$1, description=$2, due_date=$3, priority=$4, status=$5,
+		       completed_at=CASE WHEN $6 THEN COALESCE(completed_at, NOW()) END, project_id=$7,
+		       board_column_id=CASE WHEN project_id IS DISTINCT FROM $7 THEN NULL ELSE board_column_id END, updated_at=NOW()
+		WHERE id=$8 AND user_id=$9
+		RETURNING ` + todoColumns
+	if err := r.db.QueryRow(query, t.Title, t.Description, dateParam(t.DueDate), t.Priority, t.Status, t.Status == "done", t.ProjectID, t.ID, t.UserID).Scan(todoFields(t)...); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("todo not found")
+		}
+		return fmt.Errorf("error updating todo: %v", err)
+	}
+	return nil
+}
+
+// Delete removes a todo together with its subtasks
+func (r *TodoRepository) Delete(userID, id int) error {
+	res, err := r.db.Exec(`DELETE FROM todos WHERE id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting todo: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error deleting todo: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("todo not found")
+	}
+	return nil
+}
+
+// Move places a todo right before or after a sibling
+func (r *TodoRepository) Move(userID, id, targetID int, after bool) (*models.Todo, error) {
+	if id == targetID {
+		return nil, fmt.Errorf("cannot move a todo relative to itself")
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error moving todo: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockTodos(tx, userID); err != nil {
+		return nil, err
+	}
+	var parentID, targetParentID sql.NullInt64
+	if err := tx.QueryRow(`SELECT parent_id FROM todos WHERE id=$1 AND user_id=$2`, id, userID).Scan(&parentID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("todo not found")
+		}
+		return nil, fmt.Errorf("error moving todo: %v", err)
+	}
+	var targetPos string
+	if err := tx.QueryRow(`SELECT parent_id, position FROM todos WHERE id=$1 AND user_id=$2`, targetID, userID).Scan(&targetParentID, &targetPos); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("target todo not found")
+		}
+		return nil, fmt.Errorf("error moving todo: %v", err)
+	}
+	if parentID != targetParentID {
+		return nil, fmt.Errorf("todos can only be moved among their siblings")
+	}
+
+	var neighbour sql.NullString
+	var lo, hi string
+	if after {
+		err = tx.QueryRow(`SELECT MIN(position) FROM todos WHERE user_id=$1 AND parent_id IS NOT DISTINCT FROM $2 AND position > $3 AND id <> $4`, userID, parentID, targetPos, id).Scan(&neighbour)
+		lo, hi = targetPos, neighbour.String
+	} else {
+		err = tx.QueryRow(`SELECT MAX(position) FROM todos WHERE user_id=$1 AND parent_id IS NOT DISTINCT FROM $2 AND position < $3 AND id <> $4`, userID, parentID, targetPos, id).Scan(&neighbour)
+		lo, hi = neighbour.String, targetPos
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading positions: %v", err)
+	}
+	position, err := rank.Between(lo, hi)
+	if err != nil {
+		return nil, fmt.Errorf("error computing position: %v", err)
+	}
+
+	var t models.Todo
+	if err := tx.QueryRow(`UPDATE todos SET position=$1, updated_at=NOW() WHERE id=$2 AND user_id=$3 RETURNING `+todoColumns, position, id, userID).Scan(todoFields(&t)...); err != nil {
+		return nil, fmt.Errorf("error moving todo: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error moving todo: %v", err)
+	}
+	return &t, nil
+}
+
+// dateParam passes an optional date as YYYY-MM-DD, or NULL
+func dateParam(d *time.Time) interface{} {
+	if d == nil {
+		return nil
+	}
+	return d.Format("2006-01-02")
+}
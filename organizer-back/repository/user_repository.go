@@ -20,7 +20,7 @@ func NewUserRepository() *UserRepository {
 // GetUserByUsername retrieves a user by username
 func (r *UserRepository) GetUserByUsername(username string) (*models.User, error) {
 	query := `
-		SELECT u.id, u.first_name, u.last_name, u.email, u.username, u.password_hash, r.name AS role, u.created_at, u.updated_at 
+		SELECT u.id, u.first_name, u.last_name, u.email, u.username, u.password_hash, r.name AS role, u.timezone, u.created_at, u.updated_at 
 		FROM users u
 		JOIN roles r ON r.id = u.role_id
 		WHERE u.username = $1
@@ -35,6 +35,7 @@ func (r *UserRepository) GetUserByUsername(username string) (*models.User, error
 		&user.Username,
 		&user.PasswordHash,
 		&user.Role,
+		&user.Timezone,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -52,7 +53,7 @@ func (r *UserRepository) GetUserByUsername(username string) (*models.User, error
 // GetUserByEmail retrieves a user by email
 func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 	query := `
-		SELECT u.id, u.first_name, u.last_name, u.email, u.username, u.password_hash, r.name AS role, u.created_at, u.updated_at 
+		SELECT u.id, u.first_name, u.last_name, u.email, u.username, u.password_hash, r.name AS role, u.timezone, u.created_at, u.updated_at 
 		FROM users u
 		JOIN roles r ON r.id = u.role_id
 		WHERE u.email = $1
@@ -67,6 +68,7 @@ func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 		&user.Username,
 		&user.PasswordHash,
 		&user.Role,
+		&user.Timezone,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -84,9 +86,9 @@ func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 // CreateUser creates a new user
 func (r *UserRepository) CreateUser(user *models.User) error {
 	query := `
-		INSERT INTO users (first_name, last_name, email, username, password_hash, role_id)
-		VALUES ($1, $2, $3, $4, $5, (SELECT id FROM roles WHERE name = $6))
-		RETURNING id, created_at, updated_at
+		INSERT INTO users (first_name, last_name, email, username, password_hash, role_id, timezone)
+		VALUES ($1, $2, $3, $4, $5, (SELECT id FROM roles WHERE name = $6), COALESCE(NULLIF($7, ''), 'UTC'))
+		RETURNING id, timezone, created_at, updated_at
 	`
 
 	err := r.db.QueryRow(query,
@@ -96,7 +98,8 @@ func (r *UserRepository) CreateUser(user *models.User) error {
 		user.Username,
 		user.PasswordHash,
 		user.Role,
-	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+		user.Timezone,
+	).Scan(&user.ID, &user.Timezone, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		return fmt.Errorf("error creating user: %v", err)
@@ -125,7 +128,7 @@ func (r *UserRepository) UserExists(username, email string) (bool, error) {
 // GetUserByID retrieves a user by id
 func (r *UserRepository) GetUserByID(id int) (*models.User, error) {
 	query := `
-        SELECT u.id, u.first_name, u.last_name, u.email, u.username, u.password_hash, r.name AS role, u.created_at, u.updated_at 
+        SELECT u.id, u.first_name, u.last_name, u.email, u.username, u.password_hash, r.name AS role, u.timezone, u.created_at, u.updated_at 
         FROM users u
         JOIN roles r ON r.id = u.role_id
         WHERE u.id = $1
@@ -140,6 +143,7 @@ func (r *UserRepository) GetUserByID(id int) (*models.User, error) {
 		&user.Username,
 		&user.PasswordHash,
 		&user.Role,
+		&user.Timezone,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -155,7 +159,7 @@ func (r *UserRepository) GetUserByID(id int) (*models.User, error) {
 // ListUsers returns all users
 func (r *UserRepository) ListUsers() ([]models.User, error) {
 	query := `
-        SELECT u.id, u.first_name, u.last_name, u.email, u.username, u.password_hash, r.name AS role, u.created_at, u.updated_at 
+        SELECT u.id, u.first_name, u.last_name, u.email, u.username, u.password_hash, r.name AS role, u.timezone, u.created_at, u.updated_at 
         FROM users u
         JOIN roles r ON r.id = u.role_id
         ORDER BY u.id ASC
@@ -170,7 +174,7 @@ func (r *UserRepository) ListUsers() ([]models.User, error) {
 	users := []models.User{}
 	for rows.Next() {
 		var u models.User
-		if err := rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email, &u.Username, &u.PasswordHash, &u.Role, &u.CreatedAt, &u.UpdatedAt); err != nil {
+		if err := rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email, &u.Username, &u.PasswordHash, &u.Role, &u.Timezone, &u.CreatedAt, &u.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("error scanning user: %v", err)
 		}
 		users = append(users, u)
@@ -185,12 +189,40 @@ func (r *UserRepository) ListUsers() ([]models.User, error) {
 func (r *UserRepository) UpdateUser(user *models.User) error {
 	query := `
         UPDATE users 
-        SET first_name=$1, last_name=$2, email=$3, username=$4, password_hash=$5, role_id=(SELECT id FROM roles WHERE name=$6), updated_at=NOW()
+        SET first_name=$1, last_name=$2, email=$3, username=$4, password_hash=$5, role_id=(SELECT id FROM roles WHERE name=$6), timezone=$8, updated_at=NOW()
         WHERE id=$7
         RETURNING updated_at
     `
 
-	return r.db.QueryRow(query, user.FirstName, user.LastName, user.Email, user.Username, user.PasswordHash, user.Role, user.ID).Scan(&user.UpdatedAt)
+	return r.db.QueryRow(query, user.FirstName, user.LastName, user.Email, user.Username, user.PasswordHash, user.Role, user.ID, user.Timezone).Scan(&user.UpdatedAt)
+}
+
+// GetTimezone returns the IANA timezone name of a user
+func (r *UserRepository) GetTimezone(id int) (string, error) {
+	var tz string
+	if err := r.db.QueryRow(`SELECT timezone FROM users WHERE id=$1`, id).Scan(&tz); err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("user not found")
+		}
+		return "", fmt.Errorf("error querying user: %v", err)
+	}
+	return tz, nil
+}
+
+// SetTimezone changes the IANA timezone name of a user
+func (r *UserRepository) SetTimezone(id int, tz string) error {
+	res, err := r.db.Exec(`UPDATE users SET timezone=$1, updated_at=NOW() WHERE id=$2`, tz, id)
+	if err != nil {
+		return fmt.Errorf("error updating user: %v", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error updating user: %v", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
 }
 
 // DeleteUser deletes a user by id
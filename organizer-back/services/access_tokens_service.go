@@ -0,0 +1,83 @@
+package services
+
+import (
+	"crypto/subtle"
+	"errors"
+	"organizer-back/models"
+	"organizer-back/repository"
+	"strings"
+	"time"
+)
+
+// Prefix of personal access tokens, so they are recognisable in configs and secret scanners
+const accessTokenPrefix = "org_"
+
+type AccessTokensService struct {
+	repo  *repository.AccessTokenRepository
+	users *repository.UserRepository
+}
+
+func NewAccessTokensService() *AccessTokensService {
+	return &AccessTokensService{
+		repo:  repository.NewAccessTokenRepository(),
+		users: repository.NewUserRepository(),
+	}
+}
+
+func (s *AccessTokensService) List(userID int) ([]models.PersonalAccessToken, error) {
+	return s.repo.List(userID)
+}
+
+// Create issues a token; the raw value is only returned here
+func (s *AccessTokensService) Create(userID int, req *models.PersonalAccessTokenRequest) (*models.PersonalAccessToken, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+	raw, err := newPublicToken()
+	if err != nil {
+		return nil, err
+	}
+	token := accessTokenPrefix + raw
+	t := &models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hashPublicToken(token),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.repo.Create(t); err != nil {
+		return nil, err
+	}
+	t.Token = token
+	return t, nil
+}
+
+func (s *AccessTokensService) Delete(userID, id int) error {
+	return s.repo.Delete(userID, id)
+}
+
+// Authenticate checks basic auth credentials made of a username and one of that user's
+// tokens and returns the user
+func (s *AccessTokensService) Authenticate(username, token string) (*models.User, error) {
+	if !strings.HasPrefix(token, accessTokenPrefix) {
+		return nil, errors.New("invalid credentials")
+	}
+	userID, err := s.repo.Resolve(hashPublicToken(token))
+	if err != nil {
+		if err.Error() == "access token not found" {
+			return nil, errors.New("invalid credentials")
+		}
+		return nil, err
+	}
+	u, err := s.users.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.ToLower(u.Username)), []byte(strings.ToLower(username))) != 1 {
+		return nil, errors.New("invalid credentials")
+	}
+	return u, nil
+}
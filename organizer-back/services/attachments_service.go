@@ -0,0 +1,224 @@
+package services
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"organizer-back/models"
+	"organizer-back/repository"
+	"organizer-back/storage"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultAttachmentMaxBytes   = 10 << 20  // 10 MiB per file
+	defaultAttachmentQuotaBytes = 200 << 20 // 200 MiB per user
+)
+
+type AttachmentsService struct {
+	repo         *repository.AttachmentRepository
+	notes        *repository.NoteRepository
+	store        storage.BlobStore
+	maxBytes     int64
+	defaultQuota int64
+}
+
+func NewAttachmentsService(store storage.BlobStore) *AttachmentsService {
+	return &AttachmentsService{
+		repo:         repository.NewAttachmentRepository(),
+		notes:        repository.NewNoteRepository(),
+		store:        store,
+		maxBytes:     envInt64("ATTACHMENT_MAX_BYTES", defaultAttachmentMaxBytes),
+		defaultQuota: envInt64("ATTACHMENT_QUOTA_BYTES", defaultAttachmentQuotaBytes),
+	}
+}
+
+// MaxBytes is the per-file size limit
+func (s *AttachmentsService) MaxBytes() int64 {
+	return s.maxBytes
+}
+
+// Upload stores the file in the blob store and records it against the note
+func (s *AttachmentsService) Upload(userID, noteID int, fileName string, size int64, r io.Reader) (*models.AttachmentResponse, error) {
+	note, err := s.writableNote(userID, noteID)
+	if err != nil {
+		return nil, err
+	}
+	if size > s.maxBytes {
+		return nil, errors.New("file too large")
+	}
+	fileName = filepath.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	if fileName == "." || fileName == "/" || fileName == "" {
+		fileName = "file"
+	}
+
+	// Sniff the real type instead of trusting the client-provided header
+	br := bufio.NewReaderSize(r, 512)
+	head, _ := br.Peek(512)
+	contentType := http.DetectContentType(head)
+	if contentType == "application/octet-stream" {
+		if byExt := mime.TypeByExtension(filepath.Ext(fileName)); byExt != "" {
+			contentType = byExt
+		}
+	}
+
+	// Files count against the note owner's quota, whoever uploads them
+	key, err := newStorageKey(note.UserID, noteID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.Put(key, io.LimitReader(br, s.maxBytes), size, contentType); err != nil {
+		return nil, err
+	}
+
+	a := &models.Attachment{
+		NoteID:      noteID,
+		UserID:      note.UserID,
+		FileName:    fileName,
+		ContentType: contentType,
+		SizeBytes:   size,
+		StorageKey:  key,
+	}
+	if err := s.repo.CreateWithinQuota(a, s.defaultQuota); err != nil {
+		s.deleteBlobs([]string{key})
+		return nil, err
+	}
+	res := a.ToResponse()
+	return &res, nil
+}
+
+func (s *AttachmentsService) List(userID, noteID int) ([]models.AttachmentResponse, error) {
+	if _, _, err := s.notes.GetAccessible(userID, noteID); err != nil {
+		return nil, err
+	}
+	attachments, err := s.repo.ListByNote(noteID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]models.AttachmentResponse, 0, len(attachments))
+	for i := range attachments {
+		res = append(res, attachments[i].ToResponse())
+	}
+	return res, nil
+}
+
+// Open returns the attachment metadata and its blob; the caller must close the blob
+func (s *AttachmentsService) Open(userID, noteID, id int) (*models.Attachment, storage.Blob, error) {
+	if _, _, err := s.notes.GetAccessible(userID, noteID); err != nil {
+		return nil, nil, err
+	}
+	a, err := s.repo.GetByID(noteID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	blob, err := s.store.Open(a.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil, errors.New("attachment not found")
+		}
+		return nil, nil, err
+	}
+	return a, blob, nil
+}
+
+func (s *AttachmentsService) Delete(userID, noteID, id int) error {
+	if _, err := s.writableNote(userID, noteID); err != nil {
+		return err
+	}
+	key, err := s.repo.Delete(noteID, id)
+	if err != nil {
+		return err
+	}
+	s.deleteBlobs([]string{key})
+	return nil
+}
+
+// StorageKeysForNote is used before deleting a note so its blobs can be purged afterwards
+func (s *AttachmentsService) StorageKeysForNote(noteID int) ([]string, error) {
+	return s.repo.StorageKeysByNote(noteID)
+}
+
+// PurgeBlobs removes blobs whose rows were already deleted (e.g. by ON DELETE CASCADE)
+func (s *AttachmentsService) PurgeBlobs(keys []string) {
+	s.deleteBlobs(keys)
+}
+
+func (s *AttachmentsService) Usage(userID int) (*models.AttachmentUsageResponse, error) {
+	used, quota, err := s.repo.Usage(userID)
+	if err != nil {
+		return nil, err
+	}
+	limit := s.defaultQuota
+	if quota.Valid {
+		limit = quota.Int64
+	}
+	return &models.AttachmentUsageResponse{UsedBytes: used, QuotaBytes: limit, MaxFile: s.maxBytes}, nil
+}
+
+func (s *AttachmentsService) SetQuota(userID int, req *models.AttachmentQuotaRequest) error {
+	if req.QuotaBytes != nil && *req.QuotaBytes < 0 {
+		return errors.New("quota must not be negative")
+	}
+	return s.repo.SetQuota(userID, req.QuotaBytes)
+}
+
+// writableNote returns the note if the user owns it or holds an editor share
+func (s *AttachmentsService) writableNote(userID, noteID int) (*models.Note, error) {
+	note, permission, err := s.notes.GetAccessible(userID, noteID)
+	if err != nil {
+		return nil, err
+	}
+	if permission == "viewer" {
+		return nil, errors.New("forbidden")
+	}
+	return note, nil
+}
+
+// deleteBlobs is best effort: a leftover blob is only wasted space, so failures are logged
+func (s *AttachmentsService) deleteBlobs(keys []string) {
+	for _, k := range keys {
+		if err := s.store.Delete(k); err != nil {
+			log.Printf("failed to delete blob %s: %v", k, err)
+		}
+	}
+}
+
+func newStorageKey(userID, noteID int) (string, error) {
+	suffix, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("notes/%d/%d/%s", userID, noteID, suffix), nil
+}
+
+// randomHex returns n random bytes hex encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating random key: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// envInt64 reads a positive integer setting from the environment
+func envInt64(key string, def int64) int64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		log.Printf("ignoring invalid %s=%q", key, v)
+		return def
+	}
+	return n
+}
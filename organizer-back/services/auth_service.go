@@ -71,6 +71,7 @@ func (s *AuthService) Register(userReq *models.UserCreateRequest) (*models.UserR
 		Username:     userReq.Username,
 		PasswordHash: hashedPassword,
 		Role:         defaultRole(userReq.Role),
+		Timezone:     userReq.Timezone,
 	}
 
 	err = s.userRepo.CreateUser(user)
@@ -108,6 +109,22 @@ func (s *AuthService) generateToken(userID int, username string, role string) (s
 	return token.SignedString([]byte(s.jwtSecret))
 }
 
+// VerifyToken checks the signature and expiry of a token issued by Login and returns its user id
+func (s *AuthService) VerifyToken(tokenString string) (int, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(s.jwtSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return 0, errors.New("invalid token")
+	}
+	userID, ok := claims["user_id"].(float64)
+	if !ok || userID <= 0 {
+		return 0, errors.New("invalid token")
+	}
+	return int(userID), nil
+}
+
 func defaultRole(in string) string {
 	if in == "admin" {
 		return "admin"
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"errors"
+	"organizer-back/models"
+	"organizer-back/repository"
+	"strings"
+	"time"
+)
+
+// defaultBoardColumns is the board a project starts with; columns can be renamed, reordered,
+// remapped or removed
+var defaultBoardColumns = []repository.BoardColumnSeed{
+	{Name: "To do", Status: "open"},
+	{Name: "In progress", Status: "in_progress"},
+	{Name: "Done", Status: "done"},
+}
+
+type BoardsService struct {
+	repo     *repository.BoardRepository
+	todos    *repository.TodoRepository
+	projects *ProjectsService
+}
+
+func NewBoardsService() *BoardsService {
+	return &BoardsService{
+		repo:     repository.NewBoardRepository(),
+		todos:    repository.NewTodoRepository(),
+		projects: NewProjectsService(),
+	}
+}
+
+// Board returns the project with its columns and their cards, creating the default columns
+// on first use. Cards are the project's top-level todos with their subtasks.
+func (s *BoardsService) Board(userID, projectID int, now time.Time) (*models.BoardSnapshot, error) {
+	project, err := s.projects.Get(userID, projectID, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SeedColumns(projectID, defaultBoardColumns); err != nil {
+		return nil, err
+	}
+	lanes, err := s.repo.Board(userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	var todos []models.Todo
+	for _, l := range lanes {
+		todos = append(todos, l.Todos...)
+	}
+	cards, err := withSubtasks(s.todos, userID, todos)
+	if err != nil {
+		return nil, err
+	}
+
+	board := &models.BoardSnapshot{Project: *project, Columns: make([]models.BoardColumnSnapshot, len(lanes))}
+	for i, l := range lanes {
+		n := len(l.Todos)
+		board.Columns[i] = models.BoardColumnSnapshot{
+			BoardColumn: l.Column,
+			Count:       n,
+			OverLimit:   l.Column.WIPLimit != nil && n > *l.Column.WIPLimit,
+			Cards:       cards[:n:n],
+		}
+		cards = cards[n:]
+	}
+	return board, nil
+}
+
+func (s *BoardsService) CreateColumn(userID, projectID int, req *models.BoardColumnRequest) (*models.BoardColumn, error) {
+	if err := s.checkEditable(userID, projectID); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	// Seed first so a custom column does not suppress the default ones
+	if err := s.repo.SeedColumns(projectID, defaultBoardColumns); err != nil {
+		return nil, err
+	}
+	c := &models.BoardColumn{ProjectID: projectID, Name: name, WIPLimit: req.WIPLimit, Status: req.Status}
+	if err := s.repo.CreateColumn(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// UpdateColumn replaces the name, WIP limit and status mapping of a column. A lower limit
+// does not move cards out; the board reports the column as over its limit.
+func (s *BoardsService) UpdateColumn(userID, projectID, id int, req *models.BoardColumnRequest) (*models.BoardColumn, error) {
+	if err := s.checkEditable(userID, projectID); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	c := &models.BoardColumn{ID: id, ProjectID: projectID, Name: name, WIPLimit: req.WIPLimit, Status: req.Status}
+	if err := s.repo.UpdateColumn(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (s *BoardsService) DeleteColumn(userID, projectID, id int) error {
+	if err := s.checkEditable(userID, projectID); err != nil {
+		return err
+	}
+	return s.repo.DeleteColumn(projectID, id)
+}
+
+// MoveColumn reorders a column relative to another one
+func (s *BoardsService) MoveColumn(userID, projectID, id int, req *models.BoardColumnMoveRequest) (*models.BoardColumn, error) {
+	if err := s.checkEditable(userID, projectID); err != nil {
+		return nil, err
+	}
+	switch {
+	case req.Before != nil && req.After != nil:
+		return nil, errors.New("only one of before or after may be set")
+	case req.Before != nil:
+		return s.repo.MoveColumn(projectID, id, *req.Before, false)
+	case req.After != nil:
+		return s.repo.MoveColumn(projectID, id, *req.After, true)
+	}
+	return nil, errors.New("before or after is required")
+}
+
+// MoveCard moves a todo of the project to a column, before or after one of its cards or
+// last; the todo takes the status the column maps to
+func (s *BoardsService) MoveCard(userID, projectID, todoID int, req *models.BoardCardMoveRequest) (*models.TodoResponse, error) {
+	if err := s.checkEditable(userID, projectID); err != nil {
+		return nil, err
+	}
+	before, after := 0, 0
+	switch {
+	case req.Before != nil && req.After != nil:
+		return nil, errors.New("only one of before or after may be set")
+	case req.Before != nil:
+		before = *req.Before
+	case req.After != nil:
+		after = *req.After
+	}
+	t, err := s.repo.MoveCard(userID, projectID, todoID, req.ColumnID, before, after)
+	if err != nil {
+		return nil, err
+	}
+	res, err := withSubtasks(s.todos, userID, []models.Todo{*t})
+	if err != nil {
+		return nil, err
+	}
+	return &res[0], nil
+}
+
+// checkEditable verifies that the board belongs to an active project of the user
+func (s *BoardsService) checkEditable(userID, projectID int) error {
+	p, err := s.projects.repo.GetByID(userID, projectID)
+	if err != nil {
+		return err
+	}
+	if p.ArchivedAt != nil {
+		return errors.New("project is archived")
+	}
+	return nil
+}
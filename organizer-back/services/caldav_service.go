@@ -0,0 +1,439 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"organizer-back/ical"
+	"organizer-back/models"
+	"organizer-back/repository"
+	"strings"
+	"time"
+)
+
+// CalDAV collections of every user: their own events and their todos
+const (
+	CalDAVEvents = "events"
+	CalDAVTodos  = "todos"
+)
+
+const maxCalDAVNameLength = 255
+
+// CalDAVObject is a calendar object resource: one event or todo as an iCalendar document
+type CalDAVObject struct {
+	Name         string
+	ETag         string
+	LastModified time.Time
+	Data         []byte
+
+	event *models.Event
+	todo  *models.Todo
+}
+
+// CalDAVTimeRange is the time-range filter of a calendar-query; a zero bound is open
+type CalDAVTimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// CalDAVEventStore is the storage of a user's own events that CalDAV reads and writes;
+// *repository.EventRepository implements it
+type CalDAVEventStore interface {
+	Stamp(userID int) (int, *time.Time, error)
+	ListOwned(userID int) ([]models.Event, error)
+	GetByUID(userID int, uid string) (*models.Event, error)
+	GetByDavName(userID int, name string) (*models.Event, error)
+	Create(e *models.Event, attendeeIDs []int) error
+	Update(e *models.Event, attendeeIDs []int) error
+	Delete(userID, id int) error
+}
+
+// CalDAVTodoStore is the storage of a user's todos that CalDAV reads and writes;
+// *repository.TodoRepository implements it
+type CalDAVTodoStore interface {
+	Stamp(userID int) (int, *time.Time, error)
+	ListAll(userID int) ([]models.Todo, error)
+	GetByID(userID, id int) (*models.Todo, error)
+	GetByUID(userID int, uid string) (*models.Todo, error)
+	GetByDavName(userID int, name string) (*models.Todo, error)
+	Create(t *models.Todo) error
+	Update(t *models.Todo) error
+	Delete(userID, id int) error
+}
+
+type CalDAVService struct {
+	events CalDAVEventStore
+	todos  CalDAVTodoStore
+	users  TimezoneStore
+}
+
+func NewCalDAVService() *CalDAVService {
+	return NewCalDAVServiceWithStores(repository.NewEventRepository(), repository.NewTodoRepository(), repository.NewUserRepository())
+}
+
+// NewCalDAVServiceWithStores builds the service on the given storage, e.g. in-memory stores
+// in tests
+func NewCalDAVServiceWithStores(events CalDAVEventStore, todos CalDAVTodoStore, users TimezoneStore) *CalDAVService {
+	return &CalDAVService{events: events, todos: todos, users: users}
+}
+
+// CTag changes whenever an object of the collection is added, changed or removed
+func (s *CalDAVService) CTag(userID int, collection string) (string, error) {
+	var count int
+	var last *time.Time
+	var err error
+	switch collection {
+	case CalDAVEvents:
+		count, last, err = s.events.Stamp(userID)
+	case CalDAVTodos:
+		count, last, err = s.todos.Stamp(userID)
+	default:
+		return "", errors.New("calendar not found")
+	}
+	if err != nil {
+		return "", err
+	}
+	stamp := int64(0)
+	if last != nil {
+		stamp = last.UnixMicro()
+	}
+	return fmt.Sprintf("%d-%d", count, stamp), nil
+}
+
+// List returns the objects of a collection; with a time range, only events with an
+// occurrence in it and todos due in it
+func (s *CalDAVService) List(userID int, collection string, tr *CalDAVTimeRange) ([]CalDAVObject, error) {
+	switch collection {
+	case CalDAVEvents:
+		events, err := s.events.ListOwned(userID)
+		if err != nil {
+			return nil, err
+		}
+		out := []CalDAVObject{}
+		for i := range events {
+			e := &events[i]
+			if tr != nil && !eventInRange(e, tr) {
+				continue
+			}
+			out = append(out, eventObject(e))
+		}
+		return out, nil
+	case CalDAVTodos:
+		todos, err := s.todos.ListAll(userID)
+		if err != nil {
+			return nil, err
+		}
+		uids := map[int]string{}
+		for _, t := range todos {
+			uids[t.ID] = t.UID
+		}
+		out := []CalDAVObject{}
+		for i := range todos {
+			t := &todos[i]
+			if tr != nil && !todoInRange(t, tr) {
+				continue
+			}
+			parentUID := ""
+			if t.ParentID != nil {
+				parentUID = uids[*t.ParentID]
+			}
+			out = append(out, todoObject(t, parentUID))
+		}
+		return out, nil
+	}
+	return nil, errors.New("calendar not found")
+}
+
+// Get returns one object of a collection by its resource name
+func (s *CalDAVService) Get(userID int, collection, name string) (*CalDAVObject, error) {
+	switch collection {
+	case CalDAVEvents:
+		e, err := s.events.GetByDavName(userID, name)
+		if err != nil {
+			return nil, err
+		}
+		obj := eventObject(e)
+		return &obj, nil
+	case CalDAVTodos:
+		t, err := s.todos.GetByDavName(userID, name)
+		if err != nil {
+			return nil, err
+		}
+		parentUID := ""
+		if t.ParentID != nil {
+			if parent, err := s.todos.GetByID(userID, *t.ParentID); err == nil {
+				parentUID = parent.UID
+			}
+		}
+		obj := todoObject(t, parentUID)
+		return &obj, nil
+	}
+	return nil, errors.New("calendar not found")
+}
+
+// Put creates or replaces an object from an iCalendar document. ifMatch and ifNoneMatch are
+// the request's preconditions; created tells whether a new object was made.
+func (s *CalDAVService) Put(userID int, collection, name string, body io.Reader, ifMatch, ifNoneMatch string) (*CalDAVObject, bool, error) {
+	if !validCalDAVName(name) {
+		return nil, false, errors.New("invalid resource name")
+	}
+	current, err := s.Get(userID, collection, name)
+	if err != nil && !strings.HasSuffix(err.Error(), "not found") {
+		return nil, false, err
+	}
+	if err := checkCalDAVPreconditions(current, ifMatch, ifNoneMatch); err != nil {
+		return nil, false, err
+	}
+	top, err := ical.Decode(body)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(top) != 1 || top[0].Name != "VCALENDAR" {
+		return nil, false, errors.New("expected one VCALENDAR")
+	}
+	loc := userLocation(s.users, userID)
+
+	switch collection {
+	case CalDAVEvents:
+		c, err := masterComponent(top[0], "VEVENT")
+		if err != nil {
+			return nil, false, err
+		}
+		e, err := s.putEvent(userID, name, current, c, loc)
+		if err != nil {
+			return nil, false, err
+		}
+		obj := eventObject(e)
+		return &obj, current == nil, nil
+	case CalDAVTodos:
+		c, err := masterComponent(top[0], "VTODO")
+		if err != nil {
+			return nil, false, err
+		}
+		t, err := s.putTodo(userID, name, current, c, loc)
+		if err != nil {
+			return nil, false, err
+		}
+		obj, err := s.Get(userID, collection, calDAVName(t.UID, t.DavName))
+		if err != nil {
+			return nil, false, err
+		}
+		return obj, current == nil, nil
+	}
+	return nil, false, errors.New("calendar not found")
+}
+
+func (s *CalDAVService) putEvent(userID int, name string, current *CalDAVObject, c *ical.Component, loc *time.Location) (*models.Event, error) {
+	uid, err := importUID(c)
+	if err != nil {
+		return nil, err
+	}
+	req, err := eventRequestFromICal(c, loc.String(), loc)
+	if err != nil {
+		return nil, err
+	}
+	e := &models.Event{UserID: userID, UID: uid}
+	if err := applyEventRequest(e, req, loc.String()); err != nil {
+		return nil, err
+	}
+	if current != nil {
+		if current.event.UID != uid {
+			return nil, errors.New("the UID of a calendar object cannot change")
+		}
+		e.ID = current.event.ID
+		attendees := make([]int, len(current.event.Attendees))
+		for i, a := range current.event.Attendees {
+			attendees[i] = a.UserID
+		}
+		if err := s.events.Update(e, attendees); err != nil {
+			return nil, err
+		}
+		e.DavName = current.event.DavName
+		return e, nil
+	}
+	if _, err := s.events.GetByUID(userID, uid); err == nil {
+		return nil, errors.New("a calendar object with that UID already exists")
+	}
+	if name != uid+".ics" {
+		e.DavName = &name
+	}
+	if err := s.events.Create(e, nil); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (s *CalDAVService) putTodo(userID int, name string, current *CalDAVObject, c *ical.Component, loc *time.Location) (*models.Todo, error) {
+	uid, err := importUID(c)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil {
+		t := current.todo
+		if t.UID != uid {
+			return nil, errors.New("the UID of a calendar object cannot change")
+		}
+		if err := applyICalTodo(t, c, loc); err != nil {
+			return nil, err
+		}
+		if err := s.todos.Update(t); err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+	if _, err := s.todos.GetByUID(userID, uid); err == nil {
+		return nil, errors.New("a calendar object with that UID already exists")
+	}
+	t := &models.Todo{UserID: userID, UID: uid}
+	if name != uid+".ics" {
+		t.DavName = &name
+	}
+	if err := applyICalTodo(t, c, loc); err != nil {
+		return nil, err
+	}
+	if parentUID := icalParentUID(c); parentUID != "" {
+		if parent, err := s.todos.GetByUID(userID, parentUID); err == nil && parent.ParentID == nil {
+			t.ParentID = &parent.ID
+		}
+	}
+	if err := s.todos.Create(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Delete removes an object, honouring If-Match
+func (s *CalDAVService) Delete(userID int, collection, name, ifMatch string) error {
+	current, err := s.Get(userID, collection, name)
+	if err != nil {
+		return err
+	}
+	if err := checkCalDAVPreconditions(current, ifMatch, ""); err != nil {
+		return err
+	}
+	if current.event != nil {
+		return s.events.Delete(userID, current.event.ID)
+	}
+	return s.todos.Delete(userID, current.todo.ID)
+}
+
+// checkCalDAVPreconditions evaluates If-Match and If-None-Match against the current object,
+// nil when the resource does not exist
+func checkCalDAVPreconditions(current *CalDAVObject, ifMatch, ifNoneMatch string) error {
+	if ifMatch != "" {
+		if current == nil || ifMatch != "*" && !etagListed(ifMatch, current.ETag) {
+			return errors.New("precondition failed")
+		}
+	}
+	if ifNoneMatch != "" && current != nil {
+		if ifNoneMatch == "*" || etagListed(ifNoneMatch, current.ETag) {
+			return errors.New("precondition failed")
+		}
+	}
+	return nil
+}
+
+func etagListed(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// masterComponent returns the only component of the given kind that is not a change to a
+// single occurrence; those changes are ignored
+func masterComponent(cal *ical.Component, name string) (*ical.Component, error) {
+	var master *ical.Component
+	for _, c := range cal.Components {
+		switch {
+		case c.Name == "VTIMEZONE":
+		case c.Name != name:
+			return nil, fmt.Errorf("only %s components belong in this calendar", name)
+		case c.Get("RECURRENCE-ID") != nil:
+		case master != nil:
+			return nil, fmt.Errorf("a calendar object holds a single %s", name)
+		default:
+			master = c
+		}
+	}
+	if master == nil {
+		return nil, fmt.Errorf("missing %s", name)
+	}
+	return master, nil
+}
+
+func eventObject(e *models.Event) CalDAVObject {
+	cal := newCalendar()
+	cal.Append(eventComponent(e))
+	return calDAVObject(calDAVName(e.UID, e.DavName), e.ID, e.UpdatedAt, cal, e, nil)
+}
+
+func todoObject(t *models.Todo, parentUID string) CalDAVObject {
+	cal := newCalendar()
+	c := todoComponent(t)
+	if parentUID != "" {
+		c.AddText("RELATED-TO", parentUID)
+	}
+	cal.Append(c)
+	return calDAVObject(calDAVName(t.UID, t.DavName), t.ID, t.UpdatedAt, cal, nil, t)
+}
+
+func calDAVObject(name string, id int, updatedAt time.Time, cal *ical.Component, e *models.Event, t *models.Todo) CalDAVObject {
+	ical.AddTimezones(cal)
+	var buf bytes.Buffer
+	ical.Encode(&buf, cal)
+	return CalDAVObject{
+		Name:         name,
+		ETag:         fmt.Sprintf(`"%d-%d"`, id, updatedAt.UnixMicro()),
+		LastModified: updatedAt,
+		Data:         buf.Bytes(),
+		event:        e,
+		todo:         t,
+	}
+}
+
+// calDAVName is the resource name of an object: the one its client chose, or UID.ics
+func calDAVName(uid string, davName *string) string {
+	if davName != nil {
+		return *davName
+	}
+	return uid + ".ics"
+}
+
+func validCalDAVName(name string) bool {
+	return strings.HasSuffix(name, ".ics") && len(name) > len(".ics") && len(name) <= maxCalDAVNameLength &&
+		!strings.ContainsAny(name, "/\\")
+}
+
+func eventInRange(e *models.Event, tr *CalDAVTimeRange) bool {
+	from, to := tr.Start, tr.End
+	if from.IsZero() {
+		from = e.StartsAt
+	}
+	if to.IsZero() {
+		if e.RRule != nil && e.RecurrenceEnd == nil {
+			return true
+		}
+		to = e.EndsAt.Add(time.Second)
+		if e.RecurrenceEnd != nil {
+			to = e.RecurrenceEnd.Add(time.Second)
+		}
+	}
+	if !to.After(from) {
+		return false
+	}
+	return len(expandEvent(e, from, to)) > 0
+}
+
+// todoInRange keeps todos due in the range and, as RFC 4791 asks, those without a due date
+func todoInRange(t *models.Todo, tr *CalDAVTimeRange) bool {
+	if t.DueDate == nil {
+		return true
+	}
+	due := *t.DueDate
+	end := due.AddDate(0, 0, 1)
+	return (tr.Start.IsZero() || end.After(tr.Start)) && (tr.End.IsZero() || due.Before(tr.End))
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"errors"
+	"organizer-back/models"
+	"organizer-back/repository"
+	"strings"
+	"time"
+)
+
+// defaultEmotionLabels is the catalog a user starts with; it can be renamed, extended or archived
+var defaultEmotionLabels = []string{"happy", "calm", "grateful", "excited", "tired", "anxious", "sad", "angry", "stressed"}
+
+const (
+	moodDailyTrendDays     = 30
+	moodWeeklyTrendWeeks   = 12
+	moodCorrelationDays    = 90
+	moodCorrelationMinDays = 2
+	moodCorrelationLimit   = 20
+)
+
+type EmotionsService struct {
+	repo  *repository.EmotionRepository
+	notes *repository.NoteRepository
+}
+
+func NewEmotionsService() *EmotionsService {
+	return &EmotionsService{
+		repo:  repository.NewEmotionRepository(),
+		notes: repository.NewNoteRepository(),
+	}
+}
+
+// Labels lists the user's emotion catalog, creating the default one on first use
+func (s *EmotionsService) Labels(userID int, includeArchived bool) ([]models.EmotionLabel, error) {
+	if err := s.repo.SeedLabels(userID, defaultEmotionLabels); err != nil {
+		return nil, err
+	}
+	return s.repo.ListLabels(userID, includeArchived)
+}
+
+func (s *EmotionsService) CreateLabel(userID int, req *models.EmotionLabelRequest) (*models.EmotionLabel, error) {
+	name := strings.ToLower(strings.TrimSpace(req.Name))
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	// Seed first so a custom label does not suppress the default catalog
+	if err := s.repo.SeedLabels(userID, defaultEmotionLabels); err != nil {
+		return nil, err
+	}
+	l := &models.EmotionLabel{UserID: userID, Name: name, Color: req.Color}
+	if err := s.repo.CreateLabel(l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (s *EmotionsService) UpdateLabel(userID, id int, req *models.EmotionLabelRequest) (*models.EmotionLabel, error) {
+	name := strings.ToLower(strings.TrimSpace(req.Name))
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	l := &models.EmotionLabel{ID: id, UserID: userID, Name: name, Color: req.Color}
+	if err := s.repo.UpdateLabel(l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// DeleteLabel removes a label, or archives it when past entries use it. It reports whether
+// the label was archived.
+func (s *EmotionsService) DeleteLabel(userID, id int) (bool, error) {
+	return s.repo.DeleteLabel(userID, id)
+}
+
+// List returns the entries of a date range, the last 30 days up to today by default. now is
+// the current time in the caller's zone.
+func (s *EmotionsService) List(userID int, q *models.EmotionRangeQuery, now time.Time) ([]models.EmotionEntryResponse, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from, to, err := dateRange(q.From, q.To, today, today.AddDate(0, 0, -(moodDailyTrendDays-1)))
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.repo.ListEntries(userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]models.EmotionEntryResponse, len(entries))
+	for i := range entries {
+		res[i] = entries[i].ToResponse()
+	}
+	return res, nil
+}
+
+func (s *EmotionsService) Get(userID, id int) (*models.EmotionEntryResponse, error) {
+	e, err := s.repo.GetEntry(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	res := e.ToResponse()
+	return &res, nil
+}
+
+// Create records an entry; now is the current time in the caller's zone, which also dates
+// the entry
+func (s *EmotionsService) Create(userID int, req *models.EmotionEntryRequest, now time.Time) (*models.EmotionEntryResponse, error) {
+	e := &models.EmotionEntry{UserID: userID}
+	if err := s.apply(e, req, now); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateEntry(e, req.LabelIDs); err != nil {
+		return nil, err
+	}
+	return s.Get(userID, e.ID)
+}
+
+// Update replaces an entry; a missing felt_at keeps the recorded one
+func (s *EmotionsService) Update(userID, id int, req *models.EmotionEntryRequest, now time.Time) (*models.EmotionEntryResponse, error) {
+	e, err := s.repo.GetEntry(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.FeltAt == "" {
+		req.FeltAt = e.FeltAt.Format(time.RFC3339)
+	}
+	if err := s.apply(e, req, now); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateEntry(e, req.LabelIDs); err != nil {
+		return nil, err
+	}
+	return s.Get(userID, id)
+}
+
+func (s *EmotionsService) Delete(userID, id int) error {
+	return s.repo.DeleteEntry(userID, id)
+}
+
+func (s *EmotionsService) apply(e *models.EmotionEntry, req *models.EmotionEntryRequest, now time.Time) error {
+	felt := now
+	if req.FeltAt != "" {
+		t, err := time.Parse(time.RFC3339, req.FeltAt)
+		if err != nil {
+			return errors.New("invalid felt_at")
+		}
+		felt = t
+	}
+	if req.NoteID != nil {
+		if _, err := s.notes.GetByID(e.UserID, *req.NoteID); err != nil {
+			return err
+		}
+	}
+	e.Mood = req.Mood
+	e.Intensity = req.Intensity
+	e.Comment = req.Comment
+	e.NoteID = req.NoteID
+	e.FeltAt = felt
+	// The entry belongs to the day it was felt on in the caller's zone
+	e.EntryDate = localDate(felt, now.Location())
+	return nil
+}
+
+// Trends aggregates mood per day (last 30 days by default) or per week (last 12 weeks)
+func (s *EmotionsService) Trends(userID int, q *models.EmotionRangeQuery, now time.Time) ([]models.MoodTrendPoint, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	group := q.Group
+	if group == "" {
+		group = "day"
+	}
+	defFrom := today.AddDate(0, 0, -(moodDailyTrendDays - 1))
+	if group == "week" {
+		defFrom = mondayOf(today).AddDate(0, 0, -7*(moodWeeklyTrendWeeks-1))
+	}
+	from, to, err := dateRange(q.From, q.To, today, defFrom)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Trends(userID, group, from, to)
+}
+
+// Correlations relates mood to the tags of the notes written on the same days, over the
+// last 90 days by default
+func (s *EmotionsService) Correlations(userID int, q *models.EmotionRangeQuery, now time.Time) ([]models.MoodTagCorrelation, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from, to, err := dateRange(q.From, q.To, today, today.AddDate(0, 0, -(moodCorrelationDays-1)))
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.TagCorrelations(userID, from, to, moodCorrelationMinDays, moodCorrelationLimit)
+}
+
+// dateRange resolves an inclusive YYYY-MM-DD range of at most a year; to defaults to today
+// and from to defFrom
+func dateRange(fromParam, toParam string, today, defFrom time.Time) (time.Time, time.Time, error) {
+	from, to, err := ParseExportRange(&models.NoteExportQuery{From: fromParam, To: toParam})
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if from == nil {
+		from = &defFrom
+	}
+	if to == nil {
+		to = &today
+	}
+	if to.Before(*from) {
+		return time.Time{}, time.Time{}, errors.New("to must not be before from")
+	}
+	if to.Sub(*from) > 366*24*time.Hour {
+		return time.Time{}, time.Time{}, errors.New("range cannot exceed one year")
+	}
+	return *from, *to, nil
+}
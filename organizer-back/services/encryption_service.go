@@ -0,0 +1,238 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"organizer-back/keyring"
+	"organizer-back/models"
+	"organizer-back/repository"
+	"time"
+)
+
+const defaultGrantTTL = 15 * time.Minute
+
+type EncryptionService struct {
+	repo      *repository.EncryptionRepository
+	grants    *keyring.GrantStore
+	serverKEK []byte
+	grantTTL  time.Duration
+}
+
+// NewEncryptionService reads the optional server KEK (NOTES_KEK) and the grant lifetime
+// (ENCRYPTION_GRANT_TTL_SECONDS)
+func NewEncryptionService() (*EncryptionService, error) {
+	kek, err := keyring.ServerKEKFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptionService{
+		repo:      repository.NewEncryptionRepository(),
+		grants:    keyring.NewGrantStore(),
+		serverKEK: kek,
+		grantTTL:  time.Duration(envInt64("ENCRYPTION_GRANT_TTL_SECONDS", int64(defaultGrantTTL/time.Second))) * time.Second,
+	}, nil
+}
+
+func (s *EncryptionService) Status(userID int) (*models.EncryptionStatus, error) {
+	status := &models.EncryptionStatus{ServerModeAvailable: s.serverKEK != nil}
+	k, err := s.repo.Get(userID)
+	if err != nil {
+		if err.Error() == "encryption not set up" {
+			return status, nil
+		}
+		return nil, err
+	}
+	status.Enabled, status.Mode, status.CreatedAt = true, k.Mode, &k.CreatedAt
+	return status, nil
+}
+
+// Setup creates the user's data key. In passphrase mode it also returns a grant so the
+// client can start writing encrypted notes right away.
+func (s *EncryptionService) Setup(userID int, req *models.EncryptionSetupRequest) (*models.DecryptionGrantResponse, error) {
+	dataKey, err := keyring.NewKey()
+	if err != nil {
+		return nil, err
+	}
+	k := &models.UserEncryptionKey{UserID: userID, Mode: req.Mode}
+	switch req.Mode {
+	case "server":
+		if s.serverKEK == nil {
+			return nil, errors.New("server-side encryption is not configured")
+		}
+		if k.WrappedKey, err = keyring.Wrap(s.serverKEK, dataKey); err != nil {
+			return nil, err
+		}
+	case "passphrase":
+		if len(req.Passphrase) < 8 {
+			return nil, errors.New("passphrase must have at least 8 characters")
+		}
+		if err := wrapWithPassphrase(k, dataKey, req.Passphrase); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported mode %q", req.Mode)
+	}
+	if err := s.repo.Create(k); err != nil {
+		return nil, err
+	}
+	if k.Mode == "server" {
+		return nil, nil
+	}
+	return s.issue(userID, dataKey)
+}
+
+// Unlock checks the passphrase and returns a short-lived grant for the data key
+func (s *EncryptionService) Unlock(userID int, passphrase string) (*models.DecryptionGrantResponse, error) {
+	k, err := s.repo.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := s.unwrap(k, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(userID, dataKey)
+}
+
+// Lock drops a grant before it expires
+func (s *EncryptionService) Lock(grant string) {
+	s.grants.Revoke(grant)
+}
+
+// ChangePassphrase re-wraps the data key; notes do not need to be re-encrypted. Existing
+// grants are revoked.
+func (s *EncryptionService) ChangePassphrase(userID int, req *models.EncryptionPassphraseChangeRequest) error {
+	k, err := s.repo.Get(userID)
+	if err != nil {
+		return err
+	}
+	if k.Mode != "passphrase" {
+		return errors.New("notes are encrypted with the server key")
+	}
+	dataKey, err := s.unwrap(k, req.Current)
+	if err != nil {
+		return err
+	}
+	if err := wrapWithPassphrase(k, dataKey, req.New); err != nil {
+		return err
+	}
+	if err := s.repo.Rewrap(k); err != nil {
+		return err
+	}
+	s.grants.RevokeUser(userID)
+	return nil
+}
+
+// DataKey returns the user's data key: unwrapped with the server KEK in server mode, or
+// taken from a live grant in passphrase mode
+func (s *EncryptionService) DataKey(userID int, grant string) ([]byte, error) {
+	k, err := s.repo.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	if k.Mode == "server" {
+		return s.unwrap(k, "")
+	}
+	if key, ok := s.grants.Lookup(userID, grant); ok {
+		return key, nil
+	}
+	return nil, errors.New("notes are locked")
+}
+
+// SealNote encrypts a note's content; the owner's id is bound as associated data so a
+// ciphertext copied to another user's note does not decrypt
+func (s *EncryptionService) SealNote(userID int, key []byte, content string) ([]byte, error) {
+	return keyring.Seal(key, []byte(content), noteAAD(userID))
+}
+
+func (s *EncryptionService) OpenNote(userID int, key []byte, ciphertext []byte) (string, error) {
+	b, err := keyring.Open(key, ciphertext, noteAAD(userID))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Revealer decrypts the notes of one request. The key is resolved once, and only if an
+// encrypted note shows up; without it notes stay locked.
+func (s *EncryptionService) Revealer(userID int, grant string) *NoteRevealer {
+	return &NoteRevealer{s: s, userID: userID, grant: grant}
+}
+
+type NoteRevealer struct {
+	s        *EncryptionService
+	userID   int
+	grant    string
+	key      []byte
+	resolved bool
+}
+
+// Reveal decrypts n in place when possible; a note that stays encrypted keeps its
+// ciphertext and is reported as locked by ToResponse
+func (r *NoteRevealer) Reveal(n *models.Note) {
+	if !n.Encrypted || n.Ciphertext == nil {
+		return
+	}
+	if !r.resolved {
+		r.resolved = true
+		r.key, _ = r.s.DataKey(r.userID, r.grant)
+	}
+	if r.key == nil {
+		return
+	}
+	content, err := r.s.OpenNote(n.UserID, r.key, n.Ciphertext)
+	if err != nil {
+		log.Printf("note %d: cannot decrypt: %v", n.ID, err)
+		return
+	}
+	n.Content, n.Ciphertext = content, nil
+}
+
+func (s *EncryptionService) issue(userID int, dataKey []byte) (*models.DecryptionGrantResponse, error) {
+	token, expiresAt, err := s.grants.Issue(userID, dataKey, s.grantTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &models.DecryptionGrantResponse{Grant: token, ExpiresAt: expiresAt}, nil
+}
+
+func (s *EncryptionService) unwrap(k *models.UserEncryptionKey, passphrase string) ([]byte, error) {
+	if k.Mode == "server" {
+		if s.serverKEK == nil {
+			return nil, errors.New("server-side encryption is not configured")
+		}
+		return keyring.Unwrap(s.serverKEK, k.WrappedKey)
+	}
+	var params keyring.KDFParams
+	if err := json.Unmarshal(k.KDFParams, &params); err != nil {
+		return nil, fmt.Errorf("invalid key parameters: %v", err)
+	}
+	dataKey, err := keyring.Unwrap(keyring.DeriveKEK(passphrase, k.KDFSalt, params), k.WrappedKey)
+	if err == keyring.ErrWrongKey {
+		return nil, errors.New("invalid passphrase")
+	}
+	return dataKey, err
+}
+
+func wrapWithPassphrase(k *models.UserEncryptionKey, dataKey []byte, passphrase string) error {
+	salt, err := keyring.NewSalt()
+	if err != nil {
+		return err
+	}
+	params, err := json.Marshal(keyring.DefaultKDF)
+	if err != nil {
+		return err
+	}
+	wrapped, err := keyring.Wrap(keyring.DeriveKEK(passphrase, salt, keyring.DefaultKDF), dataKey)
+	if err != nil {
+		return err
+	}
+	k.WrappedKey, k.KDFSalt, k.KDFParams = wrapped, salt, params
+	return nil
+}
+
+func noteAAD(userID int) []byte {
+	return []byte(fmt.Sprintf("organizer:note:user:%d", userID))
+}
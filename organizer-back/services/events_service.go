@@ -0,0 +1,297 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"organizer-back/models"
+	"organizer-back/recurrence"
+	"organizer-back/repository"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	maxEventWindow         = 366 * 24 * time.Hour
+	maxOccurrencesPerEvent = 1000
+	maxEventOccurrences    = 5000
+	// Margin for UNTIL values without a zone, read in the event's zone later on
+	floatingUntilMargin = 24 * time.Hour
+)
+
+type EventsService struct {
+	repo *repository.EventRepository
+}
+
+func NewEventsService() *EventsService {
+	return &EventsService{repo: repository.NewEventRepository()}
+}
+
+// Occurrences expands the events the user owns or attends into their occurrences
+// overlapping the window; loc reads bare dates of the query
+func (s *EventsService) Occurrences(userID int, q *models.EventRangeQuery, loc *time.Location) ([]models.EventOccurrence, error) {
+	from, err := parseRangeBound(q.From, loc)
+	if err != nil {
+		return nil, errors.New("invalid from")
+	}
+	to, err := parseRangeBound(q.To, loc)
+	if err != nil {
+		return nil, errors.New("invalid to")
+	}
+	if !to.After(from) {
+		return nil, errors.New("to must be after from")
+	}
+	if to.Sub(from) > maxEventWindow {
+		return nil, errors.New("range cannot exceed one year")
+	}
+	events, err := s.repo.ListInRange(userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	out := []models.EventOccurrence{}
+	for i := range events {
+		e := &events[i]
+		var attendance *string
+		for _, a := range e.Attendees {
+			if a.UserID == userID && e.UserID != userID {
+				status := a.Status
+				attendance = &status
+			}
+		}
+		for _, span := range expandEvent(e, from, to) {
+			out = append(out, models.EventOccurrence{
+				EventID:     e.ID,
+				UID:         e.UID,
+				Title:       e.Title,
+				Description: e.Description,
+				Location:    e.Location,
+				StartsAt:    span[0],
+				EndsAt:      span[1],
+				AllDay:      e.AllDay,
+				Timezone:    e.Timezone,
+				Recurring:   e.RRule != nil,
+				OwnerID:     e.UserID,
+				Attendance:  attendance,
+			})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if !out[i].StartsAt.Equal(out[j].StartsAt) {
+			return out[i].StartsAt.Before(out[j].StartsAt)
+		}
+		return out[i].EventID < out[j].EventID
+	})
+	if len(out) > maxEventOccurrences {
+		out = out[:maxEventOccurrences]
+	}
+	return out, nil
+}
+
+func (s *EventsService) Get(userID, id int) (*models.Event, error) {
+	return s.repo.GetByID(userID, id)
+}
+
+// Create adds an event; defaultTZ is the caller's zone, used when the request has none
+func (s *EventsService) Create(userID int, req *models.EventRequest, defaultTZ string) (*models.Event, error) {
+	uid, err := newICalUID()
+	if err != nil {
+		return nil, err
+	}
+	e := &models.Event{UserID: userID, UID: uid}
+	if err := applyEventRequest(e, req, defaultTZ); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(e, req.AttendeeIDs); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(userID, e.ID)
+}
+
+// Update replaces one of the caller's own events
+func (s *EventsService) Update(userID, id int, req *models.EventRequest, defaultTZ string) (*models.Event, error) {
+	e := &models.Event{ID: id, UserID: userID}
+	if err := applyEventRequest(e, req, defaultTZ); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(e, req.AttendeeIDs); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(userID, id)
+}
+
+func (s *EventsService) Delete(userID, id int) error {
+	return s.repo.Delete(userID, id)
+}
+
+// SetAttendance records the caller's answer to an invitation
+func (s *EventsService) SetAttendance(userID, eventID int, status string) (*models.Event, error) {
+	if err := s.repo.SetAttendance(userID, eventID, status); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(userID, eventID)
+}
+
+func applyEventRequest(e *models.Event, req *models.EventRequest, defaultTZ string) error {
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		return errors.New("title is required")
+	}
+	tz := req.Timezone
+	if tz == "" {
+		tz = defaultTZ
+	}
+	loc, err := loadTimezone(tz)
+	if err != nil {
+		return err
+	}
+	starts, err := parseEventTime(req.StartsAt, req.AllDay, loc)
+	if err != nil {
+		return errors.New("invalid starts_at")
+	}
+	var ends time.Time
+	switch {
+	case req.EndsAt != "":
+		if ends, err = parseEventTime(req.EndsAt, req.AllDay, loc); err != nil {
+			return errors.New("invalid ends_at")
+		}
+	case req.AllDay:
+		ends = starts.AddDate(0, 0, 1)
+	default:
+		ends = starts.Add(time.Hour)
+	}
+	if ends.Before(starts) || req.AllDay && !ends.After(starts) {
+		return errors.New("ends_at must be after starts_at")
+	}
+
+	e.Title = title
+	e.Description = req.Description
+	e.Location = strings.TrimSpace(req.Location)
+	e.StartsAt, e.EndsAt = starts, ends
+	e.AllDay = req.AllDay
+	e.Timezone = tz
+	e.RRule = nil
+	e.RecurrenceEnd = nil
+	e.ExDates = []time.Time{}
+	if req.RRule == "" {
+		if len(req.ExDates) > 0 {
+			return errors.New("exdates require an rrule")
+		}
+		return nil
+	}
+	rule, err := recurrence.Parse(req.RRule)
+	if err != nil {
+		return err
+	}
+	normalized := rule.String()
+	e.RRule = &normalized
+	e.RecurrenceEnd = recurrenceEnd(rule, starts, ends.Sub(starts))
+	for _, raw := range req.ExDates {
+		d, err := parseEventTime(raw, req.AllDay, loc)
+		if err != nil {
+			return fmt.Errorf("invalid exdate %q", raw)
+		}
+		e.ExDates = append(e.ExDates, d)
+	}
+	return nil
+}
+
+// parseEventTime reads a date for all-day events and a time otherwise
+func parseEventTime(s string, allDay bool, loc *time.Location) (time.Time, error) {
+	if allDay {
+		return time.ParseInLocation("2006-01-02", s, loc)
+	}
+	return parseLocalTime(s, loc)
+}
+
+// parseRangeBound reads an RFC 3339 time or a date at midnight in loc
+func parseRangeBound(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, loc)
+}
+
+// recurrenceEnd is a bound on the end of a rule's last occurrence, nil when it never ends
+func recurrenceEnd(rule *recurrence.Rule, start time.Time, duration time.Duration) *time.Time {
+	var end time.Time
+	switch {
+	case !rule.Until.IsZero():
+		end = rule.Until.Add(duration + floatingUntilMargin)
+	case rule.Count > 0:
+		starts := rule.Between(start, start, start.AddDate(1000, 0, 0), rule.Count)
+		if len(starts) == 0 {
+			end = start.Add(duration)
+		} else {
+			end = starts[len(starts)-1].Add(duration + time.Hour)
+		}
+	default:
+		return nil
+	}
+	return &end
+}
+
+// expandEvent returns the [start, end) of each occurrence overlapping [from, to). All-day
+// occurrences keep their length in days, so they stay midnight to midnight across DST.
+func expandEvent(e *models.Event, from, to time.Time) [][2]time.Time {
+	loc, err := loadTimezone(e.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	start := e.StartsAt.In(loc)
+	end := e.EndsAt.In(loc)
+	days := daysBetween(start, end)
+	span := func(t time.Time) [2]time.Time {
+		if e.AllDay {
+			return [2]time.Time{t, t.AddDate(0, 0, days)}
+		}
+		return [2]time.Time{t, t.Add(end.Sub(start))}
+	}
+	overlaps := func(sp [2]time.Time) bool {
+		if !sp[0].Before(to) {
+			return false
+		}
+		return sp[1].After(from) || sp[1].Equal(sp[0]) && !sp[0].Before(from)
+	}
+
+	var out [][2]time.Time
+	if e.RRule == nil {
+		if sp := span(start); overlaps(sp) {
+			out = append(out, sp)
+		}
+		return out
+	}
+	rule, err := recurrence.Parse(*e.RRule)
+	if err != nil {
+		return nil
+	}
+	// Occurrences starting up to one event length (plus a DST hour) before the window can
+	// still reach into it
+	lookBack := end.Sub(start) + time.Hour
+	for _, t := range rule.Between(start, from.Add(-lookBack), to, maxOccurrencesPerEvent) {
+		if isExDate(e.ExDates, t) {
+			continue
+		}
+		if sp := span(t); overlaps(sp) {
+			out = append(out, sp)
+		}
+	}
+	return out
+}
+
+func isExDate(exdates []time.Time, t time.Time) bool {
+	for _, d := range exdates {
+		if d.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// daysBetween counts calendar days from a to b in a's location
+func daysBetween(a, b time.Time) int {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.In(a.Location()).Date()
+	da := time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC)
+	db := time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC)
+	return int(db.Sub(da).Hours() / 24)
+}
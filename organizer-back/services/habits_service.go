@@ -0,0 +1,441 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"organizer-back/models"
+	"organizer-back/repository"
+	"sort"
+	"strings"
+	"time"
+)
+
+const habitStatsDays = 30
+
+type HabitsService struct {
+	repo *repository.HabitRepository
+}
+
+func NewHabitsService() *HabitsService {
+	return &HabitsService{repo: repository.NewHabitRepository()}
+}
+
+func (s *HabitsService) List(userID int, includeArchived bool) ([]models.HabitResponse, error) {
+	habits, err := s.repo.List(userID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]models.HabitResponse, len(habits))
+	for i := range habits {
+		res[i] = habits[i].ToResponse()
+	}
+	return res, nil
+}
+
+func (s *HabitsService) Get(userID, id int) (*models.HabitResponse, error) {
+	h, err := s.repo.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	res := h.ToResponse()
+	return &res, nil
+}
+
+// Create adds a habit; now is the current time in the caller's zone and gives the default
+// start date
+func (s *HabitsService) Create(userID int, req *models.HabitRequest, now time.Time) (*models.HabitResponse, error) {
+	h := &models.Habit{UserID: userID, StartDate: localDate(now, now.Location())}
+	if err := applyHabitRequest(h, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(h); err != nil {
+		return nil, err
+	}
+	res := h.ToResponse()
+	return &res, nil
+}
+
+// Update replaces a habit; a missing start_date or archived keeps the current one
+func (s *HabitsService) Update(userID, id int, req *models.HabitRequest) (*models.HabitResponse, error) {
+	h, err := s.repo.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyHabitRequest(h, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(h); err != nil {
+		return nil, err
+	}
+	res := h.ToResponse()
+	return &res, nil
+}
+
+func (s *HabitsService) Delete(userID, id int) error {
+	return s.repo.Delete(userID, id)
+}
+
+func applyHabitRequest(h *models.Habit, req *models.HabitRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return errors.New("name is required")
+	}
+	schedule := req.Schedule
+	if schedule == "" {
+		schedule = "daily"
+	}
+	h.TimesPerWeek = nil
+	h.Weekdays = []int64{}
+	switch schedule {
+	case "weekly":
+		if req.TimesPerWeek == nil {
+			return errors.New("times_per_week is required for weekly habits")
+		}
+		h.TimesPerWeek = req.TimesPerWeek
+	case "weekdays":
+		seen := map[int64]bool{}
+		for _, d := range req.Weekdays {
+			if !seen[d] {
+				seen[d] = true
+				h.Weekdays = append(h.Weekdays, d)
+			}
+		}
+		if len(h.Weekdays) == 0 {
+			return errors.New("weekdays is required for weekdays habits")
+		}
+		sort.Slice(h.Weekdays, func(i, j int) bool { return h.Weekdays[i] < h.Weekdays[j] })
+	}
+	if req.StartDate != "" {
+		d, err := time.Parse("2006-01-02", req.StartDate)
+		if err != nil {
+			return errors.New("invalid start_date")
+		}
+		h.StartDate = d
+	}
+	if req.Archived != nil {
+		h.Archived = *req.Archived
+	}
+	h.Name = name
+	h.Description = req.Description
+	h.Color = req.Color
+	h.Schedule = schedule
+	h.Target = req.Target
+	h.Unit = strings.TrimSpace(req.Unit)
+	return nil
+}
+
+// Checkins lists the check-ins of a habit, the last 30 days up to today by default
+func (s *HabitsService) Checkins(userID, id int, q *models.HabitRangeQuery, now time.Time) ([]models.HabitCheckin, error) {
+	h, err := s.repo.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	today := localDate(now, now.Location())
+	from, to, err := dateRange(q.From, q.To, today, today.AddDate(0, 0, -(habitStatsDays-1)))
+	if err != nil {
+		return nil, err
+	}
+	checkins, err := s.repo.ListCheckins([]int{h.ID}, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for i := range checkins {
+		checkins[i].Done = habitCheckinDone(h, &checkins[i])
+	}
+	return checkins, nil
+}
+
+// Checkin records the habit on a day between its start date and today
+func (s *HabitsService) Checkin(userID, id int, date string, req *models.HabitCheckinRequest, now time.Time) (*models.HabitCheckin, error) {
+	h, err := s.repo.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil, errors.New("invalid date")
+	}
+	if h.Archived {
+		return nil, errors.New("cannot check in an archived habit")
+	}
+	if day.Before(h.StartDate) {
+		return nil, errors.New("date is before the habit's start date")
+	}
+	if day.After(localDate(now, now.Location())) {
+		return nil, errors.New("date cannot be in the future")
+	}
+	c := &models.HabitCheckin{HabitID: h.ID, Date: day.Format("2006-01-02"), Value: req.Value, Comment: req.Comment}
+	if err := s.repo.UpsertCheckin(c); err != nil {
+		return nil, err
+	}
+	c.Done = habitCheckinDone(h, c)
+	return c, nil
+}
+
+func (s *HabitsService) DeleteCheckin(userID, id int, date string) error {
+	h, err := s.repo.GetByID(userID, id)
+	if err != nil {
+		return err
+	}
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return errors.New("invalid date")
+	}
+	return s.repo.DeleteCheckin(h.ID, day)
+}
+
+// Stats measures a habit over a range (the last 30 days by default) against its schedule;
+// streaks are as of the end of the range
+func (s *HabitsService) Stats(userID, id int, q *models.HabitRangeQuery, now time.Time) (*models.HabitStats, error) {
+	h, err := s.repo.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	today := localDate(now, now.Location())
+	from, to, err := dateRange(q.From, q.To, today, today.AddDate(0, 0, -(habitStatsDays-1)))
+	if err != nil {
+		return nil, err
+	}
+	if to.After(today) {
+		to = today
+	}
+	stats := &models.HabitStats{HabitID: h.ID, From: from.Format("2006-01-02"), To: to.Format("2006-01-02"), StreakUnit: habitStreakUnit(h)}
+	if to.Before(h.StartDate) {
+		return stats, nil
+	}
+	checkins, err := s.repo.ListCheckins([]int{h.ID}, h.StartDate, to)
+	if err != nil {
+		return nil, err
+	}
+	done := habitDoneDays(h, checkins)
+	stats.Scheduled, stats.Completed = habitCompletion(h, done, from, to)
+	if stats.Scheduled > 0 {
+		stats.CompletionRate = math.Round(float64(stats.Completed)/float64(stats.Scheduled)*1000) / 1000
+	}
+	stats.CurrentStreak, stats.LongestStreak = habitStreaks(h, done, to)
+	return stats, nil
+}
+
+// Day is the daily view of the active habits on a YYYY-MM-DD date, the note_date GET /notes
+// uses; it defaults to today
+func (s *HabitsService) Day(userID int, date string, now time.Time) ([]models.HabitDay, error) {
+	day := localDate(now, now.Location())
+	if date != "" {
+		d, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return nil, errors.New("invalid date")
+		}
+		day = d
+	}
+	habits, err := s.repo.List(userID, false)
+	if err != nil {
+		return nil, err
+	}
+	var active []models.Habit
+	ids := []int{}
+	first := day
+	for _, h := range habits {
+		if h.StartDate.After(day) {
+			continue
+		}
+		active = append(active, h)
+		ids = append(ids, h.ID)
+		if h.StartDate.Before(first) {
+			first = h.StartDate
+		}
+	}
+	checkins, err := s.repo.ListCheckins(ids, first, day)
+	if err != nil {
+		return nil, err
+	}
+	byHabit := map[int][]models.HabitCheckin{}
+	for _, c := range checkins {
+		byHabit[c.HabitID] = append(byHabit[c.HabitID], c)
+	}
+
+	key := day.Format("2006-01-02")
+	res := make([]models.HabitDay, 0, len(active))
+	for i := range active {
+		h := &active[i]
+		done := habitDoneDays(h, byHabit[h.ID])
+		hd := models.HabitDay{
+			HabitResponse: h.ToResponse(),
+			Date:          key,
+			Scheduled:     habitScheduledOn(h, day),
+			Done:          done[key],
+			StreakUnit:    habitStreakUnit(h),
+		}
+		for _, c := range byHabit[h.ID] {
+			if c.Date == key {
+				c.Done = hd.Done
+				hd.Checkin = &c
+			}
+		}
+		if h.Schedule == "weekly" {
+			weekDone := habitWeekDone(done, mondayOf(day), day)
+			hd.WeekDone = &weekDone
+		}
+		hd.CurrentStreak, _ = habitStreaks(h, done, day)
+		res = append(res, hd)
+	}
+	return res, nil
+}
+
+// habitCheckinDone tells whether a check-in counts: any does without a target, otherwise
+// its value must reach it
+func habitCheckinDone(h *models.Habit, c *models.HabitCheckin) bool {
+	if h.Target == nil {
+		return true
+	}
+	return c.Value != nil && *c.Value >= *h.Target
+}
+
+// habitDoneDays returns the YYYY-MM-DD days whose check-in counts
+func habitDoneDays(h *models.Habit, checkins []models.HabitCheckin) map[string]bool {
+	done := map[string]bool{}
+	for i := range checkins {
+		if habitCheckinDone(h, &checkins[i]) {
+			done[checkins[i].Date] = true
+		}
+	}
+	return done
+}
+
+// habitScheduledOn tells whether the habit is due on a day; weekly habits may be done on any day
+func habitScheduledOn(h *models.Habit, day time.Time) bool {
+	if day.Before(h.StartDate) {
+		return false
+	}
+	if h.Schedule != "weekdays" {
+		return true
+	}
+	for _, d := range h.Weekdays {
+		if int64(day.Weekday()) == d {
+			return true
+		}
+	}
+	return false
+}
+
+func habitStreakUnit(h *models.Habit) string {
+	if h.Schedule == "weekly" {
+		return "week"
+	}
+	return "day"
+}
+
+// habitWeekDone counts the done days from monday to day
+func habitWeekDone(done map[string]bool, monday, day time.Time) int {
+	n := 0
+	for d := monday; !d.After(day); d = d.AddDate(0, 0, 1) {
+		if done[d.Format("2006-01-02")] {
+			n++
+		}
+	}
+	return n
+}
+
+// habitWeekRequired is how many done days a week needs: TimesPerWeek, or fewer when the
+// days of the week in [from, to] cannot hold that many
+func habitWeekRequired(h *models.Habit, monday, from, to time.Time) int {
+	first, last := monday, monday.AddDate(0, 0, 6)
+	if first.Before(from) {
+		first = from
+	}
+	if last.After(to) {
+		last = to
+	}
+	days := int(last.Sub(first).Hours()/24) + 1
+	if days < *h.TimesPerWeek {
+		return days
+	}
+	return *h.TimesPerWeek
+}
+
+// habitCompletion counts the scheduled and completed occurrences in [from, to], to being at
+// most today. An occurrence still open on the last day (today not done yet, or a week that
+// has not reached its target) does not count against the habit.
+func habitCompletion(h *models.Habit, done map[string]bool, from, to time.Time) (int, int) {
+	if from.Before(h.StartDate) {
+		from = h.StartDate
+	}
+	scheduled, completed := 0, 0
+	if h.Schedule == "weekly" {
+		for monday := mondayOf(from); !monday.After(to); monday = monday.AddDate(0, 0, 7) {
+			first, last := monday, monday.AddDate(0, 0, 6)
+			if first.Before(from) {
+				first = from
+			}
+			if last.After(to) {
+				last = to
+			}
+			required := habitWeekRequired(h, monday, from, monday.AddDate(0, 0, 6))
+			got := habitWeekDone(done, first, last)
+			if got > required {
+				got = required
+			}
+			if !monday.AddDate(0, 0, 6).After(to) || got >= required {
+				scheduled += required
+			} else {
+				scheduled += got
+			}
+			completed += got
+		}
+		return scheduled, completed
+	}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if !habitScheduledOn(h, d) {
+			continue
+		}
+		if done[d.Format("2006-01-02")] {
+			scheduled++
+			completed++
+		} else if d.Before(to) {
+			scheduled++
+		}
+	}
+	return scheduled, completed
+}
+
+// habitStreaks returns the current and longest streaks as of asOf. A missed occurrence ends
+// a streak, except the one still open on asOf.
+func habitStreaks(h *models.Habit, done map[string]bool, asOf time.Time) (int, int) {
+	run, longest := 0, 0
+	if h.Schedule == "weekly" {
+		for monday := mondayOf(h.StartDate); !monday.After(asOf); monday = monday.AddDate(0, 0, 7) {
+			sunday := monday.AddDate(0, 0, 6)
+			required := habitWeekRequired(h, monday, h.StartDate, sunday)
+			last := sunday
+			if last.After(asOf) {
+				last = asOf
+			}
+			switch {
+			case habitWeekDone(done, monday, last) >= required:
+				run++
+			case sunday.After(asOf):
+			default:
+				run = 0
+			}
+			if run > longest {
+				longest = run
+			}
+		}
+		return run, longest
+	}
+	for d := h.StartDate; !d.After(asOf); d = d.AddDate(0, 0, 1) {
+		if !habitScheduledOn(h, d) {
+			continue
+		}
+		switch {
+		case done[d.Format("2006-01-02")]:
+			run++
+		case d.Equal(asOf):
+		default:
+			run = 0
+		}
+		if run > longest {
+			longest = run
+		}
+	}
+	return run, longest
+}
@@ -0,0 +1,535 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"organizer-back/ical"
+	"organizer-back/models"
+	"organizer-back/recurrence"
+	"organizer-back/repository"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	icalProdID = "-//Organizer//Organizer//ES"
+	// Calendar apps poll subscriptions; they are asked to do it at most this often
+	icalRefreshInterval = "PT1H"
+	maxImportComponents = 5000
+)
+
+// UNTIL of an RRULE, to rewrite it in the form the DTSTART of an export requires
+var rruleUntil = regexp.MustCompile(`UNTIL=(\d{8})(T\d{6})?(Z?)`)
+
+type ICalService struct {
+	feeds     *repository.CalendarFeedRepository
+	events    *repository.EventRepository
+	todos     *repository.TodoRepository
+	reminders *repository.ReminderRepository
+	users     *repository.UserRepository
+}
+
+func NewICalService() *ICalService {
+	return &ICalService{
+		feeds:     repository.NewCalendarFeedRepository(),
+		events:    repository.NewEventRepository(),
+		todos:     repository.NewTodoRepository(),
+		reminders: repository.NewReminderRepository(),
+		users:     repository.NewUserRepository(),
+	}
+}
+
+// Feed describes the caller's subscription feed without its token
+func (s *ICalService) Feed(userID int) (*models.CalendarFeedResponse, error) {
+	f, err := s.feeds.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return &models.CalendarFeedResponse{}, nil
+	}
+	return &models.CalendarFeedResponse{Enabled: true, LastAccessedAt: f.LastAccessedAt, CreatedAt: &f.CreatedAt}, nil
+}
+
+// RotateFeed enables the feed with a new token, which is only returned here; the previous
+// URL stops working
+func (s *ICalService) RotateFeed(userID int) (*models.CalendarFeedResponse, error) {
+	token, err := newPublicToken()
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.feeds.Rotate(userID, hashPublicToken(token))
+	if err != nil {
+		return nil, err
+	}
+	return &models.CalendarFeedResponse{
+		Enabled:   true,
+		Token:     token,
+		URL:       "/ical/" + token + ".ics",
+		CreatedAt: &f.CreatedAt,
+	}, nil
+}
+
+func (s *ICalService) DeleteFeed(userID int) error {
+	return s.feeds.Delete(userID)
+}
+
+// FeedCalendar builds the calendar behind a feed token: events the owner has not declined,
+// active reminders and todos with a due date
+func (s *ICalService) FeedCalendar(token string) (*ical.Component, error) {
+	userID, err := s.feeds.Resolve(hashPublicToken(token))
+	if err != nil {
+		return nil, err
+	}
+	events, err := s.events.ListForFeed(userID)
+	if err != nil {
+		return nil, err
+	}
+	reminders, err := s.reminders.List(userID)
+	if err != nil {
+		return nil, err
+	}
+	todos, err := s.todos.ListWithDueDate(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	cal := newCalendar()
+	cal.Add("METHOD", "PUBLISH")
+	cal.AddText("X-WR-CALNAME", "Organizer")
+	cal.AddText("X-WR-TIMEZONE", userLocation(s.users, userID).String())
+	cal.Add("REFRESH-INTERVAL", icalRefreshInterval, "VALUE", "DURATION")
+	cal.Add("X-PUBLISHED-TTL", icalRefreshInterval)
+	for i := range events {
+		cal.Append(eventComponent(&events[i]))
+	}
+	for i := range reminders {
+		if reminders[i].Active {
+			cal.Append(reminderComponent(&reminders[i]))
+		}
+	}
+	for i := range todos {
+		cal.Append(todoComponent(&todos[i]))
+	}
+	ical.AddTimezones(cal)
+	return cal, nil
+}
+
+func newCalendar() *ical.Component {
+	cal := ical.NewComponent("VCALENDAR")
+	cal.Add("VERSION", "2.0")
+	cal.Add("PRODID", icalProdID)
+	cal.Add("CALSCALE", "GREGORIAN")
+	return cal
+}
+
+func eventComponent(e *models.Event) *ical.Component {
+	loc, err := loadTimezone(e.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	c := ical.NewComponent("VEVENT")
+	c.AddText("UID", e.UID)
+	c.AddUTC("DTSTAMP", e.UpdatedAt)
+	c.AddUTC("CREATED", e.CreatedAt)
+	c.AddUTC("LAST-MODIFIED", e.UpdatedAt)
+	c.AddText("SUMMARY", e.Title)
+	if e.Description != "" {
+		c.AddText("DESCRIPTION", e.Description)
+	}
+	if e.Location != "" {
+		c.AddText("LOCATION", e.Location)
+	}
+	if e.AllDay {
+		c.AddDate("DTSTART", e.StartsAt.In(loc))
+		c.AddDate("DTEND", e.EndsAt.In(loc))
+	} else {
+		c.AddDateTime("DTSTART", e.Timezone, e.StartsAt)
+		c.AddDateTime("DTEND", e.Timezone, e.EndsAt)
+	}
+	if e.RRule != nil {
+		c.Add("RRULE", exportRRule(*e.RRule, e.AllDay, loc))
+		if len(e.ExDates) > 0 {
+			if e.AllDay {
+				dates := make([]time.Time, len(e.ExDates))
+				for i, d := range e.ExDates {
+					dates[i] = d.In(loc)
+				}
+				c.AddDate("EXDATE", dates...)
+			} else {
+				c.AddDateTime("EXDATE", e.Timezone, e.ExDates...)
+			}
+		}
+	}
+	return c
+}
+
+func reminderComponent(r *models.Reminder) *ical.Component {
+	loc, err := loadTimezone(r.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	c := ical.NewComponent("VEVENT")
+	c.AddText("UID", fmt.Sprintf("reminder-%d@organizer", r.ID))
+	c.AddUTC("DTSTAMP", r.UpdatedAt)
+	c.AddUTC("CREATED", r.CreatedAt)
+	c.AddUTC("LAST-MODIFIED", r.UpdatedAt)
+	c.AddText("SUMMARY", r.Title)
+	if r.Message != "" {
+		c.AddText("DESCRIPTION", r.Message)
+	}
+	c.AddDateTime("DTSTART", r.Timezone, r.StartsAt)
+	c.AddDateTime("DTEND", r.Timezone, r.StartsAt)
+	c.Add("TRANSP", "TRANSPARENT")
+	if r.RRule != nil {
+		c.Add("RRULE", exportRRule(*r.RRule, false, loc))
+	}
+	alarm := ical.NewComponent("VALARM")
+	alarm.Add("ACTION", "DISPLAY")
+	alarm.Add("TRIGGER", "PT0S")
+	alarm.AddText("DESCRIPTION", r.Title)
+	c.Append(alarm)
+	return c
+}
+
+func todoComponent(t *models.Todo) *ical.Component {
+	c := ical.NewComponent("VTODO")
+	c.AddText("UID", t.UID)
+	c.AddUTC("DTSTAMP", t.UpdatedAt)
+	c.AddUTC("CREATED", t.CreatedAt)
+	c.AddUTC("LAST-MODIFIED", t.UpdatedAt)
+	c.AddText("SUMMARY", t.Title)
+	if t.Description != "" {
+		c.AddText("DESCRIPTION", t.Description)
+	}
+	if t.DueDate != nil {
+		c.AddDate("DUE", *t.DueDate)
+	}
+	c.Add("STATUS", todoICalStatus[t.Status])
+	c.Add("PRIORITY", fmt.Sprint(todoICalPriority[t.Priority]))
+	if t.CompletedAt != nil {
+		c.AddUTC("COMPLETED", *t.CompletedAt)
+	}
+	return c
+}
+
+var todoICalStatus = map[string]string{
+	"open":        "NEEDS-ACTION",
+	"in_progress": "IN-PROCESS",
+	"done":        "COMPLETED",
+	"cancelled":   "CANCELLED",
+}
+
+// PRIORITY goes from 1 (highest) to 9; 0 means undefined
+var todoICalPriority = map[string]int{"urgent": 1, "high": 3, "normal": 5, "low": 9}
+
+func todoPriorityFromICal(p int) string {
+	switch {
+	case p >= 1 && p <= 2:
+		return "urgent"
+	case p >= 3 && p <= 4:
+		return "high"
+	case p >= 6:
+		return "low"
+	}
+	return "normal"
+}
+
+// exportRRule writes UNTIL as RFC 5545 wants it next to DTSTART: a date for all-day events
+// and a UTC time otherwise. Stored rules may carry a floating UNTIL, read in loc.
+func exportRRule(rule string, allDay bool, loc *time.Location) string {
+	return rruleUntil.ReplaceAllStringFunc(rule, func(m string) string {
+		parts := rruleUntil.FindStringSubmatch(m)
+		var until time.Time
+		var err error
+		switch {
+		case parts[2] == "":
+			until, err = time.ParseInLocation("20060102", parts[1], loc)
+		case parts[3] == "Z":
+			until, err = time.Parse("20060102T150405Z", parts[1]+parts[2]+"Z")
+		default:
+			until, err = time.ParseInLocation("20060102T150405", parts[1]+parts[2], loc)
+		}
+		if err != nil {
+			return m
+		}
+		if allDay {
+			return "UNTIL=" + until.In(loc).Format("20060102")
+		}
+		return "UNTIL=" + until.UTC().Format("20060102T150405Z")
+	})
+}
+
+// Import creates or updates events and todos from an .ics file, matching existing ones by
+// UID. defaultTZ is the caller's zone, used for floating times. Components that cannot be
+// imported are reported and skipped.
+func (s *ICalService) Import(userID int, r io.Reader, defaultTZ string) (*models.CalendarImportResult, error) {
+	defLoc, err := loadTimezone(defaultTZ)
+	if err != nil {
+		return nil, err
+	}
+	top, err := ical.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+	var events, todos []*ical.Component
+	for _, cal := range top {
+		if cal.Name != "VCALENDAR" {
+			continue
+		}
+		events = append(events, cal.Children("VEVENT")...)
+		todos = append(todos, cal.Children("VTODO")...)
+	}
+	if len(events)+len(todos) == 0 {
+		return nil, errors.New("the file has no events or todos")
+	}
+	if len(events)+len(todos) > maxImportComponents {
+		return nil, fmt.Errorf("the file has more than %d events and todos", maxImportComponents)
+	}
+
+	res := &models.CalendarImportResult{Skipped: []models.CalendarImportSkip{}}
+	skip := func(c *ical.Component, err error) {
+		res.Skipped = append(res.Skipped, models.CalendarImportSkip{Component: c.Name, UID: c.Text("UID"), Reason: err.Error()})
+	}
+	for _, c := range events {
+		created, err := s.importEvent(userID, c, defaultTZ, defLoc)
+		switch {
+		case err != nil:
+			skip(c, err)
+		case created:
+			res.EventsCreated++
+		default:
+			res.EventsUpdated++
+		}
+	}
+	// Subtasks after the rest, so their parents exist
+	sort.SliceStable(todos, func(i, j int) bool {
+		return icalParentUID(todos[i]) == "" && icalParentUID(todos[j]) != ""
+	})
+	for _, c := range todos {
+		created, err := s.importTodo(userID, c, defLoc)
+		switch {
+		case err != nil:
+			skip(c, err)
+		case created:
+			res.TodosCreated++
+		default:
+			res.TodosUpdated++
+		}
+	}
+	return res, nil
+}
+
+// importEvent upserts a VEVENT; attendees of an existing event are kept
+func (s *ICalService) importEvent(userID int, c *ical.Component, defaultTZ string, defLoc *time.Location) (bool, error) {
+	uid, err := importUID(c)
+	if err != nil {
+		return false, err
+	}
+	req, err := eventRequestFromICal(c, defaultTZ, defLoc)
+	if err != nil {
+		return false, err
+	}
+	existing, err := s.events.GetByUID(userID, uid)
+	if err != nil && err.Error() != "event not found" {
+		return false, err
+	}
+	e := &models.Event{UserID: userID, UID: uid}
+	if err := applyEventRequest(e, req, defaultTZ); err != nil {
+		return false, err
+	}
+	if existing == nil {
+		return true, s.events.Create(e, nil)
+	}
+	e.ID = existing.ID
+	attendees := make([]int, len(existing.Attendees))
+	for i, a := range existing.Attendees {
+		attendees[i] = a.UserID
+	}
+	return false, s.events.Update(e, attendees)
+}
+
+// eventRequestFromICal reads a VEVENT as the request that creates or replaces an event
+func eventRequestFromICal(c *ical.Component, defaultTZ string, defLoc *time.Location) (*models.EventRequest, error) {
+	if c.Get("RECURRENCE-ID") != nil {
+		return nil, errors.New("changes to single occurrences are not supported")
+	}
+	if strings.EqualFold(c.Text("STATUS"), "CANCELLED") {
+		return nil, errors.New("the event is cancelled")
+	}
+	dtstart := c.Get("DTSTART")
+	if dtstart == nil {
+		return nil, errors.New("DTSTART is required")
+	}
+	start, err := dtstart.DateTime(defLoc)
+	if err != nil {
+		return nil, errors.New("invalid DTSTART")
+	}
+
+	req := &models.EventRequest{
+		Title:       importText(c.Text("SUMMARY"), 200),
+		Description: c.Text("DESCRIPTION"),
+		Location:    importText(c.Text("LOCATION"), 200),
+		AllDay:      start.Date,
+		Timezone:    start.TZID,
+	}
+	if req.Title == "" {
+		req.Title = "Untitled"
+	}
+	if req.Timezone == "" {
+		req.Timezone = defaultTZ
+	}
+	format := func(t time.Time) string {
+		if req.AllDay {
+			return t.Format("2006-01-02")
+		}
+		return t.Format(time.RFC3339)
+	}
+	req.StartsAt = format(start.Time)
+	switch {
+	case c.Get("DTEND") != nil:
+		end, err := c.Get("DTEND").DateTime(defLoc)
+		if err != nil || end.Date != start.Date {
+			return nil, errors.New("invalid DTEND")
+		}
+		// Some clients end an all-day event on its own day; that means a one-day event
+		if !req.AllDay || end.Time.After(start.Time) {
+			req.EndsAt = format(end.Time)
+		}
+	case c.Get("DURATION") != nil:
+		d, err := ical.ParseDuration(c.Get("DURATION").Value)
+		if err != nil || d < 0 {
+			return nil, errors.New("invalid DURATION")
+		}
+		if req.AllDay {
+			req.EndsAt = format(start.Time.AddDate(0, 0, int(d/(24*time.Hour))))
+		} else {
+			req.EndsAt = format(start.Time.Add(d))
+		}
+	case !req.AllDay:
+		// Without DTEND or DURATION a timed event takes no time
+		req.EndsAt = req.StartsAt
+	}
+	if rrule := c.Get("RRULE"); rrule != nil {
+		if _, err := recurrence.Parse(rrule.Value); err != nil {
+			return nil, err
+		}
+		req.RRule = rrule.Value
+		for _, p := range c.All("EXDATE") {
+			dates, err := p.DateTimes(defLoc)
+			if err != nil {
+				return nil, errors.New("invalid EXDATE")
+			}
+			for _, d := range dates {
+				req.ExDates = append(req.ExDates, format(d.Time))
+			}
+		}
+	}
+	return req, nil
+}
+
+// importTodo upserts a VTODO. A RELATED-TO parent that was imported (or created) before
+// makes a new todo its subtask; existing todos keep their place.
+func (s *ICalService) importTodo(userID int, c *ical.Component, defLoc *time.Location) (bool, error) {
+	uid, err := importUID(c)
+	if err != nil {
+		return false, err
+	}
+	t, err := s.todos.GetByUID(userID, uid)
+	if err != nil && err.Error() != "todo not found" {
+		return false, err
+	}
+	created := t == nil
+	if created {
+		t = &models.Todo{UserID: userID, UID: uid}
+	}
+	if err := applyICalTodo(t, c, defLoc); err != nil {
+		return false, err
+	}
+	if !created {
+		return false, s.todos.Update(t)
+	}
+	if parentUID := icalParentUID(c); parentUID != "" {
+		if parent, err := s.todos.GetByUID(userID, parentUID); err == nil && parent.ParentID == nil {
+			t.ParentID = &parent.ID
+		}
+	}
+	return true, s.todos.Create(t)
+}
+
+// applyICalTodo copies the fields of a VTODO to a todo; a DUE time becomes its date in loc
+func applyICalTodo(t *models.Todo, c *ical.Component, loc *time.Location) error {
+	t.Title = importText(c.Text("SUMMARY"), 200)
+	if t.Title == "" {
+		t.Title = "Untitled"
+	}
+	t.Description = c.Text("DESCRIPTION")
+	t.DueDate = nil
+	if p := c.Get("DUE"); p != nil {
+		due, err := p.DateTime(loc)
+		if err != nil {
+			return errors.New("invalid DUE")
+		}
+		local := due.Time
+		if !due.Date {
+			local = local.In(loc)
+		}
+		d := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+		t.DueDate = &d
+	}
+	t.Status = "open"
+	for status, value := range todoICalStatus {
+		if strings.EqualFold(c.Text("STATUS"), value) {
+			t.Status = status
+		}
+	}
+	if t.Status == "open" && c.Get("COMPLETED") != nil {
+		t.Status = "done"
+	}
+	priority, _ := strconv.Atoi(strings.TrimSpace(c.Text("PRIORITY")))
+	t.Priority = todoPriorityFromICal(priority)
+	return nil
+}
+
+// icalParentUID returns the UID of the parent a VTODO is related to, if any
+func icalParentUID(c *ical.Component) string {
+	rel := c.Get("RELATED-TO")
+	if rel == nil || rel.Param("RELTYPE") != "" && !strings.EqualFold(rel.Param("RELTYPE"), "PARENT") {
+		return ""
+	}
+	return strings.TrimSpace(rel.Text())
+}
+
+func importUID(c *ical.Component) (string, error) {
+	uid := strings.TrimSpace(c.Text("UID"))
+	if uid == "" {
+		return "", errors.New("UID is required")
+	}
+	if len(uid) > 255 {
+		return "", errors.New("UID is too long")
+	}
+	return uid, nil
+}
+
+// importText trims a value and cuts it to max characters
+func importText(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return strings.TrimSpace(string([]rune(s)[:max]))
+}
+
+// newICalUID returns a UID for entities created here
+func newICalUID() (string, error) {
+	uid, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	return uid + "@organizer", nil
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"errors"
+	"organizer-back/models"
+	"organizer-back/repository"
+	"time"
+)
+
+// bulkMaxNotes caps how many notes a single bulk operation may touch
+const bulkMaxNotes = 1000
+
+// Bulk applies one action to many of the caller's notes, selected by ids or by a filter.
+// Atomic mode (the default) is all-or-nothing; partial mode commits what it can and reports
+// the outcome of every note. Shared notes are never touched: only owned notes match.
+func (s *NotesService) Bulk(userID int, req *models.NoteBulkRequest) (*models.NoteBulkResponse, error) {
+	mode := req.Mode
+	if mode == "" {
+		mode = "atomic"
+	}
+
+	var ids []int
+	var filter *repository.NoteFilter
+	switch {
+	case len(req.IDs) > 0 && req.Filter != nil:
+		return nil, errors.New("ids and filter cannot be combined")
+	case len(req.IDs) > 0:
+		ids = req.IDs
+	case req.Filter != nil:
+		f, err := parseBulkFilter(req.Filter)
+		if err != nil {
+			return nil, err
+		}
+		filter = f
+	default:
+		return nil, errors.New("ids or filter is required")
+	}
+
+	change := repository.NoteBulkChange{Action: req.Action}
+	switch req.Action {
+	case "move":
+		d, err := time.Parse("2006-01-02", req.Date)
+		if err != nil {
+			return nil, errors.New("move requires a valid date")
+		}
+		change.Date = d
+	case "tag", "untag":
+		change.Tags = normalizeTags(req.Tags)
+		if len(change.Tags) == 0 {
+			return nil, errors.New(req.Action + " requires tags")
+		}
+	}
+
+	results, keys, err := s.repo.BulkApply(userID, ids, filter, change, mode == "partial", bulkMaxNotes)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) > 0 {
+		s.attachments.PurgeBlobs(keys)
+	}
+
+	res := &models.NoteBulkResponse{Action: req.Action, Mode: mode, Matched: len(results), Results: results}
+	for _, r := range results {
+		if r.Status == "ok" {
+			res.Succeeded++
+		} else {
+			res.Failed++
+		}
+	}
+	return res, nil
+}
+
+// parseBulkFilter validates a filter; an empty one is rejected so a typo cannot select
+// every note the user has
+func parseBulkFilter(in *models.NoteBulkFilter) (*repository.NoteFilter, error) {
+	f := &repository.NoteFilter{Starred: in.Starred, Hidden: in.Hidden}
+	if in.Tag != "" {
+		tags := normalizeTags([]string{in.Tag})
+		if len(tags) == 0 {
+			return nil, errors.New("invalid tag")
+		}
+		f.Tag = tags[0]
+	}
+	if in.Date != "" {
+		if in.From != "" || in.To != "" {
+			return nil, errors.New("date cannot be combined with from/to")
+		}
+		in = &models.NoteBulkFilter{From: in.Date, To: in.Date}
+	}
+	from, to, err := ParseExportRange(&models.NoteExportQuery{From: in.From, To: in.To})
+	if err != nil {
+		return nil, err
+	}
+	f.From, f.To = from, to
+	if f.From == nil && f.To == nil && f.Starred == nil && f.Hidden == nil && f.Tag == "" {
+		return nil, errors.New("filter must have at least one condition")
+	}
+	return f, nil
+}
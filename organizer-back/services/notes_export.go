@@ -0,0 +1,192 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"organizer-back/models"
+	"strings"
+	"time"
+)
+
+const (
+	// NoteExportFormat identifies our JSON export so the importer can recognize it
+	NoteExportFormat  = "organizer-notes"
+	noteExportVersion = 1
+)
+
+// ParseExportRange validates the optional inclusive from/to dates of an export
+func ParseExportRange(q *models.NoteExportQuery) (*time.Time, *time.Time, error) {
+	var from, to *time.Time
+	if q.From != "" {
+		d, err := time.Parse("2006-01-02", q.From)
+		if err != nil {
+			return nil, nil, errors.New("invalid from date")
+		}
+		from = &d
+	}
+	if q.To != "" {
+		d, err := time.Parse("2006-01-02", q.To)
+		if err != nil {
+			return nil, nil, errors.New("invalid to date")
+		}
+		to = &d
+	}
+	if from != nil && to != nil && to.Before(*from) {
+		return nil, nil, errors.New("to must not be before from")
+	}
+	return from, to, nil
+}
+
+// Export streams the user's notes to w in the requested format (json, markdown or zip).
+// Encrypted notes are exported decrypted when grant unlocks them; otherwise they are marked
+// locked and their content is left out.
+func (s *NotesService) Export(userID int, format string, from, to *time.Time, grant string, w io.Writer) error {
+	revealer := s.encryption.Revealer(userID, grant)
+	switch format {
+	case "", "json":
+		return s.exportJSON(userID, from, to, revealer, w)
+	case "markdown":
+		return s.exportMarkdown(userID, from, to, revealer, w)
+	case "zip":
+		return s.exportZip(userID, from, to, revealer, w)
+	default:
+		return fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+func (s *NotesService) exportJSON(userID int, from, to *time.Time, revealer *NoteRevealer, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	exportedAt, _ := json.Marshal(time.Now().UTC())
+	fmt.Fprintf(bw, `{"format":%q,"version":%d,"exported_at":%s,"notes":[`, NoteExportFormat, noteExportVersion, exportedAt)
+	first := true
+	err := s.repo.StreamByUser(userID, from, to, func(n *models.Note) error {
+		revealer.Reveal(n)
+		if !first {
+			bw.WriteByte(',')
+		}
+		first = false
+		b, err := json.Marshal(n.ToResponse())
+		if err != nil {
+			return err
+		}
+		_, err = bw.Write(b)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	bw.WriteString("]}\n")
+	return bw.Flush()
+}
+
+// exportMarkdown writes a single document with a heading per day and a metadata comment per note
+func (s *NotesService) exportMarkdown(userID int, from, to *time.Time, revealer *NoteRevealer, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# Notes\n")
+	var currentDay string
+	err := s.repo.StreamByUser(userID, from, to, func(n *models.Note) error {
+		revealer.Reveal(n)
+		day := n.NoteDate.Format("2006-01-02")
+		if day != currentDay {
+			currentDay = day
+			fmt.Fprintf(bw, "\n## %s\n", day)
+		}
+		fmt.Fprintf(bw, "\n<!-- note:%d starred=%t hidden=%t tags=%s created_at=%s updated_at=%s%s -->\n",
+			n.ID, n.Starred, n.Hidden, strings.Join(n.Tags, ","),
+			n.CreatedAt.UTC().Format(time.RFC3339), n.UpdatedAt.UTC().Format(time.RFC3339), encryptionMarks(n))
+		bw.WriteString(strings.TrimRight(n.Content, "\n"))
+		_, err := bw.WriteString("\n")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// exportZip writes one Markdown file per day. Rows arrive ordered by date, so only the
+// notes of the day being written are held in memory.
+func (s *NotesService) exportZip(userID int, from, to *time.Time, revealer *NoteRevealer, w io.Writer) error {
+	zw := zip.NewWriter(w)
+	var day []models.Note
+	flush := func() error {
+		if len(day) == 0 {
+			return nil
+		}
+		name := day[0].NoteDate.Format("2006-01-02") + ".md"
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: day[len(day)-1].UpdatedAt})
+		if err != nil {
+			return err
+		}
+		if err := writeDayMarkdown(f, day); err != nil {
+			return err
+		}
+		day = day[:0]
+		return nil
+	}
+	err := s.repo.StreamByUser(userID, from, to, func(n *models.Note) error {
+		if len(day) > 0 && !day[0].NoteDate.Equal(n.NoteDate) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		revealer.Reveal(n)
+		day = append(day, *n)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writeDayMarkdown renders a day file: YAML front matter describing each note, then the
+// notes' content separated by <!-- note:ID --> markers
+func writeDayMarkdown(w io.Writer, notes []models.Note) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("---\n")
+	fmt.Fprintf(bw, "date: %s\n", notes[0].NoteDate.Format("2006-01-02"))
+	bw.WriteString("notes:\n")
+	for i := range notes {
+		n := &notes[i]
+		// JSON strings are valid YAML flow scalars, which keeps quoting rules simple
+		tags, _ := json.Marshal(n.ToResponse().Tags)
+		fmt.Fprintf(bw, "  - id: %d\n", n.ID)
+		fmt.Fprintf(bw, "    starred: %t\n", n.Starred)
+		fmt.Fprintf(bw, "    hidden: %t\n", n.Hidden)
+		fmt.Fprintf(bw, "    tags: %s\n", tags)
+		fmt.Fprintf(bw, "    created_at: %s\n", n.CreatedAt.UTC().Format(time.RFC3339))
+		fmt.Fprintf(bw, "    updated_at: %s\n", n.UpdatedAt.UTC().Format(time.RFC3339))
+		if n.Encrypted {
+			bw.WriteString("    encrypted: true\n")
+			if n.Ciphertext != nil {
+				bw.WriteString("    locked: true\n")
+			}
+		}
+	}
+	bw.WriteString("---\n")
+	for i := range notes {
+		fmt.Fprintf(bw, "\n<!-- note:%d -->\n", notes[i].ID)
+		bw.WriteString(strings.TrimRight(notes[i].Content, "\n"))
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// encryptionMarks flags encrypted notes in the Markdown export; locked ones have no content
+func encryptionMarks(n *models.Note) string {
+	switch {
+	case n.Encrypted && n.Ciphertext != nil:
+		return " encrypted=true locked=true"
+	case n.Encrypted:
+		return " encrypted=true"
+	}
+	return ""
+}
@@ -0,0 +1,351 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"organizer-back/models"
+	"organizer-back/repository"
+	"organizer-back/storage"
+	"sync"
+	"time"
+)
+
+const (
+	defaultImportMaxBytes = 100 << 20 // 100 MiB archives
+	// A running job whose heartbeat is older than this is considered abandoned and may be resumed
+	importStaleAfter = 2 * time.Minute
+)
+
+type ImportService struct {
+	repo       *repository.ImportRepository
+	store      storage.BlobStore
+	encryption *EncryptionService
+	maxBytes   int64
+
+	// Data keys unlocked by the grant sent with the upload, so encrypted notes in the archive
+	// can be stored encrypted again. They only live in memory while the job runs.
+	keysMu sync.Mutex
+	keys   map[int][]byte
+}
+
+func NewImportService(store storage.BlobStore, encryption *EncryptionService) *ImportService {
+	return &ImportService{
+		repo:       repository.NewImportRepository(),
+		store:      store,
+		encryption: encryption,
+		maxBytes:   envInt64("IMPORT_MAX_BYTES", defaultImportMaxBytes),
+		keys:       map[int][]byte{},
+	}
+}
+
+// MaxBytes is the upload size limit for archives
+func (s *ImportService) MaxBytes() int64 {
+	return s.maxBytes
+}
+
+// Start stores the uploaded archive and queues a job that processes it in the background
+func (s *ImportService) Start(userID int, fileName string, size int64, r io.Reader, req *models.NoteImportRequest, grant string) (*models.ImportJob, error) {
+	if size > s.maxBytes {
+		return nil, errors.New("file too large")
+	}
+	key, err := newImportKey(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.Put(key, io.LimitReader(r, s.maxBytes), size, "application/octet-stream"); err != nil {
+		return nil, err
+	}
+
+	format := req.Format
+	if format == "" || format == "auto" {
+		format, err = s.detect(key)
+		if err != nil {
+			s.deleteArchive(key)
+			return nil, err
+		}
+	}
+	duplicates := req.Duplicates
+	if duplicates == "" {
+		duplicates = "skip"
+	}
+
+	job := &models.ImportJob{
+		UserID:            userID,
+		Format:            format,
+		DryRun:            req.DryRun,
+		DuplicateStrategy: duplicates,
+		FileName:          fileName,
+		StorageKey:        key,
+	}
+	if err := s.repo.CreateJob(job); err != nil {
+		s.deleteArchive(key)
+		return nil, err
+	}
+	s.rememberKey(job.ID, userID, grant)
+	go s.Run(job.ID)
+	return job, nil
+}
+
+func (s *ImportService) Get(userID, id int) (*models.ImportJobReport, error) {
+	job, err := s.repo.GetJob(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	items, err := s.repo.ListItems(id)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ImportJobReport{ImportJob: *job, Items: items}, nil
+}
+
+func (s *ImportService) List(userID int) ([]models.ImportJob, error) {
+	return s.repo.ListJobs(userID)
+}
+
+// Resume re-queues a failed job; it continues after the last item it committed
+func (s *ImportService) Resume(userID, id int, grant string) (*models.ImportJob, error) {
+	if err := s.repo.Requeue(userID, id); err != nil {
+		return nil, err
+	}
+	s.rememberKey(id, userID, grant)
+	go s.Run(id)
+	return s.repo.GetJob(userID, id)
+}
+
+// ResumePending restarts queued jobs and jobs abandoned by a crashed or stopped instance
+func (s *ImportService) ResumePending() {
+	ids, err := s.repo.PendingJobIDs(importStaleAfter)
+	if err != nil {
+		log.Printf("import: %v", err)
+		return
+	}
+	for _, id := range ids {
+		go s.Run(id)
+	}
+}
+
+// Run processes a job if it can be claimed. Items already recorded are skipped, so running
+// a partially processed job picks up exactly where it stopped.
+func (s *ImportService) Run(id int) {
+	job, ok, err := s.repo.ClaimJob(id, importStaleAfter)
+	if err != nil {
+		log.Printf("import job %d: %v", id, err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	stop := s.heartbeat(job)
+	runErr := s.process(job)
+	stop()
+	s.forgetKey(id)
+
+	if runErr != nil {
+		msg := runErr.Error()
+		log.Printf("import job %d failed: %v", id, runErr)
+		if err := s.repo.FinishJob(id, "failed", &msg); err != nil {
+			log.Printf("import job %d: %v", id, err)
+		}
+		return
+	}
+	if err := s.repo.FinishJob(id, "completed", nil); err != nil {
+		log.Printf("import job %d: %v", id, err)
+		return
+	}
+	s.deleteArchive(job.StorageKey)
+}
+
+// heartbeat keeps the job claimed while a single slow item is processed
+func (s *ImportService) heartbeat(job *models.ImportJob) func() {
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(importStaleAfter / 4)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				if err := s.repo.Heartbeat(job.ID); err != nil {
+					log.Printf("import job %d heartbeat: %v", job.ID, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (s *ImportService) process(job *models.ImportJob) error {
+	blob, err := s.store.Open(job.StorageKey)
+	if err != nil {
+		return fmt.Errorf("archive unavailable: %v", err)
+	}
+	defer blob.Close()
+
+	index := 0
+	return forEachImportItem(&blobReaderAt{blob: blob}, blob.Size(), job.Format, func(item importItem) error {
+		i := index
+		index++
+		if i < job.ProcessedItems {
+			return nil
+		}
+		return s.processItem(job, i, item)
+	})
+}
+
+func (s *ImportService) processItem(job *models.ImportJob, index int, item importItem) error {
+	res := &models.ImportJobItem{Index: index, Source: item.Source}
+	if !item.NoteDate.IsZero() {
+		d := item.NoteDate.Format("2006-01-02")
+		res.NoteDate = &d
+	}
+	if item.Err != nil {
+		res.Action = "failed"
+		res.Message = item.Err.Error()
+		return s.repo.RecordItem(job.ID, res, nil)
+	}
+
+	note := &models.Note{
+		UserID:   job.UserID,
+		NoteDate: item.NoteDate,
+		Content:  item.Content,
+		Hidden:   item.Hidden,
+		Starred:  item.Starred,
+		Tags:     normalizeTags(item.Tags),
+	}
+	if item.Encrypted {
+		if err := s.sealItem(job, note); err != nil {
+			res.Action = "failed"
+			res.Message = err.Error()
+			return s.repo.RecordItem(job.ID, res, nil)
+		}
+	}
+	err := s.repo.RecordItem(job.ID, res, func(tx *sql.Tx) error {
+		dupID, dup, err := s.repo.FindDuplicate(tx, job.UserID, item.Key, item.NoteDate, item.Content)
+		if err != nil {
+			return err
+		}
+		switch {
+		case dup && job.DuplicateStrategy == "skip":
+			res.Action = "skipped"
+			res.NoteID = &dupID
+			res.Message = "duplicate"
+			return nil
+		case dup && job.DuplicateStrategy == "overwrite":
+			res.Action = "overwritten"
+			res.NoteID = &dupID
+			if job.DryRun {
+				return nil
+			}
+			note.ID = dupID
+			if err := s.repo.OverwriteNote(tx, note); err != nil {
+				return err
+			}
+		default:
+			res.Action = "created"
+			if dup {
+				res.Message = "duplicate kept"
+			}
+			if job.DryRun {
+				return nil
+			}
+			if err := s.repo.InsertNote(tx, note); err != nil {
+				return err
+			}
+			res.NoteID = &note.ID
+		}
+		return s.repo.RememberSource(tx, job.UserID, item.Key, note.ID)
+	})
+	if err == nil {
+		return nil
+	}
+	// The item's changes were rolled back; report it and keep going with the rest
+	failed := &models.ImportJobItem{Index: index, Source: item.Source, NoteDate: res.NoteDate, Action: "failed", Message: err.Error()}
+	return s.repo.RecordItem(job.ID, failed, nil)
+}
+
+// sealItem encrypts an item that came from an encrypted note with the user's data key
+func (s *ImportService) sealItem(job *models.ImportJob, note *models.Note) error {
+	s.keysMu.Lock()
+	key := s.keys[job.ID]
+	s.keysMu.Unlock()
+	if key == nil {
+		// Server-managed keys do not need a grant
+		k, err := s.encryption.DataKey(job.UserID, "")
+		if err != nil {
+			return errors.New("encrypted note: unlock encryption and resume the import")
+		}
+		key = k
+	}
+	sealed, err := s.encryption.SealNote(job.UserID, key, note.Content)
+	if err != nil {
+		return err
+	}
+	note.Encrypted, note.Ciphertext, note.Content = true, sealed, ""
+	return nil
+}
+
+func (s *ImportService) rememberKey(jobID, userID int, grant string) {
+	if grant == "" {
+		return
+	}
+	key, err := s.encryption.DataKey(userID, grant)
+	if err != nil {
+		return
+	}
+	s.keysMu.Lock()
+	s.keys[jobID] = key
+	s.keysMu.Unlock()
+}
+
+func (s *ImportService) forgetKey(jobID int) {
+	s.keysMu.Lock()
+	delete(s.keys, jobID)
+	s.keysMu.Unlock()
+}
+
+func (s *ImportService) detect(key string) (string, error) {
+	blob, err := s.store.Open(key)
+	if err != nil {
+		return "", err
+	}
+	defer blob.Close()
+	return detectImportFormat(&blobReaderAt{blob: blob}, blob.Size())
+}
+
+func (s *ImportService) deleteArchive(key string) {
+	if err := s.store.Delete(key); err != nil {
+		log.Printf("failed to delete import archive %s: %v", key, err)
+	}
+}
+
+func newImportKey(userID int) (string, error) {
+	suffix, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("imports/%d/%s", userID, suffix), nil
+}
+
+// blobReaderAt adapts a seekable blob for archive/zip, which needs random access
+type blobReaderAt struct {
+	mu   sync.Mutex
+	blob storage.Blob
+}
+
+func (r *blobReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.blob.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.blob, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
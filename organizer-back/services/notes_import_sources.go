@@ -0,0 +1,478 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// importItem is one note-to-be read from an archive
+type importItem struct {
+	Source    string // human readable location, e.g. "daily/2025-03-02.md#2"
+	Key       string // stable identity used to recognize re-imports
+	NoteDate  time.Time
+	Content   string
+	Hidden    bool
+	Starred   bool
+	Tags      []string
+	Encrypted bool  // exported from an encrypted note; stored encrypted again
+	Err       error // the item could not be parsed; reported as failed
+}
+
+// errLockedExport is reported for encrypted notes exported without a decryption grant:
+// the archive does not contain their content
+var errLockedExport = errors.New("encrypted note was exported locked; unlock before exporting")
+
+var dateInName = regexp.MustCompile(`(\d{4})-(\d{2})-(\d{2})`)
+var noteMarker = regexp.MustCompile(`(?m)^<!-- note:(\d+)(?: [^>]*)? -->\n?`)
+
+const maxImportEntryBytes = 5 << 20
+
+// detectImportFormat sniffs the archive when the client asked for "auto"
+func detectImportFormat(r io.ReaderAt, size int64) (string, error) {
+	head := make([]byte, 4)
+	if _, err := r.ReadAt(head, 0); err != nil && err != io.EOF {
+		return "", err
+	}
+	if bytes.Equal(head, []byte("PK\x03\x04")) {
+		zr, err := zip.NewReader(r, size)
+		if err != nil {
+			return "", fmt.Errorf("invalid zip archive: %v", err)
+		}
+		hasJSON := false
+		for _, f := range zr.File {
+			if strings.HasPrefix(f.Name, ".obsidian/") || strings.Contains(f.Name, "/.obsidian/") {
+				return "obsidian", nil
+			}
+			if strings.HasSuffix(strings.ToLower(f.Name), ".json") {
+				hasJSON = true
+			}
+		}
+		if hasJSON {
+			return "dayone", nil
+		}
+		return "markdown", nil
+	}
+	// A bare JSON file: our own export declares its format, anything with "entries" is Day One
+	dec := json.NewDecoder(io.NewSectionReader(r, 0, size))
+	var probe struct {
+		Format  string          `json:"format"`
+		Notes   json.RawMessage `json:"notes"`
+		Entries json.RawMessage `json:"entries"`
+	}
+	if err := dec.Decode(&probe); err != nil {
+		return "", errors.New("unrecognized archive: expected a zip or JSON file")
+	}
+	switch {
+	case probe.Format == NoteExportFormat || probe.Notes != nil:
+		return "json", nil
+	case probe.Entries != nil:
+		return "dayone", nil
+	}
+	return "", errors.New("unrecognized JSON archive")
+}
+
+// forEachImportItem walks the archive in a deterministic order so a resumed job sees the
+// same item indexes as the original run
+func forEachImportItem(r io.ReaderAt, size int64, format string, fn func(importItem) error) error {
+	head := make([]byte, 4)
+	if _, err := r.ReadAt(head, 0); err != nil && err != io.EOF {
+		return err
+	}
+	isZip := bytes.Equal(head, []byte("PK\x03\x04"))
+
+	if !isZip {
+		switch format {
+		case "json":
+			return streamJSONArray(io.NewSectionReader(r, 0, size), "notes", func(raw json.RawMessage, i int) error {
+				return fn(parseExportedNote(raw, "notes.json", i))
+			})
+		case "dayone":
+			return streamJSONArray(io.NewSectionReader(r, 0, size), "entries", func(raw json.RawMessage, i int) error {
+				return fn(parseDayOneEntry(raw, "journal.json", i))
+			})
+		default:
+			return fmt.Errorf("%s imports must be a zip archive", format)
+		}
+	}
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("invalid zip archive: %v", err)
+	}
+	files := make([]*zip.File, 0, len(zr.File))
+	for _, f := range zr.File {
+		if !f.FileInfo().IsDir() && !ignoredImportPath(f.Name) {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	for _, f := range files {
+		lower := strings.ToLower(f.Name)
+		switch {
+		case (format == "json" || format == "dayone") && strings.HasSuffix(lower, ".json"):
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			name := f.Name
+			err = streamJSONArray(rc, map[string]string{"json": "notes", "dayone": "entries"}[format], func(raw json.RawMessage, i int) error {
+				if format == "json" {
+					return fn(parseExportedNote(raw, name, i))
+				}
+				return fn(parseDayOneEntry(raw, name, i))
+			})
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		case (format == "markdown" || format == "obsidian") && (strings.HasSuffix(lower, ".md") || strings.HasSuffix(lower, ".markdown")):
+			body, err := readZipEntry(f)
+			if err != nil {
+				if err := fn(importItem{Source: f.Name, Key: "md:" + f.Name, Err: err}); err != nil {
+					return err
+				}
+				continue
+			}
+			for _, item := range parseMarkdownFile(f.Name, body) {
+				if err := fn(item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ignoredImportPath skips app metadata, trash and macOS resource forks
+func ignoredImportPath(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
+
+func readZipEntry(f *zip.File) (string, error) {
+	if f.UncompressedSize64 > maxImportEntryBytes {
+		return "", errors.New("file too large")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(io.LimitReader(rc, maxImportEntryBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(b) > maxImportEntryBytes {
+		return "", errors.New("file too large")
+	}
+	return string(b), nil
+}
+
+// streamJSONArray decodes the elements of the array stored under key in a top-level object
+// one at a time
+func streamJSONArray(r io.Reader, key string, fn func(json.RawMessage, int) error) error {
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return errors.New("expected a JSON object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("invalid JSON: %v", err)
+		}
+		if name, _ := tok.(string); name != key {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return fmt.Errorf("invalid JSON: %v", err)
+			}
+			continue
+		}
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+			return fmt.Errorf("expected %q to be an array", key)
+		}
+		for i := 0; dec.More(); i++ {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return fmt.Errorf("invalid JSON: %v", err)
+			}
+			if err := fn(raw, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("missing %q array", key)
+}
+
+// parseExportedNote reads an element of our own JSON export (models.NoteResponse)
+func parseExportedNote(raw json.RawMessage, file string, i int) importItem {
+	var n struct {
+		ID        int      `json:"id"`
+		NoteDate  string   `json:"note_date"`
+		Content   string   `json:"content"`
+		Hidden    bool     `json:"hidden"`
+		Starred   bool     `json:"starred"`
+		Tags      []string `json:"tags"`
+		Encrypted bool     `json:"encrypted"`
+		Locked    bool     `json:"locked"`
+	}
+	item := importItem{Source: fmt.Sprintf("%s[%d]", file, i), Key: fmt.Sprintf("json:%s:%d", file, i)}
+	if err := json.Unmarshal(raw, &n); err != nil {
+		item.Err = fmt.Errorf("invalid note: %v", err)
+		return item
+	}
+	d, err := time.Parse("2006-01-02", n.NoteDate)
+	if err != nil {
+		item.Err = errors.New("invalid note_date")
+		return item
+	}
+	if n.ID != 0 {
+		item.Key = exportedNoteKey(strconv.Itoa(n.ID), d)
+	}
+	item.NoteDate, item.Content, item.Hidden, item.Starred, item.Tags = d, n.Content, n.Hidden, n.Starred, n.Tags
+	item.Encrypted = n.Encrypted
+	if n.Locked {
+		item.Err = errLockedExport
+	}
+	return item
+}
+
+// parseDayOneEntry reads an entry of a Day One JSON export
+func parseDayOneEntry(raw json.RawMessage, file string, i int) importItem {
+	var e struct {
+		UUID         string   `json:"uuid"`
+		CreationDate string   `json:"creationDate"`
+		TimeZone     string   `json:"timeZone"`
+		Text         string   `json:"text"`
+		Starred      bool     `json:"starred"`
+		Tags         []string `json:"tags"`
+	}
+	item := importItem{Source: fmt.Sprintf("%s[%d]", file, i), Key: fmt.Sprintf("dayone:%s:%d", file, i)}
+	if err := json.Unmarshal(raw, &e); err != nil {
+		item.Err = fmt.Errorf("invalid entry: %v", err)
+		return item
+	}
+	if e.UUID != "" {
+		item.Key = "dayone:" + e.UUID
+	}
+	created, err := time.Parse(time.RFC3339, e.CreationDate)
+	if err != nil {
+		item.Err = errors.New("invalid creationDate")
+		return item
+	}
+	// The journal day is the one the author saw on their clock
+	if loc, err := time.LoadLocation(e.TimeZone); err == nil && e.TimeZone != "" {
+		created = created.In(loc)
+	}
+	item.NoteDate = time.Date(created.Year(), created.Month(), created.Day(), 0, 0, 0, 0, time.UTC)
+	item.Content = e.Text
+	item.Starred = e.Starred
+	item.Tags = e.Tags
+	if strings.TrimSpace(item.Content) == "" {
+		item.Err = errors.New("entry has no text")
+	}
+	return item
+}
+
+// parseMarkdownFile turns a daily Markdown file into items. Files written by our zip export
+// carry a notes list in the front matter and <!-- note:ID --> separators; anything else
+// (plain files, Obsidian daily notes) becomes a single note.
+func parseMarkdownFile(name, text string) []importItem {
+	fm, body := splitFrontMatter(strings.ReplaceAll(text, "\r\n", "\n"))
+
+	dateStr := fm.scalars["date"]
+	if dateStr == "" {
+		if m := dateInName.FindString(path.Base(name)); m != "" {
+			dateStr = m
+		}
+	}
+	d, err := time.Parse("2006-01-02", dateStr)
+	if err != nil {
+		return []importItem{{Source: name, Key: "md:" + name, Err: errors.New("no YYYY-MM-DD date in file name or front matter")}}
+	}
+
+	markers := noteMarker.FindAllStringSubmatchIndex(body, -1)
+	if len(markers) == 0 {
+		item := importItem{Source: name, Key: "md:" + name, NoteDate: d, Content: strings.TrimSpace(body)}
+		item.Starred = fm.scalars["starred"] == "true"
+		item.Hidden = fm.scalars["hidden"] == "true"
+		item.Tags = fm.lists["tags"]
+		if item.Content == "" {
+			item.Err = errors.New("file is empty")
+		}
+		return []importItem{item}
+	}
+
+	items := make([]importItem, 0, len(markers))
+	for i, m := range markers {
+		id := body[m[2]:m[3]]
+		end := len(body)
+		if i+1 < len(markers) {
+			end = markers[i+1][0]
+		}
+		item := importItem{
+			Source:   fmt.Sprintf("%s#%s", name, id),
+			Key:      exportedNoteKey(id, d), // same identity as the JSON export of that note
+			NoteDate: d,
+			Content:  strings.TrimSpace(body[m[1]:end]),
+		}
+		locked := false
+		if meta, ok := fm.notes[id]; ok {
+			item.Starred = meta.scalars["starred"] == "true"
+			item.Hidden = meta.scalars["hidden"] == "true"
+			item.Tags = meta.lists["tags"]
+			item.Encrypted = meta.scalars["encrypted"] == "true"
+			locked = meta.scalars["locked"] == "true"
+		}
+		switch {
+		case locked:
+			item.Err = errLockedExport
+		case item.Content == "":
+			item.Err = errors.New("note is empty")
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// exportedNoteKey identifies a note coming from one of our exports; the date is included so
+// ids from different organizer instances are unlikely to collide
+func exportedNoteKey(id string, d time.Time) string {
+	return "organizer:" + id + ":" + d.Format("2006-01-02")
+}
+
+// frontMatter is the small YAML subset we need: scalars, lists and our per-note list
+type frontMatter struct {
+	scalars map[string]string
+	lists   map[string][]string
+	notes   map[string]*frontMatter
+}
+
+func newFrontMatter() *frontMatter {
+	return &frontMatter{scalars: map[string]string{}, lists: map[string][]string{}, notes: map[string]*frontMatter{}}
+}
+
+// splitFrontMatter separates a leading "---" YAML block from the body
+func splitFrontMatter(text string) (*frontMatter, string) {
+	fm := newFrontMatter()
+	if !strings.HasPrefix(text, "---\n") {
+		return fm, text
+	}
+	end := strings.Index(text[4:], "\n---")
+	if end < 0 {
+		return fm, text
+	}
+	block := text[4 : 4+end]
+	body := strings.TrimPrefix(text[4+end+4:], "\n")
+
+	var current *frontMatter // entry of the notes list being filled
+	listKey := ""
+	for _, line := range strings.Split(block, "\n") {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		trimmed := strings.TrimSpace(line)
+
+		if indent == 0 {
+			current = nil
+			listKey = ""
+			key, value, ok := strings.Cut(trimmed, ":")
+			if !ok {
+				continue
+			}
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			if value == "" {
+				listKey = key
+				continue
+			}
+			if list, ok := parseFlowList(value); ok {
+				fm.lists[key] = list
+			} else {
+				fm.scalars[key] = unquoteYAML(value)
+			}
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "- ") {
+			entry := strings.TrimSpace(trimmed[2:])
+			if listKey == "notes" {
+				current = newFrontMatter()
+				if key, value, ok := strings.Cut(entry, ":"); ok {
+					current.scalars[strings.TrimSpace(key)] = unquoteYAML(strings.TrimSpace(value))
+				}
+				if id := current.scalars["id"]; id != "" {
+					fm.notes[id] = current
+				}
+				continue
+			}
+			if listKey != "" {
+				fm.lists[listKey] = append(fm.lists[listKey], unquoteYAML(entry))
+			}
+			continue
+		}
+
+		if current != nil {
+			key, value, ok := strings.Cut(trimmed, ":")
+			if !ok {
+				continue
+			}
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			if list, ok := parseFlowList(value); ok {
+				current.lists[key] = list
+			} else {
+				current.scalars[key] = unquoteYAML(value)
+			}
+			if key == "id" {
+				fm.notes[current.scalars["id"]] = current
+			}
+		}
+	}
+	return fm, body
+}
+
+// parseFlowList parses [a, "b", 'c']
+func parseFlowList(value string) ([]string, bool) {
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return nil, false
+	}
+	// Our own export writes JSON arrays, which are valid YAML
+	var list []string
+	if err := json.Unmarshal([]byte(value), &list); err == nil {
+		return list, true
+	}
+	list = []string{}
+	for _, part := range strings.Split(strings.Trim(value, "[]"), ",") {
+		if part = unquoteYAML(strings.TrimSpace(part)); part != "" {
+			list = append(list, part)
+		}
+	}
+	return list, true
+}
+
+func unquoteYAML(v string) string {
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		if s, err := strconv.Unquote(v); err == nil {
+			return s
+		}
+	}
+	if len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'' {
+		return strings.ReplaceAll(v[1:len(v)-1], "''", "'")
+	}
+	return v
+}
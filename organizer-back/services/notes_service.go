@@ -1,58 +1,166 @@
 package services
 
 import (
+	"errors"
+	"log"
 	"organizer-back/models"
 	"organizer-back/repository"
+	"sort"
+	"strings"
 	"time"
 )
 
+const calendarPreviewLength = 80
+
 type NotesService struct {
-	repo *repository.NoteRepository
+	repo        *repository.NoteRepository
+	shares      *repository.NoteShareRepository
+	users       *repository.UserRepository
+	audit       *repository.AuditRepository
+	links       *repository.NoteLinkRepository
+	emotions    *repository.EmotionRepository
+	attachments *AttachmentsService
+	templates   *TemplatesService
+	encryption  *EncryptionService
 }
 
-func NewNotesService() *NotesService {
-	return &NotesService{repo: repository.NewNoteRepository()}
+func NewNotesService(attachments *AttachmentsService, templates *TemplatesService, encryption *EncryptionService) *NotesService {
+	return &NotesService{
+		repo:        repository.NewNoteRepository(),
+		shares:      repository.NewNoteShareRepository(),
+		users:       repository.NewUserRepository(),
+		audit:       repository.NewAuditRepository(),
+		links:       repository.NewNoteLinkRepository(),
+		emotions:    repository.NewEmotionRepository(),
+		attachments: attachments,
+		templates:   templates,
+		encryption:  encryption,
+	}
 }
 
-func (s *NotesService) ListByUserAndDate(userID int, date string, includeHidden bool) ([]models.NoteResponse, error) {
+// ListByUserAndDate lists a day's notes in the user's manual order, or starred first when
+// sort is "starred". Encrypted notes are decrypted when grant unlocks them.
+func (s *NotesService) ListByUserAndDate(userID int, date string, includeHidden bool, sort, grant string) ([]models.NoteResponse, error) {
 	d, err := time.Parse("2006-01-02", date)
 	if err != nil {
 		return nil, err
 	}
-	notes, err := s.repo.ListByUserAndDate(userID, d, includeHidden)
+	switch sort {
+	case "", "manual":
+		sort = "manual"
+	case "starred":
+	default:
+		return nil, errors.New("invalid sort, expected manual or starred")
+	}
+	// A day seen for the first time may get the user's daily template note
+	if err := s.templates.EnsureDailyNote(userID, d); err != nil {
+		log.Printf("daily note for user %d on %s: %v", userID, date, err)
+	}
+	notes, err := s.repo.ListByUserAndDate(userID, d, includeHidden, sort)
 	if err != nil {
 		return nil, err
 	}
+	revealer := s.encryption.Revealer(userID, grant)
 	res := make([]models.NoteResponse, 0, len(notes))
 	for i := range notes {
+		revealer.Reveal(&notes[i])
 		res = append(res, notes[i].ToResponse())
 	}
 	return res, nil
 }
 
-func (s *NotesService) Create(userID int, req *models.NoteCreateRequest) (*models.NoteResponse, error) {
+func (s *NotesService) Create(userID int, req *models.NoteCreateRequest, grant string) (*models.NoteResponse, error) {
 	d, err := time.Parse("2006-01-02", req.NoteDate)
 	if err != nil {
 		return nil, err
 	}
-	n, err := s.repo.Create(userID, d, req.Content)
-	if err != nil {
+	n := &models.Note{UserID: userID, NoteDate: d, Content: req.Content, Tags: normalizeTags(req.Tags)}
+	if req.Encrypted {
+		key, err := s.encryption.DataKey(userID, grant)
+		if err != nil {
+			return nil, err
+		}
+		if n.Ciphertext, err = s.encryption.SealNote(userID, key, req.Content); err != nil {
+			return nil, err
+		}
+		n.Encrypted, n.Content = true, ""
+	}
+	if err := s.repo.Create(n); err != nil {
 		return nil, err
 	}
+	// Respond with the plaintext the client just sent
+	n.Content, n.Ciphertext = req.Content, nil
 	r := n.ToResponse()
 	return &r, nil
 }
 
-func (s *NotesService) Get(userID, id int) (*models.NoteResponse, error) {
-	n, err := s.repo.GetByID(userID, id)
+// Get returns a note the user owns or that has been shared with them
+func (s *NotesService) Get(userID, id int, grant string) (*models.NoteResponse, error) {
+	n, permission, err := s.repo.GetAccessible(userID, id)
 	if err != nil {
 		return nil, err
 	}
+	if permission != "owner" {
+		s.record(userID, "note.shared_view", id, map[string]interface{}{"permission": permission})
+	}
+	s.encryption.Revealer(userID, grant).Reveal(n)
 	r := n.ToResponse()
+	if permission == "owner" {
+		// Links may point at the owner's other notes, which shared users cannot see
+		if r.Links, err = s.links.ListOutgoing(userID, id); err != nil {
+			return nil, err
+		}
+	}
 	return &r, nil
 }
 
-func (s *NotesService) Update(userID, id int, req *models.NoteUpdateRequest) (*models.NoteResponse, error) {
+// Calendar summarizes a month of the user's notes per day. Hidden and encrypted notes are
+// counted but never used as preview. With includeMood, days get their average mood and days
+// with emotion entries but no notes are listed too.
+func (s *NotesService) Calendar(userID, year, month int, includeMood bool) (*models.NoteCalendarResponse, error) {
+	from := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	days, err := s.repo.CalendarMonth(userID, from, from.AddDate(0, 1, 0), calendarPreviewLength)
+	if err != nil {
+		return nil, err
+	}
+	if includeMood {
+		moods, err := s.emotions.DailyMood(userID, from, from.AddDate(0, 1, 0))
+		if err != nil {
+			return nil, err
+		}
+		for i := range days {
+			if mood, ok := moods[days[i].Date]; ok {
+				days[i].Mood = &mood
+				delete(moods, days[i].Date)
+			}
+		}
+		for date, mood := range moods {
+			mood := mood
+			days = append(days, models.NoteCalendarDay{Date: date, Mood: &mood})
+		}
+		sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	}
+	return &models.NoteCalendarResponse{Year: year, Month: month, Days: days}, nil
+}
+
+// Links resolves the [[...]] links of one of the caller's notes
+func (s *NotesService) Links(userID, id int) ([]models.NoteLink, error) {
+	if _, err := s.repo.GetByID(userID, id); err != nil {
+		return nil, err
+	}
+	return s.links.ListOutgoing(userID, id)
+}
+
+// Backlinks lists the caller's notes that link to one of their notes
+func (s *NotesService) Backlinks(userID, id int) ([]models.NoteBacklink, error) {
+	n, err := s.repo.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.links.ListBacklinks(userID, id, n.NoteDate)
+}
+
+func (s *NotesService) Update(userID, id int, req *models.NoteUpdateRequest, grant string) (*models.NoteResponse, error) {
 	var dptr *time.Time
 	if req.NoteDate != nil {
 		d, err := time.Parse("2006-01-02", *req.NoteDate)
@@ -61,14 +169,218 @@ func (s *NotesService) Update(userID, id int, req *models.NoteUpdateRequest) (*m
 		}
 		dptr = &d
 	}
-	n, err := s.repo.Update(userID, id, dptr, req.Content, req.Hidden, req.Starred)
+	current, permission, err := s.repo.GetAccessible(userID, id)
 	if err != nil {
 		return nil, err
 	}
+	switch permission {
+	case "viewer":
+		return nil, errors.New("forbidden")
+	case "editor":
+		// hidden/starred/tags organize the owner's own listing, so only the owner may change them
+		if req.Hidden != nil || req.Starred != nil || req.Tags != nil || req.Encrypted != nil {
+			return nil, errors.New("forbidden")
+		}
+	}
+	var tags *[]string
+	if req.Tags != nil {
+		normalized := normalizeTags(*req.Tags)
+		tags = &normalized
+	}
+	content, key, err := s.nextContent(current, req, grant)
+	if err != nil {
+		return nil, err
+	}
+	n, err := s.repo.Update(current.UserID, id, dptr, content, req.Hidden, req.Starred, tags)
+	if err != nil {
+		return nil, err
+	}
+	if permission != "owner" {
+		s.record(userID, "note.shared_update", id, map[string]interface{}{"permission": permission})
+	}
+	if n.Encrypted && key != nil {
+		if n.Content, err = s.encryption.OpenNote(n.UserID, key, n.Ciphertext); err == nil {
+			n.Ciphertext = nil
+		}
+	}
 	r := n.ToResponse()
 	return &r, nil
 }
 
+// nextContent works out the stored content after an update, encrypting or decrypting when
+// the content or the encrypted flag changes. It returns nil when the content is untouched,
+// and the data key when one was needed.
+func (s *NotesService) nextContent(current *models.Note, req *models.NoteUpdateRequest, grant string) (*repository.NoteContent, []byte, error) {
+	encrypt := current.Encrypted
+	if req.Encrypted != nil {
+		encrypt = *req.Encrypted
+	}
+	if req.Content == nil && encrypt == current.Encrypted {
+		return nil, nil, nil
+	}
+	var key []byte
+	if encrypt || current.Encrypted {
+		k, err := s.encryption.DataKey(current.UserID, grant)
+		if err != nil {
+			return nil, nil, err
+		}
+		key = k
+	}
+	plaintext := current.Content
+	if req.Content != nil {
+		plaintext = *req.Content
+	} else if current.Encrypted {
+		p, err := s.encryption.OpenNote(current.UserID, key, current.Ciphertext)
+		if err != nil {
+			return nil, nil, err
+		}
+		plaintext = p
+	}
+	if !encrypt {
+		return &repository.NoteContent{Content: plaintext}, key, nil
+	}
+	sealed, err := s.encryption.SealNote(current.UserID, key, plaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &repository.NoteContent{Encrypted: true, Ciphertext: sealed}, key, nil
+}
+
+// Delete removes a note; shared users (even editors) cannot delete it
 func (s *NotesService) Delete(userID, id int) error {
-	return s.repo.Delete(userID, id)
+	_, permission, err := s.repo.GetAccessible(userID, id)
+	if err != nil {
+		return err
+	}
+	if permission != "owner" {
+		return errors.New("forbidden")
+	}
+	// Collect blob keys first: the attachment rows go away with the note (ON DELETE CASCADE)
+	keys, err := s.attachments.StorageKeysForNote(id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(userID, id); err != nil {
+		return err
+	}
+	s.attachments.PurgeBlobs(keys)
+	return nil
+}
+
+// Move reorders one of the caller's notes relative to another; moving next to a note of a
+// different day also moves the note to that day
+func (s *NotesService) Move(userID, id int, req *models.NoteMoveRequest, grant string) (*models.NoteResponse, error) {
+	var targetID int
+	after := false
+	switch {
+	case req.Before != nil && req.After != nil:
+		return nil, errors.New("only one of before or after may be set")
+	case req.Before != nil:
+		targetID = *req.Before
+	case req.After != nil:
+		targetID, after = *req.After, true
+	default:
+		return nil, errors.New("before or after is required")
+	}
+	if _, err := s.repo.GetByID(userID, id); err != nil {
+		return nil, err
+	}
+	n, err := s.repo.Move(userID, id, targetID, after)
+	if err != nil {
+		return nil, err
+	}
+	s.encryption.Revealer(userID, grant).Reveal(n)
+	r := n.ToResponse()
+	return &r, nil
+}
+
+// Share grants another user viewer or editor access to a note the caller owns
+func (s *NotesService) Share(ownerID, noteID int, req *models.NoteShareRequest) (*models.NoteShare, error) {
+	note, err := s.repo.GetByID(ownerID, noteID)
+	if err != nil {
+		return nil, err
+	}
+	if note.Encrypted {
+		return nil, errors.New("encrypted notes cannot be shared")
+	}
+	target, err := s.resolveShareTarget(req)
+	if err != nil {
+		return nil, err
+	}
+	if target.ID == ownerID {
+		return nil, errors.New("cannot share a note with yourself")
+	}
+	share, err := s.shares.Upsert(noteID, target.ID, req.Permission)
+	if err != nil {
+		return nil, err
+	}
+	s.record(ownerID, "note.share", noteID, map[string]interface{}{"user_id": target.ID, "permission": req.Permission})
+	return share, nil
+}
+
+// Unshare revokes a user's access to a note the caller owns
+func (s *NotesService) Unshare(ownerID, noteID, targetUserID int) error {
+	if _, err := s.repo.GetByID(ownerID, noteID); err != nil {
+		return err
+	}
+	if err := s.shares.Delete(noteID, targetUserID); err != nil {
+		return err
+	}
+	s.record(ownerID, "note.unshare", noteID, map[string]interface{}{"user_id": targetUserID})
+	return nil
+}
+
+func (s *NotesService) ListShares(ownerID, noteID int) ([]models.NoteShare, error) {
+	if _, err := s.repo.GetByID(ownerID, noteID); err != nil {
+		return nil, err
+	}
+	return s.shares.ListByNote(noteID)
+}
+
+func (s *NotesService) SharedWithMe(userID int, includeHidden bool) ([]models.SharedNoteResponse, error) {
+	return s.shares.ListSharedWith(userID, includeHidden)
+}
+
+// Activity returns the audit trail of a note; only the owner can read it
+func (s *NotesService) Activity(ownerID, noteID int) ([]models.AuditEntry, error) {
+	if _, err := s.repo.GetByID(ownerID, noteID); err != nil {
+		return nil, err
+	}
+	return s.audit.ListByEntity("note", noteID, 100)
+}
+
+func (s *NotesService) resolveShareTarget(req *models.NoteShareRequest) (*models.User, error) {
+	switch {
+	case req.UserID != 0:
+		return s.users.GetUserByID(req.UserID)
+	case req.Username != "":
+		return s.users.GetUserByUsername(req.Username)
+	default:
+		return nil, errors.New("user_id or username is required")
+	}
+}
+
+// record writes to the audit trail; failing to audit must not fail the user's request
+func (s *NotesService) record(actorID int, action string, noteID int, details map[string]interface{}) {
+	if err := s.audit.Record(actorID, action, "note", noteID, details); err != nil {
+		log.Printf("audit: %v", err)
+	}
+}
+
+// normalizeTags lowercases, strips a leading '#', drops empties and duplicates, keeping order
+func normalizeTags(in []string) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, t := range in {
+		t = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(t), "#")))
+		if r := []rune(t); len(r) > 50 {
+			t = string(r[:50])
+		}
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
 }
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"organizer-back/models"
+	"organizer-back/repository"
+	"time"
+)
+
+const (
+	defaultPomodoroPollSeconds = 2
+	pomodoroAdvanceBatch       = 100
+	// pomodoroTimerEvent is the SSE event type carrying a PomodoroTimerResponse
+	pomodoroTimerEvent = "pomodoro_timer"
+)
+
+// Timer returns the caller's timer as of now
+func (s *PomodorosService) Timer(userID int) (*models.PomodoroTimerResponse, error) {
+	u, err := s.repo.GetTimer(userID)
+	if err != nil {
+		return nil, err
+	}
+	return timerResponse(u, time.Now()), nil
+}
+
+// StartTimer begins a new cycle with a running focus phase. Settings left out of the request
+// come from the user's pomodoro preferences.
+func (s *PomodorosService) StartTimer(userID int, req *models.PomodoroTimerStartRequest) (*models.PomodoroTimerResponse, error) {
+	if err := s.checkTodo(userID, req.TodoID); err != nil {
+		return nil, err
+	}
+	loc := userLocation(s.users, userID)
+	prefs := s.preferences.Values(userID, "pomodoro")
+	settings := req.PomodoroSettings
+	for _, p := range []struct {
+		value *int
+		key   string
+	}{
+		{&settings.FocusMinutes, "focusMinutes"},
+		{&settings.ShortBreakMinutes, "shortBreakMinutes"},
+		{&settings.LongBreakMinutes, "longBreakMinutes"},
+		{&settings.LongBreakInterval, "longBreakInterval"},
+		{&settings.IterationsPerCycle, "iterationsPerCycle"},
+	} {
+		if n, ok := prefs[p.key].(int); ok && *p.value == 0 {
+			*p.value = n
+		}
+	}
+	autoContinue, _ := prefs["autoContinue"].(bool)
+	if req.AutoContinue != nil {
+		autoContinue = *req.AutoContinue
+	}
+	return s.command(userID, func(t *models.PomodoroTimer, c *models.PomodoroCycle, now time.Time) (*repository.TimerChange, error) {
+		if t.Status != "idle" {
+			return nil, errors.New("timer is already running")
+		}
+		cycle := &models.PomodoroCycle{
+			UserID:           userID,
+			TodoID:           req.TodoID,
+			PomodoroSettings: withPomodoroDefaults(settings),
+			StartedAt:        now,
+			CycleDate:        localDate(now, loc),
+		}
+		t.AutoContinue = autoContinue
+		beginPhase(t, cycle.PomodoroSettings, "focus", 1, now, true)
+		return &repository.TimerChange{NewCycle: cycle}, nil
+	})
+}
+
+// PauseTimer stops the countdown; pausing a focus phase counts as an interruption
+func (s *PomodorosService) PauseTimer(userID int) (*models.PomodoroTimerResponse, error) {
+	return s.command(userID, func(t *models.PomodoroTimer, c *models.PomodoroCycle, now time.Time) (*repository.TimerChange, error) {
+		if t.Status != "running" {
+			return nil, errors.New("timer is not running")
+		}
+		t.Status = "paused"
+		t.RemainingSeconds = secondsUntil(*t.PhaseEndsAt, now)
+		t.PhaseEndsAt = nil
+		if t.Phase == "focus" {
+			t.Interruptions++
+		}
+		return nil, nil
+	})
+}
+
+// ResumeTimer restarts the countdown of a paused phase, or starts a phase that is waiting
+// because auto-continue is off
+func (s *PomodorosService) ResumeTimer(userID int) (*models.PomodoroTimerResponse, error) {
+	return s.command(userID, func(t *models.PomodoroTimer, c *models.PomodoroCycle, now time.Time) (*repository.TimerChange, error) {
+		if t.Status != "paused" {
+			return nil, errors.New("timer is not paused")
+		}
+		ends := now.Add(time.Duration(t.RemainingSeconds) * time.Second)
+		t.Status = "running"
+		t.PhaseEndsAt = &ends
+		if t.PhaseStartedAt == nil {
+			t.PhaseStartedAt = &now
+		}
+		return nil, nil
+	})
+}
+
+// SkipTimer ends the current phase early and moves on to the next one
+func (s *PomodorosService) SkipTimer(userID int) (*models.PomodoroTimerResponse, error) {
+	loc := userLocation(s.users, userID)
+	return s.command(userID, func(t *models.PomodoroTimer, c *models.PomodoroCycle, now time.Time) (*repository.TimerChange, error) {
+		if t.Status == "idle" {
+			return nil, errors.New("timer is not running")
+		}
+		change := &repository.TimerChange{}
+		if iv := finishPhase(t, c, now, false, loc); iv != nil {
+			change.Intervals = append(change.Intervals, *iv)
+		}
+		advancePhase(t, c, now, t.AutoContinue, change)
+		return change, nil
+	})
+}
+
+// StopTimer ends the cycle; the unfinished phase is recorded as not completed
+func (s *PomodorosService) StopTimer(userID int) (*models.PomodoroTimerResponse, error) {
+	loc := userLocation(s.users, userID)
+	return s.command(userID, func(t *models.PomodoroTimer, c *models.PomodoroCycle, now time.Time) (*repository.TimerChange, error) {
+		if t.Status == "idle" {
+			return nil, errors.New("timer is not running")
+		}
+		change := &repository.TimerChange{EndCycle: &now}
+		if iv := finishPhase(t, c, now, false, loc); iv != nil {
+			change.Intervals = append(change.Intervals, *iv)
+		}
+		resetTimer(t)
+		return change, nil
+	})
+}
+
+// command applies a transition at the current server time and broadcasts the new state
+func (s *PomodorosService) command(userID int, apply func(t *models.PomodoroTimer, c *models.PomodoroCycle, now time.Time) (*repository.TimerChange, error)) (*models.PomodoroTimerResponse, error) {
+	var now time.Time
+	u, err := s.repo.ApplyTimer(userID, func(t *models.PomodoroTimer, c *models.PomodoroCycle) (*repository.TimerChange, error) {
+		now = time.Now()
+		if c == nil && t.Status != "idle" {
+			// Its cycle was deleted
+			resetTimer(t)
+		}
+		return apply(t, c, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := timerResponse(u, now)
+	s.publish(res)
+	return res, nil
+}
+
+// StartScheduler moves timers to their next phase when the running one ends, whether or
+// not any client is connected. Rows are claimed with SKIP LOCKED, so several instances
+// can run it.
+func (s *PomodorosService) StartScheduler() {
+	go func() {
+		t := time.NewTicker(s.pollInterval)
+		defer t.Stop()
+		for {
+			s.advanceDue()
+			<-t.C
+		}
+	}()
+}
+
+func (s *PomodorosService) advanceDue() {
+	for {
+		now := time.Now()
+		updates, err := s.repo.AdvanceDue(now, pomodoroAdvanceBatch, func(t *models.PomodoroTimer, c *models.PomodoroCycle) (*repository.TimerChange, error) {
+			if c == nil {
+				resetTimer(t)
+				return nil, nil
+			}
+			loc := userLocation(s.users, t.UserID)
+			change := &repository.TimerChange{}
+			// Catch up on every phase that ended, e.g. after all instances were down
+			for t.Status == "running" && !t.PhaseEndsAt.After(now) {
+				ended := *t.PhaseEndsAt
+				if iv := finishPhase(t, c, ended, true, loc); iv != nil {
+					change.Intervals = append(change.Intervals, *iv)
+				}
+				advancePhase(t, c, ended, t.AutoContinue, change)
+			}
+			return change, nil
+		})
+		if err != nil {
+			log.Printf("pomodoro timers: %v", err)
+			return
+		}
+		for i := range updates {
+			s.publish(timerResponse(&updates[i], now))
+		}
+		if len(updates) < pomodoroAdvanceBatch {
+			return
+		}
+	}
+}
+
+func (s *PomodorosService) publish(res *models.PomodoroTimerResponse) {
+	if err := s.broker.Publish(res.UserID, pomodoroTimerEvent, res); err != nil {
+		log.Printf("pomodoro timers: publishing to user %d: %v", res.UserID, err)
+	}
+}
+
+// finishPhase returns the interval for the phase ending at end, nil if it never ran
+func finishPhase(t *models.PomodoroTimer, c *models.PomodoroCycle, end time.Time, completed bool, loc *time.Location) *models.PomodoroInterval {
+	if t.PhaseStartedAt == nil || c == nil {
+		return nil
+	}
+	planned := phaseSeconds(c.PomodoroSettings, t.Phase)
+	remaining := t.RemainingSeconds
+	if t.Status == "running" {
+		remaining = secondsUntil(*t.PhaseEndsAt, end)
+	}
+	actual := planned - remaining
+	if actual < 0 {
+		actual = 0
+	}
+	return &models.PomodoroInterval{
+		CycleID:        c.ID,
+		TodoID:         c.TodoID,
+		Phase:          t.Phase,
+		Iteration:      t.Iteration,
+		PlannedSeconds: planned,
+		ActualSeconds:  actual,
+		Interruptions:  t.Interruptions,
+		Completed:      completed,
+		StartedAt:      *t.PhaseStartedAt,
+		EndedAt:        end,
+		IntervalDate:   localDate(*t.PhaseStartedAt, loc),
+	}
+}
+
+// advancePhase moves the timer to the phase after the current one at time at; after the
+// last break the cycle is over
+func advancePhase(t *models.PomodoroTimer, c *models.PomodoroCycle, at time.Time, run bool, change *repository.TimerChange) {
+	phase, iteration, done := nextPomodoroPhase(c.PomodoroSettings, t.Phase, t.Iteration)
+	if done {
+		change.EndCycle = &at
+		resetTimer(t)
+		return
+	}
+	beginPhase(t, c.PomodoroSettings, phase, iteration, at, run)
+}
+
+// nextPomodoroPhase follows the timer component: a break after each focus interval, long
+// every LongBreakInterval iterations, and the cycle ends with the break of its last iteration
+func nextPomodoroPhase(p models.PomodoroSettings, phase string, iteration int) (string, int, bool) {
+	if phase != "focus" {
+		if iteration >= p.IterationsPerCycle {
+			return "", 0, true
+		}
+		return "focus", iteration + 1, false
+	}
+	if iteration%p.LongBreakInterval == 0 {
+		return "long", iteration, false
+	}
+	return "short", iteration, false
+}
+
+// beginPhase sets up a phase at time at, counting down when run and waiting otherwise
+func beginPhase(t *models.PomodoroTimer, p models.PomodoroSettings, phase string, iteration int, at time.Time, run bool) {
+	t.Phase = phase
+	t.Iteration = iteration
+	t.Interruptions = 0
+	t.RemainingSeconds = phaseSeconds(p, phase)
+	if run {
+		ends := at.Add(time.Duration(t.RemainingSeconds) * time.Second)
+		t.Status = "running"
+		t.PhaseStartedAt = &at
+		t.PhaseEndsAt = &ends
+		return
+	}
+	t.Status = "paused"
+	t.PhaseStartedAt = nil
+	t.PhaseEndsAt = nil
+}
+
+func resetTimer(t *models.PomodoroTimer) {
+	t.CycleID = nil
+	t.Status = "idle"
+	t.Phase = "focus"
+	t.Iteration = 1
+	t.PhaseStartedAt = nil
+	t.PhaseEndsAt = nil
+	t.RemainingSeconds = 0
+	t.Interruptions = 0
+}
+
+func phaseSeconds(p models.PomodoroSettings, phase string) int {
+	switch phase {
+	case "short":
+		return p.ShortBreakMinutes * 60
+	case "long":
+		return p.LongBreakMinutes * 60
+	default:
+		return p.FocusMinutes * 60
+	}
+}
+
+// secondsUntil rounds the time left up, so a countdown never shows 0 before the phase ends
+func secondsUntil(end, now time.Time) int {
+	d := end.Sub(now)
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
+
+func timerResponse(u *repository.TimerUpdate, now time.Time) *models.PomodoroTimerResponse {
+	res := &models.PomodoroTimerResponse{PomodoroTimer: u.Timer, ServerTime: now}
+	t := &u.Timer
+	if u.Cycle != nil {
+		settings := u.Cycle.PomodoroSettings
+		res.Settings = &settings
+		res.TodoID = u.Cycle.TodoID
+		res.PlannedSeconds = phaseSeconds(settings, t.Phase)
+	}
+	switch t.Status {
+	case "running":
+		res.RemainingSeconds = secondsUntil(*t.PhaseEndsAt, now)
+	case "paused":
+		res.RemainingSeconds = t.RemainingSeconds
+	}
+	res.CompletedIterations = t.Iteration - 1
+	if t.Phase != "focus" {
+		res.CompletedIterations = t.Iteration
+	}
+	if t.Status == "idle" {
+		res.CompletedIterations = 0
+	}
+	return res
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"errors"
+	"organizer-back/models"
+	"organizer-back/realtime"
+	"organizer-back/repository"
+	"time"
+)
+
+// Defaults of the timer component
+var defaultPomodoroSettings = models.PomodoroSettings{
+	FocusMinutes:       25,
+	ShortBreakMinutes:  5,
+	LongBreakMinutes:   15,
+	LongBreakInterval:  4,
+	IterationsPerCycle: 4,
+}
+
+const (
+	pomodoroDailyStatsDays   = 14
+	pomodoroWeeklyStatsWeeks = 12
+)
+
+type PomodorosService struct {
+	repo         *repository.PomodoroRepository
+	todos        *repository.TodoRepository
+	users        *repository.UserRepository
+	preferences  *PreferencesService
+	broker       *realtime.Broker
+	pollInterval time.Duration
+}
+
+// NewPomodorosService publishes timer changes through broker; the timer takes the settings
+// a start command leaves out from the user's pomodoro preferences
+func NewPomodorosService(broker *realtime.Broker, preferences *PreferencesService) *PomodorosService {
+	return &PomodorosService{
+		repo:         repository.NewPomodoroRepository(),
+		todos:        repository.NewTodoRepository(),
+		users:        repository.NewUserRepository(),
+		preferences:  preferences,
+		broker:       broker,
+		pollInterval: time.Duration(envInt64("POMODORO_POLL_SECONDS", defaultPomodoroPollSeconds)) * time.Second,
+	}
+}
+
+// ListByDate returns the cycles started on a YYYY-MM-DD day, with their intervals
+func (s *PomodorosService) ListByDate(userID int, date string) ([]models.PomodoroCycleResponse, error) {
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return nil, errors.New("invalid date")
+	}
+	cycles, err := s.repo.ListByDate(userID, d)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]models.PomodoroCycleResponse, len(cycles))
+	for i := range cycles {
+		res[i] = cycles[i].ToResponse()
+	}
+	return res, nil
+}
+
+func (s *PomodorosService) Get(userID, id int) (*models.PomodoroCycleResponse, error) {
+	c, err := s.repo.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	res := c.ToResponse()
+	return &res, nil
+}
+
+// Create records a cycle and any intervals sent with it. now is the current time in the
+// caller's zone; cycles and intervals are dated by their start in that zone.
+func (s *PomodorosService) Create(userID int, req *models.PomodoroCycleRequest, now time.Time) (*models.PomodoroCycleResponse, error) {
+	c := &models.PomodoroCycle{UserID: userID, PomodoroSettings: withPomodoroDefaults(req.PomodoroSettings), StartedAt: now}
+	if req.StartedAt != "" {
+		t, err := time.Parse(time.RFC3339, req.StartedAt)
+		if err != nil {
+			return nil, errors.New("invalid started_at")
+		}
+		c.StartedAt = t
+	}
+	if req.EndedAt != "" {
+		t, err := time.Parse(time.RFC3339, req.EndedAt)
+		if err != nil {
+			return nil, errors.New("invalid ended_at")
+		}
+		if t.Before(c.StartedAt) {
+			return nil, errors.New("ended_at must not be before started_at")
+		}
+		c.EndedAt = &t
+	}
+	if err := s.checkTodo(userID, req.TodoID); err != nil {
+		return nil, err
+	}
+	c.TodoID = req.TodoID
+	c.CycleDate = localDate(c.StartedAt, now.Location())
+
+	intervals := make([]models.PomodoroInterval, len(req.Intervals))
+	for i := range req.Intervals {
+		iv, err := s.interval(userID, c, &req.Intervals[i], now.Location())
+		if err != nil {
+			return nil, err
+		}
+		intervals[i] = *iv
+	}
+	if err := s.repo.Create(c, intervals); err != nil {
+		return nil, err
+	}
+	res := c.ToResponse()
+	return &res, nil
+}
+
+// Update ends a cycle or changes its todo
+func (s *PomodorosService) Update(userID, id int, req *models.PomodoroCycleUpdateRequest) (*models.PomodoroCycleResponse, error) {
+	c, err := s.repo.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.TodoID != nil {
+		if *req.TodoID == 0 {
+			c.TodoID = nil
+		} else {
+			if err := s.checkTodo(userID, req.TodoID); err != nil {
+				return nil, err
+			}
+			c.TodoID = req.TodoID
+		}
+	}
+	if req.EndedAt != nil {
+		t, err := time.Parse(time.RFC3339, *req.EndedAt)
+		if err != nil {
+			return nil, errors.New("invalid ended_at")
+		}
+		if t.Before(c.StartedAt) {
+			return nil, errors.New("ended_at must not be before started_at")
+		}
+		c.EndedAt = &t
+	}
+	if err := s.repo.Update(c); err != nil {
+		return nil, err
+	}
+	res := c.ToResponse()
+	return &res, nil
+}
+
+func (s *PomodorosService) Delete(userID, id int) error {
+	return s.repo.Delete(userID, id)
+}
+
+// AddInterval records a focus period or break of one of the caller's cycles
+func (s *PomodorosService) AddInterval(userID, cycleID int, req *models.PomodoroIntervalRequest, now time.Time) (*models.PomodoroInterval, error) {
+	c, err := s.repo.GetByID(userID, cycleID)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := s.interval(userID, c, req, now.Location())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.AddInterval(userID, iv); err != nil {
+		return nil, err
+	}
+	return iv, nil
+}
+
+func (s *PomodorosService) interval(userID int, c *models.PomodoroCycle, req *models.PomodoroIntervalRequest, loc *time.Location) (*models.PomodoroInterval, error) {
+	started, err := time.Parse(time.RFC3339, req.StartedAt)
+	if err != nil {
+		return nil, errors.New("invalid interval started_at")
+	}
+	ended, err := time.Parse(time.RFC3339, req.EndedAt)
+	if err != nil {
+		return nil, errors.New("invalid interval ended_at")
+	}
+	if ended.Before(started) {
+		return nil, errors.New("interval ended_at must not be before started_at")
+	}
+	if started.Before(c.StartedAt) {
+		return nil, errors.New("interval cannot start before its cycle")
+	}
+	iv := &models.PomodoroInterval{
+		CycleID:        c.ID,
+		TodoID:         c.TodoID,
+		Phase:          req.Phase,
+		Iteration:      req.Iteration,
+		PlannedSeconds: req.PlannedSeconds,
+		ActualSeconds:  int(ended.Sub(started) / time.Second),
+		Interruptions:  req.Interruptions,
+		StartedAt:      started,
+		EndedAt:        ended,
+		IntervalDate:   localDate(started, loc),
+	}
+	if iv.Iteration == 0 {
+		iv.Iteration = 1
+	}
+	// Pauses make the elapsed time longer than the time actually run
+	if req.ActualSeconds != nil {
+		iv.ActualSeconds = *req.ActualSeconds
+	}
+	iv.Completed = iv.ActualSeconds >= iv.PlannedSeconds
+	if req.Completed != nil {
+		iv.Completed = *req.Completed
+	}
+	if req.TodoID != nil {
+		if err := s.checkTodo(userID, req.TodoID); err != nil {
+			return nil, err
+		}
+		iv.TodoID = req.TodoID
+	}
+	return iv, nil
+}
+
+// FocusStats aggregates focus time per day (last 14 days by default) or per week (last 12
+// weeks); now is the current time in the caller's zone
+func (s *PomodorosService) FocusStats(userID int, q *models.PomodoroStatsQuery, now time.Time) ([]models.PomodoroFocusPoint, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	group := q.Group
+	if group == "" {
+		group = "day"
+	}
+	defFrom := today.AddDate(0, 0, -(pomodoroDailyStatsDays - 1))
+	if group == "week" {
+		defFrom = mondayOf(today).AddDate(0, 0, -7*(pomodoroWeeklyStatsWeeks-1))
+	}
+	from, to, err := dateRange(q.From, q.To, today, defFrom)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.FocusStats(userID, group, from, to)
+}
+
+func (s *PomodorosService) checkTodo(userID int, todoID *int) error {
+	if todoID == nil {
+		return nil
+	}
+	_, err := s.todos.GetByID(userID, *todoID)
+	return err
+}
+
+func withPomodoroDefaults(p models.PomodoroSettings) models.PomodoroSettings {
+	if p.FocusMinutes == 0 {
+		p.FocusMinutes = defaultPomodoroSettings.FocusMinutes
+	}
+	if p.ShortBreakMinutes == 0 {
+		p.ShortBreakMinutes = defaultPomodoroSettings.ShortBreakMinutes
+	}
+	if p.LongBreakMinutes == 0 {
+		p.LongBreakMinutes = defaultPomodoroSettings.LongBreakMinutes
+	}
+	if p.LongBreakInterval == 0 {
+		p.LongBreakInterval = defaultPomodoroSettings.LongBreakInterval
+	}
+	if p.IterationsPerCycle == 0 {
+		p.IterationsPerCycle = defaultPomodoroSettings.IterationsPerCycle
+	}
+	return p
+}
+
+// localDate is the day of t in loc as a UTC midnight, the way dates are stored
+func localDate(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+)
+
+// prefField describes one preference key; integers are bounded by Min and Max and strings
+// limited to Enum or matched by Pattern
+type prefField struct {
+	Type    string // integer, boolean or string
+	Default interface{}
+	Min     int
+	Max     int
+	Enum    []string
+	Pattern *regexp.Regexp
+}
+
+// prefSchema is the current shape of a namespace. Bump Version whenever a key is renamed or
+// its meaning changes, and add an upgrade from the previous version so stored data keeps
+// working.
+type prefSchema struct {
+	Version int
+	Fields  map[string]prefField
+	// upgrades[v] turns data written under version v into version v+1
+	upgrades map[int]func(map[string]interface{}) map[string]interface{}
+}
+
+// preferenceSchemas lists the namespaces clients may store. Keys follow the frontend's
+// naming, as its components read them directly.
+var preferenceSchemas = map[string]*prefSchema{
+	"pomodoro": {
+		Version: 1,
+		Fields: map[string]prefField{
+			"focusMinutes":       {Type: "integer", Default: 25, Min: 1, Max: 180},
+			"shortBreakMinutes":  {Type: "integer", Default: 5, Min: 1, Max: 60},
+			"longBreakMinutes":   {Type: "integer", Default: 15, Min: 1, Max: 120},
+			"longBreakInterval":  {Type: "integer", Default: 4, Min: 1, Max: 12},
+			"iterationsPerCycle": {Type: "integer", Default: 4, Min: 1, Max: 24},
+			"extraIterations":    {Type: "integer", Default: 2, Min: 0, Max: 10},
+			"autoContinue":       {Type: "boolean", Default: true},
+		},
+	},
+	"calendar": {
+		Version: 1,
+		Fields: map[string]prefField{
+			"view":      {Type: "string", Default: "month", Enum: []string{"month", "week", "day"}},
+			"weekStart": {Type: "string", Default: "monday", Enum: []string{"monday", "sunday"}},
+		},
+	},
+	"general": {
+		Version: 1,
+		Fields: map[string]prefField{
+			"locale": {Type: "string", Default: "es", Pattern: regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)},
+			"theme":  {Type: "string", Default: "system", Enum: []string{"light", "dark", "system"}},
+		},
+	},
+}
+
+// defaults returns the built-in value of every key
+func (s *prefSchema) defaults() map[string]interface{} {
+	values := make(map[string]interface{}, len(s.Fields))
+	for key, f := range s.Fields {
+		values[key] = f.Default
+	}
+	return values
+}
+
+// upgrade brings data written under version up to the current version
+func (s *prefSchema) upgrade(data map[string]interface{}, version int) map[string]interface{} {
+	for v := version; v < s.Version; v++ {
+		if up := s.upgrades[v]; up != nil {
+			data = up(data)
+		}
+	}
+	return data
+}
+
+// validate checks every key of data against the schema and normalizes JSON numbers to int
+func (s *prefSchema) validate(data map[string]interface{}) error {
+	for key, value := range data {
+		f, ok := s.Fields[key]
+		if !ok {
+			return fmt.Errorf("unknown preference %q", key)
+		}
+		v, err := f.check(value)
+		if err != nil {
+			return fmt.Errorf("invalid preference %q: %v", key, err)
+		}
+		data[key] = v
+	}
+	return nil
+}
+
+// sanitize drops the keys of stored data that no longer validate, e.g. after a bound was
+// tightened, so they fall back to the defaults
+func (s *prefSchema) sanitize(data map[string]interface{}) map[string]interface{} {
+	clean := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if f, ok := s.Fields[key]; ok {
+			if v, err := f.check(value); err == nil {
+				clean[key] = v
+			}
+		}
+	}
+	return clean
+}
+
+func (f prefField) check(value interface{}) (interface{}, error) {
+	switch f.Type {
+	case "integer":
+		var n float64
+		switch v := value.(type) {
+		case float64:
+			n = v
+		case int:
+			n = float64(v)
+		default:
+			return nil, fmt.Errorf("must be an integer")
+		}
+		if n != math.Trunc(n) {
+			return nil, fmt.Errorf("must be an integer")
+		}
+		if n < float64(f.Min) || n > float64(f.Max) {
+			return nil, fmt.Errorf("must be between %d and %d", f.Min, f.Max)
+		}
+		return int(n), nil
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return nil, fmt.Errorf("must be a boolean")
+		}
+		return value, nil
+	default:
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a string")
+		}
+		if len(f.Enum) > 0 {
+			for _, e := range f.Enum {
+				if e == str {
+					return str, nil
+				}
+			}
+			return nil, fmt.Errorf("must be one of %v", f.Enum)
+		}
+		if f.Pattern != nil && !f.Pattern.MatchString(str) {
+			return nil, fmt.Errorf("has an invalid format")
+		}
+		return str, nil
+	}
+}
+
+// jsonSchema renders the namespace as a JSON Schema document
+func (s *prefSchema) jsonSchema() map[string]interface{} {
+	properties := map[string]interface{}{}
+	for key, f := range s.Fields {
+		p := map[string]interface{}{"type": f.Type, "default": f.Default}
+		switch {
+		case f.Type == "integer":
+			p["minimum"], p["maximum"] = f.Min, f.Max
+		case len(f.Enum) > 0:
+			p["enum"] = f.Enum
+		case f.Pattern != nil:
+			p["pattern"] = f.Pattern.String()
+		}
+		properties[key] = p
+	}
+	return map[string]interface{}{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           properties,
+	}
+}
+
+func preferenceNamespaces() []string {
+	names := make([]string, 0, len(preferenceSchemas))
+	for name := range preferenceSchemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mergePatch applies an RFC 7386 JSON merge patch: null removes a key, objects merge
+// recursively and any other value replaces the target's
+func mergePatch(target, patch map[string]interface{}) map[string]interface{} {
+	if target == nil {
+		target = map[string]interface{}{}
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+		if obj, ok := value.(map[string]interface{}); ok {
+			sub, _ := target[key].(map[string]interface{})
+			target[key] = mergePatch(sub, obj)
+			continue
+		}
+		target[key] = value
+	}
+	return target
+}
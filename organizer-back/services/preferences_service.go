@@ -0,0 +1,166 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"organizer-back/models"
+	"organizer-back/repository"
+)
+
+type PreferencesService struct {
+	repo *repository.PreferenceRepository
+}
+
+func NewPreferencesService() *PreferencesService {
+	return &PreferencesService{repo: repository.NewPreferenceRepository()}
+}
+
+// Schemas describes every namespace so clients can validate before saving
+func (s *PreferencesService) Schemas() []models.PreferenceSchema {
+	out := []models.PreferenceSchema{}
+	for _, name := range preferenceNamespaces() {
+		schema := preferenceSchemas[name]
+		out = append(out, models.PreferenceSchema{Namespace: name, Version: schema.Version, Schema: schema.jsonSchema()})
+	}
+	return out
+}
+
+// All returns the user's preferences of every namespace
+func (s *PreferencesService) All(userID int) ([]models.PreferencesResponse, error) {
+	out := []models.PreferencesResponse{}
+	for _, name := range preferenceNamespaces() {
+		res, err := s.Get(userID, name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *res)
+	}
+	return out, nil
+}
+
+// Get returns a namespace as the user sees it: built-in defaults, overridden by the admin
+// defaults, overridden by what the user set
+func (s *PreferencesService) Get(userID int, namespace string) (*models.PreferencesResponse, error) {
+	schema, err := preferenceSchema(namespace)
+	if err != nil {
+		return nil, err
+	}
+	defaults, err := s.repo.GetDefaults(namespace)
+	if err != nil {
+		return nil, err
+	}
+	own, err := s.repo.GetUser(userID, namespace)
+	if err != nil {
+		return nil, err
+	}
+	res := &models.PreferencesResponse{Namespace: namespace, SchemaVersion: schema.Version, Overrides: stored(schema, own)}
+	res.Values = mergePatch(mergePatch(schema.defaults(), stored(schema, defaults)), res.Overrides)
+	if own != nil {
+		res.UpdatedAt = &own.UpdatedAt
+	}
+	return res, nil
+}
+
+// Values returns the effective preferences of a namespace, the built-in defaults if they
+// cannot be loaded
+func (s *PreferencesService) Values(userID int, namespace string) map[string]interface{} {
+	res, err := s.Get(userID, namespace)
+	if err != nil {
+		log.Printf("preferences %s of user %d: %v", namespace, userID, err)
+		return preferenceSchemas[namespace].defaults()
+	}
+	return res.Values
+}
+
+// Patch applies a JSON merge patch to the user's preferences; null resets a key to its default
+func (s *PreferencesService) Patch(userID int, namespace string, patch []byte) (*models.PreferencesResponse, error) {
+	schema, err := preferenceSchema(namespace)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := parseMergePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.PatchUser(userID, namespace, schema.Version, func(current *models.PreferenceSet) (json.RawMessage, error) {
+		return patchPreferenceSet(schema, current, changes)
+	}); err != nil {
+		return nil, err
+	}
+	return s.Get(userID, namespace)
+}
+
+// Defaults returns the defaults users of a namespace inherit
+func (s *PreferencesService) Defaults(namespace string) (*models.PreferencesResponse, error) {
+	schema, err := preferenceSchema(namespace)
+	if err != nil {
+		return nil, err
+	}
+	defaults, err := s.repo.GetDefaults(namespace)
+	if err != nil {
+		return nil, err
+	}
+	res := &models.PreferencesResponse{Namespace: namespace, SchemaVersion: schema.Version, Overrides: stored(schema, defaults)}
+	res.Values = mergePatch(schema.defaults(), res.Overrides)
+	if defaults != nil {
+		res.UpdatedAt = &defaults.UpdatedAt
+	}
+	return res, nil
+}
+
+// PatchDefaults applies a JSON merge patch to the admin defaults; null restores the built-in
+// default of a key
+func (s *PreferencesService) PatchDefaults(adminID int, namespace string, patch []byte) (*models.PreferencesResponse, error) {
+	schema, err := preferenceSchema(namespace)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := parseMergePatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.PatchDefaults(namespace, schema.Version, adminID, func(current *models.PreferenceSet) (json.RawMessage, error) {
+		return patchPreferenceSet(schema, current, changes)
+	}); err != nil {
+		return nil, err
+	}
+	return s.Defaults(namespace)
+}
+
+func preferenceSchema(namespace string) (*prefSchema, error) {
+	schema, ok := preferenceSchemas[namespace]
+	if !ok {
+		return nil, errors.New("preference namespace not found")
+	}
+	return schema, nil
+}
+
+func parseMergePatch(patch []byte) (map[string]interface{}, error) {
+	var changes map[string]interface{}
+	if err := json.Unmarshal(patch, &changes); err != nil || changes == nil {
+		return nil, errors.New("merge patch must be a JSON object")
+	}
+	return changes, nil
+}
+
+// stored decodes a stored set upgraded to the current schema version
+func stored(schema *prefSchema, set *models.PreferenceSet) map[string]interface{} {
+	if set == nil {
+		return map[string]interface{}{}
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(set.Data, &data); err != nil {
+		log.Printf("preferences %s: %v", set.Namespace, err)
+		return map[string]interface{}{}
+	}
+	return schema.sanitize(schema.upgrade(data, set.SchemaVersion))
+}
+
+func patchPreferenceSet(schema *prefSchema, current *models.PreferenceSet, changes map[string]interface{}) (json.RawMessage, error) {
+	data := mergePatch(stored(schema, current), changes)
+	if err := schema.validate(data); err != nil {
+		return nil, err
+	}
+	return json.Marshal(data)
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"organizer-back/models"
+	"organizer-back/repository"
+	"strings"
+	"time"
+)
+
+type ProjectsService struct {
+	repo *repository.ProjectRepository
+}
+
+func NewProjectsService() *ProjectsService {
+	return &ProjectsService{repo: repository.NewProjectRepository()}
+}
+
+// List returns the caller's projects with their progress; now is the current time in the
+// caller's zone and decides which todos are overdue
+func (s *ProjectsService) List(userID int, q *models.ProjectListQuery, now time.Time) ([]models.ProjectResponse, error) {
+	projects, err := s.repo.List(userID, q.Status, q.IncludeArchived)
+	if err != nil {
+		return nil, err
+	}
+	return s.withProgress(userID, projects, now)
+}
+
+func (s *ProjectsService) Get(userID, id int, now time.Time) (*models.ProjectResponse, error) {
+	p, err := s.repo.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.withProgress(userID, []models.Project{*p}, now)
+	if err != nil {
+		return nil, err
+	}
+	return &res[0], nil
+}
+
+func (s *ProjectsService) Create(userID int, req *models.ProjectCreateRequest, now time.Time) (*models.ProjectResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	deadline, err := parseDeadline(req.Deadline)
+	if err != nil {
+		return nil, err
+	}
+	p := &models.Project{
+		UserID:      userID,
+		Name:        name,
+		Description: req.Description,
+		Goal:        req.Goal,
+		Deadline:    deadline,
+		Status:      req.Status,
+		Color:       req.Color,
+	}
+	if p.Status == "" {
+		p.Status = "active"
+	}
+	if err := s.repo.Create(p); err != nil {
+		return nil, err
+	}
+	return s.Get(userID, p.ID, now)
+}
+
+func (s *ProjectsService) Update(userID, id int, req *models.ProjectUpdateRequest, now time.Time) (*models.ProjectResponse, error) {
+	p, err := s.repo.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, errors.New("name is required")
+		}
+		p.Name = name
+	}
+	if req.Description != nil {
+		p.Description = *req.Description
+	}
+	if req.Goal != nil {
+		p.Goal = *req.Goal
+	}
+	if req.Deadline != nil {
+		if p.Deadline, err = parseDeadline(*req.Deadline); err != nil {
+			return nil, err
+		}
+	}
+	if req.Status != nil {
+		p.Status = *req.Status
+	}
+	if req.Color != nil {
+		switch {
+		case *req.Color == "":
+			p.Color = nil
+		case len(*req.Color) != len("#rrggbb"):
+			return nil, errors.New("invalid color, expected #rrggbb")
+		default:
+			p.Color = req.Color
+		}
+	}
+	if err := s.repo.Update(p); err != nil {
+		return nil, err
+	}
+	return s.Get(userID, id, now)
+}
+
+// Delete removes a project; its todos and the notes linking to it are kept
+func (s *ProjectsService) Delete(userID, id int) error {
+	return s.repo.Delete(userID, id)
+}
+
+// SetArchived archives or restores a project
+func (s *ProjectsService) SetArchived(userID, id int, archived bool, now time.Time) (*models.ProjectResponse, error) {
+	if _, err := s.repo.SetArchived(userID, id, archived); err != nil {
+		return nil, err
+	}
+	return s.Get(userID, id, now)
+}
+
+// Timeline lists the notes that link to the project with [[project:ID]], latest first
+func (s *ProjectsService) Timeline(userID, id int, q *models.ProjectTimelineQuery) ([]models.NoteLinkTarget, error) {
+	if _, err := s.repo.GetByID(userID, id); err != nil {
+		return nil, err
+	}
+	from, to, err := ParseExportRange(&models.NoteExportQuery{From: q.From, To: q.To})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Timeline(userID, id, from, to)
+}
+
+func (s *ProjectsService) withProgress(userID int, projects []models.Project, now time.Time) ([]models.ProjectResponse, error) {
+	ids := make([]int, len(projects))
+	for i := range projects {
+		ids[i] = projects[i].ID
+	}
+	progress, err := s.repo.Progress(userID, ids, localDate(now, now.Location()))
+	if err != nil {
+		return nil, err
+	}
+	res := make([]models.ProjectResponse, len(projects))
+	for i := range projects {
+		pr := progress[projects[i].ID]
+		if active := pr.Total - pr.Cancelled; active > 0 {
+			pr.Progress = math.Round(float64(pr.Done)/float64(active)*1000) / 1000
+		}
+		res[i] = projects[i].ToResponse(pr)
+	}
+	return res, nil
+}
+
+// checkTodoProject verifies that a todo can be put in a project: a top-level todo and an
+// active project of the same user
+func checkTodoProject(projects *repository.ProjectRepository, t *models.Todo, projectID int) error {
+	if t.ParentID != nil {
+		return errors.New("subtasks belong to the project of their parent")
+	}
+	p, err := projects.GetByID(t.UserID, projectID)
+	if err != nil {
+		return err
+	}
+	if p.ArchivedAt != nil {
+		return errors.New("project is archived")
+	}
+	return nil
+}
+
+// parseDeadline parses an optional YYYY-MM-DD deadline; empty means none
+func parseDeadline(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, errors.New("invalid deadline, expected YYYY-MM-DD")
+	}
+	return &d, nil
+}
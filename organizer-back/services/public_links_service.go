@@ -0,0 +1,234 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"organizer-back/models"
+	"organizer-back/repository"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type PublicLinksService struct {
+	repo     *repository.PublicLinkRepository
+	notes    *repository.NoteRepository
+	audit    *repository.AuditRepository
+	attempts passwordAttempts
+}
+
+func NewPublicLinksService() *PublicLinksService {
+	return &PublicLinksService{
+		repo:  repository.NewPublicLinkRepository(),
+		notes: repository.NewNoteRepository(),
+		audit: repository.NewAuditRepository(),
+	}
+}
+
+// Create publishes a note the caller owns; the raw token is only returned here
+func (s *PublicLinksService) Create(userID, noteID int, req *models.PublicLinkCreateRequest) (*models.PublicLinkResponse, error) {
+	note, err := s.notes.GetByID(userID, noteID)
+	if err != nil {
+		return nil, err
+	}
+	if note.Encrypted {
+		return nil, errors.New("encrypted notes cannot be published")
+	}
+	if note.Hidden && !req.ConfirmHidden {
+		return nil, errors.New("note is hidden: set confirm_hidden to publish it")
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+
+	token, err := newPublicToken()
+	if err != nil {
+		return nil, err
+	}
+	l := &models.PublicLink{
+		NoteID:          noteID,
+		UserID:          userID,
+		TokenHash:       hashPublicToken(token),
+		HiddenConfirmed: req.ConfirmHidden,
+		ExpiresAt:       req.ExpiresAt,
+	}
+	if req.Password != "" {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		h := string(hashed)
+		l.PasswordHash = &h
+	}
+	if err := s.repo.Create(l); err != nil {
+		return nil, err
+	}
+	if err := s.audit.Record(userID, "note.public_link_create", "note", noteID, map[string]interface{}{"link_id": l.ID}); err != nil {
+		log.Printf("audit: %v", err)
+	}
+
+	res := l.ToResponse()
+	res.Token = token
+	res.URL = "/p/" + token
+	return &res, nil
+}
+
+func (s *PublicLinksService) List(userID, noteID int) ([]models.PublicLinkResponse, error) {
+	if _, err := s.notes.GetByID(userID, noteID); err != nil {
+		return nil, err
+	}
+	links, err := s.repo.ListByNote(userID, noteID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]models.PublicLinkResponse, 0, len(links))
+	for i := range links {
+		res = append(res, links[i].ToResponse())
+	}
+	return res, nil
+}
+
+func (s *PublicLinksService) Revoke(userID, noteID, linkID int) error {
+	if err := s.repo.Revoke(userID, noteID, linkID); err != nil {
+		return err
+	}
+	if err := s.audit.Record(userID, "note.public_link_revoke", "note", noteID, map[string]interface{}{"link_id": linkID}); err != nil {
+		log.Printf("audit: %v", err)
+	}
+	return nil
+}
+
+// Resolve returns the note behind a public token. It reports "password required" when the
+// link is protected and no password was given, and "invalid password" when it does not match.
+func (s *PublicLinksService) Resolve(token, password string) (*models.PublicNoteResponse, error) {
+	l, n, err := s.repo.GetActiveByTokenHash(hashPublicToken(token), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	// Encrypted content is never served publicly
+	if n.Encrypted {
+		return nil, errors.New("link not found")
+	}
+	// A note hidden after publishing stays private unless hiding was confirmed at publish time
+	if n.Hidden && !l.HiddenConfirmed {
+		return nil, errors.New("link not found")
+	}
+	if l.PasswordHash != nil {
+		if password == "" {
+			return nil, errors.New("password required")
+		}
+		if wait := s.attempts.begin(l.ID, time.Now()); wait > 0 {
+			return nil, &TooManyAttemptsError{RetryAfter: wait}
+		}
+		if bcrypt.CompareHashAndPassword([]byte(*l.PasswordHash), []byte(password)) != nil {
+			return nil, errors.New("invalid password")
+		}
+		s.attempts.reset(l.ID)
+	}
+	if err := s.repo.TouchAccessed(l.ID); err != nil {
+		log.Printf("public link: %v", err)
+	}
+	return &models.PublicNoteResponse{
+		NoteDate:  n.NoteDate.Format("2006-01-02"),
+		Content:   n.Content,
+		UpdatedAt: n.UpdatedAt,
+	}, nil
+}
+
+const (
+	// Password guesses allowed on a link before it is locked out
+	freePasswordAttempts = 5
+	// Lockouts double from a second up to this
+	maxPasswordLockout = 15 * time.Minute
+	// A link whose last guess is older than this starts over
+	passwordAttemptsTTL = time.Hour
+	// Above this many tracked links, forgotten ones are swept on the next new link
+	maxTrackedLinks = 10000
+)
+
+// TooManyAttemptsError is returned while a link is locked out after wrong passwords
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string { return "too many attempts" }
+
+// passwordAttempts counts password guesses per link and locks a link out for a doubling
+// delay once the free attempts are used. A guess is counted before the password is checked,
+// so concurrent guesses cannot slip past the count; a right password clears it. The counts
+// live in memory, so each instance keeps its own.
+type passwordAttempts struct {
+	mu    sync.Mutex
+	links map[int]*linkAttempts
+}
+
+type linkAttempts struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// begin counts a guess on a link, or returns how long to wait when the link is locked out
+func (a *passwordAttempts) begin(linkID int, now time.Time) time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st := a.links[linkID]
+	if st == nil || now.Sub(st.last) > passwordAttemptsTTL {
+		if a.links == nil {
+			a.links = map[int]*linkAttempts{}
+		} else if len(a.links) >= maxTrackedLinks {
+			a.sweep(now)
+		}
+		st = &linkAttempts{}
+		a.links[linkID] = st
+	}
+	if now.Before(st.lockedUntil) {
+		return st.lockedUntil.Sub(now)
+	}
+	st.count++
+	st.last = now
+	if over := st.count - freePasswordAttempts; over >= 0 {
+		lockout := maxPasswordLockout
+		if over < 10 {
+			lockout = min(time.Second<<over, maxPasswordLockout)
+		}
+		st.lockedUntil = now.Add(lockout)
+	}
+	return 0
+}
+
+// reset forgets the guesses on a link once the right password was given
+func (a *passwordAttempts) reset(linkID int) {
+	a.mu.Lock()
+	delete(a.links, linkID)
+	a.mu.Unlock()
+}
+
+func (a *passwordAttempts) sweep(now time.Time) {
+	for id, st := range a.links {
+		if now.Sub(st.last) > passwordAttemptsTTL && !now.Before(st.lockedUntil) {
+			delete(a.links, id)
+		}
+	}
+}
+
+// newPublicToken returns 256 bits of randomness, URL-safe encoded
+func newPublicToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating token: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashPublicToken is what we persist, so a leaked table does not leak working links
+func hashPublicToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPasswordAttempts(t *testing.T) {
+	var a passwordAttempts
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 1; i <= freePasswordAttempts; i++ {
+		if wait := a.begin(1, now); wait != 0 {
+			t.Fatalf("guess %d locked out for %s", i, wait)
+		}
+	}
+	// The last free guess locks the link for a second, the next one for two
+	if wait := a.begin(1, now); wait != time.Second {
+		t.Errorf("wait after the free guesses = %s, want 1s", wait)
+	}
+	if wait := a.begin(1, now.Add(time.Second)); wait != 0 {
+		t.Errorf("guess after the lockout waited %s", wait)
+	}
+	if wait := a.begin(1, now.Add(2*time.Second)); wait != time.Second {
+		t.Errorf("second lockout has %s left, want 1s of 2s", wait)
+	}
+	// Other links are counted apart
+	if wait := a.begin(2, now); wait != 0 {
+		t.Errorf("another link locked out for %s", wait)
+	}
+
+	// Lockouts stop growing at the maximum
+	for i := 0; i < 30; i++ {
+		now = now.Add(maxPasswordLockout)
+		a.begin(3, now)
+	}
+	if wait := a.begin(3, now); wait != maxPasswordLockout {
+		t.Errorf("lockout after many guesses = %s, want %s", wait, maxPasswordLockout)
+	}
+
+	// The right password and time both clear the count
+	a.reset(3)
+	if wait := a.begin(3, now); wait != 0 {
+		t.Errorf("locked out after reset for %s", wait)
+	}
+	later := now.Add(passwordAttemptsTTL + maxPasswordLockout + time.Second)
+	for i := 0; i < freePasswordAttempts; i++ {
+		a.begin(4, now)
+	}
+	if wait := a.begin(4, later); wait != 0 {
+		t.Errorf("locked out after an hour for %s", wait)
+	}
+}
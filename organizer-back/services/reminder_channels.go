@@ -0,0 +1,189 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/smtp"
+	"organizer-back/models"
+	"organizer-back/realtime"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ReminderTarget is who a reminder delivery goes to
+type ReminderTarget struct {
+	UserID     int
+	Email      string
+	WebhookURL string
+}
+
+// ReminderChannel delivers reminder notifications through one medium
+type ReminderChannel interface {
+	Deliver(to ReminderTarget, n *models.ReminderNotification) error
+}
+
+// reminderChannelsFromEnv returns the configured channels. SSE and webhooks are always
+// available; email needs SMTP_HOST.
+func reminderChannelsFromEnv(broker *realtime.Broker) map[string]ReminderChannel {
+	channels := map[string]ReminderChannel{
+		"sse": &sseChannel{broker: broker},
+		"webhook": &webhookChannel{
+			client: newWebhookClient(),
+			secret: os.Getenv("REMINDER_WEBHOOK_SECRET"),
+		},
+	}
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		ch := &emailChannel{addr: host + ":" + port, from: os.Getenv("SMTP_FROM")}
+		if user := os.Getenv("SMTP_USERNAME"); user != "" {
+			ch.auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+		}
+		channels["email"] = ch
+	}
+	return channels
+}
+
+// sseChannel pushes a "reminder" event to the user's open event streams
+type sseChannel struct {
+	broker *realtime.Broker
+}
+
+func (c *sseChannel) Deliver(to ReminderTarget, n *models.ReminderNotification) error {
+	return c.broker.Publish(to.UserID, "reminder", n)
+}
+
+// webhookChannel POSTs the notification as JSON. With a secret, the body is signed with
+// HMAC-SHA256 in X-Organizer-Signature.
+type webhookChannel struct {
+	client *http.Client
+	secret string
+}
+
+// newWebhookClient returns a client that only connects to public addresses. The check runs
+// on the address actually dialed, after DNS resolution, so a name that resolves (or later
+// rebinds) to an internal address is refused too. Redirects are not followed and proxies
+// from the environment are ignored, as both would bypass the check.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !publicAddr(addr.Addr()) {
+				return fmt.Errorf("webhook address %s is not public", addr.Addr())
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// blockedPrefixes are special-purpose ranges the netip predicates do not cover: "this
+// network", carrier-grade NAT, IETF protocol assignments, benchmarking, reserved space and
+// the NAT64 prefixes, which embed IPv4 addresses a gateway would reach for us
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// publicAddr reports whether a webhook may be delivered to ip: loopback, private,
+// link-local, multicast, unspecified and blockedPrefixes addresses are internal
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *webhookChannel) Deliver(to ReminderTarget, n *models.ReminderNotification) error {
+	if to.WebhookURL == "" {
+		return errors.New("no webhook url")
+	}
+	body, err := json.Marshal(struct {
+		Event string `json:"event"`
+		*models.ReminderNotification
+	}{"reminder", n})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, to.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Organizer-Event", "reminder")
+	if c.secret != "" {
+		mac := hmac.New(sha256.New, []byte(c.secret))
+		mac.Write(body)
+		req.Header.Set("X-Organizer-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// emailChannel sends a plain text email through SMTP
+type emailChannel struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func (c *emailChannel) Deliver(to ReminderTarget, n *models.ReminderNotification) error {
+	if to.Email == "" {
+		return errors.New("user has no email")
+	}
+	// Header values must not carry line breaks
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(n.Title)
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n", c.from, to.Email, subject)
+	if n.Message != "" {
+		msg.WriteString(n.Message + "\r\n\r\n")
+	}
+	msg.WriteString(n.FireAt.Format(time.RFC1123Z) + "\r\n")
+	return smtp.SendMail(c.addr, c.auth, c.from, []string{to.Email}, msg.Bytes())
+}
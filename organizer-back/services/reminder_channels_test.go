@@ -0,0 +1,84 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"organizer-back/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1::1", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"0.1.2.3", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"100.128.0.1", true},
+		{"192.0.0.8", false},
+		{"198.18.0.1", false},
+		{"198.19.255.255", false},
+		{"198.20.0.1", true},
+		{"255.255.255.255", false},
+		{"64:ff9b::a9fe:a9fe", false},
+		{"64:ff9b::808:808", false},
+		{"64:ff9b:1::1", false},
+		{"::ffff:100.64.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := publicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("publicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookRefusesInternalAddresses(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	ch := &webhookChannel{client: newWebhookClient()}
+	n := &models.ReminderNotification{Title: "t", FireAt: time.Now()}
+	// httptest listens on loopback; "localhost" only resolves to it when dialing
+	for _, u := range []string{srv.URL, strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)} {
+		err := ch.Deliver(ReminderTarget{WebhookURL: u}, n)
+		if err == nil || !strings.Contains(err.Error(), "not public") {
+			t.Errorf("Deliver(%s) error = %v, want a not public address error", u, err)
+		}
+	}
+	if called {
+		t.Error("webhook reached a loopback server")
+	}
+}
+
+func TestValidChannelsRejectsInternalWebhooks(t *testing.T) {
+	s := &RemindersService{channels: map[string]ReminderChannel{"webhook": &webhookChannel{}}}
+	for _, u := range []string{"http://127.0.0.1/hook", "http://[::1]:8080/", "http://localhost/x", "https://app.localhost/", "http://169.254.169.254/latest", "ftp://example.com/"} {
+		if _, err := s.validChannels([]string{"webhook"}, u); err == nil {
+			t.Errorf("validChannels accepted %s", u)
+		}
+	}
+	if _, err := s.validChannels([]string{"webhook"}, "https://hooks.example.com/r"); err != nil {
+		t.Errorf("validChannels rejected a public url: %v", err)
+	}
+}
@@ -0,0 +1,299 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"net/url"
+	"organizer-back/models"
+	"organizer-back/realtime"
+	"organizer-back/recurrence"
+	"organizer-back/repository"
+	"strings"
+	"time"
+)
+
+const (
+	defaultReminderPollSeconds = 15
+	reminderFireBatch          = 100
+	reminderDeliveryBatch      = 20
+	// A claimed delivery not finished within this is retried by any instance
+	reminderDeliveryLease = 2 * time.Minute
+	reminderMaxAttempts   = 5
+	reminderDeliveryLimit = 100
+)
+
+type RemindersService struct {
+	repo         *repository.ReminderRepository
+	notes        *repository.NoteRepository
+	todos        *repository.TodoRepository
+	channels     map[string]ReminderChannel
+	pollInterval time.Duration
+}
+
+func NewRemindersService(broker *realtime.Broker) *RemindersService {
+	return &RemindersService{
+		repo:         repository.NewReminderRepository(),
+		notes:        repository.NewNoteRepository(),
+		todos:        repository.NewTodoRepository(),
+		channels:     reminderChannelsFromEnv(broker),
+		pollInterval: time.Duration(envInt64("REMINDER_POLL_SECONDS", defaultReminderPollSeconds)) * time.Second,
+	}
+}
+
+func (s *RemindersService) List(userID int) ([]models.Reminder, error) {
+	return s.repo.List(userID)
+}
+
+func (s *RemindersService) Get(userID, id int) (*models.Reminder, error) {
+	return s.repo.GetByID(userID, id)
+}
+
+// Create schedules a reminder; defaultTZ is the caller's zone, used when the request has none
+func (s *RemindersService) Create(userID int, req *models.ReminderRequest, defaultTZ string) (*models.Reminder, error) {
+	rem := &models.Reminder{UserID: userID, Active: true}
+	if err := s.apply(rem, req, defaultTZ); err != nil {
+		return nil, err
+	}
+	if rem.NextFireAt == nil {
+		return nil, errors.New("reminder has no future occurrence")
+	}
+	if err := s.repo.Create(rem); err != nil {
+		return nil, err
+	}
+	return rem, nil
+}
+
+// Update replaces a reminder. Its next occurrence is recomputed from now, so pausing and
+// resuming a reminder never fires the occurrences missed meanwhile.
+func (s *RemindersService) Update(userID, id int, req *models.ReminderRequest, defaultTZ string) (*models.Reminder, error) {
+	rem, err := s.repo.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apply(rem, req, defaultTZ); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(rem); err != nil {
+		return nil, err
+	}
+	return rem, nil
+}
+
+func (s *RemindersService) Delete(userID, id int) error {
+	return s.repo.Delete(userID, id)
+}
+
+// Deliveries lists the latest deliveries of one of the caller's reminders
+func (s *RemindersService) Deliveries(userID, id int) ([]models.ReminderDelivery, error) {
+	if _, err := s.repo.GetByID(userID, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDeliveries(id, reminderDeliveryLimit)
+}
+
+// apply validates a request and copies it onto rem, computing its next occurrence
+func (s *RemindersService) apply(rem *models.Reminder, req *models.ReminderRequest, defaultTZ string) error {
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		return errors.New("title is required")
+	}
+	tz := req.Timezone
+	if tz == "" {
+		tz = defaultTZ
+	}
+	loc, err := loadTimezone(tz)
+	if err != nil {
+		return err
+	}
+	startsAt, err := parseLocalTime(req.StartsAt, loc)
+	if err != nil {
+		return err
+	}
+	var rrule *string
+	if req.RRule != "" {
+		rule, err := recurrence.Parse(req.RRule)
+		if err != nil {
+			return err
+		}
+		normalized := rule.String()
+		rrule = &normalized
+	}
+	channels, err := s.validChannels(req.Channels, req.WebhookURL)
+	if err != nil {
+		return err
+	}
+	if req.NoteID != nil {
+		if _, err := s.notes.GetByID(rem.UserID, *req.NoteID); err != nil {
+			return err
+		}
+	}
+	if req.TodoID != nil {
+		if _, err := s.todos.GetByID(rem.UserID, *req.TodoID); err != nil {
+			return err
+		}
+	}
+
+	rem.Title = title
+	rem.Message = req.Message
+	rem.NoteID, rem.TodoID = req.NoteID, req.TodoID
+	rem.StartsAt, rem.Timezone, rem.RRule = startsAt, tz, rrule
+	rem.Channels = channels
+	rem.WebhookURL = nil
+	if req.WebhookURL != "" {
+		rem.WebhookURL = &req.WebhookURL
+	}
+	if req.Active != nil {
+		rem.Active = *req.Active
+	}
+	rem.NextFireAt = nextOccurrence(rem, time.Now())
+	return nil
+}
+
+func (s *RemindersService) validChannels(requested []string, webhookURL string) ([]string, error) {
+	if len(requested) == 0 {
+		requested = []string{"sse"}
+	}
+	channels := []string{}
+	seen := map[string]bool{}
+	for _, ch := range requested {
+		if seen[ch] {
+			continue
+		}
+		seen[ch] = true
+		if s.channels[ch] == nil {
+			return nil, fmt.Errorf("%s channel is not configured", ch)
+		}
+		channels = append(channels, ch)
+	}
+	if seen["webhook"] {
+		u, err := url.Parse(webhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+			return nil, errors.New("webhook channel needs an http(s) webhook_url")
+		}
+		// Names are checked when dialing; reject what is internal on its face right away
+		host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+		if ip, err := netip.ParseAddr(host); (err == nil && !publicAddr(ip)) || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+			return nil, errors.New("webhook_url must point to a public address")
+		}
+	}
+	return channels, nil
+}
+
+// nextOccurrence returns the first occurrence of rem after now, or nil when there is none
+func nextOccurrence(rem *models.Reminder, now time.Time) *time.Time {
+	if rem.RRule == nil {
+		if rem.StartsAt.After(now) {
+			t := rem.StartsAt
+			return &t
+		}
+		return nil
+	}
+	rule, err := recurrence.Parse(*rem.RRule)
+	if err != nil {
+		log.Printf("reminder %d: %v", rem.ID, err)
+		return nil
+	}
+	loc, err := loadTimezone(rem.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	start := rem.StartsAt.In(loc)
+	after := now
+	if start.After(now) {
+		// The start itself is the first occurrence when it matches the rule
+		after = start.Add(-time.Nanosecond)
+	}
+	if t, ok := rule.Next(start, after); ok {
+		return &t
+	}
+	return nil
+}
+
+// parseLocalTime reads RFC 3339, or a wall-clock time without offset in loc
+func parseLocalTime(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.In(loc), nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid starts_at, expected RFC 3339 or YYYY-MM-DDTHH:MM")
+}
+
+// StartScheduler polls for due reminders and pending deliveries in the background. Every
+// instance may run it: rows are claimed with FOR UPDATE SKIP LOCKED.
+func (s *RemindersService) StartScheduler() {
+	go func() {
+		t := time.NewTicker(s.pollInterval)
+		defer t.Stop()
+		for {
+			s.fireDue()
+			s.deliverPending()
+			<-t.C
+		}
+	}()
+}
+
+// fireDue turns due occurrences into deliveries. Occurrences missed while no instance was
+// running are not replayed: a reminder fires once and moves on to its next future occurrence.
+func (s *RemindersService) fireDue() {
+	for {
+		n, err := s.repo.FireDue(reminderFireBatch, func(rem *models.Reminder) *time.Time {
+			return nextOccurrence(rem, time.Now())
+		})
+		if err != nil {
+			log.Printf("reminders: %v", err)
+			return
+		}
+		if n < reminderFireBatch {
+			return
+		}
+	}
+}
+
+func (s *RemindersService) deliverPending() {
+	for {
+		claimed, err := s.repo.ClaimDeliveries(reminderDeliveryBatch, reminderDeliveryLease)
+		if err != nil {
+			log.Printf("reminders: %v", err)
+			return
+		}
+		for i := range claimed {
+			s.deliver(&claimed[i])
+		}
+		if len(claimed) < reminderDeliveryBatch {
+			return
+		}
+	}
+}
+
+func (s *RemindersService) deliver(d *repository.ClaimedDelivery) {
+	to := ReminderTarget{UserID: d.UserID, Email: d.Email}
+	if d.WebhookURL != nil {
+		to.WebhookURL = *d.WebhookURL
+	}
+	var err error
+	if ch := s.channels[d.Channel]; ch == nil {
+		err = fmt.Errorf("%s channel is not configured", d.Channel)
+	} else {
+		err = ch.Deliver(to, &d.Notification)
+	}
+	if err == nil {
+		err = s.repo.MarkDelivered(d.ID)
+	} else {
+		var retryAt *time.Time
+		if d.Attempts < reminderMaxAttempts {
+			t := time.Now().Add(time.Duration(d.Attempts*d.Attempts) * time.Minute)
+			retryAt = &t
+		}
+		log.Printf("reminder delivery %d via %s (attempt %d): %v", d.ID, d.Channel, d.Attempts, err)
+		err = s.repo.MarkFailed(d.ID, err.Error(), retryAt)
+	}
+	if err != nil {
+		log.Printf("reminders: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"log"
+	"organizer-back/models"
+	"organizer-back/repository"
+	"os"
+	"time"
+)
+
+const (
+	statsWeeks       = 12
+	statsMonths      = 12
+	statsTopTags     = 10
+	statsHeatmapDays = 365
+	// How often the note_daily_stats rollup is refreshed when enabled
+	defaultStatsRefreshSeconds = 300
+)
+
+type StatsService struct {
+	notes         *repository.NoteStatsRepository
+	rollup        bool
+	refreshPeriod time.Duration
+}
+
+// NewStatsService computes statistics straight from notes unless NOTE_STATS_ROLLUP=true, in
+// which case day totals come from the materialized rollup refreshed by RefreshRollupPeriodically
+func NewStatsService() *StatsService {
+	rollup := os.Getenv("NOTE_STATS_ROLLUP") == "true"
+	return &StatsService{
+		notes:         repository.NewNoteStatsRepository(rollup),
+		rollup:        rollup,
+		refreshPeriod: time.Duration(envInt64("NOTE_STATS_REFRESH_SECONDS", defaultStatsRefreshSeconds)) * time.Second,
+	}
+}
+
+// RefreshRollupPeriodically keeps the rollup fresh in the background; it does nothing when
+// the rollup is disabled
+func (s *StatsService) RefreshRollupPeriodically() {
+	if !s.rollup {
+		return
+	}
+	go func() {
+		t := time.NewTicker(s.refreshPeriod)
+		defer t.Stop()
+		for {
+			if err := s.notes.RefreshRollup(); err != nil {
+				log.Printf("stats: %v", err)
+			}
+			<-t.C
+		}
+	}()
+}
+
+// Notes returns the user's journaling statistics as of today
+func (s *StatsService) Notes(userID int, today time.Time) (*models.NoteStats, error) {
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	stats := &models.NoteStats{}
+	var err error
+	if stats.CurrentStreak, stats.LongestStreak, err = s.notes.Streaks(userID, today); err != nil {
+		return nil, err
+	}
+	if stats.TotalNotes, stats.TotalWords, err = s.notes.Totals(userID); err != nil {
+		return nil, err
+	}
+	if stats.Weekly, err = s.notes.Periods(userID, "week", today.AddDate(0, 0, -7*(statsWeeks-1)), today); err != nil {
+		return nil, err
+	}
+	if stats.Monthly, err = s.notes.Periods(userID, "month", firstOfMonth(today).AddDate(0, -(statsMonths-1), 0), today); err != nil {
+		return nil, err
+	}
+	if stats.TopTags, err = s.notes.TopTags(userID, statsTopTags); err != nil {
+		return nil, err
+	}
+	if stats.Heatmap, err = s.notes.Heatmap(userID, today.AddDate(0, 0, -(statsHeatmapDays-1)), today); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func firstOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// mondayOf returns the start of the ISO week of t, the way date_trunc('week', ...) does
+func mondayOf(t time.Time) time.Time {
+	return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+}
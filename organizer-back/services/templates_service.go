@@ -0,0 +1,191 @@
+package services
+
+import (
+	"errors"
+	"organizer-back/models"
+	"organizer-back/repository"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// uncheckedItem matches Markdown task list items that are still open: "- [ ] call Ana"
+var uncheckedItem = regexp.MustCompile(`^\s*[-*+] \[ \] \S`)
+
+type TemplatesService struct {
+	repo  *repository.NoteTemplateRepository
+	notes *repository.NoteRepository
+	users *repository.UserRepository
+}
+
+func NewTemplatesService() *TemplatesService {
+	return &TemplatesService{
+		repo:  repository.NewNoteTemplateRepository(),
+		notes: repository.NewNoteRepository(),
+		users: repository.NewUserRepository(),
+	}
+}
+
+func (s *TemplatesService) List(userID int) ([]models.NoteTemplate, error) {
+	return s.repo.List(userID)
+}
+
+func (s *TemplatesService) Get(userID, id int) (*models.NoteTemplate, error) {
+	return s.repo.GetByID(userID, id)
+}
+
+func (s *TemplatesService) Create(userID int, req *models.NoteTemplateRequest) (*models.NoteTemplate, error) {
+	t := &models.NoteTemplate{UserID: userID, Name: strings.TrimSpace(req.Name), Content: req.Content, Tags: normalizeTags(req.Tags)}
+	if t.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if err := s.repo.Create(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *TemplatesService) Update(userID, id int, req *models.NoteTemplateRequest) (*models.NoteTemplate, error) {
+	t := &models.NoteTemplate{ID: id, UserID: userID, Name: strings.TrimSpace(req.Name), Content: req.Content, Tags: normalizeTags(req.Tags)}
+	if t.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if err := s.repo.Update(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *TemplatesService) Delete(userID, id int) error {
+	return s.repo.Delete(userID, id)
+}
+
+// CreateNote renders a template for the given YYYY-MM-DD date and saves it as a new note
+func (s *TemplatesService) CreateNote(userID int, req *models.NoteFromTemplateRequest) (*models.NoteResponse, error) {
+	d, err := time.Parse("2006-01-02", req.NoteDate)
+	if err != nil {
+		return nil, errors.New("invalid note_date")
+	}
+	t, err := s.repo.GetByID(userID, req.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.render(t, userID, d)
+	if err != nil {
+		return nil, err
+	}
+	n := &models.Note{UserID: userID, NoteDate: d, Content: content, Tags: t.Tags}
+	if err := s.notes.Create(n); err != nil {
+		return nil, err
+	}
+	r := n.ToResponse()
+	return &r, nil
+}
+
+func (s *TemplatesService) DailySetting(userID int) (*models.DailyTemplateSetting, error) {
+	id, since, err := s.repo.GetDailySetting(userID)
+	if err != nil {
+		return nil, err
+	}
+	setting := &models.DailyTemplateSetting{TemplateID: id}
+	if since != nil {
+		d := since.Format("2006-01-02")
+		setting.Since = &d
+	}
+	return setting, nil
+}
+
+// SetDailySetting enables (or disables, with a nil template) the daily note. It only applies
+// from today on, so browsing older days does not fill them with template notes.
+func (s *TemplatesService) SetDailySetting(userID int, templateID *int) (*models.DailyTemplateSetting, error) {
+	if templateID != nil {
+		if _, err := s.repo.GetByID(userID, *templateID); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repo.SetDailySetting(userID, templateID, userToday(s.users, userID)); err != nil {
+		return nil, err
+	}
+	return s.DailySetting(userID)
+}
+
+// EnsureDailyNote materializes the daily template the first time a day without notes is
+// viewed. Future days are skipped: their carry-over would be stale by the time they arrive.
+func (s *TemplatesService) EnsureDailyNote(userID int, date time.Time) error {
+	templateID, since, err := s.repo.GetDailySetting(userID)
+	if err != nil || templateID == nil {
+		return err
+	}
+	if (since != nil && date.Before(*since)) || date.After(userToday(s.users, userID)) {
+		return nil
+	}
+	_, err = s.repo.MaterializeDaily(userID, date, func() (*models.Note, error) {
+		t, err := s.repo.GetByID(userID, *templateID)
+		if err != nil {
+			return nil, err
+		}
+		content, err := s.render(t, userID, date)
+		if err != nil {
+			return nil, err
+		}
+		return &models.Note{UserID: userID, NoteDate: date, Content: content, Tags: t.Tags}, nil
+	})
+	return err
+}
+
+// render replaces the placeholders of a template; unknown placeholders are left as they are
+func (s *TemplatesService) render(t *models.NoteTemplate, userID int, date time.Time) (string, error) {
+	vars := map[string]string{
+		"date":    date.Format("2006-01-02"),
+		"weekday": date.Weekday().String(),
+	}
+	// Placeholders are case-insensitive; only look up what the template actually uses
+	used := map[string]bool{}
+	for _, m := range templatePlaceholder.FindAllStringSubmatch(t.Content, -1) {
+		used[strings.ToLower(m[1])] = true
+	}
+	if used["username"] {
+		u, err := s.users.GetUserByID(userID)
+		if err != nil {
+			return "", err
+		}
+		vars["username"] = u.Username
+	}
+	if used["carryover"] {
+		carry, err := s.carryover(userID, date)
+		if err != nil {
+			return "", err
+		}
+		vars["carryover"] = carry
+	}
+	return templatePlaceholder.ReplaceAllStringFunc(t.Content, func(m string) string {
+		name := templatePlaceholder.FindStringSubmatch(m)[1]
+		if v, ok := vars[strings.ToLower(name)]; ok {
+			return v
+		}
+		return m
+	}), nil
+}
+
+// carryover collects the unchecked task items of the previous day with notes
+func (s *TemplatesService) carryover(userID int, date time.Time) (string, error) {
+	notes, err := s.notes.ListPreviousDay(userID, date)
+	if err != nil {
+		return "", err
+	}
+	var items []string
+	seen := map[string]bool{}
+	for _, n := range notes {
+		for _, line := range strings.Split(n.Content, "\n") {
+			line = strings.TrimRight(line, " \r\t")
+			if !uncheckedItem.MatchString(line) || seen[strings.TrimSpace(line)] {
+				continue
+			}
+			seen[strings.TrimSpace(line)] = true
+			items = append(items, line)
+		}
+	}
+	return strings.Join(items, "\n"), nil
+}
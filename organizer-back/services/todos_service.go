@@ -0,0 +1,211 @@
+package services
+
+import (
+	"errors"
+	"organizer-back/models"
+	"organizer-back/repository"
+	"strings"
+	"time"
+)
+
+type TodosService struct {
+	repo     *repository.TodoRepository
+	projects *repository.ProjectRepository
+}
+
+func NewTodosService() *TodosService {
+	return &TodosService{
+		repo:     repository.NewTodoRepository(),
+		projects: repository.NewProjectRepository(),
+	}
+}
+
+// List returns the caller's top-level todos matching the query, each with all its subtasks.
+// now is the current time in the caller's zone; it defines "today" and the completion range.
+func (s *TodosService) List(userID int, q *models.TodoListQuery, now time.Time) ([]models.TodoResponse, error) {
+	f := repository.TodoFilter{
+		View:      q.View,
+		Priority:  q.Priority,
+		ProjectID: q.ProjectID,
+		Today:     time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC),
+	}
+	if q.From != "" {
+		d, err := time.ParseInLocation("2006-01-02", q.From, now.Location())
+		if err != nil {
+			return nil, errors.New("invalid from date")
+		}
+		f.CompletedFrom = &d
+	}
+	if q.To != "" {
+		d, err := time.ParseInLocation("2006-01-02", q.To, now.Location())
+		if err != nil {
+			return nil, errors.New("invalid to date")
+		}
+		end := d.AddDate(0, 0, 1)
+		f.CompletedTo = &end
+	}
+	todos, err := s.repo.List(userID, f)
+	if err != nil {
+		return nil, err
+	}
+	return withSubtasks(s.repo, userID, todos)
+}
+
+// Get returns one of the caller's todos with its subtasks
+func (s *TodosService) Get(userID, id int) (*models.TodoResponse, error) {
+	t, err := s.repo.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	res, err := withSubtasks(s.repo, userID, []models.Todo{*t})
+	if err != nil {
+		return nil, err
+	}
+	return &res[0], nil
+}
+
+func (s *TodosService) Create(userID int, req *models.TodoCreateRequest) (*models.TodoResponse, error) {
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		return nil, errors.New("title is required")
+	}
+	due, err := parseDueDate(req.DueDate)
+	if err != nil {
+		return nil, err
+	}
+	uid, err := newICalUID()
+	if err != nil {
+		return nil, err
+	}
+	t := &models.Todo{
+		UserID:      userID,
+		UID:         uid,
+		ParentID:    req.ParentID,
+		Title:       title,
+		Description: req.Description,
+		DueDate:     due,
+		Priority:    req.Priority,
+		Status:      req.Status,
+	}
+	if t.Priority == "" {
+		t.Priority = "normal"
+	}
+	if t.Status == "" {
+		t.Status = "open"
+	}
+	if req.ProjectID != nil {
+		if err := checkTodoProject(s.projects, t, *req.ProjectID); err != nil {
+			return nil, err
+		}
+		t.ProjectID = req.ProjectID
+	}
+	if err := s.repo.Create(t); err != nil {
+		return nil, err
+	}
+	r := t.ToResponse()
+	return &r, nil
+}
+
+func (s *TodosService) Update(userID, id int, req *models.TodoUpdateRequest) (*models.TodoResponse, error) {
+	t, err := s.repo.GetByID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			return nil, errors.New("title is required")
+		}
+		t.Title = title
+	}
+	if req.Description != nil {
+		t.Description = *req.Description
+	}
+	if req.DueDate != nil {
+		if t.DueDate, err = parseDueDate(*req.DueDate); err != nil {
+			return nil, err
+		}
+	}
+	if req.Priority != nil {
+		t.Priority = *req.Priority
+	}
+	if req.Status != nil {
+		t.Status = *req.Status
+	}
+	if req.ProjectID != nil {
+		if *req.ProjectID == 0 {
+			t.ProjectID = nil
+		} else if t.ProjectID == nil || *t.ProjectID != *req.ProjectID {
+			if err := checkTodoProject(s.projects, t, *req.ProjectID); err != nil {
+				return nil, err
+			}
+			t.ProjectID = req.ProjectID
+		}
+	}
+	if err := s.repo.Update(t); err != nil {
+		return nil, err
+	}
+	return s.Get(userID, id)
+}
+
+// Delete removes a todo and its subtasks
+func (s *TodosService) Delete(userID, id int) error {
+	return s.repo.Delete(userID, id)
+}
+
+// Move reorders a todo relative to one of its siblings
+func (s *TodosService) Move(userID, id int, req *models.TodoMoveRequest) (*models.TodoResponse, error) {
+	switch {
+	case req.Before != nil && req.After != nil:
+		return nil, errors.New("only one of before or after may be set")
+	case req.Before != nil:
+		if _, err := s.repo.Move(userID, id, *req.Before, false); err != nil {
+			return nil, err
+		}
+	case req.After != nil:
+		if _, err := s.repo.Move(userID, id, *req.After, true); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("before or after is required")
+	}
+	return s.Get(userID, id)
+}
+
+// withSubtasks converts todos to responses and nests their subtasks
+func withSubtasks(repo *repository.TodoRepository, userID int, todos []models.Todo) ([]models.TodoResponse, error) {
+	ids := make([]int, 0, len(todos))
+	for _, t := range todos {
+		if t.ParentID == nil {
+			ids = append(ids, t.ID)
+		}
+	}
+	subtasks, err := repo.ListSubtasks(userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	byParent := map[int][]models.TodoResponse{}
+	for i := range subtasks {
+		st := &subtasks[i]
+		byParent[*st.ParentID] = append(byParent[*st.ParentID], st.ToResponse())
+	}
+	res := make([]models.TodoResponse, 0, len(todos))
+	for i := range todos {
+		r := todos[i].ToResponse()
+		r.Subtasks = byParent[todos[i].ID]
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// parseDueDate parses an optional YYYY-MM-DD due date; empty means none
+func parseDueDate(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, errors.New("invalid due date, expected YYYY-MM-DD")
+	}
+	return &d, nil
+}
@@ -2,8 +2,10 @@ package services
 
 import (
 	"errors"
+	"log"
 	"organizer-back/models"
 	"organizer-back/repository"
+	"time"
 )
 
 type UsersService struct {
@@ -59,6 +61,7 @@ func (s *UsersService) CreateUser(req *models.UserCreateRequest) (*models.UserRe
 		Username:     req.Username,
 		PasswordHash: hashed,
 		Role:         defaultRole(req.Role),
+		Timezone:     req.Timezone,
 	}
 	if err := s.userRepo.CreateUser(u); err != nil {
 		return nil, err
@@ -81,6 +84,9 @@ func (s *UsersService) UpdateUser(id int, req *models.UserUpdateRequest) (*model
 	if req.Role != "" {
 		current.Role = defaultRole(req.Role)
 	}
+	if req.Timezone != "" {
+		current.Timezone = req.Timezone
+	}
 
 	if req.Password != "" {
 		auth := NewAuthService()
@@ -112,3 +118,61 @@ func (s *UsersService) DeleteUser(id int) error {
 func (s *UsersService) CountUsers() (int, error) {
 	return s.userRepo.CountUsers()
 }
+
+// SetTimezone changes the zone used to work out the user's "today"
+func (s *UsersService) SetTimezone(id int, tz string) (*models.UserResponse, error) {
+	if _, err := loadTimezone(tz); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.SetTimezone(id, tz); err != nil {
+		return nil, err
+	}
+	return s.GetUser(id)
+}
+
+// Location resolves the zone of a request: override (the X-Timezone header) when set,
+// otherwise the user's preference
+func (s *UsersService) Location(userID int, override string) (*time.Location, error) {
+	if override != "" {
+		return loadTimezone(override)
+	}
+	return userLocation(s.userRepo, userID), nil
+}
+
+// TimezoneStore reads the preferred zone of users; *repository.UserRepository implements it
+type TimezoneStore interface {
+	GetTimezone(userID int) (string, error)
+}
+
+// userLocation returns the user's preferred zone, falling back to UTC
+func userLocation(users TimezoneStore, userID int) *time.Location {
+	tz, err := users.GetTimezone(userID)
+	if err != nil {
+		log.Printf("timezone of user %d: %v", userID, err)
+		return time.UTC
+	}
+	loc, err := loadTimezone(tz)
+	if err != nil {
+		log.Printf("timezone of user %d: %v", userID, err)
+		return time.UTC
+	}
+	return loc
+}
+
+// userToday is the user's current date as a UTC midnight, the way note dates are handled
+func userToday(users *repository.UserRepository, userID int) time.Time {
+	now := time.Now().In(userLocation(users, userID))
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// loadTimezone accepts IANA names only; "Local" would depend on the server's configuration
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, errors.New("invalid timezone")
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.New("invalid timezone")
+	}
+	return loc, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"organizer-back/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerStatsRoutes wires the journaling statistics used by the dashboard
+func registerStatsRoutes(api *gin.RouterGroup, statsService *services.StatsService, usersService *services.UsersService) {
+	api.GET("/stats/notes", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		now, ok := requestNow(c, usersService, userID)
+		if !ok {
+			return
+		}
+		stats, err := statsService.Notes(userID, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, stats)
+	})
+}
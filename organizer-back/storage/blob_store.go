@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// ErrNotFound is returned when a blob does not exist in the store
+var ErrNotFound = errors.New("blob not found")
+
+// Blob is an opened stored object. It is seekable so it can be served with Range support.
+type Blob interface {
+	io.ReadSeeker
+	io.Closer
+	Size() int64
+}
+
+// BlobStore abstracts where uploaded files are kept
+type BlobStore interface {
+	Put(key string, r io.Reader, size int64, contentType string) error
+	Open(key string) (Blob, error)
+	Delete(key string) error
+}
+
+// NewBlobStoreFromEnv builds the store selected by BLOB_STORE (local or s3)
+func NewBlobStoreFromEnv() (BlobStore, error) {
+	switch kind := getEnv("BLOB_STORE", "local"); kind {
+	case "local":
+		return NewLocalStore(getEnv("BLOB_LOCAL_DIR", "data/blobs"))
+	case "s3":
+		usePathStyle, err := strconv.ParseBool(getEnv("S3_USE_PATH_STYLE", "true"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid S3_USE_PATH_STYLE: %v", err)
+		}
+		return NewS3Store(S3Config{
+			Endpoint:     getEnv("S3_ENDPOINT", "http://localhost:9000"),
+			Region:       getEnv("S3_REGION", "us-east-1"),
+			Bucket:       getEnv("S3_BUCKET", "organizer"),
+			AccessKey:    os.Getenv("S3_ACCESS_KEY"),
+			SecretKey:    os.Getenv("S3_SECRET_KEY"),
+			UsePathStyle: usePathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown BLOB_STORE %q", kind)
+	}
+}
+
+// getEnv gets an environment variable with a default value
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
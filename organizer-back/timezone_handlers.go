@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"organizer-back/models"
+	"organizer-back/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerTimezoneRoutes wires the caller's timezone preference
+func registerTimezoneRoutes(api *gin.RouterGroup, usersService *services.UsersService) {
+	api.PUT("/users/me/timezone", func(c *gin.Context) {
+		userID := extractUserIDFromAuthHeader(c.GetHeader("Authorization"))
+		if userID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		var req models.UserTimezoneRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timezone"})
+			return
+		}
+		user, err := usersService.SetTimezone(userID, req.Timezone)
+		if err != nil {
+			status := http.StatusBadRequest
+			if err.Error() == "user not found" {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, user)
+	})
+}
+
+// requestNow is the current time in the caller's zone: the X-Timezone header when present,
+// otherwise their saved preference. It answers 400 and returns false for an unknown zone.
+func requestNow(c *gin.Context, usersService *services.UsersService, userID int) (time.Time, bool) {
+	loc, err := usersService.Location(userID, c.GetHeader("X-Timezone"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return time.Time{}, false
+	}
+	return timeNow().In(loc), true
+}